@@ -2,12 +2,15 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"go.signoz.io/signoz/ee/query-service/dao"
 	"go.signoz.io/signoz/ee/query-service/interfaces"
 	"go.signoz.io/signoz/ee/query-service/license"
 	baseapp "go.signoz.io/signoz/pkg/query-service/app"
+	"go.signoz.io/signoz/pkg/query-service/app/logparsingpipeline"
+	"go.signoz.io/signoz/pkg/query-service/cache"
 	baseint "go.signoz.io/signoz/pkg/query-service/interfaces"
 	basemodel "go.signoz.io/signoz/pkg/query-service/model"
 	rules "go.signoz.io/signoz/pkg/query-service/rules"
@@ -21,6 +24,10 @@ type APIHandlerOptions struct {
 	RulesManager   *rules.Manager
 	FeatureFlags   baseint.FeatureLookup
 	LicenseManager *license.Manager
+	Cache          cache.Cache
+	FluxInterval   time.Duration
+
+	LogsParsingPipelineController *logparsingpipeline.LogParsingPipelineController
 }
 
 type APIHandler struct {
@@ -36,7 +43,11 @@ func NewAPIHandler(opts APIHandlerOptions) (*APIHandler, error) {
 		SkipConfig:   opts.SkipConfig,
 		AppDao:       opts.AppDao,
 		RuleManager:  opts.RulesManager,
-		FeatureFlags: opts.FeatureFlags})
+		FeatureFlags: opts.FeatureFlags,
+		Cache:        opts.Cache,
+		FluxInterval: opts.FluxInterval,
+
+		LogsParsingPipelineController: opts.LogsParsingPipelineController})
 
 	if err != nil {
 		return nil, err
@@ -12,6 +12,7 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/app/parser"
 	"go.signoz.io/signoz/pkg/query-service/constants"
 	basemodel "go.signoz.io/signoz/pkg/query-service/model"
+	queryguard "go.signoz.io/signoz/pkg/query-service/utils/queryGuard"
 	querytemplate "go.signoz.io/signoz/pkg/query-service/utils/queryTemplate"
 	"go.uber.org/zap"
 )
@@ -43,6 +44,9 @@ func (ah *APIHandler) queryRangeMetricsV2(w http.ResponseWriter, r *http.Request
 		metricsQueryRangeParams.End = (end / step * step) * 1000
 	}
 
+	// the raw clickhouse queries are run with the limits of the query guard
+	ctx := r.Context()
+
 	type channelResult struct {
 		Series    []*basemodel.Series
 		TableName string
@@ -61,7 +65,7 @@ func (ah *APIHandler) queryRangeMetricsV2(w http.ResponseWriter, r *http.Request
 			wg.Add(1)
 			go func(name, query string) {
 				defer wg.Done()
-				seriesList, tableName, err := ah.opts.DataConnector.GetMetricResultEE(r.Context(), query)
+				seriesList, tableName, err := ah.opts.DataConnector.GetMetricResultEE(ctx, query)
 				for _, series := range seriesList {
 					series.QueryName = name
 				}
@@ -203,7 +207,12 @@ func (ah *APIHandler) queryRangeMetricsV2(w http.ResponseWriter, r *http.Request
 				return
 			}
 			queries[name] = query.String()
+			if apiErr := queryguard.Check(queries[name], constants.RawQueryAllowedDatabases); apiErr != nil {
+				RespondError(w, apiErr, nil)
+				return
+			}
 		}
+		ctx = queryguard.WithLimits(ctx)
 		seriesList, tableName, err, errQuriesByName = execClickHouseQueries(queries)
 	case basemodel.PROM:
 		seriesList, err, errQuriesByName = execPromQueries(metricsQueryRangeParams)
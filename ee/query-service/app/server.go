@@ -31,9 +31,13 @@ import (
 	baseapp "go.signoz.io/signoz/pkg/query-service/app"
 	"go.signoz.io/signoz/pkg/query-service/app/dashboards"
 	baseexplorer "go.signoz.io/signoz/pkg/query-service/app/explorer"
+	"go.signoz.io/signoz/pkg/query-service/app/logparsingpipeline"
 	"go.signoz.io/signoz/pkg/query-service/app/opamp"
 	opAmpModel "go.signoz.io/signoz/pkg/query-service/app/opamp/model"
+	"go.signoz.io/signoz/pkg/query-service/app/querier"
+	"go.signoz.io/signoz/pkg/query-service/app/queryBuilder"
 	baseauth "go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/cache"
 	baseconst "go.signoz.io/signoz/pkg/query-service/constants"
 	"go.signoz.io/signoz/pkg/query-service/healthcheck"
 	basealm "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
@@ -56,6 +60,11 @@ type ServerOptions struct {
 	// alert specific params
 	DisableRules bool
 	RuleRepoURL  string
+	// directory of Prometheus rule files imported on start
+	RuleImportDir string
+	// query cache params
+	CacheConfigPath string
+	FluxInterval    string
 }
 
 // Server runs HTTP api service
@@ -131,12 +140,34 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 		}
 	}
 
+	var c cache.Cache
+	if serverOptions.CacheConfigPath != "" {
+		cacheOpts, err := cache.LoadFromYAMLCacheConfigFile(serverOptions.CacheConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		c = cache.NewCache(cacheOpts)
+		if c == nil {
+			return nil, fmt.Errorf("cache provider: %s is not supported", cacheOpts.Provider)
+		}
+		if err := c.Connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	fluxInterval, err := time.ParseDuration(serverOptions.FluxInterval)
+	if err != nil {
+		return nil, err
+	}
+
 	<-readerReady
 	rm, err := makeRulesManager(serverOptions.PromConfigPath,
 		baseconst.GetAlertManagerApiPrefix(),
 		serverOptions.RuleRepoURL,
 		localDB,
 		reader,
+		c,
+		fluxInterval,
 		serverOptions.DisableRules,
 		lm)
 
@@ -167,6 +198,12 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 
 	telemetry.GetInstance().SetReader(reader)
 
+	// initiate logs pipelines controller
+	logParsingPipelineController, err := logparsingpipeline.NewLogParsingPipelinesController(localDB, AppDbEngine)
+	if err != nil {
+		return nil, err
+	}
+
 	apiOpts := api.APIHandlerOptions{
 		DataConnector:  reader,
 		SkipConfig:     skipConfig,
@@ -174,6 +211,10 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 		RulesManager:   rm,
 		FeatureFlags:   lm,
 		LicenseManager: lm,
+		Cache:          c,
+		FluxInterval:   fluxInterval,
+
+		LogsParsingPipelineController: logParsingPipelineController,
 	}
 
 	apiHandler, err := api.NewAPIHandler(apiOpts)
@@ -264,6 +305,7 @@ func (s *Server) createPublicServer(apiHandler *api.APIHandler) (*http.Server, e
 	apiHandler.RegisterMetricsRoutes(r, am)
 	apiHandler.RegisterLogsRoutes(r, am)
 	apiHandler.RegisterQueryRangeV3Routes(r, am)
+	apiHandler.RegisterPrometheusRoutes(r, am)
 
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
@@ -466,6 +508,12 @@ func (s *Server) Start() error {
 		zap.S().Info("msg: Rules disabled as rules.disable is set to TRUE")
 	}
 
+	if s.serverOptions.RuleImportDir != "" {
+		if err := s.ruleManager.ImportPromRulesDir(s.serverOptions.RuleImportDir); err != nil {
+			return err
+		}
+	}
+
 	err := s.initListeners()
 	if err != nil {
 		return err
@@ -557,6 +605,8 @@ func makeRulesManager(
 	ruleRepoURL string,
 	db *sqlx.DB,
 	ch baseint.Reader,
+	c cache.Cache,
+	fluxInterval time.Duration,
 	disableRules bool,
 	fm baseInterface.FeatureLookup) (*rules.Manager, error) {
 
@@ -566,6 +616,14 @@ func makeRulesManager(
 		return nil, fmt.Errorf("failed to create pql engine : %v", err)
 	}
 
+	// the querier uses the cache only when one is configured
+	q := querier.NewQuerier(querier.QuerierOptions{
+		Reader:       ch,
+		Cache:        c,
+		KeyGenerator: queryBuilder.NewKeyGenerator(),
+		FluxInterval: fluxInterval,
+	})
+
 	// notifier opts
 	notifierOpts := basealm.NotifierOptions{
 		QueueCapacity:    10000,
@@ -579,6 +637,7 @@ func makeRulesManager(
 		Queriers: &rules.Queriers{
 			PqlEngine: pqle,
 			Ch:        ch.GetConn(),
+			Querier:   q,
 		},
 		RepoURL:      ruleRepoURL,
 		DBConn:       db,
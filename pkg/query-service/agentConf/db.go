@@ -83,8 +83,13 @@ func (r *Repo) GetConfigVersion(ctx context.Context, typ ElementTypeDef, v int)
 }
 
 func (r *Repo) GetLatestVersion(ctx context.Context, typ ElementTypeDef) (*ConfigVersion, error) {
+	return getLatestVersion(ctx, r.db, typ)
+}
+
+// getLatestVersion reads the latest version with q, the db or a transaction
+func getLatestVersion(ctx context.Context, q sqlx.QueryerContext, typ ElementTypeDef) (*ConfigVersion, error) {
 	var c ConfigVersion
-	err := r.db.GetContext(ctx, &c, `SELECT 
+	err := sqlx.GetContext(ctx, q, &c, `SELECT 
 		id, 
 		version, 
 		element_type, 
@@ -109,7 +114,9 @@ func (r *Repo) GetLatestVersion(ctx context.Context, typ ElementTypeDef) (*Confi
 	return &c, err
 }
 
-func (r *Repo) insertConfig(ctx context.Context, userId string, c *ConfigVersion, elements []string) (fnerr error) {
+// insertConfig inserts the version and its elements with tx, nothing is
+// saved until the caller commits tx
+func (r *Repo) insertConfig(ctx context.Context, tx *sqlx.Tx, userId string, c *ConfigVersion, elements []string) error {
 
 	if string(c.ElementType) == "" {
 		return fmt.Errorf("element type is required for creating agent config version")
@@ -128,7 +135,7 @@ func (r *Repo) insertConfig(ctx context.Context, userId string, c *ConfigVersion
 		return fmt.Errorf("user defined versions are not supported in the agent config")
 	}
 
-	configVersion, err := r.GetLatestVersion(ctx, c.ElementType)
+	configVersion, err := getLatestVersion(ctx, tx, c.ElementType)
 	if err != nil {
 		if err != sql.ErrNoRows {
 			zap.S().Error("failed to fetch latest config version", err)
@@ -138,14 +145,6 @@ func (r *Repo) insertConfig(ctx context.Context, userId string, c *ConfigVersion
 
 	c.Version = updateVersion(configVersion.Version)
 
-	defer func() {
-		if fnerr != nil {
-			// remove all the damage (invalid rows from db)
-			r.db.Exec("DELETE FROM agent_config_versions WHERE id = $1", c.ID)
-			r.db.Exec("DELETE FROM agent_config_elements WHERE version_id=$1", c.ID)
-		}
-	}()
-
 	// insert config
 	configQuery := `INSERT INTO agent_config_versions(	
 		id, 
@@ -159,7 +158,7 @@ func (r *Repo) insertConfig(ctx context.Context, userId string, c *ConfigVersion
 		deploy_result) 
 	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
-	_, err = r.db.ExecContext(ctx,
+	_, err = tx.ExecContext(ctx,
 		configQuery,
 		c.ID,
 		c.Version,
@@ -185,7 +184,7 @@ func (r *Repo) insertConfig(ctx context.Context, userId string, c *ConfigVersion
 
 	for _, e := range elements {
 
-		_, err = r.db.ExecContext(ctx,
+		_, err = tx.ExecContext(ctx,
 			elementsQuery,
 			uuid.NewString(),
 			c.ID,
@@ -229,7 +228,7 @@ func (r *Repo) updateDeployStatusByHash(ctx context.Context, confighash string,
 	updateQuery := `UPDATE agent_config_versions
 	set deploy_status = $1, 
 	deploy_result = $2
-	WHERE last_hash=$4`
+	WHERE last_hash=$3`
 
 	_, err := r.db.ExecContext(ctx, updateQuery, status, result, confighash)
 	if err != nil {
@@ -57,6 +57,23 @@ func GetConfigHistory(ctx context.Context, typ ElementTypeDef, limit int) ([]Con
 
 // StartNewVersion launches a new config version for given set of elements
 func StartNewVersion(ctx context.Context, userId string, eleType ElementTypeDef, elementIds []string) (*ConfigVersion, error) {
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := StartNewVersionTx(ctx, tx, userId, eleType, elementIds)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return cfg, tx.Commit()
+}
+
+// StartNewVersionTx launches a new config version for given set of elements
+// in tx, the version is saved when the caller commits tx so that it can be
+// saved along with the elements
+func StartNewVersionTx(ctx context.Context, tx *sqlx.Tx, userId string, eleType ElementTypeDef, elementIds []string) (*ConfigVersion, error) {
 
 	if !m.Ready() {
 		// agent is already being updated, ask caller to wait and re-try after sometime
@@ -67,7 +84,7 @@ func StartNewVersion(ctx context.Context, userId string, eleType ElementTypeDef,
 	cfg := NewConfigversion(eleType)
 
 	// insert new config and elements into database
-	err := m.insertConfig(ctx, userId, cfg, elementIds)
+	err := m.insertConfig(ctx, tx, userId, cfg, elementIds)
 	if err != nil {
 		return nil, err
 	}
@@ -126,6 +143,22 @@ func Redeploy(ctx context.Context, typ ElementTypeDef, version int) error {
 		}
 
 		m.updateDeployStatus(ctx, ElementTypeSamplingRules, version, string(DeployInitiated), "Deployment started", configHash, configVersion.LastConf)
+	case ElementTypeLogPipelines:
+		var pipelinesConf *logPipelinesConf
+		if err := yaml.Unmarshal([]byte(configVersion.LastConf), &pipelinesConf); err != nil || pipelinesConf == nil {
+			zap.S().Error("failed to read last conf correctly", err)
+			return fmt.Errorf("failed to read the stored config correctly")
+		}
+
+		configHash, err := opamp.UpsertLogsParsingProcessor(ctx, pipelinesConf.Processors, pipelinesConf.Names, m.OnConfigUpdate)
+		if err != nil {
+			zap.S().Error("failed to call agent config update for log parsing processor:", err)
+			return fmt.Errorf("failed to deploy the config")
+		}
+
+		m.updateDeployStatus(ctx, ElementTypeLogPipelines, version, string(DeployInitiated), "Deployment started", configHash, configVersion.LastConf)
+	default:
+		return fmt.Errorf("redeploy is not supported for %s", typ)
 	}
 
 	return nil
@@ -211,8 +244,15 @@ func UpsertSamplingProcessor(ctx context.Context, version int, config *tsp.Confi
 	return nil
 }
 
+// logPipelinesConf is the last config of a log pipelines version, it has
+// the processors along with their order to allow redeploying the version
+type logPipelinesConf struct {
+	Processors map[string]interface{} `yaml:"processors"`
+	Names      []string               `yaml:"names"`
+}
+
 // UpsertLogParsingProcessors updates the agent with log parsing processors
-func UpsertLogParsingProcessor(ctx context.Context, version int, rawPipelineData []byte, config map[string]interface{}, names []string) error {
+func UpsertLogParsingProcessor(ctx context.Context, version int, config map[string]interface{}, names []string) error {
 	if !atomic.CompareAndSwapUint32(&m.lock, 0, 1) {
 		return fmt.Errorf("agent updater is busy")
 	}
@@ -225,6 +265,11 @@ func UpsertLogParsingProcessor(ctx context.Context, version int, rawPipelineData
 		return err
 	}
 
-	m.updateDeployStatus(ctx, ElementTypeLogPipelines, version, string(DeployInitiated), "Deployment started", configHash, string(rawPipelineData))
+	processorConfYaml, err := yaml.Marshal(logPipelinesConf{Processors: config, Names: names})
+	if err != nil {
+		zap.S().Warnf("unexpected error while transforming processor config to yaml", err)
+	}
+
+	m.updateDeployStatus(ctx, ElementTypeLogPipelines, version, string(DeployInitiated), "Deployment started", configHash, string(processorConfYaml))
 	return nil
 }
@@ -43,7 +43,7 @@ func (am *AuthMiddleware) ViewAccess(f func(http.ResponseWriter, *http.Request))
 			}, nil)
 			return
 		}
-		f(w, r)
+		f(w, r.WithContext(auth.AttachUserToContext(r.Context(), user)))
 	}
 }
 
@@ -64,7 +64,7 @@ func (am *AuthMiddleware) EditAccess(f func(http.ResponseWriter, *http.Request))
 			}, nil)
 			return
 		}
-		f(w, r)
+		f(w, r.WithContext(auth.AttachUserToContext(r.Context(), user)))
 	}
 }
 
@@ -86,7 +86,7 @@ func (am *AuthMiddleware) SelfAccess(f func(http.ResponseWriter, *http.Request))
 			}, nil)
 			return
 		}
-		f(w, r)
+		f(w, r.WithContext(auth.AttachUserToContext(r.Context(), user)))
 	}
 }
 
@@ -107,6 +107,6 @@ func (am *AuthMiddleware) AdminAccess(f func(http.ResponseWriter, *http.Request)
 			}, nil)
 			return
 		}
-		f(w, r)
+		f(w, r.WithContext(auth.AttachUserToContext(r.Context(), user)))
 	}
 }
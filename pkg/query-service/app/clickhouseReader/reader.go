@@ -1894,6 +1894,79 @@ func (r *ClickHouseReader) GetTopOperations(ctx context.Context, queryParams *mo
 	return &topOperationsItems, nil
 }
 
+// GetOperationWindowStats returns the latency of the root spans of the
+// operation and of the other spans of the traces they are the root of
+func (r *ClickHouseReader) GetOperationWindowStats(ctx context.Context, queryParams *model.OperationWindowStatsParams) (*model.OperationWindowStats, *model.ApiError) {
+
+	args := []interface{}{
+		clickhouse.Named("start", strconv.FormatInt(queryParams.Start.UnixNano(), 10)),
+		clickhouse.Named("end", strconv.FormatInt(queryParams.End.UnixNano(), 10)),
+		clickhouse.Named("serviceName", queryParams.ServiceName),
+		clickhouse.Named("operation", queryParams.Operation),
+	}
+
+	stats := model.OperationWindowStats{}
+
+	rootFilter := "serviceName = @serviceName AND name = @operation AND parentSpanID = '' AND timestamp >= @start AND timestamp <= @end"
+	query := fmt.Sprintf(`
+		SELECT
+			serviceName,
+			name,
+			quantile(0.5)(durationNano) as p50,
+			quantile(0.95)(durationNano) as p95,
+			COUNT(*) as numCalls,
+			countIf(hasError) as errorCount
+		FROM %s.%s
+		WHERE %s
+		GROUP BY serviceName, name`,
+		r.TraceDB, r.indexTable, rootFilter,
+	)
+	var rootItems []model.OperationStatsItem
+	err := r.db.Select(ctx, &rootItems, query, args...)
+
+	zap.S().Debug(query)
+
+	if err != nil {
+		zap.S().Error("Error in processing sql query: ", err)
+		return nil, &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("error in processing sql query")}
+	}
+	if len(rootItems) == 0 {
+		stats.Root = model.OperationStatsItem{ServiceName: queryParams.ServiceName, Name: queryParams.Operation}
+		stats.Operations = []model.OperationStatsItem{}
+		return &stats, nil
+	}
+	stats.Root = rootItems[0]
+
+	query = fmt.Sprintf(`
+		SELECT
+			serviceName,
+			name,
+			quantile(0.5)(durationNano) as p50,
+			quantile(0.95)(durationNano) as p95,
+			COUNT(*) as numCalls,
+			countIf(hasError) as errorCount
+		FROM %s.%s
+		WHERE timestamp >= @start AND timestamp <= @end AND parentSpanID != '' AND
+			traceID GLOBAL IN (SELECT traceID FROM %s.%s WHERE %s)
+		GROUP BY serviceName, name`,
+		r.TraceDB, r.indexTable, r.TraceDB, r.indexTable, rootFilter,
+	)
+	err = r.db.Select(ctx, &stats.Operations, query, args...)
+
+	zap.S().Debug(query)
+
+	if err != nil {
+		zap.S().Error("Error in processing sql query: ", err)
+		return nil, &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("error in processing sql query")}
+	}
+
+	if stats.Operations == nil {
+		stats.Operations = []model.OperationStatsItem{}
+	}
+
+	return &stats, nil
+}
+
 func (r *ClickHouseReader) GetUsage(ctx context.Context, queryParams *model.GetUsageParams) (*[]model.UsageItem, error) {
 
 	var usageItems []model.UsageItem
@@ -1982,9 +2055,156 @@ func (r *ClickHouseReader) SearchTraces(ctx context.Context, traceId string, spa
 		}
 	}
 
+	if len(searchSpanResponses) > 0 && len(searchSpansResult) > 0 {
+		// the timestamps of the spans are in milliseconds
+		traceStart, traceEnd := searchSpanResponses[0].TimeUnixNano, searchSpanResponses[0].TimeUnixNano
+		for _, item := range searchSpanResponses {
+			if item.TimeUnixNano < traceStart {
+				traceStart = item.TimeUnixNano
+			}
+			if spanEnd := item.TimeUnixNano + uint64(item.DurationNano/1000000); spanEnd > traceEnd {
+				traceEnd = spanEnd
+			}
+		}
+		logCounts, apiErr := r.getSpanLogCounts(ctx, traceId, time.UnixMilli(int64(traceStart)), time.UnixMilli(int64(traceEnd)))
+		if apiErr != nil {
+			// the trace is returned without the log counts
+			zap.S().Error("failed to count the logs of trace ", traceId, ": ", apiErr.Err)
+		} else {
+			searchSpansResult[0].SpanLogCounts = logCounts
+		}
+	}
+
 	return &searchSpansResult, nil
 }
 
+// getSpanLogCounts returns the number of logs of the spans of the trace, the
+// logs are looked for from a bit before the start to a bit after the end
+func (r *ClickHouseReader) getSpanLogCounts(ctx context.Context, traceID string, start, end time.Time) (map[string]uint64, *model.ApiError) {
+	var items []model.SpanLogCountItem
+
+	query := fmt.Sprintf(`
+		SELECT span_id, count() as count
+		FROM %s.%s
+		WHERE timestamp >= @start AND timestamp <= @end AND trace_id = @traceID AND span_id != ''
+		GROUP BY span_id`,
+		r.logsDB, r.logsTable,
+	)
+	args := []interface{}{
+		clickhouse.Named("start", uint64(start.Add(-constants.TraceLogsMargin).UnixNano())),
+		clickhouse.Named("end", uint64(end.Add(constants.TraceLogsMargin).UnixNano())),
+		clickhouse.Named("traceID", traceID),
+	}
+	err := r.db.Select(ctx, &items, query, args...)
+
+	zap.S().Debug(query)
+
+	if err != nil {
+		zap.S().Error("Error in processing sql query: ", err)
+		return nil, &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("error in processing sql query")}
+	}
+
+	counts := make(map[string]uint64, len(items))
+	for _, item := range items {
+		counts[item.SpanID] = item.Count
+	}
+	return counts, nil
+}
+
+// GetTraceSpans returns the spans of the trace, unlike SearchTraces the
+// timestamps of the spans are in nanoseconds
+func (r *ClickHouseReader) GetTraceSpans(ctx context.Context, traceID string) ([]model.SearchSpanResponseItem, *model.ApiError) {
+	var searchScanResponses []model.SearchSpanDBResponseItem
+
+	query := fmt.Sprintf("SELECT timestamp, traceID, model FROM %s.%s WHERE traceID=$1", r.TraceDB, r.SpansTable)
+
+	err := r.db.Select(ctx, &searchScanResponses, query, traceID)
+
+	zap.S().Info(query)
+
+	if err != nil {
+		zap.S().Debug("Error in processing sql query: ", err)
+		return nil, &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("Error in processing sql query")}
+	}
+	if len(searchScanResponses) == 0 {
+		return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("trace %s not found", traceID)}
+	}
+
+	spans := make([]model.SearchSpanResponseItem, 0, len(searchScanResponses))
+	for _, item := range searchScanResponses {
+		var span model.SearchSpanResponseItem
+		if err := easyjson.Unmarshal([]byte(item.Model), &span); err != nil {
+			zap.S().Error("failed to unmarshal the span of trace ", traceID, ": ", err)
+			continue
+		}
+		span.TimeUnixNano = uint64(item.Timestamp.UnixNano())
+		spans = append(spans, span)
+	}
+	return spans, nil
+}
+
+// ImportTraceSpans writes the spans to the spans table the trace detail is
+// read from and to the span index the traces are searched and aggregated on
+func (r *ClickHouseReader) ImportTraceSpans(ctx context.Context, spans []model.ImportedSpan) *model.ApiError {
+	spansBatch, err := r.db.PrepareBatch(ctx, fmt.Sprintf("INSERT INTO %s.%s (timestamp, traceID, model)", r.TraceDB, r.SpansTable))
+	if err != nil {
+		zap.S().Error("failed to prepare the batch of the spans: ", err)
+		return &model.ApiError{Typ: model.ErrorExec, Err: err}
+	}
+	// the batches hold a connection until they are sent or aborted, aborting
+	// a sent batch does nothing
+	defer spansBatch.Abort()
+	indexBatch, err := r.db.PrepareBatch(ctx, fmt.Sprintf(
+		"INSERT INTO %s.%s (timestamp, traceID, spanID, parentSpanID, serviceName, name, kind, durationNano, statusCode, hasError, "+
+			"events, stringTagMap, numberTagMap, boolTagMap, resourceTagsMap)", r.TraceDB, r.indexTable))
+	if err != nil {
+		zap.S().Error("failed to prepare the batch of the span index: ", err)
+		return &model.ApiError{Typ: model.ErrorExec, Err: err}
+	}
+	defer indexBatch.Abort()
+
+	for _, item := range spans {
+		span := item.Span
+		data, err := easyjson.Marshal(span)
+		if err != nil {
+			return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("failed to encode span %s: %w", span.SpanID, err)}
+		}
+		timestamp := time.Unix(0, int64(span.TimeUnixNano))
+		if err := spansBatch.Append(timestamp, span.TraceID, string(data)); err != nil {
+			return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid span %s: %w", span.SpanID, err)}
+		}
+		if err := indexBatch.Append(
+			timestamp,
+			span.TraceID,
+			span.SpanID,
+			item.ParentSpanID,
+			span.ServiceName,
+			span.Name,
+			int8(span.Kind),
+			uint64(span.DurationNano),
+			item.StatusCode,
+			span.HasError,
+			span.Events,
+			item.StringTagMap,
+			item.NumberTagMap,
+			item.BoolTagMap,
+			item.ResourceTagMap,
+		); err != nil {
+			return &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid span %s: %w", span.SpanID, err)}
+		}
+	}
+
+	if err := indexBatch.Send(); err != nil {
+		zap.S().Error("failed to write the span index: ", err)
+		return &model.ApiError{Typ: model.ErrorExec, Err: err}
+	}
+	if err := spansBatch.Send(); err != nil {
+		zap.S().Error("failed to write the spans: ", err)
+		return &model.ApiError{Typ: model.ErrorExec, Err: err}
+	}
+	return nil
+}
+
 func (r *ClickHouseReader) GetDependencyGraph(ctx context.Context, queryParams *model.GetServicesParams) (*[]model.ServiceMapDependencyResponseItem, error) {
 
 	response := []model.ServiceMapDependencyResponseItem{}
@@ -2413,7 +2633,7 @@ func (r *ClickHouseReader) SetTTL(ctx context.Context,
 		}(tableName)
 
 	default:
-		return nil, &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("error while setting ttl. ttl type should be <metrics|traces>, got %v",
+		return nil, &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("error while setting ttl. ttl type should be <metrics|traces|logs>, got %v",
 			params.Type)}
 	}
 
@@ -2515,7 +2735,7 @@ func getLocalTableNameArray(tableNames []string) []string {
 	return localTableNames
 }
 
-// GetTTL returns current ttl, expected ttl and past setTTL status for metrics/traces.
+// GetTTL returns current ttl, expected ttl and past setTTL status for metrics/traces/logs.
 func (r *ClickHouseReader) GetTTL(ctx context.Context, ttlParams *model.GetTTLParams) (*model.GetTTLResponseItem, *model.ApiError) {
 
 	parseTTL := func(queryResp string) (int, int) {
@@ -2601,6 +2821,15 @@ func (r *ClickHouseReader) GetTTL(ctx context.Context, ttlParams *model.GetTTLPa
 		}
 	}
 
+	// engineFull returns the table definition TTL is parsed from, the table
+	// may not exist yet, e.g. logs before the first log is ingested
+	engineFull := func(dbResp *model.DBResponseTTL) string {
+		if dbResp == nil {
+			return ""
+		}
+		return dbResp.EngineFull
+	}
+
 	switch ttlParams.Type {
 	case constants.TraceTTL:
 		tableNameArray := []string{signozTraceDBName + "." + signozTraceTableName, signozTraceDBName + "." + signozDurationMVTable, signozTraceDBName + "." + signozSpansTable, signozTraceDBName + "." + signozErrorIndexTable, signozTraceDBName + "." + signozUsageExplorerTable, signozTraceDBName + "." + defaultDependencyGraphTable}
@@ -2623,7 +2852,7 @@ func (r *ClickHouseReader) GetTTL(ctx context.Context, ttlParams *model.GetTTLPa
 			ttlQuery.ColdStorageTtl = ttlQuery.ColdStorageTtl / 3600 // convert to hours
 		}
 
-		delTTL, moveTTL := parseTTL(dbResp.EngineFull)
+		delTTL, moveTTL := parseTTL(engineFull(dbResp))
 		return &model.GetTTLResponseItem{TracesTime: delTTL, TracesMoveTime: moveTTL, ExpectedTracesTime: ttlQuery.TTL, ExpectedTracesMoveTime: ttlQuery.ColdStorageTtl, Status: status}, nil
 
 	case constants.MetricsTTL:
@@ -2646,7 +2875,7 @@ func (r *ClickHouseReader) GetTTL(ctx context.Context, ttlParams *model.GetTTLPa
 			ttlQuery.ColdStorageTtl = ttlQuery.ColdStorageTtl / 3600 // convert to hours
 		}
 
-		delTTL, moveTTL := parseTTL(dbResp.EngineFull)
+		delTTL, moveTTL := parseTTL(engineFull(dbResp))
 		return &model.GetTTLResponseItem{MetricsTime: delTTL, MetricsMoveTime: moveTTL, ExpectedMetricsTime: ttlQuery.TTL, ExpectedMetricsMoveTime: ttlQuery.ColdStorageTtl, Status: status}, nil
 
 	case constants.LogsTTL:
@@ -2669,11 +2898,11 @@ func (r *ClickHouseReader) GetTTL(ctx context.Context, ttlParams *model.GetTTLPa
 			ttlQuery.ColdStorageTtl = ttlQuery.ColdStorageTtl / 3600 // convert to hours
 		}
 
-		delTTL, moveTTL := parseTTL(dbResp.EngineFull)
+		delTTL, moveTTL := parseTTL(engineFull(dbResp))
 		return &model.GetTTLResponseItem{LogsTime: delTTL, LogsMoveTime: moveTTL, ExpectedLogsTime: ttlQuery.TTL, ExpectedLogsMoveTime: ttlQuery.ColdStorageTtl, Status: status}, nil
 
 	default:
-		return nil, &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("error while getting ttl. ttl type should be metrics|traces, got %v",
+		return nil, &model.ApiError{Typ: model.ErrorExec, Err: fmt.Errorf("error while getting ttl. ttl type should be metrics|traces|logs, got %v",
 			ttlParams.Type)}
 	}
 
@@ -3001,30 +3230,31 @@ func (r *ClickHouseReader) getPrevErrorID(ctx context.Context, queryParams *mode
 	}
 }
 
+// metricAutocompleteTagConditions filters the time series by the metric
+// name and tags of the params
+func metricAutocompleteTagConditions(params *model.MetricAutocompleteTagParams) (string, []interface{}) {
+	conditions := []string{"metric_name=$1"}
+	args := []interface{}{params.MetricName}
+	for key, val := range params.MetricTags {
+		args = append(args, key, val)
+		conditions = append(conditions, fmt.Sprintf("JSONExtractString(labels, $%d) = $%d", len(args)-1, len(args)))
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
 func (r *ClickHouseReader) GetMetricAutocompleteTagKey(ctx context.Context, params *model.MetricAutocompleteTagParams) (*[]string, *model.ApiError) {
 
-	var query string
-	var err error
 	var tagKeyList []string
-	var rows driver.Rows
-
-	tagsWhereClause := ""
 
-	for key, val := range params.MetricTags {
-		tagsWhereClause += fmt.Sprintf(" AND JSONExtractString(labels, '%s') = '%s' ", key, val)
-	}
+	whereClause, args := metricAutocompleteTagConditions(params)
 	// "select distinctTagKeys from (SELECT DISTINCT arrayJoin(tagKeys) distinctTagKeys from (SELECT DISTINCT(JSONExtractKeys(labels)) tagKeys from signoz_metrics.time_series WHERE JSONExtractString(labels,'__name__')='node_udp_queues'))  WHERE distinctTagKeys ILIKE '%host%';"
+	query := fmt.Sprintf("select distinctTagKeys from (SELECT DISTINCT arrayJoin(tagKeys) distinctTagKeys from (SELECT DISTINCT(JSONExtractKeys(labels)) tagKeys from %s.%s%s))", signozMetricDBName, signozTSTableName, whereClause)
 	if len(params.Match) != 0 {
-		query = fmt.Sprintf("select distinctTagKeys from (SELECT DISTINCT arrayJoin(tagKeys) distinctTagKeys from (SELECT DISTINCT(JSONExtractKeys(labels)) tagKeys from %s.%s WHERE metric_name=$1 %s)) WHERE distinctTagKeys ILIKE $2;", signozMetricDBName, signozTSTableName, tagsWhereClause)
-
-		rows, err = r.db.Query(ctx, query, params.MetricName, fmt.Sprintf("%%%s%%", params.Match))
-
-	} else {
-		query = fmt.Sprintf("select distinctTagKeys from (SELECT DISTINCT arrayJoin(tagKeys) distinctTagKeys from (SELECT DISTINCT(JSONExtractKeys(labels)) tagKeys from %s.%s WHERE metric_name=$1 %s ));", signozMetricDBName, signozTSTableName, tagsWhereClause)
-
-		rows, err = r.db.Query(ctx, query, params.MetricName)
+		args = append(args, fmt.Sprintf("%%%s%%", params.Match))
+		query += fmt.Sprintf(" WHERE distinctTagKeys ILIKE $%d", len(args))
 	}
 
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		zap.S().Error(err)
 		return nil, &model.ApiError{Typ: model.ErrorExec, Err: err}
@@ -3043,27 +3273,17 @@ func (r *ClickHouseReader) GetMetricAutocompleteTagKey(ctx context.Context, para
 
 func (r *ClickHouseReader) GetMetricAutocompleteTagValue(ctx context.Context, params *model.MetricAutocompleteTagParams) (*[]string, *model.ApiError) {
 
-	var query string
-	var err error
 	var tagValueList []string
-	var rows driver.Rows
-	tagsWhereClause := ""
-
-	for key, val := range params.MetricTags {
-		tagsWhereClause += fmt.Sprintf(" AND JSONExtractString(labels, '%s') = '%s' ", key, val)
-	}
 
+	whereClause, args := metricAutocompleteTagConditions(params)
+	args = append(args, params.TagKey)
+	query := fmt.Sprintf("SELECT DISTINCT(JSONExtractString(labels, $%d)) AS tagValue FROM %s.%s%s", len(args), signozMetricDBName, signozTSTableName, whereClause)
 	if len(params.Match) != 0 {
-		query = fmt.Sprintf("SELECT DISTINCT(JSONExtractString(labels, '%s')) from %s.%s WHERE metric_name=$1 %s AND JSONExtractString(labels, '%s') ILIKE $2;", params.TagKey, signozMetricDBName, signozTSTableName, tagsWhereClause, params.TagKey)
-
-		rows, err = r.db.Query(ctx, query, params.TagKey, params.MetricName, fmt.Sprintf("%%%s%%", params.Match))
-
-	} else {
-		query = fmt.Sprintf("SELECT DISTINCT(JSONExtractString(labels, '%s')) FROM %s.%s WHERE metric_name=$2 %s;", params.TagKey, signozMetricDBName, signozTSTableName, tagsWhereClause)
-		rows, err = r.db.Query(ctx, query, params.TagKey, params.MetricName)
-
+		args = append(args, fmt.Sprintf("%%%s%%", params.Match))
+		query = fmt.Sprintf("SELECT tagValue FROM (%s) WHERE tagValue ILIKE $%d", query, len(args))
 	}
 
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		zap.S().Error(err)
 		return nil, &model.ApiError{Typ: model.ErrorExec, Err: err}
@@ -3081,6 +3301,80 @@ func (r *ClickHouseReader) GetMetricAutocompleteTagValue(ctx context.Context, pa
 	return &tagValueList, nil
 }
 
+// promLabelConditions filters the time series by the metric name, when it
+// is set, and the tags of the params, only the series with samples in the
+// time range of the params are kept
+func promLabelConditions(params *model.PromLabelParams) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	samplesConditions := ""
+	if params.MetricName != "" {
+		args = append(args, params.MetricName)
+		conditions = append(conditions, "metric_name=$1")
+		samplesConditions = "metric_name=$1 AND "
+	}
+	for key, val := range params.MetricTags {
+		args = append(args, key, val)
+		conditions = append(conditions, fmt.Sprintf("JSONExtractString(labels, $%d) = $%d", len(args)-1, len(args)))
+	}
+	args = append(args, params.Start, params.End)
+	conditions = append(conditions, fmt.Sprintf("fingerprint IN (SELECT DISTINCT fingerprint FROM %s.%s WHERE %stimestamp_ms >= $%d AND timestamp_ms <= $%d)",
+		signozMetricDBName, signozSampleTableName, samplesConditions, len(args)-1, len(args)))
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// GetPromLabelNames returns the label names of the time series of the
+// params, at most params.Limit of them
+func (r *ClickHouseReader) GetPromLabelNames(ctx context.Context, params *model.PromLabelParams) (*[]string, *model.ApiError) {
+
+	whereClause, args := promLabelConditions(params)
+	query := fmt.Sprintf("SELECT DISTINCT arrayJoin(JSONExtractKeys(labels)) AS name FROM %s.%s%s ORDER BY name LIMIT %d", signozMetricDBName, signozTSTableName, whereClause, params.Limit)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		zap.S().Error(err)
+		return nil, &model.ApiError{Typ: model.ErrorExec, Err: err}
+	}
+
+	defer rows.Close()
+	names := []string{}
+	var name string
+	for rows.Next() {
+		if err := rows.Scan(&name); err != nil {
+			return nil, &model.ApiError{Typ: model.ErrorExec, Err: err}
+		}
+		names = append(names, name)
+	}
+	return &names, nil
+}
+
+// GetPromLabelValues returns the values of the label params.LabelName of
+// the time series of the params, at most params.Limit of them
+func (r *ClickHouseReader) GetPromLabelValues(ctx context.Context, params *model.PromLabelParams) (*[]string, *model.ApiError) {
+
+	whereClause, args := promLabelConditions(params)
+	args = append(args, params.LabelName)
+	query := fmt.Sprintf("SELECT value FROM (SELECT DISTINCT JSONExtractString(labels, $%d) AS value FROM %s.%s%s) WHERE value != '' ORDER BY value LIMIT %d",
+		len(args), signozMetricDBName, signozTSTableName, whereClause, params.Limit)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		zap.S().Error(err)
+		return nil, &model.ApiError{Typ: model.ErrorExec, Err: err}
+	}
+
+	defer rows.Close()
+	values := []string{}
+	var value string
+	for rows.Next() {
+		if err := rows.Scan(&value); err != nil {
+			return nil, &model.ApiError{Typ: model.ErrorExec, Err: err}
+		}
+		values = append(values, value)
+	}
+	return &values, nil
+}
+
 func (r *ClickHouseReader) GetMetricAutocompleteMetricNames(ctx context.Context, matchText string, limit int) (*[]string, *model.ApiError) {
 
 	var query string
@@ -3837,6 +4131,7 @@ func (r *ClickHouseReader) GetLogAggregateAttributes(ctx context.Context, req *v
 		v3.AggregateOperatorP90,
 		v3.AggregateOperatorP95,
 		v3.AggregateOperatorP99,
+		v3.AggregateOperatorQuantile,
 		v3.AggregateOperatorAvg,
 		v3.AggregateOperatorSum,
 		v3.AggregateOperatorMin,
@@ -4271,6 +4566,7 @@ func (r *ClickHouseReader) GetTraceAggregateAttributes(ctx context.Context, req
 		v3.AggregateOperatorP90,
 		v3.AggregateOperatorP95,
 		v3.AggregateOperatorP99,
+		v3.AggregateOperatorQuantile,
 		v3.AggregateOperatorAvg,
 		v3.AggregateOperatorSum,
 		v3.AggregateOperatorMin,
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"go.signoz.io/signoz/pkg/query-service/model"
 )
 
 type GetStatusFiltersTest struct {
@@ -27,3 +28,29 @@ func TestGetStatusFilters(t *testing.T) {
 		assert.Equal(getStatusFilters(test.query, test.statusParams, test.excludeMap), test.expected)
 	}
 }
+
+func TestMetricAutocompleteTagConditions(t *testing.T) {
+	assert := assert.New(t)
+
+	// no metric name matches no series
+	where, args := metricAutocompleteTagConditions(&model.MetricAutocompleteTagParams{})
+	assert.Equal(" WHERE metric_name=$1", where)
+	assert.Equal([]interface{}{""}, args)
+
+	where, args = metricAutocompleteTagConditions(&model.MetricAutocompleteTagParams{MetricName: "up", MetricTags: map[string]string{"job": "api"}})
+	assert.Equal(" WHERE metric_name=$1 AND JSONExtractString(labels, $2) = $3", where)
+	assert.Equal([]interface{}{"up", "job", "api"}, args)
+}
+
+func TestPromLabelConditions(t *testing.T) {
+	assert := assert.New(t)
+
+	where, args := promLabelConditions(&model.PromLabelParams{Start: 1000, End: 2000})
+	assert.Equal(" WHERE fingerprint IN (SELECT DISTINCT fingerprint FROM signoz_metrics.distributed_samples_v2 WHERE timestamp_ms >= $1 AND timestamp_ms <= $2)", where)
+	assert.Equal([]interface{}{int64(1000), int64(2000)}, args)
+
+	where, args = promLabelConditions(&model.PromLabelParams{MetricName: "up", MetricTags: map[string]string{"job": "api"}, Start: 1000, End: 2000})
+	assert.Equal(" WHERE metric_name=$1 AND JSONExtractString(labels, $2) = $3 AND fingerprint IN "+
+		"(SELECT DISTINCT fingerprint FROM signoz_metrics.distributed_samples_v2 WHERE metric_name=$1 AND timestamp_ms >= $4 AND timestamp_ms <= $5)", where)
+	assert.Equal([]interface{}{"up", "job", "api", int64(1000), int64(2000)}, args)
+}
@@ -64,6 +64,39 @@ func InitDB(dataSourceName string) (*sqlx.DB, error) {
 		return nil, fmt.Errorf("Error in creating rules table: %s", err.Error())
 	}
 
+	table_schema = `CREATE TABLE IF NOT EXISTS rule_state_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule_id TEXT NOT NULL,
+		rule_name TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		state TEXT NOT NULL,
+		state_changed_at INTEGER NOT NULL,
+		labels TEXT NOT NULL,
+		value REAL NOT NULL,
+		receivers TEXT NOT NULL,
+		active_at INTEGER NOT NULL,
+		fired_at INTEGER NOT NULL,
+		resolved_at INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_rule_state_history_rule_id ON rule_state_history (rule_id, state_changed_at);
+	CREATE INDEX IF NOT EXISTS idx_rule_state_history_state ON rule_state_history (state, state_changed_at);`
+
+	_, err = db.Exec(table_schema)
+	if err != nil {
+		return nil, fmt.Errorf("Error in creating rule_state_history table: %s", err.Error())
+	}
+
+	table_schema = `CREATE TABLE IF NOT EXISTS rule_alert_state (
+		rule_id TEXT PRIMARY KEY,
+		updated_at datetime NOT NULL,
+		data TEXT NOT NULL
+	);`
+
+	_, err = db.Exec(table_schema)
+	if err != nil {
+		return nil, fmt.Errorf("Error in creating rule_alert_state table: %s", err.Error())
+	}
+
 	table_schema = `CREATE TABLE IF NOT EXISTS notification_channels (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		created_at datetime NOT NULL,
@@ -20,16 +20,21 @@ import (
 	"github.com/prometheus/prometheus/promql"
 	"go.signoz.io/signoz/pkg/query-service/app/dashboards"
 	"go.signoz.io/signoz/pkg/query-service/app/explorer"
+	"go.signoz.io/signoz/pkg/query-service/app/logparsingpipeline"
 	"go.signoz.io/signoz/pkg/query-service/app/logs"
 	logsv3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
 	"go.signoz.io/signoz/pkg/query-service/app/metrics"
 	metricsv3 "go.signoz.io/signoz/pkg/query-service/app/metrics/v3"
 	"go.signoz.io/signoz/pkg/query-service/app/parser"
+	"go.signoz.io/signoz/pkg/query-service/app/querier"
 	"go.signoz.io/signoz/pkg/query-service/app/queryBuilder"
+	"go.signoz.io/signoz/pkg/query-service/app/traces"
 	tracesV3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
 	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/cache"
 	"go.signoz.io/signoz/pkg/query-service/constants"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	queryguard "go.signoz.io/signoz/pkg/query-service/utils/queryGuard"
 	querytemplate "go.signoz.io/signoz/pkg/query-service/utils/queryTemplate"
 
 	"go.signoz.io/signoz/pkg/query-service/dao"
@@ -40,7 +45,6 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/rules"
 	"go.signoz.io/signoz/pkg/query-service/telemetry"
 	"go.signoz.io/signoz/pkg/query-service/version"
-	"go.uber.org/multierr"
 	"go.uber.org/zap"
 )
 
@@ -71,6 +75,13 @@ type APIHandler struct {
 	ready        func(http.HandlerFunc) http.HandlerFunc
 	queryBuilder *queryBuilder.QueryBuilder
 
+	// querier serves v3 query range requests, through the cache when
+	// one is configured
+	querier interfaces.Querier
+
+	// logs parsing pipelines controller, nil when agent config is not set up
+	LogsParsingPipelineController *logparsingpipeline.LogParsingPipelineController
+
 	// SetupCompleted indicates if SigNoz is ready for general use.
 	// at the moment, we mark the app ready when the first user
 	// is registers.
@@ -91,6 +102,16 @@ type APIHandlerOpts struct {
 
 	// feature flags querier
 	FeatureFlags interfaces.FeatureLookup
+
+	// cache used by the querier, optional
+	Cache cache.Cache
+
+	// interval for which the recent data is considered to be in flux
+	// and is always fetched from the datastore
+	FluxInterval time.Duration
+
+	// Log parsing pipelines
+	LogsParsingPipelineController *logparsingpipeline.LogParsingPipelineController
 }
 
 // NewAPIHandler returns an APIHandler
@@ -108,6 +129,8 @@ func NewAPIHandler(opts APIHandlerOpts) (*APIHandler, error) {
 		alertManager: alertManager,
 		ruleManager:  opts.RuleManager,
 		featureFlags: opts.FeatureFlags,
+
+		LogsParsingPipelineController: opts.LogsParsingPipelineController,
 	}
 
 	builderOpts := queryBuilder.QueryBuilderOptions{
@@ -117,6 +140,13 @@ func NewAPIHandler(opts APIHandlerOpts) (*APIHandler, error) {
 	}
 	aH.queryBuilder = queryBuilder.NewQueryBuilder(builderOpts)
 
+	aH.querier = querier.NewQuerier(querier.QuerierOptions{
+		Reader:       opts.Reader,
+		Cache:        opts.Cache,
+		KeyGenerator: queryBuilder.NewKeyGenerator(),
+		FluxInterval: opts.FluxInterval,
+	})
+
 	aH.ready = aH.testReady
 
 	dashboards.LoadDashboardFiles(aH.featureFlags)
@@ -289,10 +319,14 @@ func (aH *APIHandler) RegisterRoutes(router *mux.Router, am *AuthMiddleware) {
 	router.HandleFunc("/api/v1/rules", am.ViewAccess(aH.listRules)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/rules/{id}", am.ViewAccess(aH.getRule)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/rules", am.EditAccess(aH.createRule)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/import", am.EditAccess(aH.importRules)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/rules/{id}", am.EditAccess(aH.editRule)).Methods(http.MethodPut)
 	router.HandleFunc("/api/v1/rules/{id}", am.EditAccess(aH.deleteRule)).Methods(http.MethodDelete)
 	router.HandleFunc("/api/v1/rules/{id}", am.EditAccess(aH.patchRule)).Methods(http.MethodPatch)
 	router.HandleFunc("/api/v1/testRule", am.EditAccess(aH.testRule)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/rules/{id}/history/timeline", am.ViewAccess(aH.getRuleStateTimeline)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/alerts/history/firing", am.ViewAccess(aH.getFiringAlertsHistory)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/rules/{id}/slo/budget", am.ViewAccess(aH.getSLOErrorBudget)).Methods(http.MethodGet)
 
 	router.HandleFunc("/api/v1/dashboards", am.ViewAccess(aH.getDashboards)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/dashboards", am.EditAccess(aH.createDashboards)).Methods(http.MethodPost)
@@ -317,6 +351,12 @@ func (aH *APIHandler) RegisterRoutes(router *mux.Router, am *AuthMiddleware) {
 	router.HandleFunc("/api/v1/service/top_operations", am.ViewAccess(aH.getTopOperations)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/service/top_level_operations", am.ViewAccess(aH.getServicesTopLevelOps)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/traces/{traceId}", am.ViewAccess(aH.SearchTraces)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/traces/{traceId}/analysis", am.ViewAccess(aH.getTraceAnalysis)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/traces/{traceId}/diff/{targetTraceId}", am.ViewAccess(aH.getTraceDiff)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/traces/diff/windows", am.ViewAccess(aH.getTraceWindowsDiff)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/traces/{traceId}/export", am.ViewAccess(aH.exportTrace)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/traces/import", am.EditAccess(aH.importTraces)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/traces/{traceId}/logs", am.ViewAccess(aH.getTraceLogs)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/usage", am.ViewAccess(aH.getUsage)).Methods(http.MethodGet)
 	router.HandleFunc("/api/v1/dependency_graph", am.ViewAccess(aH.dependencyGraph)).Methods(http.MethodPost)
 	router.HandleFunc("/api/v1/settings/ttl", am.AdminAccess(aH.setTTL)).Methods(http.MethodPost)
@@ -473,6 +513,9 @@ func (aH *APIHandler) QueryRangeMetricsV2(w http.ResponseWriter, r *http.Request
 		metricsQueryRangeParams.End = (end / step * step) * 1000
 	}
 
+	// the raw clickhouse queries are run with the limits of the query guard
+	ctx := r.Context()
+
 	type channelResult struct {
 		Series []*model.Series
 		Err    error
@@ -489,7 +532,7 @@ func (aH *APIHandler) QueryRangeMetricsV2(w http.ResponseWriter, r *http.Request
 			wg.Add(1)
 			go func(name, query string) {
 				defer wg.Done()
-				seriesList, err := aH.reader.GetMetricResult(r.Context(), query)
+				seriesList, err := aH.reader.GetMetricResult(ctx, query)
 				for _, series := range seriesList {
 					series.QueryName = name
 				}
@@ -629,7 +672,12 @@ func (aH *APIHandler) QueryRangeMetricsV2(w http.ResponseWriter, r *http.Request
 			}
 
 			queries[name] = query.String()
+			if apiErr := queryguard.Check(queries[name], constants.RawQueryAllowedDatabases); apiErr != nil {
+				RespondError(w, apiErr, nil)
+				return
+			}
 		}
+		ctx = queryguard.WithLimits(ctx)
 		seriesList, err, errQuriesByName = execClickHouseQueries(queries)
 	case model.PROM:
 		seriesList, err, errQuriesByName = execPromQueries(metricsQueryRangeParams)
@@ -660,6 +708,48 @@ func (aH *APIHandler) QueryRangeMetricsV2(w http.ResponseWriter, r *http.Request
 	aH.Respond(w, resp)
 }
 
+func (aH *APIHandler) getRuleStateTimeline(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	start, end, err := parseRuleStateHistoryRequest(r)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	timeline, err := aH.ruleManager.GetRuleStateTimeline(r.Context(), id, start, end)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, timeline)
+}
+
+func (aH *APIHandler) getSLOErrorBudget(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	budget, apiErr := aH.ruleManager.GetSLOErrorBudget(r.Context(), id)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	aH.Respond(w, budget)
+}
+
+func (aH *APIHandler) getFiringAlertsHistory(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parseRuleStateHistoryRequest(r)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	alerts, err := aH.ruleManager.GetFiringAlerts(r.Context(), start, end)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorInternal, Err: err}, nil)
+		return
+	}
+	aH.Respond(w, alerts)
+}
+
 func (aH *APIHandler) listRules(w http.ResponseWriter, r *http.Request) {
 
 	rules, err := aH.ruleManager.ListRuleStates()
@@ -744,11 +834,11 @@ func (aH *APIHandler) queryDashboardVars(w http.ResponseWriter, r *http.Request)
 		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("query is required")}, nil)
 		return
 	}
-	if strings.Contains(strings.ToLower(query), "alter table") {
-		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("query shouldn't alter data")}, nil)
+	if apiErr := queryguard.Check(query, constants.RawQueryAllowedDatabases); apiErr != nil {
+		RespondError(w, apiErr, nil)
 		return
 	}
-	dashboardVars, err := aH.reader.QueryDashboardVars(r.Context(), query)
+	dashboardVars, err := aH.reader.QueryDashboardVars(queryguard.WithLimits(r.Context()), query)
 	if err != nil {
 		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
 		return
@@ -807,8 +897,12 @@ func (aH *APIHandler) queryDashboardVarsV2(w http.ResponseWriter, r *http.Reques
 		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
 		return
 	}
+	if apiErr := queryguard.Check(query, constants.RawQueryAllowedDatabases); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
 
-	dashboardVars, err := aH.reader.QueryDashboardVars(r.Context(), query)
+	dashboardVars, err := aH.reader.QueryDashboardVars(queryguard.WithLimits(r.Context()), query)
 	if err != nil {
 		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
 		return
@@ -1003,6 +1097,26 @@ func (aH *APIHandler) editRule(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// importRules imports the rule groups of a Prometheus rule file (yaml)
+func (aH *APIHandler) importRules(w http.ResponseWriter, r *http.Request) {
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, constants.MaxRuleImportSize))
+	if err != nil {
+		zap.S().Errorf("msg: error in getting req body of import rules API\n", "\t error:", err)
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	result, apiErr := aH.ruleManager.ImportPromRules(body)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	aH.Respond(w, result)
+}
+
 func (aH *APIHandler) getChannel(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 	channel, apiErrorObj := aH.reader.GetChannel(id)
@@ -1406,6 +1520,140 @@ func (aH *APIHandler) SearchTraces(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// getTraceAnalysis returns the critical path of the trace and the self time
+// of its spans, services and operations
+func (aH *APIHandler) getTraceAnalysis(w http.ResponseWriter, r *http.Request) {
+	traceID := mux.Vars(r)["traceId"]
+
+	spans, apiErr := aH.reader.GetTraceSpans(r.Context(), traceID)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	analysis, err := traces.AnalyzeTrace(traceID, spans)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, analysis)
+}
+
+// exportTrace downloads the trace as OTLP JSON or as the JSON of the Jaeger UI
+func (aH *APIHandler) exportTrace(w http.ResponseWriter, r *http.Request) {
+	traceID := mux.Vars(r)["traceId"]
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = traces.TraceFormatOTLP
+	}
+	if format != traces.TraceFormatOTLP && format != traces.TraceFormatJaeger {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("unsupported trace format %s", format)}, nil)
+		return
+	}
+
+	spans, apiErr := aH.reader.GetTraceSpans(r.Context(), traceID)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	var result interface{}
+	if format == traces.TraceFormatJaeger {
+		result = traces.ExportJaeger(traceID, spans)
+	} else {
+		result = traces.ExportOTLP(spans)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"trace-%s-%s.json\"", traceID, format))
+	aH.WriteJSON(w, r, result)
+}
+
+// importTraces writes the traces of an OTLP JSON or a Jaeger JSON file, the
+// format is detected from the file when it is not given
+func (aH *APIHandler) importTraces(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, constants.MaxTraceImportSize))
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	spans, err := traces.ImportTraces(r.URL.Query().Get("format"), body)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	if apiErr := aH.reader.ImportTraceSpans(r.Context(), spans); apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	traceIDs := map[string]bool{}
+	for _, span := range spans {
+		traceIDs[span.Span.TraceID] = true
+	}
+	aH.Respond(w, model.ImportTracesResponse{TraceCount: len(traceIDs), SpanCount: len(spans)})
+}
+
+// getTraceDiff compares the span tree of the trace with the one of the
+// target trace
+func (aH *APIHandler) getTraceDiff(w http.ResponseWriter, r *http.Request) {
+	baseTraceID := mux.Vars(r)["traceId"]
+	targetTraceID := mux.Vars(r)["targetTraceId"]
+
+	baseSpans, apiErr := aH.reader.GetTraceSpans(r.Context(), baseTraceID)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	targetSpans, apiErr := aH.reader.GetTraceSpans(r.Context(), targetTraceID)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	diff, err := traces.DiffTraces(baseTraceID, baseSpans, targetTraceID, targetSpans)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	aH.Respond(w, diff)
+}
+
+// getTraceWindowsDiff compares the latency of the operations of the traces
+// of a root operation in two time windows
+func (aH *APIHandler) getTraceWindowsDiff(w http.ResponseWriter, r *http.Request) {
+	params, err := parseTraceWindowsDiffRequest(r)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	base, apiErr := aH.reader.GetOperationWindowStats(r.Context(), &model.OperationWindowStatsParams{
+		ServiceName: params.ServiceName,
+		Operation:   params.Operation,
+		Start:       params.BaseStart,
+		End:         params.BaseEnd,
+	})
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	target, apiErr := aH.reader.GetOperationWindowStats(r.Context(), &model.OperationWindowStatsParams{
+		ServiceName: params.ServiceName,
+		Operation:   params.Operation,
+		Start:       params.TargetStart,
+		End:         params.TargetEnd,
+	})
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	aH.Respond(w, traces.CompareOperationWindows(params.ServiceName, params.Operation, base, target, params.Limit))
+}
+
 func (aH *APIHandler) listErrors(w http.ResponseWriter, r *http.Request) {
 
 	query, err := parseListErrorsRequest(r)
@@ -2175,6 +2423,13 @@ func (aH *APIHandler) RegisterLogsRoutes(router *mux.Router, am *AuthMiddleware)
 	subRouter.HandleFunc("/fields", am.ViewAccess(aH.logFields)).Methods(http.MethodGet)
 	subRouter.HandleFunc("/fields", am.EditAccess(aH.logFieldUpdate)).Methods(http.MethodPost)
 	subRouter.HandleFunc("/aggregate", am.ViewAccess(aH.logAggregate)).Methods(http.MethodGet)
+	subRouter.HandleFunc("/{logId}/trace", am.ViewAccess(aH.getLogTrace)).Methods(http.MethodGet)
+
+	// log pipelines
+	subRouter.HandleFunc("/pipelines/preview", am.EditAccess(aH.previewLogsPipelines)).Methods(http.MethodPost)
+	subRouter.HandleFunc("/pipelines/{version}", am.ViewAccess(aH.listLogsPipelinesHandler)).Methods(http.MethodGet)
+	subRouter.HandleFunc("/pipelines", am.EditAccess(aH.createLogsPipeline)).Methods(http.MethodPost)
+	subRouter.HandleFunc("/pipelines/{version}/redeploy", am.EditAccess(aH.redeployLogsPipelines)).Methods(http.MethodPost)
 }
 
 func (aH *APIHandler) logFields(w http.ResponseWriter, r *http.Request) {
@@ -2286,6 +2541,290 @@ func (aH *APIHandler) logAggregate(w http.ResponseWriter, r *http.Request) {
 	aH.WriteJSON(w, r, res)
 }
 
+// parsePipelinesVersion returns the version in the path, -1 for latest
+func parsePipelinesVersion(r *http.Request) (int, error) {
+	versionString := mux.Vars(r)["version"]
+	if versionString == "latest" {
+		return -1, nil
+	}
+	version, err := strconv.Atoi(versionString)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("version must be latest or a positive number, got %s", versionString)
+	}
+	return version, nil
+}
+
+func (aH *APIHandler) listLogsPipelinesHandler(w http.ResponseWriter, r *http.Request) {
+	if aH.LogsParsingPipelineController == nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("logs pipelines are not available")}, nil)
+		return
+	}
+	version, err := parsePipelinesVersion(r)
+	if err != nil {
+		RespondError(w, model.BadRequest(err), nil)
+		return
+	}
+
+	var payload *logparsingpipeline.PipelinesResponse
+	var apiErr *model.ApiError
+	if version == -1 {
+		payload, apiErr = aH.LogsParsingPipelineController.GetLatestPipelines(r.Context())
+	} else {
+		payload, apiErr = aH.LogsParsingPipelineController.GetPipelinesByVersion(r.Context(), version)
+	}
+	if apiErr != nil {
+		RespondError(w, apiErr, "Failed to get pipelines")
+		return
+	}
+	aH.Respond(w, payload)
+}
+
+func (aH *APIHandler) createLogsPipeline(w http.ResponseWriter, r *http.Request) {
+	if aH.LogsParsingPipelineController == nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("logs pipelines are not available")}, nil)
+		return
+	}
+	req := logparsingpipeline.PostablePipelines{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, model.BadRequest(err), nil)
+		return
+	}
+
+	var userId string
+	if user, ok := auth.GetUserFromContext(r.Context()); ok {
+		userId = user.Id
+	}
+
+	res, apiErr := aH.LogsParsingPipelineController.ApplyPipelines(r.Context(), userId, req.Pipelines)
+	if apiErr != nil {
+		RespondError(w, apiErr, "Failed to apply pipelines")
+		return
+	}
+	aH.Respond(w, res)
+}
+
+const (
+	defaultPreviewLogsLimit = 10
+	maxPreviewLogsLimit     = 100
+)
+
+const (
+	defaultTraceLogsLimit = 1000
+	maxTraceLogsLimit     = 10000
+)
+
+// logColumnFilter returns the filter of the logs whose column has the value
+func logColumnFilter(column, value string) *v3.FilterSet {
+	return &v3.FilterSet{
+		Operator: "AND",
+		Items: []v3.FilterItem{{
+			Key:      v3.AttributeKey{Key: column, DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true},
+			Operator: v3.FilterOperatorEqual,
+			Value:    value,
+		}},
+	}
+}
+
+// getTraceLogs returns the logs of the trace grouped by span, the logs are
+// looked for around the spans of the trace unless a time range is given
+func (aH *APIHandler) getTraceLogs(w http.ResponseWriter, r *http.Request) {
+	traceID := mux.Vars(r)["traceId"]
+
+	start, end, limit, err := parseTraceLogsRequest(r)
+	if err != nil {
+		RespondError(w, model.BadRequest(err), nil)
+		return
+	}
+
+	spans, apiErr := aH.reader.GetTraceSpans(r.Context(), traceID)
+	if apiErr != nil && (apiErr.Typ != model.ErrorNotFound || start == 0) {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	if start == 0 {
+		start, end = traces.LogsTimeRange(spans)
+	}
+
+	logs, apiErr := aH.listLogs(r.Context(), start, end, logColumnFilter("trace_id", traceID), limit, "asc")
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	aH.Respond(w, traces.GroupLogsBySpan(traceID, spans, logs))
+}
+
+// getLogTrace returns the summary of the trace of the log and the span it
+// was written in, the log is looked for in the last day unless a time range
+// is given
+func (aH *APIHandler) getLogTrace(w http.ResponseWriter, r *http.Request) {
+	logID := mux.Vars(r)["logId"]
+
+	start, end, err := parseOptionalTimeRange(r)
+	if err != nil {
+		RespondError(w, model.BadRequest(err), nil)
+		return
+	}
+	if start == 0 {
+		end = time.Now().UnixMilli()
+		start = end - (24 * time.Hour).Milliseconds()
+	}
+
+	logs, apiErr := aH.listLogs(r.Context(), start, end, logColumnFilter("id", logID), 1, "desc")
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	if len(logs) == 0 {
+		RespondError(w, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("log %s not found", logID)}, nil)
+		return
+	}
+	log := logs[0]
+	if log.TraceID == "" {
+		RespondError(w, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("log %s has no trace", logID)}, nil)
+		return
+	}
+
+	spans, apiErr := aH.reader.GetTraceSpans(r.Context(), log.TraceID)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	summary, err := traces.SummarizeTrace(log.TraceID, spans)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+
+	result := model.LogTrace{Log: log, Trace: summary}
+	for i := range spans {
+		if log.SpanID != "" && spans[i].SpanID == log.SpanID {
+			result.Span = &spans[i]
+			break
+		}
+	}
+	aH.Respond(w, result)
+}
+
+// getPreviewSampleLogs fetches the recent logs matching the filter of the
+// preview request
+func (aH *APIHandler) getPreviewSampleLogs(ctx context.Context, req *logparsingpipeline.PipelinesPreviewRequest) ([]model.GetLogsResponse, *model.ApiError) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultPreviewLogsLimit
+	}
+	if limit > maxPreviewLogsLimit {
+		return nil, model.BadRequest(fmt.Errorf("limit of sample logs can be at most %d", maxPreviewLogsLimit))
+	}
+	end := req.End
+	if end == 0 {
+		end = time.Now().UnixMilli()
+	}
+	start := req.Start
+	if start == 0 {
+		start = end - (15 * time.Minute).Milliseconds()
+	}
+	if start >= end {
+		return nil, model.BadRequestStr("start must be before end")
+	}
+
+	return aH.listLogs(ctx, start, end, req.Filter, limit, "desc")
+}
+
+// listLogs fetches the logs matching the filter with the logs query builder,
+// the logs are ordered by their timestamp
+func (aH *APIHandler) listLogs(ctx context.Context, start, end int64, filters *v3.FilterSet, limit uint64, order string) ([]model.GetLogsResponse, *model.ApiError) {
+	params := &v3.QueryRangeParamsV3{
+		Start: start,
+		End:   end,
+		Step:  60,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeList,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:         "A",
+					DataSource:        v3.DataSourceLogs,
+					AggregateOperator: v3.AggregateOperatorNoOp,
+					Expression:        "A",
+					StepInterval:      60,
+					Filters:           filters,
+					Limit:             limit,
+					OrderBy:           []v3.OrderBy{{ColumnName: "timestamp", Order: order}},
+				},
+			},
+		},
+	}
+	if err := params.CompositeQuery.Validate(); err != nil {
+		return nil, model.BadRequest(err)
+	}
+	if logsv3.EnrichmentRequired(params) {
+		fields, err := aH.getLogFieldsV3(ctx, params)
+		if err != nil {
+			return nil, model.InternalError(err)
+		}
+		logsv3.Enrich(params, fields)
+	}
+
+	query, err := logsv3.PrepareLogsQuery(start, end, v3.QueryTypeBuilder, v3.PanelTypeList, params.CompositeQuery.BuilderQueries["A"])
+	if err != nil {
+		return nil, model.BadRequest(err)
+	}
+	rows, err := aH.reader.GetListResultV3(ctx, query)
+	if err != nil {
+		return nil, model.InternalError(err)
+	}
+	return logparsingpipeline.LogsFromListRows(rows), nil
+}
+
+func (aH *APIHandler) previewLogsPipelines(w http.ResponseWriter, r *http.Request) {
+	req := logparsingpipeline.PipelinesPreviewRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, model.BadRequest(err), nil)
+		return
+	}
+
+	logs := req.Logs
+	if len(logs) == 0 {
+		if req.Filter == nil {
+			RespondError(w, model.BadRequestStr("either logs or a filter to fetch sample logs is required"), nil)
+			return
+		}
+		var apiErr *model.ApiError
+		logs, apiErr = aH.getPreviewSampleLogs(r.Context(), &req)
+		if apiErr != nil {
+			RespondError(w, apiErr, "Failed to fetch sample logs")
+			return
+		}
+	}
+
+	res, apiErr := logparsingpipeline.PreviewPipelines(req.Pipelines, logs)
+	if apiErr != nil {
+		RespondError(w, apiErr, "Failed to preview pipelines")
+		return
+	}
+	aH.Respond(w, res)
+}
+
+func (aH *APIHandler) redeployLogsPipelines(w http.ResponseWriter, r *http.Request) {
+	if aH.LogsParsingPipelineController == nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorUnavailable, Err: fmt.Errorf("logs pipelines are not available")}, nil)
+		return
+	}
+	version, err := parsePipelinesVersion(r)
+	if err != nil || version == -1 {
+		RespondError(w, model.BadRequestStr("version to redeploy must be a positive number"), nil)
+		return
+	}
+
+	res, apiErr := aH.LogsParsingPipelineController.RedeployPipelines(r.Context(), version)
+	if apiErr != nil {
+		RespondError(w, apiErr, "Failed to redeploy pipelines")
+		return
+	}
+	aH.Respond(w, res)
+}
+
 func (aH *APIHandler) getExplorerQueries(w http.ResponseWriter, r *http.Request) {
 	queries, err := explorer.GetQueries()
 	if err != nil {
@@ -2449,184 +2988,6 @@ func (aH *APIHandler) autoCompleteAttributeValues(w http.ResponseWriter, r *http
 	aH.Respond(w, response)
 }
 
-func (aH *APIHandler) execClickHouseGraphQueries(ctx context.Context, queries map[string]string) ([]*v3.Result, error, map[string]string) {
-	type channelResult struct {
-		Series []*v3.Series
-		Err    error
-		Name   string
-		Query  string
-	}
-
-	ch := make(chan channelResult, len(queries))
-	var wg sync.WaitGroup
-
-	for name, query := range queries {
-		wg.Add(1)
-		go func(name, query string) {
-			defer wg.Done()
-			seriesList, err := aH.reader.GetTimeSeriesResultV3(ctx, query)
-
-			if err != nil {
-				ch <- channelResult{Err: fmt.Errorf("error in query-%s: %v", name, err), Name: name, Query: query}
-				return
-			}
-			ch <- channelResult{Series: seriesList, Name: name, Query: query}
-		}(name, query)
-	}
-
-	wg.Wait()
-	close(ch)
-
-	var errs []error
-	errQuriesByName := make(map[string]string)
-	res := make([]*v3.Result, 0)
-	// read values from the channel
-	for r := range ch {
-		if r.Err != nil {
-			errs = append(errs, r.Err)
-			errQuriesByName[r.Name] = r.Query
-			continue
-		}
-		res = append(res, &v3.Result{
-			QueryName: r.Name,
-			Series:    r.Series,
-		})
-	}
-	if len(errs) != 0 {
-		return nil, fmt.Errorf("encountered multiple errors: %s", multierr.Combine(errs...)), errQuriesByName
-	}
-	return res, nil, nil
-}
-
-func (aH *APIHandler) execClickHouseListQueries(ctx context.Context, queries map[string]string) ([]*v3.Result, error, map[string]string) {
-	type channelResult struct {
-		List  []*v3.Row
-		Err   error
-		Name  string
-		Query string
-	}
-
-	ch := make(chan channelResult, len(queries))
-	var wg sync.WaitGroup
-
-	for name, query := range queries {
-		wg.Add(1)
-		go func(name, query string) {
-			defer wg.Done()
-			rowList, err := aH.reader.GetListResultV3(ctx, query)
-
-			if err != nil {
-				ch <- channelResult{Err: fmt.Errorf("error in query-%s: %v", name, err), Name: name, Query: query}
-				return
-			}
-			ch <- channelResult{List: rowList, Name: name, Query: query}
-		}(name, query)
-	}
-
-	wg.Wait()
-	close(ch)
-
-	var errs []error
-	errQuriesByName := make(map[string]string)
-	res := make([]*v3.Result, 0)
-	// read values from the channel
-	for r := range ch {
-		if r.Err != nil {
-			errs = append(errs, r.Err)
-			errQuriesByName[r.Name] = r.Query
-			continue
-		}
-		res = append(res, &v3.Result{
-			QueryName: r.Name,
-			List:      r.List,
-		})
-	}
-	if len(errs) != 0 {
-		return nil, fmt.Errorf("encountered multiple errors: %s", multierr.Combine(errs...)), errQuriesByName
-	}
-	return res, nil, nil
-}
-
-func (aH *APIHandler) execPromQueries(ctx context.Context, metricsQueryRangeParams *v3.QueryRangeParamsV3) ([]*v3.Result, error, map[string]string) {
-	type channelResult struct {
-		Series []*v3.Series
-		Err    error
-		Name   string
-		Query  string
-	}
-
-	ch := make(chan channelResult, len(metricsQueryRangeParams.CompositeQuery.PromQueries))
-	var wg sync.WaitGroup
-
-	for name, query := range metricsQueryRangeParams.CompositeQuery.PromQueries {
-		if query.Disabled {
-			continue
-		}
-		wg.Add(1)
-		go func(name string, query *v3.PromQuery) {
-			var seriesList []*v3.Series
-			defer wg.Done()
-			tmpl := template.New("promql-query")
-			tmpl, tmplErr := tmpl.Parse(query.Query)
-			if tmplErr != nil {
-				ch <- channelResult{Err: fmt.Errorf("error in parsing query-%s: %v", name, tmplErr), Name: name, Query: query.Query}
-				return
-			}
-			var queryBuf bytes.Buffer
-			tmplErr = tmpl.Execute(&queryBuf, metricsQueryRangeParams.Variables)
-			if tmplErr != nil {
-				ch <- channelResult{Err: fmt.Errorf("error in parsing query-%s: %v", name, tmplErr), Name: name, Query: query.Query}
-				return
-			}
-			query.Query = queryBuf.String()
-			queryModel := model.QueryRangeParams{
-				Start: time.UnixMilli(metricsQueryRangeParams.Start),
-				End:   time.UnixMilli(metricsQueryRangeParams.End),
-				Step:  time.Duration(metricsQueryRangeParams.Step * int64(time.Second)),
-				Query: query.Query,
-			}
-			promResult, _, err := aH.reader.GetQueryRangeResult(ctx, &queryModel)
-			if err != nil {
-				ch <- channelResult{Err: fmt.Errorf("error in query-%s: %v", name, err), Name: name, Query: query.Query}
-				return
-			}
-			matrix, _ := promResult.Matrix()
-			for _, v := range matrix {
-				var s v3.Series
-				s.Labels = v.Metric.Copy().Map()
-				for _, p := range v.Points {
-					s.Points = append(s.Points, v3.Point{Timestamp: p.T, Value: p.V})
-				}
-				seriesList = append(seriesList, &s)
-			}
-			ch <- channelResult{Series: seriesList, Name: name, Query: query.Query}
-		}(name, query)
-	}
-
-	wg.Wait()
-	close(ch)
-
-	var errs []error
-	errQuriesByName := make(map[string]string)
-	res := make([]*v3.Result, 0)
-	// read values from the channel
-	for r := range ch {
-		if r.Err != nil {
-			errs = append(errs, r.Err)
-			errQuriesByName[r.Name] = r.Query
-			continue
-		}
-		res = append(res, &v3.Result{
-			QueryName: r.Name,
-			Series:    r.Series,
-		})
-	}
-	if len(errs) != 0 {
-		return nil, fmt.Errorf("encountered multiple errors: %s", multierr.Combine(errs...)), errQuriesByName
-	}
-	return res, nil, nil
-}
-
 func (aH *APIHandler) getLogFieldsV3(ctx context.Context, queryRangeParams *v3.QueryRangeParamsV3) (map[string]v3.AttributeKey, error) {
 	data := map[string]v3.AttributeKey{}
 	for _, query := range queryRangeParams.CompositeQuery.BuilderQueries {
@@ -2700,7 +3061,6 @@ func (aH *APIHandler) queryRangeV3(ctx context.Context, queryRangeParams *v3.Que
 	var result []*v3.Result
 	var err error
 	var errQuriesByName map[string]string
-	var queries map[string]string
 	switch queryRangeParams.CompositeQuery.QueryType {
 	case v3.QueryTypeBuilder:
 		// check if any enrichment is required for logs if yes then enrich them
@@ -2724,28 +3084,20 @@ func (aH *APIHandler) queryRangeV3(ctx context.Context, queryRangeParams *v3.Que
 			return
 		}
 
-		queries, err = aH.queryBuilder.PrepareQueries(queryRangeParams, spanKeys)
-		if err != nil {
-			RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
-			return
-		}
-
-		if queryRangeParams.CompositeQuery.PanelType == v3.PanelTypeList || queryRangeParams.CompositeQuery.PanelType == v3.PanelTypeTrace {
-			result, err, errQuriesByName = aH.execClickHouseListQueries(r.Context(), queries)
-		} else {
-			result, err, errQuriesByName = aH.execClickHouseGraphQueries(r.Context(), queries)
-		}
+		result, err, errQuriesByName = aH.querier.QueryRange(ctx, queryRangeParams, spanKeys)
 	case v3.QueryTypeClickHouseSQL:
-		queries := make(map[string]string)
-		for name, query := range queryRangeParams.CompositeQuery.ClickHouseQueries {
-			if query.Disabled {
+		for _, chQuery := range queryRangeParams.CompositeQuery.ClickHouseQueries {
+			if chQuery.Disabled {
 				continue
 			}
-			queries[name] = query.Query
+			if apiErr := queryguard.Check(chQuery.Query, constants.RawQueryAllowedDatabases); apiErr != nil {
+				RespondError(w, apiErr, nil)
+				return
+			}
 		}
-		result, err, errQuriesByName = aH.execClickHouseGraphQueries(r.Context(), queries)
+		result, err, errQuriesByName = aH.querier.QueryRange(queryguard.WithLimits(ctx), queryRangeParams, nil)
 	case v3.QueryTypePromQL:
-		result, err, errQuriesByName = aH.execPromQueries(r.Context(), queryRangeParams)
+		result, err, errQuriesByName = aH.querier.QueryRange(ctx, queryRangeParams, nil)
 	default:
 		err = fmt.Errorf("invalid query type")
 		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, errQuriesByName)
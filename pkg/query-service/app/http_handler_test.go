@@ -8,6 +8,7 @@ import (
 	"strings"
 	"testing"
 
+	"go.signoz.io/signoz/pkg/query-service/constants"
 	"go.signoz.io/signoz/pkg/query-service/model"
 )
 
@@ -130,3 +131,17 @@ func TestPrepareQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestImportRulesBodyLimit(t *testing.T) {
+	defer func(size int64) { constants.MaxRuleImportSize = size }(constants.MaxRuleImportSize)
+	constants.MaxRuleImportSize = 16
+
+	aH := &APIHandler{}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rules/import", strings.NewReader("groups:\n  - name: api\n"))
+	w := httptest.NewRecorder()
+	aH.importRules(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected the oversized file to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,136 @@
+package logparsingpipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.signoz.io/signoz/pkg/query-service/agentConf"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+)
+
+// historyLimit is the number of past versions returned with the pipelines
+const historyLimit = 10
+
+// Controller takes care of deployment cycle of log parsing pipelines.
+type LogParsingPipelineController struct {
+	Repo
+}
+
+func NewLogParsingPipelinesController(db *sqlx.DB, engine string) (*LogParsingPipelineController, error) {
+	repo := NewRepo(db)
+	err := repo.InitDB(engine)
+	return &LogParsingPipelineController{Repo: repo}, err
+}
+
+// PipelinesResponse is used to prepare http response for pipelines config related requests
+type PipelinesResponse struct {
+	*agentConf.ConfigVersion
+
+	Pipelines []model.Pipeline          `json:"pipelines"`
+	History   []agentConf.ConfigVersion `json:"history"`
+}
+
+// ApplyPipelines stores the given pipelines as a new version and deploys it
+// to the collectors
+func (ic *LogParsingPipelineController) ApplyPipelines(ctx context.Context, userId string, postable []PostablePipeline) (*PipelinesResponse, *model.ApiError) {
+	if len(postable) == 0 {
+		return nil, model.BadRequestStr("at least one pipeline is required, disable the pipelines to stop processing logs")
+	}
+	if err := validatePipelines(postable); err != nil {
+		return nil, model.BadRequest(err)
+	}
+
+	if !agentConf.Ready() {
+		return nil, model.BadRequest(fmt.Errorf("agent updater unavailable at the moment. Please try in sometime"))
+	}
+
+	var pipelines []model.Pipeline
+	for i := range postable {
+		// every version gets its own copy of the pipelines so that the
+		// older versions can be viewed and redeployed as they were
+		pipeline, apiErr := newPipeline(&postable[i], userId)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		pipelines = append(pipelines, *pipeline)
+	}
+
+	// the processors are prepared before saving so that a version that can
+	// not be deployed is never saved
+	processors, names, err := PreparePipelineProcessor(pipelines)
+	if err != nil {
+		zap.S().Errorf("failed to generate processor config from pipelines for deployment %s", err.Error())
+		return nil, model.BadRequest(errors.Wrap(err, "failed to generate processor config from pipelines for deployment"))
+	}
+
+	cfg, apiErr := ic.insertVersion(ctx, userId, pipelines)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// send the changes to opamp.
+	if err := agentConf.UpsertLogParsingProcessor(ctx, cfg.Version, processors, names); err != nil {
+		zap.S().Errorf("failed to call agent config update for log parsing processor %s", err.Error())
+		return nil, model.InternalError(errors.Wrapf(err, "version %d is saved but could not be deployed", cfg.Version))
+	}
+
+	return ic.GetPipelinesByVersion(ctx, cfg.Version)
+}
+
+// GetPipelinesByVersion responds with version info and associated pipelines
+func (ic *LogParsingPipelineController) GetPipelinesByVersion(ctx context.Context, version int) (*PipelinesResponse, *model.ApiError) {
+	pipelines, errs := ic.getPipelinesByVersion(ctx, version)
+	if len(errs) > 0 {
+		zap.S().Errorf("failed to get pipelines for version %d, %v", version, errs)
+		return nil, model.InternalError(fmt.Errorf("failed to get pipelines for given version"))
+	}
+
+	configVersion, err := agentConf.GetConfigVersion(ctx, agentConf.ElementTypeLogPipelines, version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &model.ApiError{Typ: model.ErrorNotFound, Err: fmt.Errorf("version %d of the pipelines not found", version)}
+		}
+		zap.S().Errorf("failed to get config for version %d, %s", version, err.Error())
+		return nil, model.InternalError(fmt.Errorf("failed to get config for given version"))
+	}
+
+	history, err := agentConf.GetConfigHistory(ctx, agentConf.ElementTypeLogPipelines, historyLimit)
+	if err != nil {
+		zap.S().Errorf("failed to get config history for pipelines, %s", err.Error())
+		return nil, model.InternalError(fmt.Errorf("failed to get config history"))
+	}
+
+	return &PipelinesResponse{
+		ConfigVersion: configVersion,
+		Pipelines:     pipelines,
+		History:       history,
+	}, nil
+}
+
+// GetLatestPipelines responds with the latest version of the pipelines, the
+// response is empty when no pipelines have been saved yet
+func (ic *LogParsingPipelineController) GetLatestPipelines(ctx context.Context) (*PipelinesResponse, *model.ApiError) {
+	configVersion, err := agentConf.GetLatestVersion(ctx, agentConf.ElementTypeLogPipelines)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &PipelinesResponse{Pipelines: []model.Pipeline{}, History: []agentConf.ConfigVersion{}}, nil
+		}
+		return nil, model.InternalError(fmt.Errorf("failed to get latest config version"))
+	}
+	return ic.GetPipelinesByVersion(ctx, configVersion.Version)
+}
+
+// RedeployPipelines deploys an older version of the pipelines again
+func (ic *LogParsingPipelineController) RedeployPipelines(ctx context.Context, version int) (*PipelinesResponse, *model.ApiError) {
+	if _, apiErr := ic.GetPipelinesByVersion(ctx, version); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := agentConf.Redeploy(ctx, agentConf.ElementTypeLogPipelines, version); err != nil {
+		return nil, model.BadRequest(err)
+	}
+	return ic.GetPipelinesByVersion(ctx, version)
+}
@@ -0,0 +1,154 @@
+package logparsingpipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.signoz.io/signoz/pkg/query-service/agentConf"
+	"go.signoz.io/signoz/pkg/query-service/app/logparsingpipeline/sqlite"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	"go.uber.org/zap"
+)
+
+// Repo handles DDL and DML ops on logs pipelines
+type Repo struct {
+	db *sqlx.DB
+}
+
+// NewRepo initiates a new logs pipelines repo
+func NewRepo(db *sqlx.DB) Repo {
+	return Repo{
+		db: db,
+	}
+}
+
+func (r *Repo) InitDB(engine string) error {
+	switch engine {
+	case "sqlite3", "sqlite":
+		return sqlite.InitDB(r.db)
+	default:
+		return fmt.Errorf("unsupported db")
+	}
+}
+
+// newPipeline returns the copy of the given pipeline stored with a version
+func newPipeline(postable *PostablePipeline, userId string) (*model.Pipeline, *model.ApiError) {
+	rawConfig, err := json.Marshal(postable.Config)
+	if err != nil {
+		return nil, model.BadRequest(errors.Wrap(err, "failed to unmarshal postable pipeline config"))
+	}
+
+	return &model.Pipeline{
+		Id:          uuid.New().String(),
+		OrderId:     postable.OrderId,
+		Enabled:     postable.Enabled,
+		Name:        postable.Name,
+		Alias:       postable.Alias,
+		Description: &postable.Description,
+		Filter:      postable.Filter,
+		Config:      postable.Config,
+		RawConfig:   string(rawConfig),
+		Creator: model.Creator{
+			CreatedBy: userId,
+			CreatedAt: time.Now(),
+		},
+	}, nil
+}
+
+// insertVersion stores the pipelines and a new version made of them in one
+// transaction, so that nothing is left behind when either fails
+func (r *Repo) insertVersion(ctx context.Context, userId string, pipelines []model.Pipeline) (*agentConf.ConfigVersion, *model.ApiError) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, model.InternalError(errors.Wrap(err, "failed to start a transaction"))
+	}
+	defer tx.Rollback()
+
+	var elements []string
+	for i := range pipelines {
+		if err := insertPipeline(ctx, tx, &pipelines[i]); err != nil {
+			return nil, err
+		}
+		elements = append(elements, pipelines[i].Id)
+	}
+
+	cfg, err := agentConf.StartNewVersionTx(ctx, tx, userId, agentConf.ElementTypeLogPipelines, elements)
+	if err != nil || cfg == nil {
+		return nil, model.InternalError(errors.Wrap(err, "failed to save the pipelines version"))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, model.InternalError(errors.Wrap(err, "failed to save the pipelines version"))
+	}
+	return cfg, nil
+}
+
+// insertPipeline stores a copy of the given pipeline with tx
+func insertPipeline(ctx context.Context, tx *sqlx.Tx, pipeline *model.Pipeline) *model.ApiError {
+	insertQuery := `INSERT INTO pipelines
+	(id, order_id, enabled, created_by, created_at, name, alias, description, filter, config_json)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := tx.ExecContext(ctx,
+		insertQuery,
+		pipeline.Id,
+		pipeline.OrderId,
+		pipeline.Enabled,
+		pipeline.Creator.CreatedBy,
+		pipeline.Creator.CreatedAt,
+		pipeline.Name,
+		pipeline.Alias,
+		pipeline.Description,
+		pipeline.Filter,
+		pipeline.RawConfig)
+
+	if err != nil {
+		zap.S().Errorf("error in inserting pipeline data: ", zap.Error(err))
+		return model.InternalError(errors.Wrap(err, "failed to insert pipeline"))
+	}
+	return nil
+}
+
+// getPipelinesByVersion returns the pipelines of the given version in the
+// order they are applied
+func (r *Repo) getPipelinesByVersion(ctx context.Context, version int) ([]model.Pipeline, []error) {
+	var errs []error
+	pipelines := []model.Pipeline{}
+
+	versionQuery := `SELECT r.id,
+		r.name,
+		r.config_json,
+		r.alias,
+		r.description,
+		r.filter,
+		r.order_id,
+		COALESCE(r.created_by, '') as created_by,
+		r.created_at,
+		r.enabled
+		FROM pipelines r,
+			 agent_config_elements e,
+			 agent_config_versions v
+		WHERE r.id = e.element_id
+		AND v.id = e.version_id
+		AND e.element_type = $1
+		AND v.version = $2
+		ORDER BY order_id asc`
+
+	err := r.db.SelectContext(ctx, &pipelines, versionQuery, agentConf.ElementTypeLogPipelines, version)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to get pipelines from db: %v", err)}
+	}
+
+	for i := range pipelines {
+		if err := pipelines[i].ParseRawConfig(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return pipelines, errs
+}
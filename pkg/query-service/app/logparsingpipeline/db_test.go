@@ -0,0 +1,55 @@
+package logparsingpipeline
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.signoz.io/signoz/pkg/query-service/agentConf"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func TestInsertVersionSavesNothingOnError(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "signoz.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	controller, err := NewLogParsingPipelinesController(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := agentConf.Initiate(db, "sqlite"); err != nil {
+		t.Fatal(err)
+	}
+
+	pipeline, apiErr := newPipeline(&PostablePipeline{
+		OrderId: 1,
+		Name:    "pipeline 1",
+		Alias:   "pipeline1",
+		Enabled: true,
+		Filter:  `attributes.method == "GET"`,
+		Config: []model.PipelineOperator{
+			{OrderId: 1, ID: "move", Type: "move", From: "attributes.method", To: "attributes.http_method", Enabled: true},
+		},
+	}, "user")
+	if apiErr != nil {
+		t.Fatal(apiErr.Err)
+	}
+
+	Convey("The pipelines are not saved without their version", t, func() {
+		// the version can not be started without connected agents
+		_, apiErr := controller.insertVersion(context.Background(), "user", []model.Pipeline{*pipeline})
+		So(apiErr, ShouldNotBeNil)
+
+		var count int
+		So(db.Get(&count, `SELECT count(*) FROM pipelines`), ShouldBeNil)
+		So(count, ShouldEqual, 0)
+		So(db.Get(&count, `SELECT count(*) FROM agent_config_versions`), ShouldBeNil)
+		So(count, ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,97 @@
+package logparsingpipeline
+
+import (
+	"fmt"
+	"sort"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+const (
+	// routerOperatorId is the id of the router added in front of the
+	// operators of every pipeline to apply the filter of the pipeline
+	routerOperatorId = "router_signoz"
+	// noopOperatorId is the id of the operator every log ends in, logs not
+	// matching the filter of the pipeline are routed to it directly
+	noopOperatorId = "noop"
+)
+
+// PreparePipelineProcessor returns the logstransform processors for the
+// enabled pipelines along with the processor names in the order they are
+// to be added to the collector logs pipeline
+func PreparePipelineProcessor(pipelines []model.Pipeline) (map[string]interface{}, []string, error) {
+	sorted := make([]model.Pipeline, len(pipelines))
+	copy(sorted, pipelines)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].OrderId < sorted[j].OrderId
+	})
+
+	processors := map[string]interface{}{}
+	names := []string{}
+	for _, v := range sorted {
+		if !v.Enabled {
+			continue
+		}
+
+		operators := getOperators(v.Config)
+		if len(operators) == 0 {
+			continue
+		}
+
+		router := model.PipelineOperator{
+			ID:   routerOperatorId,
+			Type: "router",
+			Routes: &[]model.Route{
+				{
+					Output: operators[0].ID,
+					Expr:   v.Filter,
+				},
+			},
+			Default: noopOperatorId,
+		}
+		// noop is the last operator so that the logs are not dropped
+		noop := model.PipelineOperator{
+			ID:   noopOperatorId,
+			Type: "noop",
+		}
+
+		config := append([]model.PipelineOperator{router}, operators...)
+		config = append(config, noop)
+
+		name := constants.LogsPPLPfx + v.Alias
+		if _, ok := processors[name]; ok {
+			return nil, nil, fmt.Errorf("duplicate alias %s cannot be present", v.Alias)
+		}
+		processors[name] = model.Processor{
+			Operators: config,
+		}
+		names = append(names, name)
+	}
+	return processors, names, nil
+}
+
+// getOperators returns the enabled operators in the order they are
+// applied, each one with the next as its output
+func getOperators(ops []model.PipelineOperator) []model.PipelineOperator {
+	sorted := make([]model.PipelineOperator, len(ops))
+	copy(sorted, ops)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].OrderId < sorted[j].OrderId
+	})
+
+	filteredOp := []model.PipelineOperator{}
+	for _, operator := range sorted {
+		if !operator.Enabled {
+			continue
+		}
+		if len(filteredOp) > 0 {
+			filteredOp[len(filteredOp)-1].Output = operator.ID
+		}
+		filteredOp = append(filteredOp, operator)
+	}
+	if len(filteredOp) > 0 {
+		filteredOp[len(filteredOp)-1].Output = noopOperatorId
+	}
+	return filteredOp
+}
@@ -0,0 +1,86 @@
+package logparsingpipeline
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func TestPreparePipelineProcessor(t *testing.T) {
+	pipelines := []model.Pipeline{
+		{
+			OrderId: 2,
+			Name:    "second",
+			Alias:   "second",
+			Enabled: true,
+			Filter:  `attributes.method == "GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 2, ID: "remove", Type: "remove", Field: "attributes.path", Enabled: true},
+				{OrderId: 1, ID: "move", Type: "move", From: "attributes.method", To: "attributes.http_method", Enabled: true},
+				{OrderId: 3, ID: "add", Type: "add", Field: "attributes.env", Value: "prod", Enabled: false},
+			},
+		},
+		{
+			OrderId: 1,
+			Name:    "first",
+			Alias:   "first",
+			Enabled: true,
+			Filter:  `body matches "^GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "add", Type: "add", Field: "attributes.env", Value: "prod", Enabled: true},
+			},
+		},
+		{
+			OrderId: 3,
+			Name:    "disabled",
+			Alias:   "disabled",
+			Enabled: false,
+			Filter:  `body matches "^GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "add", Type: "add", Field: "attributes.env", Value: "prod", Enabled: true},
+			},
+		},
+		{
+			OrderId: 4,
+			Name:    "no operators",
+			Alias:   "empty",
+			Enabled: true,
+			Filter:  `body matches "^GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "add", Type: "add", Field: "attributes.env", Value: "prod", Enabled: false},
+			},
+		},
+	}
+
+	Convey("PreparePipelineProcessor", t, func() {
+		processors, names, err := PreparePipelineProcessor(pipelines)
+		So(err, ShouldBeNil)
+		So(names, ShouldResemble, []string{constants.LogsPPLPfx + "first", constants.LogsPPLPfx + "second"})
+		So(len(processors), ShouldEqual, 2)
+
+		second := processors[constants.LogsPPLPfx+"second"].(model.Processor)
+		So(len(second.Operators), ShouldEqual, 4)
+
+		router := second.Operators[0]
+		So(router.Type, ShouldEqual, "router")
+		So(router.Default, ShouldEqual, noopOperatorId)
+		So(*router.Routes, ShouldResemble, []model.Route{{Output: "move", Expr: `attributes.method == "GET"`}})
+
+		So(second.Operators[1].ID, ShouldEqual, "move")
+		So(second.Operators[1].Output, ShouldEqual, "remove")
+		So(second.Operators[2].ID, ShouldEqual, "remove")
+		So(second.Operators[2].Output, ShouldEqual, noopOperatorId)
+		So(second.Operators[3].ID, ShouldEqual, noopOperatorId)
+
+		// the input is not modified
+		So(pipelines[0].Config[0].Output, ShouldEqual, "")
+	})
+	Convey("PreparePipelineProcessor with a duplicate alias", t, func() {
+		duplicate := pipelines[0]
+		duplicate.OrderId = 5
+		_, _, err := PreparePipelineProcessor(append([]model.Pipeline{duplicate}, pipelines...))
+		So(err, ShouldBeError)
+	})
+}
@@ -0,0 +1,191 @@
+package logparsingpipeline
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// PostablePipelines are a list of user defined pipelines
+type PostablePipelines struct {
+	Pipelines []PostablePipeline `json:"pipelines"`
+}
+
+// PostablePipeline captures user inputs in setting the pipeline
+type PostablePipeline struct {
+	Id          string                   `json:"id"`
+	OrderId     int                      `json:"orderId"`
+	Name        string                   `json:"name"`
+	Alias       string                   `json:"alias"`
+	Description string                   `json:"description"`
+	Enabled     bool                     `json:"enabled"`
+	Filter      string                   `json:"filter"`
+	Config      []model.PipelineOperator `json:"config"`
+}
+
+// the alias becomes part of the collector processor name
+var aliasRE = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,20}$`)
+
+// validatePipelines checks the pipelines of a version, the aliases must be
+// unique as they name the collector processors
+func validatePipelines(postable []PostablePipeline) error {
+	aliasUnique := map[string]struct{}{}
+	for _, p := range postable {
+		if err := p.IsValid(); err != nil {
+			return errors.Wrapf(err, "invalid pipeline %s", p.Name)
+		}
+		if _, ok := aliasUnique[p.Alias]; ok {
+			return fmt.Errorf("duplicate alias %s cannot be present", p.Alias)
+		}
+		aliasUnique[p.Alias] = struct{}{}
+	}
+	return nil
+}
+
+// IsValid checks if postable pipeline has all the required params
+func (p *PostablePipeline) IsValid() error {
+	if p.OrderId == 0 {
+		return fmt.Errorf("orderId with value >= 1 is required")
+	}
+	if p.Name == "" {
+		return fmt.Errorf("pipeline name is required")
+	}
+
+	if !aliasRE.MatchString(p.Alias) {
+		return fmt.Errorf("pipeline alias is required and can only contain upto 20 alphanumeric characters, '_' and '-'")
+	}
+
+	if p.Filter == "" {
+		return fmt.Errorf("filter of the pipeline is required")
+	}
+
+	// the operators are chained in the order they are listed, output of an
+	// operator is rewritten while building the collector config
+	idUnique := map[string]struct{}{}
+	for _, op := range p.Config {
+		if op.OrderId == 0 {
+			return fmt.Errorf("orderId with value >= 1 is required in operator")
+		}
+		if op.ID == "" {
+			return fmt.Errorf("id of an operator cannot be empty")
+		}
+		if op.Type == "" {
+			return fmt.Errorf("type of an operator cannot be empty")
+		}
+		if op.ID == routerOperatorId || op.ID == noopOperatorId {
+			return fmt.Errorf("id %s is reserved", op.ID)
+		}
+		if _, ok := idUnique[op.ID]; ok {
+			return fmt.Errorf("duplicate id %s cannot be present", op.ID)
+		}
+
+		err := isValidOperator(op)
+		if err != nil {
+			return err
+		}
+
+		idUnique[op.ID] = struct{}{}
+	}
+	return nil
+}
+
+func isValidOperator(op model.PipelineOperator) error {
+	switch op.Type {
+	case "json_parser":
+		if op.ParseFrom == "" || op.ParseTo == "" {
+			return fmt.Errorf("parse from and parse to of %s json operator cannot be empty", op.ID)
+		}
+	case "grok_parser":
+		if op.Pattern == "" {
+			return fmt.Errorf("pattern of %s grok operator cannot be empty", op.ID)
+		}
+	case "regex_parser":
+		if op.Regex == "" {
+			return fmt.Errorf("regex of %s regex operator cannot be empty", op.ID)
+		}
+		r, err := regexp.Compile(op.Regex)
+		if err != nil {
+			return fmt.Errorf("error compiling regex expression of %s regex operator", op.ID)
+		}
+		namedCaptureGroups := 0
+		for _, groupName := range r.SubexpNames() {
+			if groupName != "" {
+				namedCaptureGroups++
+			}
+		}
+		if namedCaptureGroups == 0 {
+			return fmt.Errorf("no capture groups in regex expression of %s regex operator", op.ID)
+		}
+	case "copy":
+		if op.From == "" || op.To == "" {
+			return fmt.Errorf("from or to of %s copy operator cannot be empty", op.ID)
+		}
+	case "move":
+		if op.From == "" || op.To == "" {
+			return fmt.Errorf("from or to of %s move operator cannot be empty", op.ID)
+		}
+	case "add":
+		if op.Field == "" || op.Value == "" {
+			return fmt.Errorf("field or value of %s add operator cannot be empty", op.ID)
+		}
+	case "remove":
+		if op.Field == "" {
+			return fmt.Errorf("field of %s remove operator cannot be empty", op.ID)
+		}
+	case "trace_parser":
+		if op.TraceParser == nil {
+			return fmt.Errorf("trace parser of %s trace_parser operator cannot be empty", op.ID)
+		}
+		parseFrom := func(p *model.ParseFrom) string {
+			if p == nil {
+				return ""
+			}
+			return p.ParseFrom
+		}
+		if parseFrom(op.TraceParser.TraceId) == "" && parseFrom(op.TraceParser.SpanId) == "" && parseFrom(op.TraceParser.TraceFlags) == "" {
+			return fmt.Errorf("one of trace_id, span_id, trace_flags of %s trace_parser operator must be present", op.ID)
+		}
+	case "time_parser":
+		if op.ParseFrom == "" {
+			return fmt.Errorf("parse from of %s time_parser operator cannot be empty", op.ID)
+		}
+		if op.LayoutType != "strptime" && op.LayoutType != "gotime" && op.LayoutType != "epoch" {
+			return fmt.Errorf("layout type of %s time_parser operator should be one of (strptime, gotime, epoch)", op.ID)
+		}
+		if op.Layout == "" {
+			return fmt.Errorf("layout of %s time_parser operator cannot be empty", op.ID)
+		}
+	case "router":
+		if op.Routes == nil || len(*op.Routes) == 0 {
+			return fmt.Errorf("routes of %s router operator cannot be empty", op.ID)
+		}
+		for _, route := range *op.Routes {
+			if route.Expr == "" || route.Output == "" {
+				return fmt.Errorf("expr or output of a route of %s router operator cannot be empty", op.ID)
+			}
+		}
+	case "retain":
+		if len(op.Fields) == 0 {
+			return fmt.Errorf("fields of %s retain operator cannot be empty", op.ID)
+		}
+	default:
+		return fmt.Errorf("operator type %s not supported for %s, use one of (grok_parser, regex_parser, json_parser, copy, move, add, remove, time_parser, trace_parser, router, retain)", op.Type, op.ID)
+	}
+
+	if !isValidOtelValue(op.ParseFrom) ||
+		!isValidOtelValue(op.ParseTo) ||
+		!isValidOtelValue(op.From) ||
+		!isValidOtelValue(op.To) ||
+		!isValidOtelValue(op.Field) {
+		return fmt.Errorf("value should have prefix of body, attributes, resource for operator Id %s", op.ID)
+	}
+	return nil
+}
+
+var otelValueRE = regexp.MustCompile(`^(body|attributes|resource)(\.[a-zA-Z0-9_.-]+)*$`)
+
+func isValidOtelValue(val string) bool {
+	return val == "" || otelValueRE.MatchString(val)
+}
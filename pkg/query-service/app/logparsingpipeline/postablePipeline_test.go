@@ -0,0 +1,179 @@
+package logparsingpipeline
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+var correctQueriesTest = []struct {
+	Name     string
+	Pipeline PostablePipeline
+	IsValid  bool
+}{
+	{
+		Name: "No orderId",
+		Pipeline: PostablePipeline{
+			Name:   "pipeline 1",
+			Alias:  "pipeline1",
+			Filter: `attributes.method == "GET"`,
+			Config: []model.PipelineOperator{},
+		},
+		IsValid: false,
+	},
+	{
+		Name: "Invalid alias",
+		Pipeline: PostablePipeline{
+			OrderId: 1,
+			Name:    "pipeline 1",
+			Alias:   "pipe line",
+			Filter:  `attributes.method == "GET"`,
+			Config:  []model.PipelineOperator{},
+		},
+		IsValid: false,
+	},
+	{
+		Name: "Empty filter",
+		Pipeline: PostablePipeline{
+			OrderId: 1,
+			Name:    "pipeline 1",
+			Alias:   "pipeline1",
+			Config:  []model.PipelineOperator{},
+		},
+		IsValid: false,
+	},
+	{
+		Name: "Reserved operator id",
+		Pipeline: PostablePipeline{
+			OrderId: 1,
+			Name:    "pipeline 1",
+			Alias:   "pipeline1",
+			Filter:  `attributes.method == "GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: noopOperatorId, Type: "remove", Field: "attributes.method", Enabled: true},
+			},
+		},
+		IsValid: false,
+	},
+	{
+		Name: "Duplicate operator id",
+		Pipeline: PostablePipeline{
+			OrderId: 1,
+			Name:    "pipeline 1",
+			Alias:   "pipeline1",
+			Filter:  `attributes.method == "GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "remove", Type: "remove", Field: "attributes.method", Enabled: true},
+				{OrderId: 2, ID: "remove", Type: "remove", Field: "attributes.path", Enabled: true},
+			},
+		},
+		IsValid: false,
+	},
+	{
+		Name: "Regex without capture groups",
+		Pipeline: PostablePipeline{
+			OrderId: 1,
+			Name:    "pipeline 1",
+			Alias:   "pipeline1",
+			Filter:  `attributes.method == "GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "regex", Type: "regex_parser", Regex: "^[a-z]+$", ParseFrom: "body", Enabled: true},
+			},
+		},
+		IsValid: false,
+	},
+	{
+		Name: "Invalid field prefix",
+		Pipeline: PostablePipeline{
+			OrderId: 1,
+			Name:    "pipeline 1",
+			Alias:   "pipeline1",
+			Filter:  `attributes.method == "GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "move", Type: "move", From: "attributes.method", To: "labels.method", Enabled: true},
+			},
+		},
+		IsValid: false,
+	},
+	{
+		Name: "Trace parser without fields",
+		Pipeline: PostablePipeline{
+			OrderId: 1,
+			Name:    "pipeline 1",
+			Alias:   "pipeline1",
+			Filter:  `attributes.method == "GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "trace", Type: "trace_parser", TraceParser: &model.TraceParser{}, Enabled: true},
+			},
+		},
+		IsValid: false,
+	},
+	{
+		Name: "Unsupported operator",
+		Pipeline: PostablePipeline{
+			OrderId: 1,
+			Name:    "pipeline 1",
+			Alias:   "pipeline1",
+			Filter:  `attributes.method == "GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "csv", Type: "csv_parser", Enabled: true},
+			},
+		},
+		IsValid: false,
+	},
+	{
+		Name: "Valid",
+		Pipeline: PostablePipeline{
+			OrderId: 1,
+			Name:    "pipeline 1",
+			Alias:   "pipeline1",
+			Filter:  `attributes.method == "GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "regex", Type: "regex_parser", Regex: `^(?P<method>[A-Z]+) (?P<path>\S+)$`, ParseFrom: "body", ParseTo: "attributes", Enabled: true},
+				{OrderId: 2, ID: "move", Type: "move", From: "attributes.method", To: "attributes.http_method", Enabled: true},
+				{OrderId: 3, ID: "trace", Type: "trace_parser", TraceParser: &model.TraceParser{TraceId: &model.ParseFrom{ParseFrom: "attributes.trace_id"}}, Enabled: false},
+			},
+		},
+		IsValid: true,
+	},
+}
+
+func TestIsValidPostablePipeline(t *testing.T) {
+	for _, test := range correctQueriesTest {
+		Convey(test.Name, t, func() {
+			err := test.Pipeline.IsValid()
+			if test.IsValid {
+				So(err, ShouldBeNil)
+			} else {
+				So(err, ShouldBeError)
+			}
+		})
+	}
+}
+
+func TestValidatePipelines(t *testing.T) {
+	pipeline := func(orderId int, alias string) PostablePipeline {
+		return PostablePipeline{
+			OrderId: orderId,
+			Name:    "pipeline " + alias,
+			Alias:   alias,
+			Filter:  `attributes.method == "GET"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "move", Type: "move", From: "attributes.method", To: "attributes.http_method", Enabled: true},
+			},
+		}
+	}
+
+	Convey("Unique aliases", t, func() {
+		So(validatePipelines([]PostablePipeline{pipeline(1, "first"), pipeline(2, "second")}), ShouldBeNil)
+	})
+	Convey("Duplicate alias", t, func() {
+		disabled := pipeline(2, "first")
+		disabled.Enabled = false
+		So(validatePipelines([]PostablePipeline{pipeline(1, "first"), disabled}), ShouldBeError)
+	})
+	Convey("Invalid pipeline", t, func() {
+		So(validatePipelines([]PostablePipeline{pipeline(1, "first"), pipeline(0, "second")}), ShouldBeError)
+	})
+}
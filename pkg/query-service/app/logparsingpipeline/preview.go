@@ -0,0 +1,449 @@
+package logparsingpipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// maxOperatorsPerLog guards against operators routing logs in a cycle
+const maxOperatorsPerLog = 1000
+
+// PipelinesPreviewRequest has the pipelines to preview and the logs to run
+// them on. When no logs are given, recent logs matching Filter are used.
+type PipelinesPreviewRequest struct {
+	Pipelines []PostablePipeline      `json:"pipelines"`
+	Logs      []model.GetLogsResponse `json:"logs"`
+
+	Filter *v3.FilterSet `json:"filter"`
+	// Start and End in milliseconds, the last 15 minutes by default
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// Limit is the number of sample logs fetched with Filter
+	Limit uint64 `json:"limit"`
+}
+
+// PipelinesPreviewResponse has the logs as they would be after the
+// collectors processed them with the pipelines
+type PipelinesPreviewResponse struct {
+	OutputLogs []model.GetLogsResponse `json:"logs"`
+	Errors     []PreviewError          `json:"errors"`
+}
+
+// PreviewError is an error an operator hit on a log. The collector logs
+// such errors and sends the log on unmodified or drops it, depending on
+// the on_error of the operator.
+type PreviewError struct {
+	LogIndex  int    `json:"logIndex"`
+	Processor string `json:"processor"`
+	Operator  string `json:"operator"`
+	Error     string `json:"error"`
+	Dropped   bool   `json:"dropped"`
+}
+
+// PreviewPipelines validates the pipelines and runs the logs through them
+func PreviewPipelines(postable []PostablePipeline, logs []model.GetLogsResponse) (*PipelinesPreviewResponse, *model.ApiError) {
+	if len(postable) == 0 {
+		return nil, model.BadRequestStr("at least one pipeline is required for preview")
+	}
+	pipelines := make([]model.Pipeline, 0, len(postable))
+	for _, p := range postable {
+		if err := p.IsValid(); err != nil {
+			return nil, model.BadRequest(errors.Wrapf(err, "invalid pipeline %s", p.Name))
+		}
+		pipelines = append(pipelines, model.Pipeline{
+			Id:      p.Id,
+			OrderId: p.OrderId,
+			Name:    p.Name,
+			Alias:   p.Alias,
+			Enabled: p.Enabled,
+			Filter:  p.Filter,
+			Config:  p.Config,
+		})
+	}
+
+	response, err := SimulatePipelinesProcessing(pipelines, logs)
+	if err != nil {
+		return nil, model.BadRequest(errors.Wrap(err, "failed to generate processor config from pipelines"))
+	}
+	return response, nil
+}
+
+// SimulatePipelinesProcessing runs the logs through the processors the
+// pipelines are deployed as, in the order the collector runs them
+func SimulatePipelinesProcessing(pipelines []model.Pipeline, logs []model.GetLogsResponse) (*PipelinesPreviewResponse, error) {
+	processors, names, err := PreparePipelineProcessor(pipelines)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PipelinesPreviewResponse{
+		OutputLogs: []model.GetLogsResponse{},
+		Errors:     []PreviewError{},
+	}
+	for idx, log := range logs {
+		entry := newLogEntry(log)
+		dropped := false
+		for _, name := range names {
+			processor := processors[name].(model.Processor)
+			var errs []PreviewError
+			entry, errs = runOperators(processor.Operators, entry)
+			for i := range errs {
+				errs[i].LogIndex = idx
+				errs[i].Processor = name
+			}
+			response.Errors = append(response.Errors, errs...)
+			if entry == nil {
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			response.OutputLogs = append(response.OutputLogs, entry.toLog())
+		}
+	}
+	return response, nil
+}
+
+// runOperators passes the entry through the operators starting with the
+// first one. It returns nil when the entry is dropped.
+func runOperators(operators []model.PipelineOperator, entry *logEntry) (*logEntry, []PreviewError) {
+	var errs []PreviewError
+	if len(operators) == 0 {
+		return entry, errs
+	}
+
+	byId := make(map[string]int, len(operators))
+	for i, op := range operators {
+		byId[op.ID] = i
+	}
+
+	current := 0
+	for steps := 0; current < len(operators); steps++ {
+		if steps > maxOperatorsPerLog {
+			errs = append(errs, PreviewError{Operator: operators[current].ID, Error: "operators are routing the log in a cycle", Dropped: true})
+			return nil, errs
+		}
+
+		op := operators[current]
+		processed := entry.copy()
+		next, err := runOperator(op, processed)
+		if err != nil {
+			drop := op.OnError == "drop" || op.OnError == "drop_quiet"
+			errs = append(errs, PreviewError{Operator: op.ID, Error: err.Error(), Dropped: drop})
+			if drop {
+				return nil, errs
+			}
+			// the log is sent on unmodified
+			processed, next = entry, op.Output
+		}
+		entry = processed
+
+		if op.Type == "router" && next == "" {
+			// logs not matching any route and without a default are dropped
+			return nil, errs
+		}
+		if next == "" {
+			current++
+			continue
+		}
+		idx, ok := byId[next]
+		if !ok {
+			errs = append(errs, PreviewError{Operator: op.ID, Error: fmt.Sprintf("output operator %s not found", next), Dropped: true})
+			return nil, errs
+		}
+		current = idx
+	}
+	return entry, errs
+}
+
+// runOperator applies the operator to the entry in place and returns the
+// id of the operator the entry goes to next, empty for the following one
+func runOperator(op model.PipelineOperator, entry *logEntry) (string, error) {
+	switch op.Type {
+	case "regex_parser":
+		if err := runRegexParser(op, entry); err != nil {
+			return "", err
+		}
+	case "json_parser":
+		if err := runJSONParser(op, entry); err != nil {
+			return "", err
+		}
+	case "move", "copy":
+		from, err := parseField(op.From)
+		if err != nil {
+			return "", err
+		}
+		to, err := parseField(op.To)
+		if err != nil {
+			return "", err
+		}
+		value, ok := entry.get(from)
+		if !ok {
+			return "", fmt.Errorf("%s: field does not exist", op.From)
+		}
+		value = copyValue(value)
+		if op.Type == "move" {
+			entry.remove(from)
+		}
+		if err := entry.set(to, value); err != nil {
+			return "", err
+		}
+	case "add":
+		f, err := parseField(op.Field)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(op.Value, "EXPR(") {
+			return "", fmt.Errorf("expressions in the value of add operator are not supported in preview")
+		}
+		if err := entry.set(f, op.Value); err != nil {
+			return "", err
+		}
+	case "remove":
+		f, err := parseField(op.Field)
+		if err != nil {
+			return "", err
+		}
+		entry.remove(f)
+	case "retain":
+		if err := runRetain(op, entry); err != nil {
+			return "", err
+		}
+	case "time_parser":
+		if err := runTimeParser(op.ParseFrom, op.LayoutType, op.Layout, entry); err != nil {
+			return "", err
+		}
+	case "trace_parser":
+		if err := runTraceParser(op, entry); err != nil {
+			return "", err
+		}
+	case "router":
+		return runRouter(op, entry)
+	case "noop":
+	default:
+		return "", fmt.Errorf("operator type %s is not supported in preview", op.Type)
+	}
+	return op.Output, nil
+}
+
+func parseFromField(parseFrom string) (field, error) {
+	if parseFrom == "" {
+		return field{root: "body"}, nil
+	}
+	return parseField(parseFrom)
+}
+
+func parseToField(parseTo string) (field, error) {
+	if parseTo == "" {
+		return field{root: "attributes"}, nil
+	}
+	return parseField(parseTo)
+}
+
+func getString(entry *logEntry, f field) (string, error) {
+	value, ok := entry.get(f)
+	if !ok {
+		return "", fmt.Errorf("%s: field does not exist", f)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("type %T cannot be parsed", value)
+	}
+	return s, nil
+}
+
+func runRegexParser(op model.PipelineOperator, entry *logEntry) error {
+	from, err := parseFromField(op.ParseFrom)
+	if err != nil {
+		return err
+	}
+	to, err := parseToField(op.ParseTo)
+	if err != nil {
+		return err
+	}
+	r, err := regexp.Compile(op.Regex)
+	if err != nil {
+		return err
+	}
+	value, err := getString(entry, from)
+	if err != nil {
+		return err
+	}
+	matches := r.FindStringSubmatch(value)
+	if matches == nil {
+		return fmt.Errorf("regex pattern does not match")
+	}
+	parsed := map[string]interface{}{}
+	for i, name := range r.SubexpNames() {
+		if i == 0 || name == "" {
+			continue
+		}
+		parsed[name] = matches[i]
+	}
+	return setParsed(op, entry, to, parsed)
+}
+
+func runJSONParser(op model.PipelineOperator, entry *logEntry) error {
+	from, err := parseFromField(op.ParseFrom)
+	if err != nil {
+		return err
+	}
+	to, err := parseToField(op.ParseTo)
+	if err != nil {
+		return err
+	}
+	value, err := getString(entry, from)
+	if err != nil {
+		return err
+	}
+	parsed := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return fmt.Errorf("failed to parse value as json: %v", err)
+	}
+	return setParsed(op, entry, to, parsed)
+}
+
+// setParsed writes the values a parser extracted and applies its embedded
+// timestamp parser
+func setParsed(op model.PipelineOperator, entry *logEntry, to field, parsed map[string]interface{}) error {
+	if to.root == "body" && to.key == "" {
+		entry.body = parsed
+	} else if err := entry.set(to, parsed); err != nil {
+		return err
+	}
+	if op.Timestamp != nil {
+		return runTimeParser(op.Timestamp.ParseFrom, op.Timestamp.LayoutType, op.Timestamp.Layout, entry)
+	}
+	return nil
+}
+
+func runTimeParser(parseFrom, layoutType, layout string, entry *logEntry) error {
+	from, err := parseField(parseFrom)
+	if err != nil {
+		return err
+	}
+	value, ok := entry.get(from)
+	if !ok {
+		return fmt.Errorf("%s: field does not exist", parseFrom)
+	}
+	t, err := parseTime(value, layoutType, layout)
+	if err != nil {
+		return err
+	}
+	entry.timestamp = t
+	return nil
+}
+
+var (
+	traceIdRE    = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+	spanIdRE     = regexp.MustCompile(`^[0-9a-fA-F]{16}$`)
+	traceFlagsRE = regexp.MustCompile(`^[0-9a-fA-F]{2}$`)
+)
+
+func runTraceParser(op model.PipelineOperator, entry *logEntry) error {
+	if op.TraceParser == nil {
+		return fmt.Errorf("trace parser config is missing")
+	}
+	parse := func(p *model.ParseFrom, re *regexp.Regexp, name string) (string, error) {
+		if p == nil || p.ParseFrom == "" {
+			return "", nil
+		}
+		f, err := parseField(p.ParseFrom)
+		if err != nil {
+			return "", err
+		}
+		value, ok := entry.get(f)
+		if !ok {
+			return "", nil
+		}
+		s, ok := value.(string)
+		if !ok || !re.MatchString(s) {
+			return "", fmt.Errorf("invalid %s %v", name, value)
+		}
+		return strings.ToLower(s), nil
+	}
+
+	traceId, err := parse(op.TraceParser.TraceId, traceIdRE, "trace_id")
+	if err != nil {
+		return err
+	}
+	spanId, err := parse(op.TraceParser.SpanId, spanIdRE, "span_id")
+	if err != nil {
+		return err
+	}
+	traceFlags, err := parse(op.TraceParser.TraceFlags, traceFlagsRE, "trace_flags")
+	if err != nil {
+		return err
+	}
+	if traceId != "" {
+		entry.traceId = traceId
+	}
+	if spanId != "" {
+		entry.spanId = spanId
+	}
+	if traceFlags != "" {
+		flags, _ := strconv.ParseUint(traceFlags, 16, 8)
+		entry.traceFlags = uint32(flags)
+	}
+	return nil
+}
+
+func runRetain(op model.PipelineOperator, entry *logEntry) error {
+	retained := map[string]map[string]struct{}{}
+	for _, name := range op.Fields {
+		f, err := parseField(name)
+		if err != nil {
+			return err
+		}
+		if f.root == "body" {
+			continue
+		}
+		if retained[f.root] == nil {
+			retained[f.root] = map[string]struct{}{}
+		}
+		retained[f.root][f.key] = struct{}{}
+	}
+	for root, keys := range retained {
+		m := entry.flatMap(root)
+		for k := range m {
+			if _, ok := keys[k]; !ok {
+				delete(m, k)
+			}
+		}
+	}
+	return nil
+}
+
+// runRouter returns the output of the first route the entry matches, a
+// route whose expression fails to evaluate is skipped as the collector does
+func runRouter(op model.PipelineOperator, entry *logEntry) (string, error) {
+	var errs []string
+	if op.Routes != nil {
+		for _, route := range *op.Routes {
+			expression, err := newLogExpression(route.Expr)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			matched, err := expression.matches(entry)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("failed to evaluate %s: %v", route.Expr, err))
+				continue
+			}
+			if matched {
+				return route.Output, nil
+			}
+		}
+	}
+	if len(errs) > 0 && op.Default == "" {
+		return "", fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return op.Default, nil
+}
@@ -0,0 +1,331 @@
+package logparsingpipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// logEntry is the in-process representation of a log the preview operators
+// work on. Attributes and resource are kept flat, with the dotted keys they
+// are stored with in clickhouse, while a parsed body is a nested map.
+type logEntry struct {
+	id             string
+	timestamp      time.Time
+	body           interface{}
+	attributes     map[string]interface{}
+	resource       map[string]interface{}
+	traceId        string
+	spanId         string
+	traceFlags     uint32
+	severityText   string
+	severityNumber uint8
+}
+
+func newLogEntry(log model.GetLogsResponse) *logEntry {
+	entry := &logEntry{
+		id:             log.ID,
+		timestamp:      time.Unix(0, int64(log.Timestamp)).UTC(),
+		body:           log.Body,
+		attributes:     map[string]interface{}{},
+		resource:       map[string]interface{}{},
+		traceId:        log.TraceID,
+		spanId:         log.SpanID,
+		traceFlags:     log.TraceFlags,
+		severityText:   log.SeverityText,
+		severityNumber: log.SeverityNumber,
+	}
+	for k, v := range log.Attributes_string {
+		entry.attributes[k] = v
+	}
+	for k, v := range log.Attributes_int64 {
+		entry.attributes[k] = v
+	}
+	for k, v := range log.Attributes_float64 {
+		entry.attributes[k] = v
+	}
+	for k, v := range log.Resources_string {
+		entry.resource[k] = v
+	}
+	return entry
+}
+
+// toLog converts the entry back to the shape logs are returned in, values
+// are typed the way the clickhouse exporter stores them
+func (e *logEntry) toLog() model.GetLogsResponse {
+	log := model.GetLogsResponse{
+		Timestamp:          uint64(e.timestamp.UnixNano()),
+		ID:                 e.id,
+		TraceID:            e.traceId,
+		SpanID:             e.spanId,
+		TraceFlags:         e.traceFlags,
+		SeverityText:       e.severityText,
+		SeverityNumber:     e.severityNumber,
+		Body:               stringify(e.body),
+		Resources_string:   map[string]string{},
+		Attributes_string:  map[string]string{},
+		Attributes_int64:   map[string]int64{},
+		Attributes_float64: map[string]float64{},
+	}
+	for k, v := range e.attributes {
+		switch value := v.(type) {
+		case int:
+			log.Attributes_int64[k] = int64(value)
+		case int64:
+			log.Attributes_int64[k] = value
+		case float64:
+			log.Attributes_float64[k] = value
+		default:
+			log.Attributes_string[k] = stringify(value)
+		}
+	}
+	for k, v := range e.resource {
+		log.Resources_string[k] = stringify(v)
+	}
+	return log
+}
+
+func (e *logEntry) copy() *logEntry {
+	c := *e
+	c.body = copyValue(e.body)
+	c.attributes = copyValue(e.attributes).(map[string]interface{})
+	c.resource = copyValue(e.resource).(map[string]interface{})
+	return &c
+}
+
+func copyValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			c[k] = copyValue(item)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(value))
+		for i, item := range value {
+			c[i] = copyValue(item)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+func stringify(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// field is a parsed reference to a part of the log entry e.g. body,
+// body.message, attributes.http.method or resource.service.name
+type field struct {
+	root string
+	key  string
+}
+
+func parseField(path string) (field, error) {
+	root, key, _ := strings.Cut(path, ".")
+	switch root {
+	case "body", "attributes", "resource":
+		return field{root: root, key: key}, nil
+	}
+	return field{}, fmt.Errorf("field %s should have prefix of body, attributes, resource", path)
+}
+
+func (f field) String() string {
+	if f.key == "" {
+		return f.root
+	}
+	return f.root + "." + f.key
+}
+
+func (e *logEntry) flatMap(root string) map[string]interface{} {
+	if root == "attributes" {
+		return e.attributes
+	}
+	return e.resource
+}
+
+// get returns the value of the field and whether it is present
+func (e *logEntry) get(f field) (interface{}, bool) {
+	if f.root == "body" {
+		if f.key == "" {
+			return e.body, e.body != nil
+		}
+		current := e.body
+		for _, part := range strings.Split(f.key, ".") {
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = m[part]; !ok {
+				return nil, false
+			}
+		}
+		return current, true
+	}
+	if f.key == "" {
+		return e.flatMap(f.root), true
+	}
+	v, ok := e.flatMap(f.root)[f.key]
+	return v, ok
+}
+
+// set writes value to the field, maps written to attributes or resource
+// are flattened into dotted keys
+func (e *logEntry) set(f field, value interface{}) error {
+	if f.root == "body" {
+		if f.key == "" {
+			e.body = value
+			return nil
+		}
+		m, ok := e.body.(map[string]interface{})
+		if !ok {
+			if e.body != nil && e.body != "" {
+				return fmt.Errorf("cannot set %s, body is not a map", f)
+			}
+			m = map[string]interface{}{}
+			e.body = m
+		}
+		parts := strings.Split(f.key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := m[part].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[part] = next
+			}
+			m = next
+		}
+		m[parts[len(parts)-1]] = value
+		return nil
+	}
+
+	target := e.flatMap(f.root)
+	if nested, ok := value.(map[string]interface{}); ok {
+		flatten(target, f.key, nested)
+		return nil
+	}
+	if f.key == "" {
+		return fmt.Errorf("cannot set %s to a value that is not a map", f)
+	}
+	target[f.key] = value
+	return nil
+}
+
+func flatten(target map[string]interface{}, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			flatten(target, key, nested)
+			continue
+		}
+		target[key] = v
+	}
+}
+
+// remove deletes the field, it is not an error if the field is absent
+func (e *logEntry) remove(f field) {
+	if f.root == "body" {
+		if f.key == "" {
+			e.body = nil
+			return
+		}
+		parts := strings.Split(f.key, ".")
+		m, ok := e.body.(map[string]interface{})
+		for _, part := range parts[:len(parts)-1] {
+			if !ok {
+				return
+			}
+			m, ok = m[part].(map[string]interface{})
+		}
+		if ok {
+			delete(m, parts[len(parts)-1])
+		}
+		return
+	}
+	if f.key == "" {
+		for k := range e.flatMap(f.root) {
+			delete(e.flatMap(f.root), k)
+		}
+		return
+	}
+	delete(e.flatMap(f.root), f.key)
+}
+
+// LogsFromListRows converts the rows of a v3 logs list query to logs
+func LogsFromListRows(rows []*v3.Row) []model.GetLogsResponse {
+	logs := make([]model.GetLogsResponse, 0, len(rows))
+	for _, row := range rows {
+		log := model.GetLogsResponse{Timestamp: uint64(row.Timestamp.UnixNano())}
+		for column, v := range row.Data {
+			value := reflect.ValueOf(v)
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			switch data := value.Interface().(type) {
+			case string:
+				switch column {
+				case "id":
+					log.ID = data
+				case "trace_id":
+					log.TraceID = data
+				case "span_id":
+					log.SpanID = data
+				case "severity_text":
+					log.SeverityText = data
+				case "body":
+					log.Body = data
+				}
+			case uint32:
+				if column == "trace_flags" {
+					log.TraceFlags = data
+				}
+			case uint8:
+				if column == "severity_number" {
+					log.SeverityNumber = data
+				}
+			case map[string]string:
+				switch column {
+				case "attributes_string":
+					log.Attributes_string = data
+				case "resources_string":
+					log.Resources_string = data
+				}
+			case map[string]int64:
+				if column == "attributes_int64" {
+					log.Attributes_int64 = data
+				}
+			case map[string]float64:
+				if column == "attributes_float64" {
+					log.Attributes_float64 = data
+				}
+			}
+		}
+		logs = append(logs, log)
+	}
+	return logs
+}
@@ -0,0 +1,121 @@
+package logparsingpipeline
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/SigNoz/govaluate"
+)
+
+// logExpression evaluates the expressions used by pipeline filters and
+// router routes e.g. `attributes.method == "GET" and body matches "^ERROR"`.
+// The collector evaluates them with expr, the subset of its syntax used
+// with logs is rewritten to govaluate: fields become bracketed variables
+// and the textual operators become symbols.
+type logExpression struct {
+	expression *govaluate.EvaluableExpression
+	fields     map[string]field
+}
+
+var exprOperators = map[string]string{
+	"and":     "&&",
+	"or":      "||",
+	"not":     "!",
+	"matches": "=~",
+}
+
+func newLogExpression(expr string) (*logExpression, error) {
+	var b strings.Builder
+	fields := map[string]field{}
+
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			// string literal, copied as is with the quote govaluate expects
+			j := i + 1
+			for j < len(runes) && runes[j] != c {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unclosed string literal in %s", expr)
+			}
+			quote := c
+			if quote == '`' {
+				quote = '"'
+			}
+			b.WriteRune(quote)
+			b.WriteString(string(runes[i+1 : j]))
+			b.WriteRune(quote)
+			i = j + 1
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			ident := string(runes[i:j])
+			i = j
+
+			// attributes["http.method"] is the same as attributes.http.method
+			for i+1 < len(runes) && runes[i] == '[' && (runes[i+1] == '"' || runes[i+1] == '\'') {
+				end := strings.IndexRune(string(runes[i+2:]), runes[i+1])
+				if end < 0 || i+2+end+1 >= len(runes) || runes[i+2+end+1] != ']' {
+					break
+				}
+				ident = ident + "." + string(runes[i+2:i+2+end])
+				i = i + 2 + end + 2
+			}
+
+			if op, ok := exprOperators[ident]; ok {
+				b.WriteString(" " + op + " ")
+				continue
+			}
+			switch ident {
+			case "true", "false", "in":
+				b.WriteString(ident)
+				continue
+			case "nil":
+				b.WriteString("[nil]")
+				continue
+			}
+			f, err := parseField(ident)
+			if err != nil {
+				return nil, fmt.Errorf("unknown identifier %s in %s", ident, expr)
+			}
+			fields[f.String()] = f
+			b.WriteString("[" + f.String() + "]")
+		default:
+			b.WriteRune(c)
+			i++
+		}
+	}
+
+	expression, err := govaluate.NewEvaluableExpression(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression %s: %v", expr, err)
+	}
+	return &logExpression{expression: expression, fields: fields}, nil
+}
+
+// matches reports whether the expression is true for the entry
+func (e *logExpression) matches(entry *logEntry) (bool, error) {
+	params := map[string]interface{}{"nil": nil}
+	for name, f := range e.fields {
+		value, _ := entry.get(f)
+		params[name] = value
+	}
+	result, err := e.expression.Evaluate(params)
+	if err != nil {
+		return false, err
+	}
+	matched, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %s evaluated to %v, not a boolean", e.expression.String(), result)
+	}
+	return matched, nil
+}
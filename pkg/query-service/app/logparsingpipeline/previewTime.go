@@ -0,0 +1,119 @@
+package logparsingpipeline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// strptimeDirectives maps the strptime directives to go time layout
+var strptimeDirectives = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'h': "Jan",
+	'c': "Mon Jan _2 15:04:05 2006",
+	'd': "02",
+	'e': "_2",
+	'D': "01/02/06",
+	'F': "2006-01-02",
+	'f': "999999",
+	'L': "999",
+	'H': "15",
+	'I': "03",
+	'j': "002",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'S': "05",
+	'T': "15:04:05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+	'%': "%",
+}
+
+func strptimeToGoLayout(layout string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			b.WriteByte(layout[i])
+			continue
+		}
+		if i+1 >= len(layout) {
+			return "", fmt.Errorf("layout %s ends with a hanging %%", layout)
+		}
+		directive, ok := strptimeDirectives[layout[i+1]]
+		if !ok {
+			return "", fmt.Errorf("unsupported strptime directive %%%c in %s", layout[i+1], layout)
+		}
+		b.WriteString(directive)
+		i++
+	}
+	return b.String(), nil
+}
+
+// parseTime parses value the way the collector time parser does for the
+// given layout type
+func parseTime(value interface{}, layoutType, layout string) (time.Time, error) {
+	switch layoutType {
+	case "strptime", "gotime":
+		s, ok := value.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("type %T cannot be parsed as a time", value)
+		}
+		if layoutType == "strptime" {
+			var err error
+			if layout, err = strptimeToGoLayout(layout); err != nil {
+				return time.Time{}, err
+			}
+		}
+		t, err := time.ParseInLocation(layout, s, time.UTC)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t, nil
+	case "epoch":
+		return parseEpoch(value, layout)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported layout type %s", layoutType)
+	}
+}
+
+func parseEpoch(value interface{}, layout string) (time.Time, error) {
+	var epoch float64
+	switch v := value.(type) {
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid value '%v' for layout '%s'", v, layout)
+		}
+		epoch = f
+	case int64:
+		epoch = float64(v)
+	case int:
+		epoch = float64(v)
+	case float64:
+		epoch = v
+	default:
+		return time.Time{}, fmt.Errorf("type %T cannot be parsed as an epoch", value)
+	}
+
+	switch layout {
+	case "s", "s.ms", "s.us", "s.ns":
+		seconds, fraction := math.Modf(epoch)
+		return time.Unix(int64(seconds), int64(fraction*1e9)).UTC(), nil
+	case "ms":
+		return time.UnixMilli(int64(epoch)).UTC(), nil
+	case "us":
+		return time.UnixMicro(int64(epoch)).UTC(), nil
+	case "ns":
+		return time.Unix(0, int64(epoch)).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid epoch layout %s", layout)
+	}
+}
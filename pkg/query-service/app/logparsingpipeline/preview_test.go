@@ -0,0 +1,156 @@
+package logparsingpipeline
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func TestPreviewPipelines(t *testing.T) {
+	parsePipeline := PostablePipeline{
+		OrderId: 1,
+		Name:    "nginx",
+		Alias:   "nginx",
+		Enabled: true,
+		Filter:  `attributes.source == "nginx"`,
+		Config: []model.PipelineOperator{
+			{
+				OrderId:   1,
+				ID:        "parse",
+				Type:      "regex_parser",
+				Enabled:   true,
+				ParseFrom: "body",
+				ParseTo:   "attributes",
+				Regex:     `^(?P<method>[A-Z]+) (?P<path>\S+) (?P<time>\S+)$`,
+			},
+			{
+				OrderId:    2,
+				ID:         "time",
+				Type:       "time_parser",
+				Enabled:    true,
+				ParseFrom:  "attributes.time",
+				LayoutType: "strptime",
+				Layout:     "%Y-%m-%dT%H:%M:%S",
+			},
+			{
+				OrderId: 3,
+				ID:      "move",
+				Type:    "move",
+				Enabled: true,
+				From:    "attributes.method",
+				To:      "attributes.http.method",
+			},
+		},
+	}
+
+	Convey("operators transform the logs matching the filter", t, func() {
+		logs := []model.GetLogsResponse{
+			{
+				ID:                "1",
+				Body:              "GET /health 2023-05-01T10:00:00",
+				Attributes_string: map[string]string{"source": "nginx"},
+			},
+			{
+				ID:                "2",
+				Body:              "GET /health 2023-05-01T10:00:00",
+				Attributes_string: map[string]string{"source": "apache"},
+			},
+		}
+		res, apiErr := PreviewPipelines([]PostablePipeline{parsePipeline}, logs)
+		So(apiErr, ShouldBeNil)
+		So(res.Errors, ShouldBeEmpty)
+		So(len(res.OutputLogs), ShouldEqual, 2)
+
+		parsed := res.OutputLogs[0]
+		So(parsed.Attributes_string, ShouldResemble, map[string]string{
+			"source":      "nginx",
+			"path":        "/health",
+			"time":        "2023-05-01T10:00:00",
+			"http.method": "GET",
+		})
+		So(parsed.Timestamp, ShouldEqual, uint64(time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC).UnixNano()))
+
+		// the second log does not match the filter and is not modified
+		So(res.OutputLogs[1].Attributes_string, ShouldResemble, map[string]string{"source": "apache"})
+		So(res.OutputLogs[1].Body, ShouldEqual, "GET /health 2023-05-01T10:00:00")
+	})
+
+	Convey("errors are handled as on_error of the operator", t, func() {
+		logs := []model.GetLogsResponse{
+			{
+				ID:                "1",
+				Body:              "not an access log",
+				Attributes_string: map[string]string{"source": "nginx"},
+			},
+		}
+
+		res, apiErr := PreviewPipelines([]PostablePipeline{parsePipeline}, logs)
+		So(apiErr, ShouldBeNil)
+		So(len(res.OutputLogs), ShouldEqual, 1)
+		So(res.OutputLogs[0].Body, ShouldEqual, "not an access log")
+		So(len(res.Errors), ShouldEqual, 3)
+		So(res.Errors[0].Operator, ShouldEqual, "parse")
+		So(res.Errors[0].Dropped, ShouldBeFalse)
+
+		dropping := parsePipeline
+		dropping.Config = append([]model.PipelineOperator{}, parsePipeline.Config...)
+		dropping.Config[0].OnError = "drop"
+		res, apiErr = PreviewPipelines([]PostablePipeline{dropping}, logs)
+		So(apiErr, ShouldBeNil)
+		So(res.OutputLogs, ShouldBeEmpty)
+		So(len(res.Errors), ShouldEqual, 1)
+		So(res.Errors[0].Dropped, ShouldBeTrue)
+	})
+
+	Convey("json, trace parser and router operators", t, func() {
+		routes := []model.Route{
+			{Expr: `attributes.level == "error"`, Output: "add_error"},
+		}
+		pipeline := PostablePipeline{
+			OrderId: 1,
+			Name:    "json",
+			Alias:   "json",
+			Enabled: true,
+			Filter:  `body matches "^{"`,
+			Config: []model.PipelineOperator{
+				{OrderId: 1, ID: "json", Type: "json_parser", Enabled: true, ParseFrom: "body", ParseTo: "attributes"},
+				{
+					OrderId: 2,
+					ID:      "trace",
+					Type:    "trace_parser",
+					Enabled: true,
+					TraceParser: &model.TraceParser{
+						TraceId: &model.ParseFrom{ParseFrom: "attributes.trace_id"},
+					},
+				},
+				{OrderId: 3, ID: "route", Type: "router", Enabled: true, Routes: &routes, Default: "remove"},
+				{OrderId: 4, ID: "add_error", Type: "add", Enabled: true, Field: "attributes.alert", Value: "true", Output: "remove"},
+				{OrderId: 5, ID: "remove", Type: "remove", Enabled: true, Field: "attributes.trace_id"},
+			},
+		}
+		logs := []model.GetLogsResponse{
+			{Body: `{"level": "error", "trace_id": "0123456789ABCDEF0123456789ABCDEF", "retries": 3}`},
+			{Body: `{"level": "info"}`},
+		}
+
+		res, apiErr := PreviewPipelines([]PostablePipeline{pipeline}, logs)
+		So(apiErr, ShouldBeNil)
+		So(res.Errors, ShouldBeEmpty)
+		So(len(res.OutputLogs), ShouldEqual, 2)
+
+		So(res.OutputLogs[0].TraceID, ShouldEqual, "0123456789abcdef0123456789abcdef")
+		So(res.OutputLogs[0].Attributes_string, ShouldResemble, map[string]string{"level": "error", "alert": "true"})
+		So(res.OutputLogs[0].Attributes_float64, ShouldResemble, map[string]float64{"retries": 3})
+		So(res.OutputLogs[1].Attributes_string, ShouldResemble, map[string]string{"level": "info"})
+	})
+
+	Convey("invalid pipelines are rejected", t, func() {
+		invalid := parsePipeline
+		invalid.Alias = ""
+		_, apiErr := PreviewPipelines([]PostablePipeline{invalid}, nil)
+		So(apiErr, ShouldNotBeNil)
+		So(apiErr.Typ, ShouldEqual, model.ErrorBadData)
+	})
+}
@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func InitDB(db *sqlx.DB) error {
+	var err error
+	if db == nil {
+		return fmt.Errorf("invalid db connection")
+	}
+
+	// pipelines are never updated in place, every version of the logs
+	// pipelines refers to its own copy of each pipeline through
+	// agent_config_elements
+	table_schema := `CREATE TABLE IF NOT EXISTS pipelines(
+		id TEXT PRIMARY KEY,
+		order_id INTEGER,
+		enabled BOOLEAN,
+		created_by TEXT,
+		created_at datetime NOT NULL,
+		name VARCHAR(400) NOT NULL,
+		alias VARCHAR(20) NOT NULL,
+		description TEXT,
+		filter TEXT NOT NULL,
+		config_json TEXT
+	);
+	`
+	_, err = db.Exec(table_schema)
+	if err != nil {
+		return errors.Wrap(err, "Error in creating pipelines table")
+	}
+	return nil
+}
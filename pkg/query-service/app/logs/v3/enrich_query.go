@@ -25,12 +25,14 @@ func EnrichmentRequired(params *v3.QueryRangeParamsV3) bool {
 		}
 
 		// check filter attribute
-		if query.Filters != nil && len(query.Filters.Items) != 0 {
-			for _, item := range query.Filters.Items {
-				if !isEnriched(item.Key) {
-					return true
-				}
+		filtersEnriched := true
+		query.Filters.Walk(func(item *v3.FilterItem) {
+			if !isEnriched(item.Key) {
+				filtersEnriched = false
 			}
+		})
+		if !filtersEnriched {
+			return true
 		}
 
 		groupByLookup := map[string]struct{}{}
@@ -95,11 +97,9 @@ func enrichLogsQuery(query *v3.BuilderQuery, fields map[string]v3.AttributeKey)
 	}
 
 	// enrich filter attribute
-	if query.Filters != nil && len(query.Filters.Items) != 0 {
-		for i := 0; i < len(query.Filters.Items); i++ {
-			query.Filters.Items[i].Key = enrichFieldWithMetadata(query.Filters.Items[i].Key, fields)
-		}
-	}
+	query.Filters.Walk(func(item *v3.FilterItem) {
+		item.Key = enrichFieldWithMetadata(item.Key, fields)
+	})
 
 	// enrich groupby
 	for i := 0; i < len(query.GroupBy); i++ {
@@ -48,8 +48,8 @@ var logOperators = map[v3.FilterOperator]string{
 	v3.FilterOperatorNotRegex:        "NOT REGEXP",
 	v3.FilterOperatorIn:              "IN",
 	v3.FilterOperatorNotIn:           "NOT IN",
-	v3.FilterOperatorExists:          "has(%s_%s_key, '%s')",
-	v3.FilterOperatorNotExists:       "not has(%s_%s_key, '%s')",
+	v3.FilterOperatorExists:          "has(%s_%s_key, %s)",
+	v3.FilterOperatorNotExists:       "not has(%s_%s_key, %s)",
 	// (todo) check contains/not contains/
 }
 
@@ -73,7 +73,7 @@ func getClickhouseLogsColumnDataType(columnDataType v3.AttributeKeyDataType) str
 
 // getClickhouseColumnName returns the corresponding clickhouse column name for the given attribute/resource key
 func getClickhouseColumnName(key v3.AttributeKey) string {
-	clickhouseColumn := key.Key
+	clickhouseColumn := utils.ClickHouseFormattedColumn(key.Key)
 	if key.Key == constants.TIMESTAMP || key.Key == "id" {
 		return key.Key
 	}
@@ -83,7 +83,7 @@ func getClickhouseColumnName(key v3.AttributeKey) string {
 	if !key.IsColumn {
 		columnType := getClickhouseLogsColumnType(key.Type)
 		columnDataType := getClickhouseLogsColumnDataType(key.DataType)
-		clickhouseColumn = fmt.Sprintf("%s_%s_value[indexOf(%s_%s_key, '%s')]", columnType, columnDataType, columnType, columnDataType, key.Key)
+		clickhouseColumn = fmt.Sprintf("%s_%s_value[indexOf(%s_%s_key, %s)]", columnType, columnDataType, columnType, columnDataType, utils.QuoteString(key.Key))
 	}
 	return clickhouseColumn
 }
@@ -96,7 +96,7 @@ func getSelectLabels(aggregatorOperator v3.AggregateOperator, groupBy []v3.Attri
 	} else {
 		for _, tag := range groupBy {
 			columnName := getClickhouseColumnName(tag)
-			selectLabels += fmt.Sprintf(", %s as %s", columnName, tag.Key)
+			selectLabels += fmt.Sprintf(", %s as %s", columnName, utils.ClickHouseFormattedIdentifier(tag.Key))
 		}
 	}
 	return selectLabels, nil
@@ -105,31 +105,35 @@ func getSelectLabels(aggregatorOperator v3.AggregateOperator, groupBy []v3.Attri
 func buildLogsTimeSeriesFilterQuery(fs *v3.FilterSet, groupBy []v3.AttributeKey) (string, error) {
 	var conditions []string
 
-	if fs != nil && len(fs.Items) != 0 {
-		for _, item := range fs.Items {
-			op := v3.FilterOperator(strings.ToLower(strings.TrimSpace(string(item.Operator))))
-			value, err := utils.ValidateAndCastValue(item.Value, item.Key.DataType)
-			if err != nil {
-				return "", fmt.Errorf("failed to validate and cast value for %s: %v", item.Key.Key, err)
-			}
-			if logsOp, ok := logOperators[op]; ok {
-				switch op {
-				case v3.FilterOperatorExists, v3.FilterOperatorNotExists:
-					columnType := getClickhouseLogsColumnType(item.Key.Type)
-					columnDataType := getClickhouseLogsColumnDataType(item.Key.DataType)
-					conditions = append(conditions, fmt.Sprintf(logsOp, columnType, columnDataType, item.Key.Key))
-				case v3.FilterOperatorContains, v3.FilterOperatorNotContains:
-					columnName := getClickhouseColumnName(item.Key)
-					conditions = append(conditions, fmt.Sprintf("%s %s '%%%s%%'", columnName, logsOp, item.Value))
-				default:
-					columnName := getClickhouseColumnName(item.Key)
-					fmtVal := utils.ClickHouseFormattedValue(value)
-					conditions = append(conditions, fmt.Sprintf("%s %s %s", columnName, logsOp, fmtVal))
-				}
-			} else {
-				return "", fmt.Errorf("unsupported operator: %s", op)
-			}
+	filterCondition, err := fs.BuildCondition(func(item v3.FilterItem) (string, error) {
+		op := v3.FilterOperator(strings.ToLower(strings.TrimSpace(string(item.Operator))))
+		value, err := utils.ValidateAndCastValue(item.Value, item.Key.DataType)
+		if err != nil {
+			return "", fmt.Errorf("failed to validate and cast value for %s: %v", item.Key.Key, err)
+		}
+		logsOp, ok := logOperators[op]
+		if !ok {
+			return "", fmt.Errorf("unsupported operator: %s", op)
 		}
+		switch op {
+		case v3.FilterOperatorExists, v3.FilterOperatorNotExists:
+			columnType := getClickhouseLogsColumnType(item.Key.Type)
+			columnDataType := getClickhouseLogsColumnDataType(item.Key.DataType)
+			return fmt.Sprintf(logsOp, columnType, columnDataType, utils.QuoteString(item.Key.Key)), nil
+		case v3.FilterOperatorContains, v3.FilterOperatorNotContains:
+			columnName := getClickhouseColumnName(item.Key)
+			return fmt.Sprintf("%s %s %s", columnName, logsOp, utils.QuoteString(fmt.Sprintf("%%%v%%", item.Value))), nil
+		default:
+			columnName := getClickhouseColumnName(item.Key)
+			fmtVal := utils.ClickHouseFormattedValue(value)
+			return fmt.Sprintf("%s %s %s", columnName, logsOp, fmtVal), nil
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if filterCondition != "" {
+		conditions = append(conditions, filterCondition)
 	}
 
 	// add group by conditions to filter out log lines which doesn't have the key
@@ -137,7 +141,7 @@ func buildLogsTimeSeriesFilterQuery(fs *v3.FilterSet, groupBy []v3.AttributeKey)
 		if !attr.IsColumn {
 			columnType := getClickhouseLogsColumnType(attr.Type)
 			columnDataType := getClickhouseLogsColumnDataType(attr.DataType)
-			conditions = append(conditions, fmt.Sprintf("indexOf(%s_%s_key, '%s') > 0", columnType, columnDataType, attr.Key))
+			conditions = append(conditions, fmt.Sprintf("indexOf(%s_%s_key, %s) > 0", columnType, columnDataType, utils.QuoteString(attr.Key)))
 		}
 	}
 
@@ -183,8 +187,9 @@ func buildLogsQuery(panelType v3.PanelType, start, end, step int64, mq *v3.Build
 		having = " having " + having
 	}
 
+	// the keys in the labels can have % in them so it is escaped in the template
 	queryTmpl :=
-		"SELECT toStartOfInterval(fromUnixTimestamp64Nano(timestamp), INTERVAL %d SECOND) AS ts" + selectLabels +
+		"SELECT toStartOfInterval(fromUnixTimestamp64Nano(timestamp), INTERVAL %d SECOND) AS ts" + strings.ReplaceAll(selectLabels, "%", "%%") +
 			", %s as value " +
 			"from signoz_logs.distributed_logs " +
 			"where " + timeFilter + "%s " +
@@ -221,8 +226,13 @@ func buildLogsQuery(panelType v3.PanelType, start, end, step int64, mq *v3.Build
 		v3.AggregateOperatorP75,
 		v3.AggregateOperatorP90,
 		v3.AggregateOperatorP95,
-		v3.AggregateOperatorP99:
-		op := fmt.Sprintf("quantile(%v)(%s)", aggregateOperatorToPercentile[mq.AggregateOperator], aggregationKey)
+		v3.AggregateOperatorP99,
+		v3.AggregateOperatorQuantile:
+		percentile, err := mq.Percentile(aggregateOperatorToPercentile)
+		if err != nil {
+			return "", err
+		}
+		op := fmt.Sprintf("quantile(%v)(%s)", percentile, aggregationKey)
 		query := fmt.Sprintf(queryTmpl, step, op, filterSubQuery, groupBy, having, orderBy)
 		return query, nil
 	case v3.AggregateOperatorAvg, v3.AggregateOperatorSum, v3.AggregateOperatorMin, v3.AggregateOperatorMax:
@@ -233,7 +243,7 @@ func buildLogsQuery(panelType v3.PanelType, start, end, step int64, mq *v3.Build
 		if mq.AggregateAttribute.Key != "" {
 			columnType := getClickhouseLogsColumnType(mq.AggregateAttribute.Type)
 			columnDataType := getClickhouseLogsColumnDataType(mq.AggregateAttribute.DataType)
-			filterSubQuery = fmt.Sprintf("%s AND has(%s_%s_key, '%s')", filterSubQuery, columnType, columnDataType, mq.AggregateAttribute.Key)
+			filterSubQuery = fmt.Sprintf("%s AND has(%s_%s_key, %s)", filterSubQuery, columnType, columnDataType, utils.QuoteString(mq.AggregateAttribute.Key))
 		}
 
 		op := "toFloat64(count(*))"
@@ -255,8 +265,11 @@ func buildLogsQuery(panelType v3.PanelType, start, end, step int64, mq *v3.Build
 // groupBy returns a string of comma separated tags for group by clause
 // `ts` is always added to the group by clause
 func groupBy(tags ...string) string {
-	tags = append(tags, "ts")
-	return strings.Join(tags, ",")
+	groupTags := make([]string, 0, len(tags)+1)
+	for _, tag := range tags {
+		groupTags = append(groupTags, utils.ClickHouseFormattedIdentifier(tag))
+	}
+	return strings.Join(append(groupTags, "ts"), ",")
 }
 
 func groupByAttributeKeyTags(tags ...v3.AttributeKey) string {
@@ -284,10 +297,10 @@ func orderBy(panelType v3.PanelType, items []v3.OrderBy, tags []string) []string
 
 	for _, tag := range tags {
 		if item, ok := itemsLookup[tag]; ok {
-			orderBy = append(orderBy, fmt.Sprintf("%s %s", item.ColumnName, item.Order))
+			orderBy = append(orderBy, fmt.Sprintf("%s %s", utils.ClickHouseFormattedIdentifier(tag), item.Order))
 			addedToOrderBy[item.ColumnName] = true
 		} else {
-			orderBy = append(orderBy, fmt.Sprintf("%s ASC", tag))
+			orderBy = append(orderBy, fmt.Sprintf("%s ASC", utils.ClickHouseFormattedIdentifier(tag)))
 		}
 	}
 
@@ -338,7 +351,7 @@ func having(items []v3.Having) string {
 	// aggregate something and filter on that aggregate
 	var having []string
 	for _, item := range items {
-		having = append(having, fmt.Sprintf("value %s %s", item.Operator, utils.ClickHouseFormattedValue(item.Value)))
+		having = append(having, fmt.Sprintf("value %s %s", item.SQLOperator(), utils.ClickHouseFormattedValue(item.Value)))
 	}
 	return strings.Join(having, " AND ")
 }
@@ -210,6 +210,32 @@ var timeSeriesFilterQueryData = []struct {
 		}},
 		ExpectedFilter: " AND attributes_string_value[indexOf(attributes_string_key, 'body')] ILIKE '%test%'",
 	},
+	{
+		Name: "Test OR of nested groups",
+		FilterSet: &v3.FilterSet{Operator: "OR", Groups: []v3.FilterSet{
+			{Items: []v3.FilterItem{
+				{Key: v3.AttributeKey{Key: "service", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeResource}, Value: "a", Operator: "="},
+				{Key: v3.AttributeKey{Key: "status", DataType: v3.AttributeKeyDataTypeInt64, Type: v3.AttributeKeyTypeTag}, Value: 500, Operator: ">="},
+			}},
+			{Items: []v3.FilterItem{
+				{Key: v3.AttributeKey{Key: "service", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeResource}, Value: "b", Operator: "="},
+				{Key: v3.AttributeKey{Key: "duration", DataType: v3.AttributeKeyDataTypeFloat64, Type: v3.AttributeKeyTypeTag}, Value: 2, Operator: ">"},
+			}},
+		}},
+		ExpectedFilter: " AND ((resources_string_value[indexOf(resources_string_key, 'service')] = 'a' AND attributes_int64_value[indexOf(attributes_int64_key, 'status')] >= 500) OR " +
+			"(resources_string_value[indexOf(resources_string_key, 'service')] = 'b' AND attributes_float64_value[indexOf(attributes_float64_key, 'duration')] > 2.000000))",
+	},
+	{
+		Name: "Test NOT group with items",
+		FilterSet: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+			{Key: v3.AttributeKey{Key: "method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "GET", Operator: "="},
+		}, Groups: []v3.FilterSet{
+			{Operator: "NOT", Items: []v3.FilterItem{
+				{Key: v3.AttributeKey{Key: "user_name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "john", Operator: "="},
+			}},
+		}},
+		ExpectedFilter: " AND attributes_string_value[indexOf(attributes_string_key, 'method')] = 'GET' AND NOT (attributes_string_value[indexOf(attributes_string_key, 'user_name')] = 'john')",
+	},
 }
 
 func TestBuildLogsTimeSeriesFilterQuery(t *testing.T) {
@@ -518,6 +544,28 @@ var testBuildLogsQueryData = []struct {
 			"group by method,ts " +
 			"order by method ASC,ts",
 	},
+	{
+		Name:      "Test aggregate quantile",
+		PanelType: v3.PanelTypeGraph,
+		Start:     1680066360726210000,
+		End:       1680066458000000000,
+		Step:      60,
+		BuilderQuery: &v3.BuilderQuery{
+			QueryName:          "A",
+			AggregateAttribute: v3.AttributeKey{Key: "bytes", IsColumn: true},
+			AggregateOperator:  v3.AggregateOperatorQuantile,
+			Quantile:           0.999,
+			Expression:         "A",
+			Filters:            &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{}},
+		},
+		TableName: "logs",
+		ExpectedQuery: "SELECT toStartOfInterval(fromUnixTimestamp64Nano(timestamp), INTERVAL 60 SECOND) AS ts, " +
+			"quantile(0.999)(bytes) as value " +
+			"from signoz_logs.distributed_logs " +
+			"where (timestamp >= 1680066360726210000 AND timestamp <= 1680066458000000000) " +
+			"group by ts " +
+			"order by ts",
+	},
 	{
 		Name:      "Test aggregate RateSum",
 		PanelType: v3.PanelTypeGraph,
@@ -698,6 +746,32 @@ var testBuildLogsQueryData = []struct {
 		TableName:     "logs",
 		ExpectedQuery: "SELECT toStartOfInterval(fromUnixTimestamp64Nano(timestamp), INTERVAL 60 SECOND) AS ts, toFloat64(count(distinct(attributes_string_value[indexOf(attributes_string_key, 'name')]))) as value from signoz_logs.distributed_logs where (timestamp >= 1680066360726210000 AND timestamp <= 1680066458000000000) AND body ILIKE '%test%' group by ts having value > 10 order by ts",
 	},
+	{
+		Name:      "Test aggregate with having clause NOT_IN",
+		PanelType: v3.PanelTypeGraph,
+		Start:     1680066360726210000,
+		End:       1680066458000000000,
+		Step:      60,
+		BuilderQuery: &v3.BuilderQuery{
+			QueryName:          "A",
+			AggregateAttribute: v3.AttributeKey{Key: "name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag},
+			AggregateOperator:  v3.AggregateOperatorCountDistinct,
+			Expression:         "A",
+			Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+				{Key: v3.AttributeKey{Key: "body", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeUnspecified, IsColumn: true}, Value: "%test%", Operator: "like"},
+			},
+			},
+			Having: []v3.Having{
+				{
+					ColumnName: "name",
+					Operator:   "NOT_IN",
+					Value:      []interface{}{10, 20},
+				},
+			},
+		},
+		TableName:     "logs",
+		ExpectedQuery: "SELECT toStartOfInterval(fromUnixTimestamp64Nano(timestamp), INTERVAL 60 SECOND) AS ts, toFloat64(count(distinct(attributes_string_value[indexOf(attributes_string_key, 'name')]))) as value from signoz_logs.distributed_logs where (timestamp >= 1680066360726210000 AND timestamp <= 1680066458000000000) AND body ILIKE '%test%' group by ts having value NOT IN [10,20] order by ts",
+	},
 	{
 		Name:      "Test attribute with same name as top level key",
 		PanelType: v3.PanelTypeGraph,
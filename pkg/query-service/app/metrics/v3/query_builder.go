@@ -52,63 +52,68 @@ func buildMetricsTimeSeriesFilterQuery(fs *v3.FilterSet, groupTags []v3.Attribut
 	var conditions []string
 	conditions = append(conditions, fmt.Sprintf("metric_name = %s", utils.ClickHouseFormattedValue(metricName)))
 
-	if fs != nil && len(fs.Items) != 0 {
-		for _, item := range fs.Items {
-			toFormat := item.Value
-			op := v3.FilterOperator(strings.ToLower(strings.TrimSpace(string(item.Operator))))
-			// if the received value is an array for like/match op, just take the first value
-			// or should we throw an error?
-			if op == v3.FilterOperatorLike || op == v3.FilterOperatorRegex || op == v3.FilterOperatorNotLike || op == v3.FilterOperatorNotRegex {
-				x, ok := item.Value.([]interface{})
-				if ok {
-					if len(x) == 0 {
-						continue
-					}
-					toFormat = x[0]
+	filterCondition, err := fs.BuildCondition(func(item v3.FilterItem) (string, error) {
+		toFormat := item.Value
+		op := v3.FilterOperator(strings.ToLower(strings.TrimSpace(string(item.Operator))))
+		// if the received value is an array for like/match op, just take the first value
+		// or should we throw an error?
+		if op == v3.FilterOperatorLike || op == v3.FilterOperatorRegex || op == v3.FilterOperatorNotLike || op == v3.FilterOperatorNotRegex {
+			x, ok := item.Value.([]interface{})
+			if ok {
+				if len(x) == 0 {
+					return "", nil
 				}
+				toFormat = x[0]
 			}
+		}
 
-			if op == v3.FilterOperatorContains || op == v3.FilterOperatorNotContains {
-				toFormat = fmt.Sprintf("%%%s%%", toFormat)
-			}
-			fmtVal := utils.ClickHouseFormattedValue(toFormat)
-			switch op {
-			case v3.FilterOperatorEqual:
-				conditions = append(conditions, fmt.Sprintf("JSONExtractString(labels, '%s') = %s", item.Key.Key, fmtVal))
-			case v3.FilterOperatorNotEqual:
-				conditions = append(conditions, fmt.Sprintf("JSONExtractString(labels, '%s') != %s", item.Key.Key, fmtVal))
-			case v3.FilterOperatorIn:
-				conditions = append(conditions, fmt.Sprintf("JSONExtractString(labels, '%s') IN %s", item.Key.Key, fmtVal))
-			case v3.FilterOperatorNotIn:
-				conditions = append(conditions, fmt.Sprintf("JSONExtractString(labels, '%s') NOT IN %s", item.Key.Key, fmtVal))
-			case v3.FilterOperatorLike:
-				conditions = append(conditions, fmt.Sprintf("like(JSONExtractString(labels, '%s'), %s)", item.Key.Key, fmtVal))
-			case v3.FilterOperatorNotLike:
-				conditions = append(conditions, fmt.Sprintf("notLike(JSONExtractString(labels, '%s'), %s)", item.Key.Key, fmtVal))
-			case v3.FilterOperatorRegex:
-				conditions = append(conditions, fmt.Sprintf("match(JSONExtractString(labels, '%s'), %s)", item.Key.Key, fmtVal))
-			case v3.FilterOperatorNotRegex:
-				conditions = append(conditions, fmt.Sprintf("not match(JSONExtractString(labels, '%s'), %s)", item.Key.Key, fmtVal))
-			case v3.FilterOperatorGreaterThan:
-				conditions = append(conditions, fmt.Sprintf("JSONExtractString(labels, '%s') > %s", item.Key.Key, fmtVal))
-			case v3.FilterOperatorGreaterThanOrEq:
-				conditions = append(conditions, fmt.Sprintf("JSONExtractString(labels, '%s') >= %s", item.Key.Key, fmtVal))
-			case v3.FilterOperatorLessThan:
-				conditions = append(conditions, fmt.Sprintf("JSONExtractString(labels, '%s') < %s", item.Key.Key, fmtVal))
-			case v3.FilterOperatorLessThanOrEq:
-				conditions = append(conditions, fmt.Sprintf("JSONExtractString(labels, '%s') <= %s", item.Key.Key, fmtVal))
-			case v3.FilterOperatorContains:
-				conditions = append(conditions, fmt.Sprintf("like(JSONExtractString(labels, '%s'), %s)", item.Key.Key, fmtVal))
-			case v3.FilterOperatorNotContains:
-				conditions = append(conditions, fmt.Sprintf("notLike(JSONExtractString(labels, '%s'), %s)", item.Key.Key, fmtVal))
-			case v3.FilterOperatorExists:
-				conditions = append(conditions, fmt.Sprintf("has(JSONExtractKeys(labels), '%s')", item.Key.Key))
-			case v3.FilterOperatorNotExists:
-				conditions = append(conditions, fmt.Sprintf("not has(JSONExtractKeys(labels), '%s')", item.Key.Key))
-			default:
-				return "", fmt.Errorf("unsupported operation")
-			}
+		if op == v3.FilterOperatorContains || op == v3.FilterOperatorNotContains {
+			toFormat = fmt.Sprintf("%%%s%%", toFormat)
+		}
+		fmtVal := utils.ClickHouseFormattedValue(toFormat)
+		key := utils.QuoteString(item.Key.Key)
+		switch op {
+		case v3.FilterOperatorEqual:
+			return fmt.Sprintf("JSONExtractString(labels, %s) = %s", key, fmtVal), nil
+		case v3.FilterOperatorNotEqual:
+			return fmt.Sprintf("JSONExtractString(labels, %s) != %s", key, fmtVal), nil
+		case v3.FilterOperatorIn:
+			return fmt.Sprintf("JSONExtractString(labels, %s) IN %s", key, fmtVal), nil
+		case v3.FilterOperatorNotIn:
+			return fmt.Sprintf("JSONExtractString(labels, %s) NOT IN %s", key, fmtVal), nil
+		case v3.FilterOperatorLike:
+			return fmt.Sprintf("like(JSONExtractString(labels, %s), %s)", key, fmtVal), nil
+		case v3.FilterOperatorNotLike:
+			return fmt.Sprintf("notLike(JSONExtractString(labels, %s), %s)", key, fmtVal), nil
+		case v3.FilterOperatorRegex:
+			return fmt.Sprintf("match(JSONExtractString(labels, %s), %s)", key, fmtVal), nil
+		case v3.FilterOperatorNotRegex:
+			return fmt.Sprintf("not match(JSONExtractString(labels, %s), %s)", key, fmtVal), nil
+		case v3.FilterOperatorGreaterThan:
+			return fmt.Sprintf("JSONExtractString(labels, %s) > %s", key, fmtVal), nil
+		case v3.FilterOperatorGreaterThanOrEq:
+			return fmt.Sprintf("JSONExtractString(labels, %s) >= %s", key, fmtVal), nil
+		case v3.FilterOperatorLessThan:
+			return fmt.Sprintf("JSONExtractString(labels, %s) < %s", key, fmtVal), nil
+		case v3.FilterOperatorLessThanOrEq:
+			return fmt.Sprintf("JSONExtractString(labels, %s) <= %s", key, fmtVal), nil
+		case v3.FilterOperatorContains:
+			return fmt.Sprintf("like(JSONExtractString(labels, %s), %s)", key, fmtVal), nil
+		case v3.FilterOperatorNotContains:
+			return fmt.Sprintf("notLike(JSONExtractString(labels, %s), %s)", key, fmtVal), nil
+		case v3.FilterOperatorExists:
+			return fmt.Sprintf("has(JSONExtractKeys(labels), %s)", key), nil
+		case v3.FilterOperatorNotExists:
+			return fmt.Sprintf("not has(JSONExtractKeys(labels), %s)", key), nil
+		default:
+			return "", fmt.Errorf("unsupported operation")
 		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if filterCondition != "" {
+		conditions = append(conditions, filterCondition)
 	}
 	queryString := strings.Join(conditions, " AND ")
 
@@ -117,7 +122,7 @@ func buildMetricsTimeSeriesFilterQuery(fs *v3.FilterSet, groupTags []v3.Attribut
 		selectLabels = "labels,"
 	} else {
 		for _, tag := range groupTags {
-			selectLabels += fmt.Sprintf(" JSONExtractString(labels, '%s') as %s,", tag.Key, tag.Key)
+			selectLabels += fmt.Sprintf(" JSONExtractString(labels, %s) as %s,", utils.QuoteString(tag.Key), utils.ClickHouseFormattedIdentifier(tag.Key))
 		}
 	}
 
@@ -136,7 +141,8 @@ func buildMetricQuery(start, end, step int64, mq *v3.BuilderQuery, tableName str
 		mq.AggregateOperator == v3.AggregateOperatorHistQuant75 ||
 		mq.AggregateOperator == v3.AggregateOperatorHistQuant90 ||
 		mq.AggregateOperator == v3.AggregateOperatorHistQuant95 ||
-		mq.AggregateOperator == v3.AggregateOperatorHistQuant99 {
+		mq.AggregateOperator == v3.AggregateOperatorHistQuant99 ||
+		mq.AggregateOperator == v3.AggregateOperatorHistQuant {
 		found := false
 		for _, tag := range mq.GroupBy {
 			if tag.Key == "le" {
@@ -247,11 +253,20 @@ func buildMetricQuery(start, end, step int64, mq *v3.BuilderQuery, tableName str
 		v3.AggregateOperatorP75,
 		v3.AggregateOperatorP90,
 		v3.AggregateOperatorP95,
-		v3.AggregateOperatorP99:
-		op := fmt.Sprintf("quantile(%v)(value)", aggregateOperatorToPercentile[mq.AggregateOperator])
+		v3.AggregateOperatorP99,
+		v3.AggregateOperatorQuantile:
+		percentile, err := mq.Percentile(aggregateOperatorToPercentile)
+		if err != nil {
+			return "", err
+		}
+		op := fmt.Sprintf("quantile(%v)(value)", percentile)
 		query := fmt.Sprintf(queryTmpl, groupTags, step, op, filterSubQuery, groupBy, orderBy)
 		return query, nil
-	case v3.AggregateOperatorHistQuant50, v3.AggregateOperatorHistQuant75, v3.AggregateOperatorHistQuant90, v3.AggregateOperatorHistQuant95, v3.AggregateOperatorHistQuant99:
+	case v3.AggregateOperatorHistQuant50, v3.AggregateOperatorHistQuant75, v3.AggregateOperatorHistQuant90, v3.AggregateOperatorHistQuant95, v3.AggregateOperatorHistQuant99, v3.AggregateOperatorHistQuant:
+		value, err := mq.Percentile(aggregateOperatorToPercentile)
+		if err != nil {
+			return "", err
+		}
 		rateGroupBy := "fingerprint, " + groupBy
 		rateGroupTags := "fingerprint, " + groupTags
 		rateOrderBy := "fingerprint, " + orderBy
@@ -262,9 +277,8 @@ func buildMetricQuery(start, end, step int64, mq *v3.BuilderQuery, tableName str
 		query := `SELECT %s ts, ` + rateWithoutNegative + ` as value FROM(%s) WHERE isNaN(value) = 0`
 		query = fmt.Sprintf(query, groupTags, subQuery)
 		query = fmt.Sprintf(`SELECT %s ts, sum(value) as value FROM (%s) GROUP BY %s HAVING isNaN(value) = 0 ORDER BY %s ts`, groupTags, query, groupBy, orderBy)
-		value := aggregateOperatorToPercentile[mq.AggregateOperator]
 
-		query = fmt.Sprintf(`SELECT %s ts, histogramQuantile(arrayMap(x -> toFloat64(x), groupArray(le)), groupArray(value), %.3f) as value FROM (%s) GROUP BY %s ORDER BY %s ts`, groupTagsWithoutLe, value, query, groupByWithoutLe, orderWithoutLe)
+		query = fmt.Sprintf(`SELECT %s ts, histogramQuantile(arrayMap(x -> toFloat64(x), groupArray(le)), groupArray(value), %v) as value FROM (%s) GROUP BY %s ORDER BY %s ts`, groupTagsWithoutLe, value, query, groupByWithoutLe, orderWithoutLe)
 		return query, nil
 	case v3.AggregateOperatorAvg, v3.AggregateOperatorSum, v3.AggregateOperatorMin, v3.AggregateOperatorMax:
 		op := fmt.Sprintf("%s(value)", aggregateOperatorToSQLFunc[mq.AggregateOperator])
@@ -297,16 +311,24 @@ func buildMetricQuery(start, end, step int64, mq *v3.BuilderQuery, tableName str
 	}
 }
 
+// formattedTags returns the tags formatted as identifiers
+func formattedTags(tags []string) []string {
+	formatted := make([]string, 0, len(tags)+1)
+	for _, tag := range tags {
+		formatted = append(formatted, utils.ClickHouseFormattedIdentifier(tag))
+	}
+	return formatted
+}
+
 // groupBy returns a string of comma separated tags for group by clause
 // `ts` is always added to the group by clause
 func groupBy(tags ...string) string {
-	tags = append(tags, "ts")
-	return strings.Join(tags, ",")
+	return strings.Join(append(formattedTags(tags), "ts"), ",")
 }
 
 // groupSelect returns a string of comma separated tags for select clause
 func groupSelect(tags ...string) string {
-	groupTags := strings.Join(tags, ",")
+	groupTags := strings.Join(formattedTags(tags), ",")
 	if len(tags) != 0 {
 		groupTags += ", "
 	}
@@ -338,12 +360,12 @@ func orderBy(items []v3.OrderBy, tags []string) string {
 		for _, item := range items {
 			if item.ColumnName == tag {
 				found = true
-				orderBy = append(orderBy, fmt.Sprintf("%s %s", item.ColumnName, item.Order))
+				orderBy = append(orderBy, fmt.Sprintf("%s %s", utils.ClickHouseFormattedIdentifier(tag), item.Order))
 				break
 			}
 		}
 		if !found {
-			orderBy = append(orderBy, fmt.Sprintf("%s ASC", tag))
+			orderBy = append(orderBy, fmt.Sprintf("%s ASC", utils.ClickHouseFormattedIdentifier(tag)))
 		}
 	}
 	return strings.Join(orderBy, ",")
@@ -360,7 +382,7 @@ func orderByAttributeKeyTags(items []v3.OrderBy, tags []v3.AttributeKey) string
 func having(items []v3.Having) string {
 	var having []string
 	for _, item := range items {
-		having = append(having, fmt.Sprintf("%s %s %v", "value", item.Operator, utils.ClickHouseFormattedValue(item.Value)))
+		having = append(having, fmt.Sprintf("%s %s %v", "value", item.SQLOperator(), utils.ClickHouseFormattedValue(item.Value)))
 	}
 	return strings.Join(having, " AND ")
 }
@@ -63,6 +63,39 @@ func TestBuildQueryWithFilters(t *testing.T) {
 	})
 }
 
+func TestBuildQueryWithNestedFilters(t *testing.T) {
+	t.Run("TestBuildQueryWithNestedFilters", func(t *testing.T) {
+		q := &v3.QueryRangeParamsV3{
+			Start: 1650991982000,
+			End:   1651078382000,
+			Step:  60,
+			CompositeQuery: &v3.CompositeQuery{
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:          "A",
+						AggregateAttribute: v3.AttributeKey{Key: "name"},
+						Filters: &v3.FilterSet{Operator: "OR", Groups: []v3.FilterSet{
+							{Items: []v3.FilterItem{
+								{Key: v3.AttributeKey{Key: "service"}, Value: "a", Operator: v3.FilterOperatorEqual},
+								{Key: v3.AttributeKey{Key: "status"}, Value: "500", Operator: v3.FilterOperatorGreaterThanOrEq},
+							}},
+							{Operator: "NOT", Items: []v3.FilterItem{
+								{Key: v3.AttributeKey{Key: "service"}, Value: []interface{}{"b", "c"}, Operator: v3.FilterOperatorIn},
+							}},
+						}},
+						AggregateOperator: v3.AggregateOperatorSum,
+						Expression:        "A",
+					},
+				},
+			},
+		}
+		query, err := PrepareMetricQuery(q.Start, q.End, q.CompositeQuery.QueryType, q.CompositeQuery.PanelType, q.CompositeQuery.BuilderQueries["A"])
+		require.NoError(t, err)
+
+		require.Contains(t, query, "WHERE metric_name = 'name' AND ((JSONExtractString(labels, 'service') = 'a' AND JSONExtractString(labels, 'status') >= '500') OR NOT (JSONExtractString(labels, 'service') IN ['b','c']))")
+	})
+}
+
 func TestBuildQueryWithMultipleQueries(t *testing.T) {
 	t.Run("TestBuildQueryWithFilters", func(t *testing.T) {
 		q := &v3.QueryRangeParamsV3{
@@ -235,6 +268,30 @@ func TestBuildQueryOperators(t *testing.T) {
 	}
 }
 
+func TestBuildQueryQuantile(t *testing.T) {
+	t.Run("TestBuildQueryQuantile", func(t *testing.T) {
+		mq := &v3.BuilderQuery{
+			QueryName:          "A",
+			AggregateAttribute: v3.AttributeKey{Key: "name"},
+			AggregateOperator:  v3.AggregateOperatorQuantile,
+			Quantile:           0.999,
+			Expression:         "A",
+		}
+		query, err := PrepareMetricQuery(1650991982000, 1651078382000, v3.QueryTypeBuilder, v3.PanelTypeGraph, mq)
+		require.NoError(t, err)
+		require.Contains(t, query, "quantile(0.999)(value) as value")
+
+		mq.AggregateOperator = v3.AggregateOperatorHistQuant
+		query, err = PrepareMetricQuery(1650991982000, 1651078382000, v3.QueryTypeBuilder, v3.PanelTypeGraph, mq)
+		require.NoError(t, err)
+		require.Contains(t, query, "histogramQuantile(arrayMap(x -> toFloat64(x), groupArray(le)), groupArray(value), 0.999) as value")
+
+		mq.Quantile = 1.5
+		_, err = PrepareMetricQuery(1650991982000, 1651078382000, v3.QueryTypeBuilder, v3.PanelTypeGraph, mq)
+		require.Error(t, err)
+	})
+}
+
 func TestBuildQueryXRate(t *testing.T) {
 	t.Run("TestBuildQueryXRate", func(t *testing.T) {
 
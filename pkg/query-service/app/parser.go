@@ -66,6 +66,44 @@ func parseGetTopOperationsRequest(r *http.Request) (*model.GetTopOperationsParam
 	return postData, nil
 }
 
+func parseTraceWindowsDiffRequest(r *http.Request) (*model.TraceWindowsDiffParams, error) {
+	var postData *model.TraceWindowsDiffParams
+	err := json.NewDecoder(r.Body).Decode(&postData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	postData.BaseStart, err = parseTimeStr(postData.BaseStartTime, "baseStart")
+	if err != nil {
+		return nil, err
+	}
+	postData.BaseEnd, err = parseTimeStr(postData.BaseEndTime, "baseEnd")
+	if err != nil {
+		return nil, err
+	}
+	postData.TargetStart, err = parseTimeStr(postData.TargetStartTime, "targetStart")
+	if err != nil {
+		return nil, err
+	}
+	postData.TargetEnd, err = parseTimeStr(postData.TargetEndTime, "targetEnd")
+	if err != nil {
+		return nil, err
+	}
+	if !postData.BaseStart.Before(*postData.BaseEnd) || !postData.TargetStart.Before(*postData.TargetEnd) {
+		return nil, errors.New("the start of a window should be before its end")
+	}
+
+	if len(postData.ServiceName) == 0 {
+		return nil, errors.New("service param missing in query")
+	}
+	if len(postData.Operation) == 0 {
+		return nil, errors.New("operation param missing in query")
+	}
+
+	return postData, nil
+}
+
 func parseMetricsTime(s string) (time.Time, error) {
 	if t, err := strconv.ParseFloat(s, 64); err == nil {
 		s, ns := math.Modf(t)
@@ -648,6 +686,72 @@ func parseTimeMinusBuffer(param string, r *http.Request) (*time.Time, error) {
 
 }
 
+// parseRuleStateHistoryRequest returns the start and end (unix milli) of
+// the alert state history requested, the last 24 hours by default
+func parseRuleStateHistoryRequest(r *http.Request) (int64, int64, error) {
+	end := time.Now().UnixMilli()
+	if endStr := r.URL.Query().Get("end"); endStr != "" {
+		var err error
+		if end, err = strconv.ParseInt(endStr, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("end param is not in correct timestamp format")
+		}
+	}
+	start := end - (24 * time.Hour).Milliseconds()
+	if startStr := r.URL.Query().Get("start"); startStr != "" {
+		var err error
+		if start, err = strconv.ParseInt(startStr, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("start param is not in correct timestamp format")
+		}
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("start param should not be after end param")
+	}
+	return start, end, nil
+}
+
+// parseOptionalTimeRange returns the start and the end (ms) of the request,
+// both are zero when they are not given
+func parseOptionalTimeRange(r *http.Request) (int64, int64, error) {
+	startStr, endStr := r.URL.Query().Get("start"), r.URL.Query().Get("end")
+	if startStr == "" && endStr == "" {
+		return 0, 0, nil
+	}
+	if startStr == "" || endStr == "" {
+		return 0, 0, fmt.Errorf("both start and end params are required")
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("start param is not in correct timestamp format")
+	}
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("end param is not in correct timestamp format")
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("start param should not be after end param")
+	}
+	return start, end, nil
+}
+
+// parseTraceLogsRequest returns the time range and the number of the logs of
+// the trace requested
+func parseTraceLogsRequest(r *http.Request) (int64, int64, uint64, error) {
+	start, end, err := parseOptionalTimeRange(r)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	limit := uint64(defaultTraceLogsLimit)
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if limit, err = strconv.ParseUint(limitStr, 10, 64); err != nil || limit == 0 {
+			return 0, 0, 0, fmt.Errorf("limit param should be a positive number")
+		}
+	}
+	if limit > maxTraceLogsLimit {
+		return 0, 0, 0, fmt.Errorf("limit param can be at most %d", maxTraceLogsLimit)
+	}
+	return start, end, limit, nil
+}
+
 func parseTTLParams(r *http.Request) (*model.TTLParams, error) {
 
 	// make sure either of the query params are present
@@ -970,11 +1074,7 @@ func ParseQueryRangeParams(r *http.Request) (*v3.QueryRangeParamsV3, *model.ApiE
 
 	if queryRangeParams.CompositeQuery.QueryType == v3.QueryTypeBuilder {
 		for _, query := range queryRangeParams.CompositeQuery.BuilderQueries {
-			if query.Filters == nil || len(query.Filters.Items) == 0 {
-				continue
-			}
-			for idx := range query.Filters.Items {
-				item := &query.Filters.Items[idx]
+			query.Filters.Walk(func(item *v3.FilterItem) {
 				value := item.Value
 				if value != nil {
 					switch x := value.(type) {
@@ -995,7 +1095,7 @@ func ParseQueryRangeParams(r *http.Request) (*v3.QueryRangeParamsV3, *model.ApiE
 						}
 					}
 				}
-			}
+			})
 		}
 	}
 	queryRangeParams.Variables = formattedVars
@@ -757,3 +757,61 @@ func TestParseQueryRangeParamsDashboardVarsSubstitution(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTraceLogsRequest(t *testing.T) {
+	reqCases := []struct {
+		desc          string
+		queryString   string
+		expectedStart int64
+		expectedEnd   int64
+		expectedLimit uint64
+		expectErr     bool
+		errMsg        string
+	}{
+		{
+			desc:          "defaults",
+			expectedLimit: defaultTraceLogsLimit,
+		},
+		{
+			desc:          "time range and limit",
+			queryString:   "start=1680066360726&end=1680066458000&limit=50",
+			expectedStart: 1680066360726,
+			expectedEnd:   1680066458000,
+			expectedLimit: 50,
+		},
+		{
+			desc:        "start without end",
+			queryString: "start=1680066360726",
+			expectErr:   true,
+			errMsg:      "both start and end params are required",
+		},
+		{
+			desc:        "start after end",
+			queryString: "start=1680066458000&end=1680066360726",
+			expectErr:   true,
+			errMsg:      "start param should not be after end param",
+		},
+		{
+			desc:        "limit too large",
+			queryString: "limit=100000",
+			expectErr:   true,
+			errMsg:      "limit param can be at most",
+		},
+	}
+
+	for _, tc := range reqCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/traces/abc/logs?"+tc.queryString, nil)
+			start, end, limit, err := parseTraceLogsRequest(r)
+			if tc.expectErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStart, start)
+			assert.Equal(t, tc.expectedEnd, end)
+			assert.Equal(t, tc.expectedLimit, limit)
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	promModel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	promParser "github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"go.uber.org/zap"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// promSeriesDefaultRange is the range series and labels are looked up in
+// when the request has no start, the lookup of all the time would be too slow
+const promSeriesDefaultRange = time.Hour
+
+// promLabelsLimit is the most label names or values a lookup returns
+const promLabelsLimit = 10000
+
+// RegisterPrometheusRoutes registers a subset of the Prometheus HTTP API so
+// that tools like Grafana can use query-service as a Prometheus datasource,
+// with http://<query-service>/prometheus as the datasource url
+func (aH *APIHandler) RegisterPrometheusRoutes(router *mux.Router, am *AuthMiddleware) {
+	subRouter := router.PathPrefix("/prometheus/api/v1").Subrouter()
+	subRouter.HandleFunc("/query", am.ViewAccess(aH.promQuery)).Methods(http.MethodGet, http.MethodPost)
+	subRouter.HandleFunc("/query_range", am.ViewAccess(aH.promQueryRange)).Methods(http.MethodGet, http.MethodPost)
+	subRouter.HandleFunc("/series", am.ViewAccess(aH.promSeries)).Methods(http.MethodGet, http.MethodPost)
+	subRouter.HandleFunc("/labels", am.ViewAccess(aH.promLabels)).Methods(http.MethodGet, http.MethodPost)
+	subRouter.HandleFunc("/label/{name}/values", am.ViewAccess(aH.promLabelValues)).Methods(http.MethodGet)
+}
+
+// promQueryContext applies the timeout param of the request
+func promQueryContext(r *http.Request) (context.Context, context.CancelFunc, *model.ApiError) {
+	ctx := r.Context()
+	if to := r.FormValue("timeout"); to != "" {
+		timeout, err := parseMetricsDuration(to)
+		if err != nil {
+			return nil, nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// promResultError maps the errors of the query engine to the error types
+// of the Prometheus API
+func promResultError(err error) *model.ApiError {
+	switch err.(type) {
+	case promql.ErrQueryCanceled:
+		return &model.ApiError{Typ: model.ErrorCanceled, Err: err}
+	case promql.ErrQueryTimeout:
+		return &model.ApiError{Typ: model.ErrorTimeout, Err: err}
+	case promql.ErrStorage:
+		return &model.ApiError{Typ: model.ErrorInternal, Err: err}
+	}
+	return &model.ApiError{Typ: model.ErrorExec, Err: err}
+}
+
+func (aH *APIHandler) promQuery(w http.ResponseWriter, r *http.Request) {
+	params, apiErr := parseInstantQueryMetricsRequest(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	ctx, cancel, apiErr := promQueryContext(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	defer cancel()
+
+	qry, err := aH.reader.GetQueryEngine().NewInstantQuery(*aH.reader.GetFanoutStorage(), &promql.QueryOpts{}, params.Query, params.Time)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	defer qry.Close()
+
+	res := qry.Exec(ctx)
+	if res.Err != nil {
+		RespondError(w, promResultError(res.Err), nil)
+		return
+	}
+	aH.Respond(w, &model.QueryDataV2{ResultType: res.Value.Type(), Result: res.Value})
+}
+
+func (aH *APIHandler) promQueryRange(w http.ResponseWriter, r *http.Request) {
+	params, apiErr := parseQueryRangeRequest(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	ctx, cancel, apiErr := promQueryContext(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	defer cancel()
+
+	qry, err := aH.reader.GetQueryEngine().NewRangeQuery(*aH.reader.GetFanoutStorage(), &promql.QueryOpts{}, params.Query, params.Start, params.End, params.Step)
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: err}, nil)
+		return
+	}
+	defer qry.Close()
+
+	res := qry.Exec(ctx)
+	if res.Err != nil {
+		RespondError(w, promResultError(res.Err), nil)
+		return
+	}
+	aH.Respond(w, &model.QueryDataV2{ResultType: res.Value.Type(), Result: res.Value})
+}
+
+// parsePromMatchers parses the match[] selectors of the request
+func parsePromMatchers(r *http.Request) ([][]*labels.Matcher, *model.ApiError) {
+	if err := r.ParseForm(); err != nil {
+		return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+	}
+	var matcherSets [][]*labels.Matcher
+	for _, selector := range r.Form["match[]"] {
+		matchers, err := promParser.ParseMetricSelector(selector)
+		if err != nil {
+			return nil, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+		}
+		matcherSets = append(matcherSets, matchers)
+	}
+	return matcherSets, nil
+}
+
+// parsePromTimeRange parses the optional start and end of the request, end
+// defaults to now and start to promSeriesDefaultRange before end
+func parsePromTimeRange(r *http.Request) (time.Time, time.Time, *model.ApiError) {
+	end := time.Now()
+	if t := r.FormValue("end"); t != "" {
+		var err error
+		end, err = parseMetricsTime(t)
+		if err != nil {
+			return time.Time{}, time.Time{}, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+		}
+	}
+	start := end.Add(-promSeriesDefaultRange)
+	if t := r.FormValue("start"); t != "" {
+		var err error
+		start, err = parseMetricsTime(t)
+		if err != nil {
+			return time.Time{}, time.Time{}, &model.ApiError{Typ: model.ErrorBadData, Err: err}
+		}
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("end timestamp must not be before start time")}
+	}
+	return start, end, nil
+}
+
+func (aH *APIHandler) promSeries(w http.ResponseWriter, r *http.Request) {
+	matcherSets, apiErr := parsePromMatchers(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	if len(matcherSets) == 0 {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("no match[] parameter provided")}, nil)
+		return
+	}
+	start, end, apiErr := parsePromTimeRange(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	ctx, cancel, apiErr := promQueryContext(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	defer cancel()
+
+	querier, err := (*aH.reader.GetFanoutStorage()).Querier(ctx, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		RespondError(w, &model.ApiError{Typ: model.ErrorExec, Err: err}, nil)
+		return
+	}
+	defer querier.Close()
+
+	hints := &storage.SelectHints{Start: start.UnixMilli(), End: end.UnixMilli(), Func: "series"}
+	seen := make(map[uint64]bool)
+	series := []labels.Labels{}
+	for _, matchers := range matcherSets {
+		set := querier.Select(false, hints, matchers...)
+		for set.Next() {
+			lbls := set.At().Labels()
+			if h := lbls.Hash(); !seen[h] {
+				seen[h] = true
+				series = append(series, lbls)
+			}
+		}
+		if err := set.Err(); err != nil {
+			RespondError(w, &model.ApiError{Typ: model.ErrorExec, Err: err}, nil)
+			return
+		}
+		for _, warning := range set.Warnings() {
+			zap.S().Warn("warning in series lookup: ", warning)
+		}
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return labels.Compare(series[i], series[j]) < 0
+	})
+	aH.Respond(w, series)
+}
+
+// promLabelParams turns the match[] selectors into the params of the label
+// lookups in the time range. Only the equality matchers narrow down the
+// lookup, a selector without a metric name looks up all the metrics.
+func promLabelParams(matcherSets [][]*labels.Matcher, start, end time.Time) []*model.PromLabelParams {
+	newParams := func() *model.PromLabelParams {
+		return &model.PromLabelParams{
+			MetricTags: map[string]string{},
+			Start:      start.UnixMilli(),
+			End:        end.UnixMilli(),
+			Limit:      promLabelsLimit,
+		}
+	}
+	if len(matcherSets) == 0 {
+		return []*model.PromLabelParams{newParams()}
+	}
+	params := make([]*model.PromLabelParams, 0, len(matcherSets))
+	for _, matchers := range matcherSets {
+		p := newParams()
+		for _, m := range matchers {
+			if m.Type != labels.MatchEqual {
+				continue
+			}
+			if m.Name == labels.MetricName {
+				p.MetricName = m.Value
+			} else {
+				p.MetricTags[m.Name] = m.Value
+			}
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// sortedUnique returns the non empty values sorted without duplicates
+func sortedUnique(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := []string{}
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+func (aH *APIHandler) promLabels(w http.ResponseWriter, r *http.Request) {
+	matcherSets, apiErr := parsePromMatchers(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	start, end, apiErr := parsePromTimeRange(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	var names []string
+	for _, params := range promLabelParams(matcherSets, start, end) {
+		found, apiErr := aH.reader.GetPromLabelNames(r.Context(), params)
+		if apiErr != nil {
+			RespondError(w, apiErr, nil)
+			return
+		}
+		names = append(names, *found...)
+	}
+	aH.Respond(w, sortedUnique(names))
+}
+
+func (aH *APIHandler) promLabelValues(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if !promModel.LabelName(name).IsValid() {
+		RespondError(w, &model.ApiError{Typ: model.ErrorBadData, Err: fmt.Errorf("invalid label name: %q", name)}, nil)
+		return
+	}
+	matcherSets, apiErr := parsePromMatchers(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+	start, end, apiErr := parsePromTimeRange(r)
+	if apiErr != nil {
+		RespondError(w, apiErr, nil)
+		return
+	}
+
+	var values []string
+	for _, params := range promLabelParams(matcherSets, start, end) {
+		params.LabelName = name
+		found, apiErr := aH.reader.GetPromLabelValues(r.Context(), params)
+		if apiErr != nil {
+			RespondError(w, apiErr, nil)
+			return
+		}
+		values = append(values, *found...)
+	}
+	aH.Respond(w, sortedUnique(values))
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPromLabelParams(t *testing.T) {
+	form := url.Values{}
+	form.Add("match[]", `up{job="api", instance=~"host.*"}`)
+	form.Add("match[]", `{service_name="frontend"}`)
+	req := httptest.NewRequest("POST", "/prometheus/api/v1/labels", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	matcherSets, apiErr := parsePromMatchers(req)
+	if apiErr != nil {
+		t.Fatalf("unexpected error: %v", apiErr)
+	}
+	start, end := time.Unix(1680066000, 0), time.Unix(1680069600, 0)
+	params := promLabelParams(matcherSets, start, end)
+	if len(params) != 2 {
+		t.Fatalf("expected 2 params, got %d", len(params))
+	}
+	if params[0].MetricName != "up" || !reflect.DeepEqual(map[string]string(params[0].MetricTags), map[string]string{"job": "api"}) {
+		t.Errorf("expected the metric name and the equality matchers, got %+v", params[0])
+	}
+	if params[1].MetricName != "" || !reflect.DeepEqual(map[string]string(params[1].MetricTags), map[string]string{"service_name": "frontend"}) {
+		t.Errorf("expected all the metrics with the tag, got %+v", params[1])
+	}
+	for _, p := range params {
+		if p.Start != start.UnixMilli() || p.End != end.UnixMilli() || p.Limit != promLabelsLimit {
+			t.Errorf("expected the time range and the limit of the lookup, got %+v", p)
+		}
+	}
+
+	if params := promLabelParams(nil, start, end); len(params) != 1 || params[0].MetricName != "" {
+		t.Errorf("expected a single lookup of all the metrics, got %+v", params)
+	}
+}
+
+func TestParsePromMatchersInvalid(t *testing.T) {
+	req := httptest.NewRequest("GET", "/prometheus/api/v1/series?match[]="+url.QueryEscape(`up{job=}`), nil)
+	if _, apiErr := parsePromMatchers(req); apiErr == nil {
+		t.Errorf("expected an error for the invalid selector")
+	}
+}
+
+func TestSortedUnique(t *testing.T) {
+	got := sortedUnique([]string{"job", "", "instance", "job", "__name__"})
+	expected := []string{"__name__", "instance", "job"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
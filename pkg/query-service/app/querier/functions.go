@@ -0,0 +1,211 @@
+package querier
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// applyFunctions applies the functions in order to the points of each
+// series. The series are not cached after the functions are applied, so the
+// cache keeps the raw points the functions can be applied to again.
+// start and end are unix milli and step is in seconds, they are used to
+// fill the gaps of the series.
+func applyFunctions(series []*v3.Series, functions []v3.Function, start, end, step int64) error {
+	if len(functions) == 0 {
+		return nil
+	}
+	for _, s := range series {
+		s.SortPoints()
+		for idx := range functions {
+			points, err := applyFunction(s.Points, &functions[idx], start, end, step)
+			if err != nil {
+				return err
+			}
+			s.Points = finitePoints(points)
+		}
+	}
+	return nil
+}
+
+func applyFunction(points []v3.Point, function *v3.Function, start, end, step int64) ([]v3.Point, error) {
+	arg, hasArg, err := function.Arg(0)
+	if err != nil {
+		return nil, fmt.Errorf("function %s is invalid: %w", function.Name, err)
+	}
+
+	switch function.Name {
+	case v3.FunctionNameMovingAvg:
+		return movingWindow(points, int(arg), mean), nil
+	case v3.FunctionNameMovingMedian:
+		return movingWindow(points, int(arg), median), nil
+	case v3.FunctionNameEWMA:
+		return ewma(points, arg), nil
+	case v3.FunctionNameClampMin:
+		return mapPoints(points, func(v float64) float64 { return math.Max(v, arg) }), nil
+	case v3.FunctionNameClampMax:
+		return mapPoints(points, func(v float64) float64 { return math.Min(v, arg) }), nil
+	case v3.FunctionNameCutOffMin:
+		return filterPoints(points, func(v float64) bool { return v >= arg }), nil
+	case v3.FunctionNameCutOffMax:
+		return filterPoints(points, func(v float64) bool { return v <= arg }), nil
+	case v3.FunctionNameAbsolute:
+		return mapPoints(points, math.Abs), nil
+	case v3.FunctionNameLog:
+		base := 10.0
+		if hasArg {
+			base = arg
+		}
+		return mapPoints(points, func(v float64) float64 { return math.Log(v) / math.Log(base) }), nil
+	case v3.FunctionNameCumSum:
+		var sum float64
+		return mapPoints(points, func(v float64) float64 {
+			sum += v
+			return sum
+		}), nil
+	case v3.FunctionNameRunningDiff:
+		return runningDiff(points), nil
+	case v3.FunctionNameTimeShift:
+		shift := int64(arg * 1000)
+		shifted := make([]v3.Point, len(points))
+		for idx, p := range points {
+			shifted[idx] = v3.Point{Timestamp: p.Timestamp + shift, Value: p.Value}
+		}
+		return shifted, nil
+	case v3.FunctionNameFillZero:
+		return fillGaps(points, start, end, step, false), nil
+	case v3.FunctionNameFillPrevious:
+		return fillGaps(points, start, end, step, true), nil
+	}
+	return nil, fmt.Errorf("unknown function %s", function.Name)
+}
+
+func mapPoints(points []v3.Point, fn func(float64) float64) []v3.Point {
+	mapped := make([]v3.Point, len(points))
+	for idx, p := range points {
+		mapped[idx] = v3.Point{Timestamp: p.Timestamp, Value: fn(p.Value)}
+	}
+	return mapped
+}
+
+func filterPoints(points []v3.Point, keep func(float64) bool) []v3.Point {
+	filtered := make([]v3.Point, 0, len(points))
+	for _, p := range points {
+		if keep(p.Value) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// finitePoints drops the points json has no representation for, such as
+// the log of a negative value
+func finitePoints(points []v3.Point) []v3.Point {
+	return filterPoints(points, func(v float64) bool {
+		return !math.IsNaN(v) && !math.IsInf(v, 0)
+	})
+}
+
+// movingWindow replaces each point with the reduced value of the window of
+// the last n points ending at it, the first points have shorter windows
+func movingWindow(points []v3.Point, n int, reduce func([]float64) float64) []v3.Point {
+	moved := make([]v3.Point, len(points))
+	window := make([]float64, 0, n)
+	for idx, p := range points {
+		if len(window) == n {
+			window = window[1:]
+		}
+		window = append(window, p.Value)
+		moved[idx] = v3.Point{Timestamp: p.Timestamp, Value: reduce(window)}
+	}
+	return moved
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// ewma smooths the points with the exponentially weighted moving average,
+// the higher alpha the more weight the recent points have
+func ewma(points []v3.Point, alpha float64) []v3.Point {
+	smoothed := make([]v3.Point, len(points))
+	var avg float64
+	for idx, p := range points {
+		if idx == 0 {
+			avg = p.Value
+		} else {
+			avg = alpha*p.Value + (1-alpha)*avg
+		}
+		smoothed[idx] = v3.Point{Timestamp: p.Timestamp, Value: avg}
+	}
+	return smoothed
+}
+
+// runningDiff replaces each point with the difference from the previous
+// point, the first point has no previous point and is dropped
+func runningDiff(points []v3.Point) []v3.Point {
+	if len(points) < 2 {
+		return []v3.Point{}
+	}
+	diffs := make([]v3.Point, 0, len(points)-1)
+	for idx := 1; idx < len(points); idx++ {
+		diffs = append(diffs, v3.Point{
+			Timestamp: points[idx].Timestamp,
+			Value:     points[idx].Value - points[idx-1].Value,
+		})
+	}
+	return diffs
+}
+
+// fillGaps adds the points missing at each step between start and end,
+// with zero or with the value of the previous point. With the previous
+// value the gaps before the first point stay empty.
+func fillGaps(points []v3.Point, start, end, step int64, previous bool) []v3.Point {
+	if step <= 0 {
+		return points
+	}
+	stepMs := step * 1000
+	start = start - start%stepMs
+
+	filled := make([]v3.Point, 0, (end-start)/stepMs+1)
+	idx := 0
+	var last *v3.Point
+	for ts := start; ts <= end; ts += stepMs {
+		// points off the step are kept as they are
+		for idx < len(points) && points[idx].Timestamp < ts {
+			filled = append(filled, points[idx])
+			last = &points[idx]
+			idx++
+		}
+		if idx < len(points) && points[idx].Timestamp == ts {
+			filled = append(filled, points[idx])
+			last = &points[idx]
+			idx++
+			continue
+		}
+		switch {
+		case !previous:
+			filled = append(filled, v3.Point{Timestamp: ts, Value: 0})
+		case last != nil:
+			filled = append(filled, v3.Point{Timestamp: ts, Value: last.Value})
+		}
+	}
+	return append(filled, points[idx:]...)
+}
@@ -0,0 +1,217 @@
+package querier
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/app/queryBuilder"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func pointsOf(values ...float64) []v3.Point {
+	points := make([]v3.Point, len(values))
+	for idx, v := range values {
+		points[idx] = v3.Point{Timestamp: int64(idx+1) * 60000, Value: v}
+	}
+	return points
+}
+
+func TestApplyFunctions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		points    []v3.Point
+		functions []v3.Function
+		expected  []v3.Point
+	}{
+		{
+			name:      "moving average",
+			points:    pointsOf(1, 2, 3, 4, 5),
+			functions: []v3.Function{{Name: v3.FunctionNameMovingAvg, Args: []interface{}{3.0}}},
+			expected:  pointsOf(1, 1.5, 2, 3, 4),
+		},
+		{
+			name:      "moving median",
+			points:    pointsOf(1, 9, 3, 4, 100),
+			functions: []v3.Function{{Name: v3.FunctionNameMovingMedian, Args: []interface{}{3.0}}},
+			expected:  pointsOf(1, 5, 3, 4, 4),
+		},
+		{
+			name:      "ewma",
+			points:    pointsOf(10, 20, 20),
+			functions: []v3.Function{{Name: v3.FunctionNameEWMA, Args: []interface{}{0.5}}},
+			expected:  pointsOf(10, 15, 17.5),
+		},
+		{
+			name:      "clamp min and max",
+			points:    pointsOf(-5, 5, 50),
+			functions: []v3.Function{{Name: v3.FunctionNameClampMin, Args: []interface{}{0.0}}, {Name: v3.FunctionNameClampMax, Args: []interface{}{10.0}}},
+			expected:  pointsOf(0, 5, 10),
+		},
+		{
+			name:      "cut off min",
+			points:    pointsOf(1, 5, 2),
+			functions: []v3.Function{{Name: v3.FunctionNameCutOffMin, Args: []interface{}{2.0}}},
+			expected:  []v3.Point{{Timestamp: 120000, Value: 5}, {Timestamp: 180000, Value: 2}},
+		},
+		{
+			name:      "cut off max",
+			points:    pointsOf(1, 5, 2),
+			functions: []v3.Function{{Name: v3.FunctionNameCutOffMax, Args: []interface{}{2.0}}},
+			expected:  []v3.Point{{Timestamp: 60000, Value: 1}, {Timestamp: 180000, Value: 2}},
+		},
+		{
+			name:      "absolute",
+			points:    pointsOf(-1, 2),
+			functions: []v3.Function{{Name: v3.FunctionNameAbsolute}},
+			expected:  pointsOf(1, 2),
+		},
+		{
+			name:      "log drops non positive values",
+			points:    pointsOf(100, 0, -1, 1000),
+			functions: []v3.Function{{Name: v3.FunctionNameLog}},
+			expected:  []v3.Point{{Timestamp: 60000, Value: 2}, {Timestamp: 240000, Value: 3}},
+		},
+		{
+			name:      "log with base",
+			points:    pointsOf(8),
+			functions: []v3.Function{{Name: v3.FunctionNameLog, Args: []interface{}{2.0}}},
+			expected:  pointsOf(3),
+		},
+		{
+			name:      "cumulative sum",
+			points:    pointsOf(1, 2, 3),
+			functions: []v3.Function{{Name: v3.FunctionNameCumSum}},
+			expected:  pointsOf(1, 3, 6),
+		},
+		{
+			name:      "running diff",
+			points:    pointsOf(1, 4, 2),
+			functions: []v3.Function{{Name: v3.FunctionNameRunningDiff}},
+			expected:  []v3.Point{{Timestamp: 120000, Value: 3}, {Timestamp: 180000, Value: -2}},
+		},
+		{
+			name:      "time shift",
+			points:    pointsOf(1),
+			functions: []v3.Function{{Name: v3.FunctionNameTimeShift, Args: []interface{}{60.0}}},
+			expected:  []v3.Point{{Timestamp: 120000, Value: 1}},
+		},
+		{
+			name:      "fill zero",
+			points:    []v3.Point{{Timestamp: 120000, Value: 2}, {Timestamp: 240000, Value: 4}},
+			functions: []v3.Function{{Name: v3.FunctionNameFillZero}},
+			expected:  pointsOf(0, 2, 0, 4, 0),
+		},
+		{
+			name:      "fill previous",
+			points:    []v3.Point{{Timestamp: 120000, Value: 2}, {Timestamp: 240000, Value: 4}},
+			functions: []v3.Function{{Name: v3.FunctionNameFillPrevious}},
+			expected:  []v3.Point{{Timestamp: 120000, Value: 2}, {Timestamp: 180000, Value: 2}, {Timestamp: 240000, Value: 4}, {Timestamp: 300000, Value: 4}},
+		},
+		{
+			name:   "functions are applied in order",
+			points: []v3.Point{{Timestamp: 120000, Value: 2}, {Timestamp: 240000, Value: 4}},
+			functions: []v3.Function{
+				{Name: v3.FunctionNameFillZero},
+				{Name: v3.FunctionNameCumSum},
+				{Name: v3.FunctionNameMovingAvg, Args: []interface{}{2.0}},
+			},
+			expected: pointsOf(0, 1, 2, 4, 6),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			series := []*v3.Series{{Points: tc.points}}
+			err := applyFunctions(series, tc.functions, 60000, 300000, 60)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(series[0].Points) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, series[0].Points)
+			}
+			for idx, p := range series[0].Points {
+				if p.Timestamp != tc.expected[idx].Timestamp || math.Abs(p.Value-tc.expected[idx].Value) > 1e-9 {
+					t.Fatalf("expected %v, got %v", tc.expected, series[0].Points)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyFunctionsSortsPoints(t *testing.T) {
+	series := []*v3.Series{{Points: []v3.Point{{Timestamp: 120000, Value: 2}, {Timestamp: 60000, Value: 1}}}}
+	err := applyFunctions(series, []v3.Function{{Name: v3.FunctionNameCumSum}}, 60000, 120000, 60)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(series[0].Points, pointsOf(1, 3)) {
+		t.Errorf("expected %v, got %v", pointsOf(1, 3), series[0].Points)
+	}
+}
+
+func TestQueryRangeFunctions(t *testing.T) {
+	q := NewQuerier(QuerierOptions{
+		Reader:       nil,
+		FluxInterval: 5 * time.Minute,
+		KeyGenerator: queryBuilder.NewKeyGenerator(),
+
+		TestingMode: true,
+		ReturnedSeries: []*v3.Series{
+			{Labels: map[string]string{"service_name": "frontend"}, Points: pointsOf(1, 2, 3)},
+		},
+	})
+	params := &v3.QueryRangeParamsV3{
+		Start: 60000,
+		End:   180000,
+		Step:  60,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:          "A",
+					StepInterval:       60,
+					DataSource:         v3.DataSourceMetrics,
+					AggregateAttribute: v3.AttributeKey{Key: "http_server_requests_seconds_count"},
+					AggregateOperator:  v3.AggregateOperatorSumRate,
+					Expression:         "A",
+					Functions:          []v3.Function{{Name: v3.FunctionNameCumSum}},
+				},
+			},
+		},
+	}
+	results, err, _ := q.QueryRange(context.Background(), params, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(results[0].Series[0].Points, pointsOf(1, 3, 6)) {
+		t.Errorf("expected %v, got %v", pointsOf(1, 3, 6), results[0].Series[0].Points)
+	}
+}
+
+func TestFunctionValidate(t *testing.T) {
+	testCases := []struct {
+		function v3.Function
+		valid    bool
+	}{
+		{v3.Function{Name: v3.FunctionNameMovingAvg, Args: []interface{}{5.0}}, true},
+		{v3.Function{Name: v3.FunctionNameMovingAvg}, false},
+		{v3.Function{Name: v3.FunctionNameMovingAvg, Args: []interface{}{1.5}}, false},
+		{v3.Function{Name: v3.FunctionNameEWMA, Args: []interface{}{0.0}}, false},
+		{v3.Function{Name: v3.FunctionNameEWMA, Args: []interface{}{"0.3"}}, true},
+		{v3.Function{Name: v3.FunctionNameLog}, true},
+		{v3.Function{Name: v3.FunctionNameLog, Args: []interface{}{1.0}}, false},
+		{v3.Function{Name: v3.FunctionNameCumSum, Args: []interface{}{1.0}}, false},
+		{v3.Function{Name: v3.FunctionNameClampMin, Args: []interface{}{true}}, false},
+		{v3.Function{Name: "median"}, false},
+	}
+	for _, tc := range testCases {
+		err := tc.function.Validate()
+		if (err == nil) != tc.valid {
+			t.Errorf("%s %v: expected valid %v, got %v", tc.function.Name, tc.function.Args, tc.valid, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package querier
+
+import (
+	"context"
+	"sync"
+
+	"go.signoz.io/signoz/pkg/query-service/auth"
+)
+
+// userLimiter caps the number of clickhouse queries a single user can have
+// in flight, across all the requests the user makes. Queries that are not
+// made on behalf of a user, e.g. alert rule evaluations, are not limited.
+type userLimiter struct {
+	max int
+
+	mu    sync.Mutex
+	slots map[string]*userSlots
+}
+
+// userSlots are the slots of a user, refs counts the queries holding or
+// waiting for a slot and the entry is removed when it drops to zero
+type userSlots struct {
+	slots chan struct{}
+	refs  int
+}
+
+func newUserLimiter(max int) *userLimiter {
+	return &userLimiter{
+		max:   max,
+		slots: make(map[string]*userSlots),
+	}
+}
+
+// acquire blocks until the user in ctx has a free slot or ctx is done. The
+// returned func releases the slot and must always be called.
+func (l *userLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil || l.max <= 0 {
+		return func() {}, nil
+	}
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok || user == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	slots, ok := l.slots[user.Id]
+	if !ok {
+		slots = &userSlots{slots: make(chan struct{}, l.max)}
+		l.slots[user.Id] = slots
+	}
+	slots.refs++
+	l.mu.Unlock()
+
+	select {
+	case slots.slots <- struct{}{}:
+		return func() {
+			<-slots.slots
+			l.unref(user.Id, slots)
+		}, nil
+	case <-ctx.Done():
+		l.unref(user.Id, slots)
+		return func() {}, ctx.Err()
+	}
+}
+
+// unref drops a reference to the slots of the user and removes them when
+// no query holds or waits for one
+func (l *userLimiter) unref(userId string, slots *userSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots.refs--
+	if slots.refs == 0 {
+		delete(l.slots, userId)
+	}
+}
+
+// runConcurrently calls run for each of the names using at most max
+// goroutines and waits for all of them to return. Names that are not started
+// before ctx is done are passed to onCancel instead.
+func runConcurrently(ctx context.Context, max int, names []string, run func(name string), onCancel func(name string, err error)) {
+	if max <= 0 {
+		max = 1
+	}
+	sem := make(chan struct{}, max)
+	var wg sync.WaitGroup
+	for _, name := range names {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			onCancel(name, ctx.Err())
+			continue
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			run(name)
+		}(name)
+	}
+	wg.Wait()
+}
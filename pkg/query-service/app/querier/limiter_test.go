@@ -0,0 +1,47 @@
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func TestUserLimiterRemovesDrainedSlots(t *testing.T) {
+	l := newUserLimiter(1)
+	ctx := auth.AttachUserToContext(context.Background(), &model.UserPayload{User: model.User{Id: "user-1"}})
+
+	release, err := l.acquire(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// a query waiting for the held slot gives up on the deadline
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	releaseWait, err := l.acquire(waitCtx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+	releaseWait()
+	if len(l.slots) != 1 {
+		t.Fatalf("expected the slots of the user to be kept while held, got %d", len(l.slots))
+	}
+
+	release()
+	if len(l.slots) != 0 {
+		t.Errorf("expected the drained slots to be removed, got %d", len(l.slots))
+	}
+
+	// the user gets new slots on the next query
+	release, err = l.acquire(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release()
+	if len(l.slots) != 0 {
+		t.Errorf("expected the drained slots to be removed, got %d", len(l.slots))
+	}
+}
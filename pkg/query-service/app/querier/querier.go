@@ -1,20 +1,27 @@
 package querier
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"sort"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
+	"github.com/SigNoz/govaluate"
 	logsV3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
 	metricsV3 "go.signoz.io/signoz/pkg/query-service/app/metrics/v3"
+	"go.signoz.io/signoz/pkg/query-service/app/queryBuilder"
 	tracesV3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
 
 	"go.signoz.io/signoz/pkg/query-service/cache"
 	"go.signoz.io/signoz/pkg/query-service/cache/status"
+	"go.signoz.io/signoz/pkg/query-service/constants"
 	"go.signoz.io/signoz/pkg/query-service/interfaces"
 	"go.signoz.io/signoz/pkg/query-service/model"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
@@ -29,12 +36,18 @@ type querier struct {
 	cache        cache.Cache
 	reader       interfaces.Reader
 	keyGenerator cache.KeyGenerator
+	builder      *queryBuilder.QueryBuilder
 
 	fluxInterval time.Duration
 
+	maxConcurrentQueries int
+	timeout              time.Duration
+	userLimiter          *userLimiter
+
 	// used for testing
 	// TODO(srikanthccv): remove this once we have a proper mock
 	testingMode     bool
+	mu              sync.Mutex
 	queriesExecuted []string
 	returnedSeries  []*v3.Series
 	returnedErr     error
@@ -46,6 +59,16 @@ type QuerierOptions struct {
 	KeyGenerator cache.KeyGenerator
 	FluxInterval time.Duration
 
+	// MaxConcurrentQueries is the number of queries of a composite query
+	// run in parallel, defaults to constants.MaxConcurrentQueriesPerRequest
+	MaxConcurrentQueries int
+	// MaxConcurrentQueriesPerUser caps the clickhouse queries a user can
+	// have in flight, defaults to constants.MaxConcurrentQueriesPerUser
+	MaxConcurrentQueriesPerUser int
+	// Timeout is the deadline for all the queries of a request, defaults
+	// to constants.QueryRangeTimeout
+	Timeout time.Duration
+
 	// used for testing
 	TestingMode    bool
 	ReturnedSeries []*v3.Series
@@ -53,28 +76,76 @@ type QuerierOptions struct {
 }
 
 func NewQuerier(opts QuerierOptions) interfaces.Querier {
+	if opts.MaxConcurrentQueries <= 0 {
+		opts.MaxConcurrentQueries = constants.MaxConcurrentQueriesPerRequest
+	}
+	if opts.MaxConcurrentQueriesPerUser <= 0 {
+		opts.MaxConcurrentQueriesPerUser = constants.MaxConcurrentQueriesPerUser
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = constants.QueryRangeTimeout
+	}
 	return &querier{
 		cache:        opts.Cache,
 		reader:       opts.Reader,
 		keyGenerator: opts.KeyGenerator,
 		fluxInterval: opts.FluxInterval,
 
+		maxConcurrentQueries: opts.MaxConcurrentQueries,
+		timeout:              opts.Timeout,
+		userLimiter:          newUserLimiter(opts.MaxConcurrentQueriesPerUser),
+
+		builder: queryBuilder.NewQueryBuilder(queryBuilder.QueryBuilderOptions{
+			BuildTraceQuery:  tracesV3.PrepareTracesQuery,
+			BuildLogQuery:    logsV3.PrepareLogsQuery,
+			BuildMetricQuery: metricsV3.PrepareMetricQuery,
+		}),
+
 		testingMode:    opts.TestingMode,
 		returnedSeries: opts.ReturnedSeries,
 		returnedErr:    opts.ReturnedErr,
 	}
 }
 
-func (q *querier) execClickHouseQuery(ctx context.Context, query string) ([]*v3.Series, error) {
+func (q *querier) recordQuery(query string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 	q.queriesExecuted = append(q.queriesExecuted, query)
+}
+
+func (q *querier) execClickHouseQuery(ctx context.Context, query string) ([]*v3.Series, error) {
+	release, err := q.userLimiter.acquire(ctx)
+	defer release()
+	if err != nil {
+		return nil, err
+	}
+	q.recordQuery(query)
 	if q.testingMode && q.reader == nil {
 		return q.returnedSeries, q.returnedErr
 	}
 	return q.reader.GetTimeSeriesResultV3(ctx, query)
 }
 
+func (q *querier) execClickHouseListQuery(ctx context.Context, query string) ([]*v3.Row, error) {
+	release, err := q.userLimiter.acquire(ctx)
+	defer release()
+	if err != nil {
+		return nil, err
+	}
+	q.recordQuery(query)
+	if q.testingMode && q.reader == nil {
+		return nil, q.returnedErr
+	}
+	return q.reader.GetListResultV3(ctx, query)
+}
+
 func (q *querier) execPromQuery(ctx context.Context, params *model.QueryRangeParams) ([]*v3.Series, error) {
-	q.queriesExecuted = append(q.queriesExecuted, params.Query)
+	release, err := q.userLimiter.acquire(ctx)
+	defer release()
+	if err != nil {
+		return nil, err
+	}
+	q.recordQuery(params.Query)
 	if q.testingMode && q.reader == nil {
 		return q.returnedSeries, q.returnedErr
 	}
@@ -212,7 +283,7 @@ func mergeSerieses(cachedSeries, missedSeries []*v3.Series) []*v3.Series {
 			seriesesByLabels[labelsToString(series.Labels)] = series
 			continue
 		}
-		seriesesByLabels[labelsToString(series.Labels)].Points = append(seriesesByLabels[labelsToString(series.Labels)].Points, series.Points...)
+		seriesesByLabels[labelsToString(series.Labels)].Points = mergePoints(seriesesByLabels[labelsToString(series.Labels)].Points, series.Points)
 	}
 	// Sort the points in each series by timestamp
 	for idx := range seriesesByLabels {
@@ -223,204 +294,435 @@ func mergeSerieses(cachedSeries, missedSeries []*v3.Series) []*v3.Series {
 	return mergedSeries
 }
 
-func (q *querier) runBuilderQueries(ctx context.Context, params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey) ([]*v3.Series, error, map[string]string) {
+// mergePoints appends the missed points to the cached points, a missed point
+// replaces the cached point with the same timestamp
+func mergePoints(cachedPoints, missedPoints []v3.Point) []v3.Point {
+	missedTimestamps := make(map[int64]struct{}, len(missedPoints))
+	for _, point := range missedPoints {
+		missedTimestamps[point.Timestamp] = struct{}{}
+	}
+	points := make([]v3.Point, 0, len(cachedPoints)+len(missedPoints))
+	for _, point := range cachedPoints {
+		if _, ok := missedTimestamps[point.Timestamp]; !ok {
+			points = append(points, point)
+		}
+	}
+	return append(points, missedPoints...)
+}
 
-	cacheKeys := q.keyGenerator.GenerateKeys(params)
+// seriesInRange returns copies of the series with only the points in
+// [start, end], start is aligned to the step (seconds) so the interval the
+// range starts in is kept. The series without points in the range are left
+// out.
+func seriesInRange(seriesList []*v3.Series, start, end, step int64) []*v3.Series {
+	if step > 0 {
+		start = start - start%(step*1000)
+	}
+	filtered := make([]*v3.Series, 0, len(seriesList))
+	for _, series := range seriesList {
+		points := make([]v3.Point, 0, len(series.Points))
+		for _, point := range series.Points {
+			if point.Timestamp >= start && point.Timestamp <= end {
+				points = append(points, point)
+			}
+		}
+		if len(points) == 0 {
+			continue
+		}
+		filtered = append(filtered, &v3.Series{Labels: series.Labels, Points: points})
+	}
+	return filtered
+}
 
-	seriesList := make([]*v3.Series, 0)
-	errQueriesByName := make(map[string]string)
-	var err error
+// runWithCache returns the series for [params.Start, params.End] using the
+// cached series stored under cacheKey for the part of the range already seen
+// and fetch for the missing intervals. The merged result is written back to
+// the cache when anything new was fetched. The cache is neither read nor
+// written when params.NoCache is set. The cache key does not include the
+// time range, so the cached series may have points of earlier ranges, only
+// the points in the range (step aligned, in seconds) are returned.
+func (q *querier) runWithCache(cacheKey string, params *v3.QueryRangeParamsV3, step int64, fetch func(start, end int64) ([]*v3.Series, error)) ([]*v3.Series, error) {
+	if q.cache == nil || cacheKey == "" {
+		return fetch(params.Start, params.End)
+	}
 
-	for queryName, builderQuery := range params.CompositeQuery.BuilderQueries {
+	var cachedData []byte
+	if !params.NoCache {
+		var retrieveStatus status.RetrieveStatus
+		var err error
+		cachedData, retrieveStatus, err = q.cache.Retrieve(cacheKey, true)
+		zap.L().Debug("cache retrieve status", zap.String("status", retrieveStatus.String()))
+		if err != nil {
+			return nil, err
+		}
+	}
+	misses := q.findMissingTimeRanges(params.Start, params.End, params.Step, cachedData)
+	missedSeries := make([]*v3.Series, 0)
+	cachedSeries := make([]*v3.Series, 0)
+	for _, miss := range misses {
+		series, err := fetch(miss.start, miss.end)
+		if err != nil {
+			return nil, err
+		}
+		missedSeries = append(missedSeries, series...)
+	}
+	if err := json.Unmarshal(cachedData, &cachedSeries); err != nil && cachedData != nil {
+		return nil, err
+	}
+	mergedSeries := mergeSerieses(cachedSeries, missedSeries)
 
-		// TODO: add support for logs and traces
-		if builderQuery.DataSource == v3.DataSourceLogs {
-			query, err := logsV3.PrepareLogsQuery(params.Start, params.End, params.CompositeQuery.QueryType, params.CompositeQuery.PanelType, builderQuery)
-			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
-			}
-			series, err := q.execClickHouseQuery(ctx, query)
-			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
-			}
-			seriesList = append(seriesList, series...)
-			continue
+	// Cache the seriesList for future queries
+	if len(missedSeries) > 0 && !params.NoCache {
+		mergedSeriesData, err := json.Marshal(mergedSeries)
+		if err != nil {
+			return nil, err
 		}
+		if err := q.cache.Store(cacheKey, mergedSeriesData, time.Hour); err != nil {
+			return nil, err
+		}
+	}
+	return seriesInRange(mergedSeries, params.Start, params.End, step), nil
+}
 
-		if builderQuery.DataSource == v3.DataSourceTraces {
-			query, err := tracesV3.PrepareTracesQuery(params.Start, params.End, params.CompositeQuery.QueryType, params.CompositeQuery.PanelType, builderQuery, keys)
-			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
-			}
+// cacheable reports whether the result of the builder query can be served
+// from the cache. Formulas are cacheable when all the queries they refer to
+// are metrics queries.
+func cacheable(params *v3.QueryRangeParamsV3, builderQuery *v3.BuilderQuery) bool {
+	switch params.CompositeQuery.PanelType {
+	case v3.PanelTypeList, v3.PanelTypeTrace, v3.PanelTypeValue:
+		// list and trace panels return rows and value panels reduce the whole
+		// range to a single point, neither can be merged with a cached range
+		return false
+	}
+	if builderQuery.IsSeriesLimit() {
+		// the top groups of a missing range may not be the top groups of
+		// the whole range
+		return false
+	}
+	if builderQuery.QueryName == builderQuery.Expression {
+		return true
+	}
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(builderQuery.Expression, queryBuilder.EvalFuncs)
+	if err != nil {
+		return false
+	}
+	for _, variable := range expression.Vars() {
+		query, ok := params.CompositeQuery.BuilderQueries[variable]
+		if !ok || query.QueryName != query.Expression || isEventsQuery(query) || query.IsSeriesLimit() {
+			return false
+		}
+	}
+	return true
+}
 
-			series, err := q.execClickHouseQuery(ctx, query)
-			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
-			}
-			seriesList = append(seriesList, series...)
-			continue
+// isEventsQuery reports whether the builder query aggregates logs or spans
+func isEventsQuery(builderQuery *v3.BuilderQuery) bool {
+	return builderQuery.DataSource == v3.DataSourceLogs || builderQuery.DataSource == v3.DataSourceTraces
+}
+
+// attributesCacheKey returns the attribute keys the query is built with as
+// resolved from keys, so that a change in the attribute metadata, e.g. an
+// attribute becoming a materialized column, doesn't serve the cached series
+func attributesCacheKey(builderQuery *v3.BuilderQuery, keys map[string]v3.AttributeKey) string {
+	attributes := []v3.AttributeKey{}
+	if builderQuery.AggregateAttribute.Key != "" {
+		attributes = append(attributes, builderQuery.AggregateAttribute)
+	}
+	builderQuery.Filters.Walk(func(item *v3.FilterItem) {
+		attributes = append(attributes, item.Key)
+	})
+	attributes = append(attributes, builderQuery.GroupBy...)
+
+	parts := make([]string, 0, len(attributes))
+	for _, attribute := range attributes {
+		if key, ok := keys[attribute.Key]; ok && (attribute.Type == "" || attribute.DataType == "") {
+			attribute = key
 		}
+		parts = append(parts, attribute.CacheKey())
+	}
+	return strings.Join(parts, ",")
+}
 
-		cacheKey := cacheKeys[queryName]
-		var cachedData []byte
-		if !params.NoCache {
-			var retrieveStatus status.RetrieveStatus
-			cachedData, retrieveStatus, err = q.cache.Retrieve(cacheKey, true)
-			zap.L().Debug("cache retrieve status", zap.String("status", retrieveStatus.String()))
-			if err != nil {
-				return nil, err, nil
-			}
+// prepareBuilderQuery builds the query for queryName over [start, end]
+func (q *querier) prepareBuilderQuery(params *v3.QueryRangeParamsV3, queryName string, keys map[string]v3.AttributeKey, start, end int64) (string, error) {
+	builderQuery := params.CompositeQuery.BuilderQueries[queryName]
+	queryType := params.CompositeQuery.QueryType
+	panelType := params.CompositeQuery.PanelType
+
+	if builderQuery.QueryName == builderQuery.Expression {
+		query := *builderQuery
+		if query.DataSource != v3.DataSourceLogs && query.DataSource != v3.DataSourceTraces {
+			// queries of any other source are metrics queries
+			query.DataSource = v3.DataSourceMetrics
 		}
-		misses := q.findMissingTimeRanges(params.Start, params.End, params.Step, cachedData)
-		missedSeries := make([]*v3.Series, 0)
-		cachedSeries := make([]*v3.Series, 0)
-		for _, miss := range misses {
-			query, err := metricsV3.PrepareMetricQuery(
-				miss.start,
-				miss.end,
-				params.CompositeQuery.QueryType,
-				params.CompositeQuery.PanelType,
-				builderQuery,
-			)
-			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
-			}
-			series, err := q.execClickHouseQuery(ctx, query)
-			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
-			}
-			missedSeries = append(missedSeries, series...)
+		return q.builder.PrepareBuilderQuery(start, end, queryType, panelType, &query, keys)
+	}
+
+	// formula queries are built from the queries they refer to
+	shifted := *params
+	shifted.Start = start
+	shifted.End = end
+	queries, err := q.builder.PrepareQueries(&shifted, keys)
+	if err != nil {
+		return "", err
+	}
+	query, ok := queries[queryName]
+	if !ok {
+		return "", fmt.Errorf("no query could be built for %s", queryName)
+	}
+	return query, nil
+}
+
+// timeShiftLabel is the label added to the series of the time shifted
+// queries, so they can be told apart from the series of the same query
+// without the shift
+const timeShiftLabel = "time_shift"
+
+// labelTimeShift labels the series with the time shift (seconds) as 1d,
+// 2h, 30m or 45s
+func labelTimeShift(series []*v3.Series, shift int64) {
+	if shift == 0 {
+		return
+	}
+	var label string
+	switch {
+	case shift%(24*60*60) == 0:
+		label = fmt.Sprintf("%dd", shift/(24*60*60))
+	case shift%(60*60) == 0:
+		label = fmt.Sprintf("%dh", shift/(60*60))
+	case shift%60 == 0:
+		label = fmt.Sprintf("%dm", shift/60)
+	default:
+		label = fmt.Sprintf("%ds", shift)
+	}
+	for _, s := range series {
+		if s.Labels == nil {
+			s.Labels = make(map[string]string)
 		}
-		if err := json.Unmarshal(cachedData, &cachedSeries); err != nil && cachedData != nil {
-			errQueriesByName[queryName] = err.Error()
-			continue
+		s.Labels[timeShiftLabel] = label
+	}
+}
+
+func (q *querier) runBuilderQueries(ctx context.Context, params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey) ([]*v3.Result, error, map[string]string) {
+
+	cacheKeys := q.keyGenerator.GenerateKeys(params)
+
+	queryNames := make([]string, 0, len(params.CompositeQuery.BuilderQueries))
+	for queryName, builderQuery := range params.CompositeQuery.BuilderQueries {
+		if !builderQuery.Disabled {
+			queryNames = append(queryNames, queryName)
 		}
-		mergedSeries := mergeSerieses(cachedSeries, missedSeries)
+	}
 
-		seriesList = append(seriesList, mergedSeries...)
-		// Cache the seriesList for future queries
-		if len(missedSeries) > 0 {
-			mergedSeriesData, err := json.Marshal(mergedSeries)
-			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
+	collector := newResultCollector()
+	q.runConcurrently(ctx, queryNames, collector, func(queryName string) (*v3.Result, error) {
+		builderQuery := params.CompositeQuery.BuilderQueries[queryName]
+
+		fetch := func(start, end int64) ([]*v3.Series, error) {
+			if isEventsQuery(builderQuery) && start > params.Start && builderQuery.StepInterval > 0 {
+				// the cached value of the interval the cached series ends in may
+				// have been aggregated from part of the interval, query it again
+				start = start - start%(builderQuery.StepInterval*1000)
 			}
-			err = q.cache.Store(cacheKey, mergedSeriesData, time.Hour)
+			query, err := q.prepareBuilderQuery(params, queryName, keys, start, end)
 			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
+				return nil, err
+			}
+			return q.execClickHouseQuery(ctx, query)
+		}
+
+		var cacheKey string
+		if cacheable(params, builderQuery) {
+			cacheKey = cacheKeys[queryName]
+			if isEventsQuery(builderQuery) {
+				cacheKey = fmt.Sprintf("%s&attributes=%s", cacheKey, attributesCacheKey(builderQuery, keys))
 			}
 		}
+		series, err := q.runWithCache(cacheKey, params, builderQuery.StepInterval, fetch)
+		if err != nil {
+			return nil, err
+		}
+		// formulas are computed from the points of the queries they refer
+		// to before the functions of those queries are applied
+		if err := applyFunctions(series, builderQuery.Functions, params.Start, params.End, builderQuery.StepInterval); err != nil {
+			return nil, err
+		}
+		labelTimeShift(series, builderQuery.TimeShift)
+		return &v3.Result{QueryName: queryName, Series: series}, nil
+	})
+	return collector.done("error in builder queries")
+}
+
+func (q *querier) runBuilderListQueries(ctx context.Context, params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey) ([]*v3.Result, error, map[string]string) {
+	queries, err := q.builder.PrepareQueries(params, keys)
+	if err != nil {
+		return nil, err, nil
 	}
-	if len(errQueriesByName) > 0 {
-		err = fmt.Errorf("error in builder queries")
+	queryNames := make([]string, 0, len(queries))
+	for queryName := range queries {
+		queryNames = append(queryNames, queryName)
 	}
-	return seriesList, err, errQueriesByName
+
+	collector := newResultCollector()
+	q.runConcurrently(ctx, queryNames, collector, func(queryName string) (*v3.Result, error) {
+		rows, err := q.execClickHouseListQuery(ctx, queries[queryName])
+		if err != nil {
+			return nil, err
+		}
+		return &v3.Result{QueryName: queryName, List: rows}, nil
+	})
+	return collector.done("error in builder queries")
 }
 
-func (q *querier) runPromQueries(ctx context.Context, params *v3.QueryRangeParamsV3) ([]*v3.Series, error, map[string]string) {
-	seriesList := make([]*v3.Series, 0)
-	errQueriesByName := make(map[string]string)
-	var err error
+func (q *querier) runPromQueries(ctx context.Context, params *v3.QueryRangeParamsV3) ([]*v3.Result, error, map[string]string) {
+	cacheKeys := q.keyGenerator.GenerateKeys(params)
+
+	queryNames := make([]string, 0, len(params.CompositeQuery.PromQueries))
 	for queryName, promQuery := range params.CompositeQuery.PromQueries {
-		cacheKey := q.keyGenerator.GenerateKeys(params)[queryName]
-		var cachedData []byte
-		var retrieveStatus status.RetrieveStatus
-		if !params.NoCache {
-			cachedData, retrieveStatus, err = q.cache.Retrieve(cacheKey, true)
-			zap.L().Debug("cache retrieve status", zap.String("status", retrieveStatus.String()))
+		if !promQuery.Disabled {
+			queryNames = append(queryNames, queryName)
 		}
+	}
+
+	collector := newResultCollector()
+	q.runConcurrently(ctx, queryNames, collector, func(queryName string) (*v3.Result, error) {
+		promQuery := params.CompositeQuery.PromQueries[queryName]
+		tmpl, err := template.New("promql-query").Parse(promQuery.Query)
 		if err != nil {
-			errQueriesByName[queryName] = err.Error()
-			continue
-		}
-		misses := q.findMissingTimeRanges(params.Start, params.End, params.Step, cachedData)
-		missedSeries := make([]*v3.Series, 0)
-		cachedSeries := make([]*v3.Series, 0)
-		for _, miss := range misses {
-			query := metricsV3.BuildPromQuery(
-				promQuery,
-				params.Step,
-				miss.start,
-				miss.end,
-			)
-			series, err := q.execPromQuery(ctx, query)
-			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
-			}
-			missedSeries = append(missedSeries, series...)
+			return nil, err
 		}
-		if err := json.Unmarshal(cachedData, &cachedSeries); err != nil && cachedData != nil {
-			errQueriesByName[queryName] = err.Error()
-			continue
+		var queryBuf bytes.Buffer
+		if err := tmpl.Execute(&queryBuf, params.Variables); err != nil {
+			return nil, err
 		}
-		mergedSeries := mergeSerieses(cachedSeries, missedSeries)
+		query := *promQuery
+		query.Query = queryBuf.String()
 
-		seriesList = append(seriesList, mergedSeries...)
-		// Cache the seriesList for future queries
-		if len(missedSeries) > 0 {
-			mergedSeriesData, err := json.Marshal(mergedSeries)
+		fetch := func(start, end int64) ([]*v3.Series, error) {
+			shift := query.TimeShift * 1000
+			series, err := q.execPromQuery(ctx, metricsV3.BuildPromQuery(&query, params.Step, start-shift, end-shift))
 			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
+				return nil, err
 			}
-			err = q.cache.Store(cacheKey, mergedSeriesData, time.Hour)
-			if err != nil {
-				errQueriesByName[queryName] = err.Error()
-				continue
+			for _, s := range series {
+				for idx := range s.Points {
+					s.Points[idx].Timestamp += shift
+				}
 			}
+			return series, nil
 		}
-	}
-	if len(errQueriesByName) > 0 {
-		err = fmt.Errorf("error in prom queries")
-	}
-	return seriesList, err, errQueriesByName
+		series, err := q.runWithCache(cacheKeys[queryName], params, params.Step, fetch)
+		if err != nil {
+			return nil, err
+		}
+		labelTimeShift(series, query.TimeShift)
+		return &v3.Result{QueryName: queryName, Series: series}, nil
+	})
+	return collector.done("error in prom queries")
 }
 
-func (q *querier) runClickHouseQueries(ctx context.Context, params *v3.QueryRangeParamsV3) ([]*v3.Series, error, map[string]string) {
-	seriesList := make([]*v3.Series, 0)
-	errQueriesByName := make(map[string]string)
-	var err error
+func (q *querier) runClickHouseQueries(ctx context.Context, params *v3.QueryRangeParamsV3) ([]*v3.Result, error, map[string]string) {
+	queryNames := make([]string, 0, len(params.CompositeQuery.ClickHouseQueries))
 	for queryName, clickHouseQuery := range params.CompositeQuery.ClickHouseQueries {
-		series, err := q.execClickHouseQuery(ctx, clickHouseQuery.Query)
+		if !clickHouseQuery.Disabled {
+			queryNames = append(queryNames, queryName)
+		}
+	}
+
+	collector := newResultCollector()
+	q.runConcurrently(ctx, queryNames, collector, func(queryName string) (*v3.Result, error) {
+		series, err := q.execClickHouseQuery(ctx, params.CompositeQuery.ClickHouseQueries[queryName].Query)
 		if err != nil {
-			errQueriesByName[queryName] = err.Error()
-			continue
+			return nil, err
 		}
-		seriesList = append(seriesList, series...)
+		return &v3.Result{QueryName: queryName, Series: series}, nil
+	})
+	return collector.done("error in clickhouse queries")
+}
+
+// resultCollector gathers the results and the errors of queries run
+// concurrently
+type resultCollector struct {
+	mu               sync.Mutex
+	results          []*v3.Result
+	errQueriesByName map[string]string
+}
+
+func newResultCollector() *resultCollector {
+	return &resultCollector{
+		results:          make([]*v3.Result, 0),
+		errQueriesByName: make(map[string]string),
 	}
-	if len(errQueriesByName) > 0 {
-		err = fmt.Errorf("error in clickhouse queries")
+}
+
+func (c *resultCollector) add(queryName string, result *v3.Result, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.errQueriesByName[queryName] = err.Error()
+		return
 	}
-	return seriesList, err, errQueriesByName
+	c.results = append(c.results, result)
 }
 
-func (q *querier) QueryRange(ctx context.Context, params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey) ([]*v3.Series, error, map[string]string) {
-	var seriesList []*v3.Series
+// done returns the collected results, sorted by query name, and an error
+// with msg if any of the queries failed
+func (c *resultCollector) done(msg string) ([]*v3.Result, error, map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sort.Slice(c.results, func(i, j int) bool {
+		return c.results[i].QueryName < c.results[j].QueryName
+	})
+	var err error
+	if len(c.errQueriesByName) > 0 {
+		err = errors.New(msg)
+	}
+	return c.results, err, c.errQueriesByName
+}
+
+// runConcurrently runs the named queries with at most q.maxConcurrentQueries
+// of them in flight and collects their results in collector
+func (q *querier) runConcurrently(ctx context.Context, queryNames []string, collector *resultCollector, run func(queryName string) (*v3.Result, error)) {
+	runConcurrently(ctx, q.maxConcurrentQueries, queryNames, func(queryName string) {
+		result, err := run(queryName)
+		collector.add(queryName, result, err)
+	}, func(queryName string, err error) {
+		collector.add(queryName, nil, err)
+	})
+}
+
+func (q *querier) QueryRange(ctx context.Context, params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey) ([]*v3.Result, error, map[string]string) {
+	var results []*v3.Result
 	var err error
 	var errQueriesByName map[string]string
+
+	if q.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.timeout)
+		defer cancel()
+	}
+
 	if params.CompositeQuery != nil {
 		switch params.CompositeQuery.QueryType {
 		case v3.QueryTypeBuilder:
-			seriesList, err, errQueriesByName = q.runBuilderQueries(ctx, params, keys)
+			if params.CompositeQuery.PanelType == v3.PanelTypeList || params.CompositeQuery.PanelType == v3.PanelTypeTrace {
+				results, err, errQueriesByName = q.runBuilderListQueries(ctx, params, keys)
+			} else {
+				results, err, errQueriesByName = q.runBuilderQueries(ctx, params, keys)
+			}
 		case v3.QueryTypePromQL:
-			seriesList, err, errQueriesByName = q.runPromQueries(ctx, params)
+			results, err, errQueriesByName = q.runPromQueries(ctx, params)
 		case v3.QueryTypeClickHouseSQL:
-			seriesList, err, errQueriesByName = q.runClickHouseQueries(ctx, params)
+			results, err, errQueriesByName = q.runClickHouseQueries(ctx, params)
 		default:
 			err = fmt.Errorf("invalid query type")
 		}
 	}
-	return seriesList, err, errQueriesByName
+	return results, err, errQueriesByName
 }
 
 func (q *querier) QueriesExecuted() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 	return q.queriesExecuted
 }
@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"go.signoz.io/signoz/pkg/query-service/app/queryBuilder"
+	"go.signoz.io/signoz/pkg/query-service/auth"
 	"go.signoz.io/signoz/pkg/query-service/cache/inmemory"
+	"go.signoz.io/signoz/pkg/query-service/model"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 )
 
@@ -505,3 +507,472 @@ func TestQueryRange(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryRangeResultsByQueryName(t *testing.T) {
+	param := &v3.QueryRangeParamsV3{
+		Start: 1675115596722,
+		End:   1675115596722 + 120*60*1000,
+		Step:  60,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:          "A",
+					StepInterval:       60,
+					DataSource:         v3.DataSourceMetrics,
+					AggregateAttribute: v3.AttributeKey{Key: "signoz_latency_count", DataType: "float64", IsColumn: true},
+					AggregateOperator:  v3.AggregateOperatorSumRate,
+					Expression:         "A",
+				},
+				"B": {
+					QueryName:          "B",
+					StepInterval:       60,
+					DataSource:         v3.DataSourceMetrics,
+					AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total", DataType: "float64", IsColumn: true},
+					AggregateOperator:  v3.AggregateOperatorSumRate,
+					Expression:         "B",
+					Disabled:           true,
+				},
+				"F1": {
+					QueryName:  "F1",
+					Expression: "A/B",
+				},
+			},
+		},
+	}
+	q := NewQuerier(QuerierOptions{
+		Cache:        inmemory.New(&inmemory.Options{TTL: 5 * time.Minute, CleanupInterval: 10 * time.Minute}),
+		Reader:       nil,
+		FluxInterval: 5 * time.Minute,
+		KeyGenerator: queryBuilder.NewKeyGenerator(),
+
+		TestingMode: true,
+		ReturnedSeries: []*v3.Series{
+			{
+				Labels: map[string]string{},
+				Points: []v3.Point{{Timestamp: 1675115596722, Value: 1}},
+			},
+		},
+	})
+
+	results, err, errByName := q.QueryRange(context.Background(), param, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %s, %v", err, errByName)
+	}
+	names := map[string]bool{}
+	for _, result := range results {
+		names[result.QueryName] = true
+	}
+	if len(results) != 2 || !names["A"] || !names["F1"] {
+		t.Errorf("expected results for A and F1, got %v", names)
+	}
+	for _, query := range q.QueriesExecuted() {
+		if strings.Contains(query, "signoz_calls_total") && !strings.Contains(query, "JOIN") {
+			t.Errorf("expected disabled query B to run only as part of F1, got %s", query)
+		}
+	}
+}
+
+func TestQueryRangeTimeShift(t *testing.T) {
+	start := int64(1675115596722)
+	end := start + 120*60*1000
+	param := &v3.QueryRangeParamsV3{
+		Start: start,
+		End:   end,
+		Step:  60,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:          "A",
+					StepInterval:       60,
+					DataSource:         v3.DataSourceMetrics,
+					AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total", DataType: "float64", IsColumn: true},
+					AggregateOperator:  v3.AggregateOperatorSumRate,
+					Expression:         "A",
+					TimeShift:          24 * 60 * 60,
+				},
+			},
+		},
+	}
+	q := NewQuerier(QuerierOptions{
+		Reader:       nil,
+		FluxInterval: 5 * time.Minute,
+		KeyGenerator: queryBuilder.NewKeyGenerator(),
+
+		TestingMode: true,
+		ReturnedSeries: []*v3.Series{
+			{
+				Labels: map[string]string{"service_name": "frontend"},
+				Points: []v3.Point{{Timestamp: start, Value: 1}},
+			},
+		},
+	})
+
+	results, err, errByName := q.QueryRange(context.Background(), param, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %s, %v", err, errByName)
+	}
+
+	shift := int64(24 * 60 * 60 * 1000)
+	executed := q.QueriesExecuted()
+	if len(executed) != 1 {
+		t.Fatalf("expected 1 query, got %d", len(executed))
+	}
+	expected := fmt.Sprintf("timestamp_ms >= %d AND timestamp_ms <= %d", start-shift, end-shift)
+	if !strings.Contains(executed[0], expected) {
+		t.Errorf("expected query to contain %s, got %s", expected, executed[0])
+	}
+	if !strings.HasPrefix(executed[0], "SELECT * REPLACE (ts + INTERVAL 86400 SECOND AS ts) FROM (") {
+		t.Errorf("expected the timestamps to be moved forward, got %s", executed[0])
+	}
+
+	if len(results) != 1 || len(results[0].Series) != 1 {
+		t.Fatalf("expected 1 series, got %v", results)
+	}
+	if label := results[0].Series[0].Labels["time_shift"]; label != "1d" {
+		t.Errorf("expected the series to be labelled with the time shift, got %q", label)
+	}
+}
+
+func TestQueryRangeLogsAndTracesCache(t *testing.T) {
+	newParams := func(start, end int64, panelType v3.PanelType, dataSource v3.DataSource) *v3.QueryRangeParamsV3 {
+		return &v3.QueryRangeParamsV3{
+			Start: start,
+			End:   end,
+			Step:  60,
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				PanelType: panelType,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:         "A",
+						StepInterval:      60,
+						DataSource:        dataSource,
+						AggregateOperator: v3.AggregateOperatorCount,
+						Filters: &v3.FilterSet{
+							Operator: "AND",
+							Items: []v3.FilterItem{
+								{
+									Key:      v3.AttributeKey{Key: "method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag},
+									Operator: "=",
+									Value:    "GET",
+								},
+							},
+						},
+						GroupBy:    []v3.AttributeKey{{Key: "serviceName", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true}},
+						OrderBy:    []v3.OrderBy{{ColumnName: "#SIGNOZ_VALUE", Order: "desc"}},
+						Expression: "A",
+					},
+				},
+			},
+		}
+	}
+
+	start := int64(1675115580000)
+	testCases := []struct {
+		name            string
+		dataSource      v3.DataSource
+		panelType       v3.PanelType
+		secondTimeRange string
+	}{
+		{
+			name:       "logs graph reuses the cached range",
+			dataSource: v3.DataSourceLogs,
+			panelType:  v3.PanelTypeGraph,
+			// the interval the cached series ends in is queried again
+			secondTimeRange: fmt.Sprintf("timestamp >= %d AND timestamp <= %d", (start+120*60*1000)*1000000, (start+180*60*1000)*1000000),
+		},
+		{
+			name:            "traces graph reuses the cached range",
+			dataSource:      v3.DataSourceTraces,
+			panelType:       v3.PanelTypeGraph,
+			secondTimeRange: fmt.Sprintf("timestamp >= '%d' AND timestamp <= '%d'", (start+120*60*1000)*1000000, (start+180*60*1000)*1000000),
+		},
+		{
+			name:            "logs list is not cached",
+			dataSource:      v3.DataSourceLogs,
+			panelType:       v3.PanelTypeList,
+			secondTimeRange: fmt.Sprintf("timestamp >= %d AND timestamp <= %d", (start+60*60*1000)*1000000, (start+180*60*1000)*1000000),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := NewQuerier(QuerierOptions{
+				Cache:        inmemory.New(&inmemory.Options{TTL: 5 * time.Minute, CleanupInterval: 10 * time.Minute}),
+				Reader:       nil,
+				FluxInterval: 5 * time.Minute,
+				KeyGenerator: queryBuilder.NewKeyGenerator(),
+
+				TestingMode: true,
+				ReturnedSeries: []*v3.Series{
+					{
+						Labels: map[string]string{"serviceName": "frontend"},
+						Points: []v3.Point{
+							{Timestamp: start, Value: 1},
+							{Timestamp: start + 60*60*1000, Value: 2},
+							{Timestamp: start + 120*60*1000, Value: 3},
+						},
+					},
+				},
+			})
+			params := []*v3.QueryRangeParamsV3{
+				newParams(start, start+120*60*1000, tc.panelType, tc.dataSource),
+				newParams(start+60*60*1000, start+180*60*1000, tc.panelType, tc.dataSource),
+			}
+			for _, param := range params {
+				_, err, errByName := q.QueryRange(context.Background(), param, nil)
+				if err != nil {
+					t.Fatalf("expected no error, got %s, %v", err, errByName)
+				}
+			}
+			if len(q.QueriesExecuted()) != 2 {
+				t.Fatalf("expected 2 queries to be executed, got %d", len(q.QueriesExecuted()))
+			}
+			if !strings.Contains(q.QueriesExecuted()[1], tc.secondTimeRange) {
+				t.Errorf("expected query to contain %s, got %s", tc.secondTimeRange, q.QueriesExecuted()[1])
+			}
+		})
+	}
+}
+
+func TestQueryRangeTracesCacheKeyUsesEnrichedAttributes(t *testing.T) {
+	param := &v3.QueryRangeParamsV3{
+		Start: 1675115580000,
+		End:   1675115580000 + 120*60*1000,
+		Step:  60,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:         "A",
+					StepInterval:      60,
+					DataSource:        v3.DataSourceTraces,
+					AggregateOperator: v3.AggregateOperatorCount,
+					GroupBy:           []v3.AttributeKey{{Key: "http.method"}},
+					Expression:        "A",
+				},
+			},
+		},
+	}
+	q := NewQuerier(QuerierOptions{
+		Cache:        inmemory.New(&inmemory.Options{TTL: 5 * time.Minute, CleanupInterval: 10 * time.Minute}),
+		Reader:       nil,
+		FluxInterval: 5 * time.Minute,
+		KeyGenerator: queryBuilder.NewKeyGenerator(),
+
+		TestingMode: true,
+		ReturnedSeries: []*v3.Series{
+			{
+				Labels: map[string]string{"http.method": "GET"},
+				Points: []v3.Point{{Timestamp: 1675115580000, Value: 1}, {Timestamp: 1675115580000 + 120*60*1000, Value: 1}},
+			},
+		},
+	})
+
+	keys := []map[string]v3.AttributeKey{
+		{"http.method": {Key: "http.method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}},
+		// the attribute is materialized as a column between the two requests
+		{"http.method": {Key: "http.method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true}},
+	}
+	for _, k := range keys {
+		_, err, errByName := q.QueryRange(context.Background(), param, k)
+		if err != nil {
+			t.Fatalf("expected no error, got %s, %v", err, errByName)
+		}
+	}
+	if len(q.QueriesExecuted()) != 2 {
+		t.Fatalf("expected 2 queries to be executed, got %d", len(q.QueriesExecuted()))
+	}
+	if !strings.Contains(q.QueriesExecuted()[1], "http.method as `http.method`") {
+		t.Errorf("expected the second query to be built with the column, got %s", q.QueriesExecuted()[1])
+	}
+}
+
+func TestQueryRangeRunsQueriesConcurrently(t *testing.T) {
+	clickHouseQueries := map[string]*v3.ClickHouseQuery{}
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		clickHouseQueries[name] = &v3.ClickHouseQuery{
+			Query: fmt.Sprintf("SELECT toStartOfMinute(timestamp) as ts, count() as value FROM %s GROUP BY ts", name),
+		}
+	}
+	clickHouseQueries["C"].Disabled = true
+	param := &v3.QueryRangeParamsV3{
+		Start: 1675115596722,
+		End:   1675115596722 + 120*60*1000,
+		Step:  60,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType:         v3.QueryTypeClickHouseSQL,
+			PanelType:         v3.PanelTypeGraph,
+			ClickHouseQueries: clickHouseQueries,
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		returnedErr error
+	}{
+		{name: "success"},
+		{name: "error", returnedErr: fmt.Errorf("code: 241, memory limit exceeded")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := NewQuerier(QuerierOptions{
+				Reader:               nil,
+				FluxInterval:         5 * time.Minute,
+				KeyGenerator:         queryBuilder.NewKeyGenerator(),
+				MaxConcurrentQueries: 2,
+
+				TestingMode:    true,
+				ReturnedSeries: []*v3.Series{{Labels: map[string]string{}, Points: []v3.Point{{Timestamp: 1675115596722, Value: 1}}}},
+				ReturnedErr:    tc.returnedErr,
+			})
+			results, err, errByName := q.QueryRange(context.Background(), param, nil)
+			if len(q.QueriesExecuted()) != 4 {
+				t.Fatalf("expected 4 queries to be executed, got %d", len(q.QueriesExecuted()))
+			}
+			if tc.returnedErr == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got %s, %v", err, errByName)
+				}
+				var names []string
+				for _, result := range results {
+					names = append(names, result.QueryName)
+				}
+				if strings.Join(names, ",") != "A,B,D,E" {
+					t.Errorf("expected results for A,B,D,E in order, got %v", names)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if len(results) != 0 || len(errByName) != 4 {
+				t.Fatalf("expected 4 errors and no results, got %v and %d results", errByName, len(results))
+			}
+			if errByName["A"] != tc.returnedErr.Error() {
+				t.Errorf("expected the error of A to be reported, got %s", errByName["A"])
+			}
+		})
+	}
+}
+
+func TestQueryRangeDeadline(t *testing.T) {
+	param := &v3.QueryRangeParamsV3{
+		Start: 1675115596722,
+		End:   1675115596722 + 120*60*1000,
+		Step:  60,
+		CompositeQuery: &v3.CompositeQuery{
+			QueryType: v3.QueryTypeClickHouseSQL,
+			PanelType: v3.PanelTypeGraph,
+			ClickHouseQueries: map[string]*v3.ClickHouseQuery{
+				"A": {Query: "SELECT 1"},
+			},
+		},
+	}
+	q := NewQuerier(QuerierOptions{
+		KeyGenerator:                queryBuilder.NewKeyGenerator(),
+		MaxConcurrentQueriesPerUser: 1,
+		Timeout:                     10 * time.Millisecond,
+		TestingMode:                 true,
+	})
+
+	// hold the only slot of the user so that the query waits for the deadline
+	user := &model.UserPayload{User: model.User{Id: "user-1"}}
+	ctx := auth.AttachUserToContext(context.Background(), user)
+	release, err := q.(*querier).userLimiter.acquire(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error acquiring the slot: %s", err)
+	}
+	defer release()
+
+	_, err, errByName := q.QueryRange(ctx, param, nil)
+	if err == nil || errByName["A"] != context.DeadlineExceeded.Error() {
+		t.Fatalf("expected A to exceed the deadline, got %v, %v", err, errByName)
+	}
+	if len(q.QueriesExecuted()) != 0 {
+		t.Errorf("expected no query to be executed, got %v", q.QueriesExecuted())
+	}
+
+	// queries not made on behalf of a user are not limited
+	_, err, errByName = q.QueryRange(context.Background(), param, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %s, %v", err, errByName)
+	}
+}
+
+func TestQueryRangeCacheReturnsPointsInRange(t *testing.T) {
+	newParams := func(start, end int64) *v3.QueryRangeParamsV3 {
+		return &v3.QueryRangeParamsV3{
+			Start: start,
+			End:   end,
+			Step:  60,
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				PanelType: v3.PanelTypeGraph,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:          "A",
+						StepInterval:       60,
+						AggregateAttribute: v3.AttributeKey{Key: "http_server_requests_seconds_count", DataType: "float64", IsColumn: true},
+						AggregateOperator:  v3.AggregateOperatorSumRate,
+						Expression:         "A",
+					},
+				},
+			},
+		}
+	}
+
+	now := int64(1675115580000)
+	dayAgo := now - 24*60*60*1000
+	q := NewQuerier(QuerierOptions{
+		Cache:        inmemory.New(&inmemory.Options{TTL: 5 * time.Minute, CleanupInterval: 10 * time.Minute}),
+		Reader:       nil,
+		FluxInterval: 5 * time.Minute,
+		KeyGenerator: queryBuilder.NewKeyGenerator(),
+
+		TestingMode: true,
+		ReturnedSeries: []*v3.Series{
+			{
+				Labels: map[string]string{"service_name": "frontend"},
+				Points: []v3.Point{
+					{Timestamp: dayAgo, Value: 1},
+					{Timestamp: dayAgo + 5*60*1000, Value: 2},
+					{Timestamp: now - 5*60*1000, Value: 3},
+					{Timestamp: now, Value: 4},
+				},
+			},
+		},
+	})
+
+	// both windows are cached under the same key
+	windows := []struct{ start, end int64 }{
+		{dayAgo, dayAgo + 5*60*1000},
+		{now - 5*60*1000, now},
+		{dayAgo + 30, dayAgo + 5*60*1000},
+	}
+	for _, window := range windows {
+		results, err, errByName := q.QueryRange(context.Background(), newParams(window.start, window.end), nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %s, %v", err, errByName)
+		}
+		if len(results) != 1 || len(results[0].Series) != 1 {
+			t.Fatalf("expected 1 series, got %v", results)
+		}
+		points := results[0].Series[0].Points
+		if len(points) != 2 {
+			t.Errorf("expected 2 points in [%d, %d], got %v", window.start, window.end, points)
+		}
+		// the start of the window is aligned to the step
+		alignedStart := window.start - window.start%60000
+		for _, point := range points {
+			if point.Timestamp < alignedStart || point.Timestamp > window.end {
+				t.Errorf("expected points in [%d, %d], got %d", alignedStart, window.end, point.Timestamp)
+			}
+		}
+	}
+}
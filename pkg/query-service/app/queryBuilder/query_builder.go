@@ -7,6 +7,7 @@ import (
 	"github.com/SigNoz/govaluate"
 	"go.signoz.io/signoz/pkg/query-service/cache"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils"
 	"go.uber.org/zap"
 )
 
@@ -88,9 +89,15 @@ func expressionToQuery(qp *v3.QueryRangeParamsV3, varToQuery map[string]string,
 	tokens := expression.Tokens()
 	for idx := range tokens {
 		token := tokens[idx]
-		if token.Kind == govaluate.VARIABLE {
-			token.Value = fmt.Sprintf("%v.value", token.Value)
-			token.Meta = fmt.Sprintf("%v.value", token.Meta)
+		switch token.Kind {
+		case govaluate.VARIABLE:
+			token.Value = fmt.Sprintf("%s.value", utils.ClickHouseFormattedIdentifier(fmt.Sprint(token.Value)))
+			token.Meta = fmt.Sprintf("%s.value", utils.ClickHouseFormattedIdentifier(fmt.Sprint(token.Meta)))
+		case govaluate.STRING:
+			// the expression string puts the strings in single quotes as they
+			// are, so only the escaped content of the literal is kept
+			quoted := utils.QuoteString(fmt.Sprint(token.Meta))
+			token.Meta = quoted[1 : len(quoted)-1]
 		}
 		modified = append(modified, token)
 	}
@@ -100,11 +107,12 @@ func expressionToQuery(qp *v3.QueryRangeParamsV3, varToQuery map[string]string,
 	var formulaSubQuery string
 	var joinUsing string
 	var prevVar string
-	for idx, variable := range variables {
-		query := varToQuery[variable]
+	for idx, name := range variables {
+		query := varToQuery[name]
+		variable := utils.ClickHouseFormattedIdentifier(name)
 		groupTags := []string{}
-		for _, tag := range qp.CompositeQuery.BuilderQueries[variable].GroupBy {
-			groupTags = append(groupTags, tag.Key)
+		for _, tag := range qp.CompositeQuery.BuilderQueries[name].GroupBy {
+			groupTags = append(groupTags, utils.ClickHouseFormattedIdentifier(tag.Key))
 		}
 		groupTags = append(groupTags, "ts")
 		if joinUsing == "" {
@@ -130,6 +138,44 @@ func expressionToQuery(qp *v3.QueryRangeParamsV3, varToQuery map[string]string,
 	return formulaQuery, nil
 }
 
+// PrepareBuilderQuery builds the query of a builder query that is not a
+// formula. A time shifted query is built for the shifted range and its
+// timestamps are moved forward, so formulas can join it with the other
+// queries.
+func (qb *QueryBuilder) PrepareBuilderQuery(start, end int64, queryType v3.QueryType, panelType v3.PanelType, query *v3.BuilderQuery, args ...interface{}) (string, error) {
+	shift := query.TimeShift * 1000
+	start, end = start-shift, end-shift
+
+	var queryString string
+	var err error
+	switch query.DataSource {
+	case v3.DataSourceTraces:
+		keys := map[string]v3.AttributeKey{}
+		if len(args) > 0 {
+			keys = args[0].(map[string]v3.AttributeKey)
+		}
+		queryString, err = qb.options.BuildTraceQuery(start, end, queryType, panelType, query, keys)
+	case v3.DataSourceLogs:
+		queryString, err = qb.options.BuildLogQuery(start, end, queryType, panelType, query)
+	case v3.DataSourceMetrics:
+		queryString, err = qb.options.BuildMetricQuery(start, end, queryType, panelType, query)
+	default:
+		zap.S().Errorf("Unknown data source %s", query.DataSource)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if panelType == v3.PanelTypeGraph && query.IsSeriesLimit() {
+		queryString = addSeriesLimit(queryString, query)
+	}
+	if query.TimeShift > 0 {
+		queryString = fmt.Sprintf("SELECT * REPLACE (ts + INTERVAL %d SECOND AS ts) FROM (%s)", query.TimeShift, queryString)
+	}
+	return queryString, nil
+}
+
 func (qb *QueryBuilder) PrepareQueries(params *v3.QueryRangeParamsV3, args ...interface{}) (map[string]string, error) {
 	queries := make(map[string]string)
 
@@ -140,31 +186,12 @@ func (qb *QueryBuilder) PrepareQueries(params *v3.QueryRangeParamsV3, args ...in
 		// Build queries for each builder query
 		for queryName, query := range compositeQuery.BuilderQueries {
 			if query.Expression == queryName {
-				switch query.DataSource {
-				case v3.DataSourceTraces:
-					keys := map[string]v3.AttributeKey{}
-					if len(args) > 0 {
-						keys = args[0].(map[string]v3.AttributeKey)
-					}
-					queryString, err := qb.options.BuildTraceQuery(params.Start, params.End, compositeQuery.QueryType, compositeQuery.PanelType, query, keys)
-					if err != nil {
-						return nil, err
-					}
-					queries[queryName] = queryString
-				case v3.DataSourceLogs:
-					queryString, err := qb.options.BuildLogQuery(params.Start, params.End, compositeQuery.QueryType, compositeQuery.PanelType, query)
-					if err != nil {
-						return nil, err
-					}
-					queries[queryName] = queryString
-				case v3.DataSourceMetrics:
-					queryString, err := qb.options.BuildMetricQuery(params.Start, params.End, compositeQuery.QueryType, compositeQuery.PanelType, query)
-					if err != nil {
-						return nil, err
-					}
+				queryString, err := qb.PrepareBuilderQuery(params.Start, params.End, compositeQuery.QueryType, compositeQuery.PanelType, query, args...)
+				if err != nil {
+					return nil, err
+				}
+				if queryString != "" {
 					queries[queryName] = queryString
-				default:
-					zap.S().Errorf("Unknown data source %s", query.DataSource)
 				}
 			}
 		}
@@ -226,15 +253,19 @@ func (c *cacheKeyGenerator) GenerateKeys(params *v3.QueryRangeParamsV3) map[stri
 			parts = append(parts, fmt.Sprintf("source=%s", query.DataSource))
 			parts = append(parts, fmt.Sprintf("step=%d", query.StepInterval))
 			parts = append(parts, fmt.Sprintf("aggregate=%s", query.AggregateOperator))
+			if query.AggregateOperator.IsParameterisedQuantile() {
+				parts = append(parts, fmt.Sprintf("quantile=%v", query.Quantile))
+			}
+			if query.TimeShift != 0 {
+				parts = append(parts, fmt.Sprintf("timeShift=%d", query.TimeShift))
+			}
 
 			if query.AggregateAttribute.Key != "" {
 				parts = append(parts, fmt.Sprintf("aggregateAttribute=%s", query.AggregateAttribute.CacheKey()))
 			}
 
-			if query.Filters != nil && len(query.Filters.Items) > 0 {
-				for idx, filter := range query.Filters.Items {
-					parts = append(parts, fmt.Sprintf("filter-%d=%s", idx, filter.CacheKey()))
-				}
+			if !query.Filters.IsEmpty() {
+				parts = append(parts, fmt.Sprintf("filters=%s", query.Filters.CacheKey()))
 			}
 
 			if len(query.GroupBy) > 0 {
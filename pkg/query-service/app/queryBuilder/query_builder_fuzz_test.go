@@ -0,0 +1,227 @@
+package queryBuilder
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	logsv3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
+	metricsv3 "go.signoz.io/signoz/pkg/query-service/app/metrics/v3"
+	tracesv3 "go.signoz.io/signoz/pkg/query-service/app/traces/v3"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils"
+)
+
+// benignKey is quoted everywhere like the keys of the fuzz inputs
+const benignKey = "benign key"
+
+// keyQueries returns the metrics, logs and traces queries and a formula that
+// use the key as a filter key and value, a group by key and an order by column
+func keyQueries(key string) map[string]*v3.CompositeQuery {
+	metricQuery := func(name string, op v3.AggregateOperator) *v3.BuilderQuery {
+		return &v3.BuilderQuery{
+			QueryName:          name,
+			DataSource:         v3.DataSourceMetrics,
+			AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+			AggregateOperator:  op,
+			Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+				{Key: v3.AttributeKey{Key: key}, Value: key, Operator: v3.FilterOperatorEqual},
+				{Key: v3.AttributeKey{Key: key}, Value: key, Operator: v3.FilterOperatorContains},
+				{Key: v3.AttributeKey{Key: key}, Operator: v3.FilterOperatorExists},
+			}},
+			GroupBy:    []v3.AttributeKey{{Key: key}},
+			OrderBy:    []v3.OrderBy{{ColumnName: key, Order: "desc"}},
+			LimitMode:  v3.LimitModeSeries,
+			Limit:      5,
+			Expression: name,
+		}
+	}
+	tag := v3.AttributeKey{Key: key, DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}
+	column := v3.AttributeKey{Key: key, DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true}
+	filters := &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+		{Key: tag, Value: key, Operator: v3.FilterOperatorEqual},
+		{Key: column, Value: key, Operator: v3.FilterOperatorNotEqual},
+		{Key: tag, Value: key, Operator: v3.FilterOperatorContains},
+		{Key: tag, Value: key, Operator: v3.FilterOperatorExists},
+	}}
+
+	return map[string]*v3.CompositeQuery{
+		"metrics": {
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": metricQuery("A", v3.AggregateOperatorSumRate),
+				"B": metricQuery("B", v3.AggregateOperatorSum),
+				"C": {QueryName: "C", Expression: "A/B"},
+			},
+		},
+		"logs": {
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:          "A",
+					DataSource:         v3.DataSourceLogs,
+					AggregateAttribute: tag,
+					AggregateOperator:  v3.AggregateOperatorCount,
+					Filters:            filters,
+					GroupBy:            []v3.AttributeKey{tag},
+					OrderBy:            []v3.OrderBy{{ColumnName: key, Order: "desc"}},
+					Expression:         "A",
+				},
+			},
+		},
+		"logs list": {
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeList,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:         "A",
+					DataSource:        v3.DataSourceLogs,
+					AggregateOperator: v3.AggregateOperatorNoOp,
+					Filters:           filters,
+					OrderBy:           []v3.OrderBy{{ColumnName: key, Order: "desc", IsColumn: true}},
+					Expression:        "A",
+				},
+			},
+		},
+		"traces": {
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeGraph,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:          "A",
+					DataSource:         v3.DataSourceTraces,
+					AggregateAttribute: tag,
+					AggregateOperator:  v3.AggregateOperatorCount,
+					Filters: &v3.FilterSet{Operator: "AND", Items: append(filters.Items, v3.FilterItem{
+						Key: column, Value: key, Operator: v3.FilterOperatorNotExists,
+					})},
+					GroupBy:    []v3.AttributeKey{tag},
+					OrderBy:    []v3.OrderBy{{ColumnName: key, Order: "desc"}},
+					Expression: "A",
+				},
+			},
+		},
+		"traces list": {
+			QueryType: v3.QueryTypeBuilder,
+			PanelType: v3.PanelTypeList,
+			BuilderQueries: map[string]*v3.BuilderQuery{
+				"A": {
+					QueryName:         "A",
+					DataSource:        v3.DataSourceTraces,
+					AggregateOperator: v3.AggregateOperatorNoOp,
+					Filters:           filters,
+					SelectColumns:     []v3.AttributeKey{tag, column},
+					OrderBy:           []v3.OrderBy{{ColumnName: key, Order: "desc", IsColumn: true}},
+					Expression:        "A",
+				},
+			},
+		},
+	}
+}
+
+// skeleton replaces the string literals and the quoted identifiers of the
+// query with placeholders, two queries built with different keys have the
+// same skeleton when the keys did not change the structure of the query
+func skeleton(query string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c != '\'' && c != '`' {
+			sb.WriteByte(c)
+			continue
+		}
+		end := -1
+		for j := i + 1; j < len(query); j++ {
+			if query[j] == '\\' {
+				j++
+			} else if query[j] == c {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote at %d", i)
+		}
+		if c == '\'' {
+			sb.WriteString("'S'")
+		} else {
+			sb.WriteString("`I`")
+		}
+		i = end
+	}
+	return sb.String(), nil
+}
+
+func buildKeyQueries(key string) (map[string]string, error) {
+	qb := NewQueryBuilder(QueryBuilderOptions{
+		BuildMetricQuery: metricsv3.PrepareMetricQuery,
+		BuildLogQuery:    logsv3.PrepareLogsQuery,
+		BuildTraceQuery:  tracesv3.PrepareTracesQuery,
+	})
+	built := make(map[string]string)
+	for name, compositeQuery := range keyQueries(key) {
+		queries, err := qb.PrepareQueries(&v3.QueryRangeParamsV3{
+			Start:          1650991982000,
+			End:            1651078382000,
+			Step:           60,
+			CompositeQuery: compositeQuery,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for queryName, query := range queries {
+			built[name+" "+queryName] = query
+		}
+	}
+	return built, nil
+}
+
+func FuzzBuilderKeys(f *testing.F) {
+	for _, key := range []string{
+		"x' OR 1=1 --",
+		"x') OR has(labels, 'y",
+		"x` FROM system.users --",
+		`x\`,
+		`x\'`,
+		"a)`, (SELECT 1",
+		"service name",
+		"k8s.pod.name",
+	} {
+		f.Add(key)
+	}
+
+	benign, err := buildKeyQueries(benignKey)
+	if err != nil {
+		f.Fatal(err)
+	}
+	want := make(map[string]string)
+	for name, query := range benign {
+		s, err := skeleton(query)
+		if err != nil {
+			f.Fatalf("%s: %v", name, err)
+		}
+		want[name] = s
+	}
+
+	f.Fuzz(func(t *testing.T, key string) {
+		// the plain identifiers are not quoted and the key can not be empty
+		if key == "" || utils.ClickHouseFormattedColumn(key) == key {
+			t.Skip()
+		}
+		queries, err := buildKeyQueries(key)
+		if err != nil {
+			t.Fatalf("failed to build the queries for key %q: %v", key, err)
+		}
+		for name, query := range queries {
+			got, err := skeleton(query)
+			if err != nil {
+				t.Fatalf("%s: key %q broke the query: %v\n%s", name, key, err, query)
+			}
+			if got != want[name] {
+				t.Fatalf("%s: key %q changed the query structure\n got: %s\nwant: %s", name, key, got, want[name])
+			}
+		}
+	})
+}
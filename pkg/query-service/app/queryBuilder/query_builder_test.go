@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	logsv3 "go.signoz.io/signoz/pkg/query-service/app/logs/v3"
 	metricsv3 "go.signoz.io/signoz/pkg/query-service/app/metrics/v3"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 )
@@ -56,6 +57,135 @@ func TestBuildQueryWithMultipleQueriesAndFormula(t *testing.T) {
 	})
 }
 
+func TestBuildQueryWithTimeShiftInFormula(t *testing.T) {
+	t.Run("TestBuildQueryWithTimeShiftInFormula", func(t *testing.T) {
+		q := &v3.QueryRangeParamsV3{
+			Start: 1650991982000,
+			End:   1651078382000,
+			Step:  60,
+			CompositeQuery: &v3.CompositeQuery{
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:          "A",
+						DataSource:         v3.DataSourceMetrics,
+						AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+						AggregateOperator:  v3.AggregateOperatorSumRate,
+						Expression:         "A",
+					},
+					"B": {
+						QueryName:          "B",
+						DataSource:         v3.DataSourceMetrics,
+						AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+						AggregateOperator:  v3.AggregateOperatorSumRate,
+						Expression:         "B",
+						TimeShift:          7 * 24 * 60 * 60,
+					},
+					"C": {
+						QueryName:  "C",
+						Expression: "A/B",
+					},
+				},
+			},
+		}
+		qbOptions := QueryBuilderOptions{
+			BuildMetricQuery: metricsv3.PrepareMetricQuery,
+		}
+		qb := NewQueryBuilder(qbOptions)
+
+		queries, err := qb.PrepareQueries(q)
+		require.NoError(t, err)
+
+		require.Contains(t, queries["A"], "timestamp_ms >= 1650991982000 AND timestamp_ms <= 1651078382000")
+		require.True(t, strings.HasPrefix(queries["B"], "SELECT * REPLACE (ts + INTERVAL 604800 SECOND AS ts) FROM ("))
+		require.Contains(t, queries["B"], "timestamp_ms >= 1650387182000 AND timestamp_ms <= 1650473582000")
+		require.Contains(t, queries["C"], "SELECT A.ts as ts, A.value / B.value")
+		require.Contains(t, queries["C"], "(SELECT * REPLACE (ts + INTERVAL 604800 SECOND AS ts) FROM (")
+	})
+}
+
+func TestBuildQueryWithSeriesLimit(t *testing.T) {
+	t.Run("TestBuildQueryWithSeriesLimit", func(t *testing.T) {
+		q := &v3.QueryRangeParamsV3{
+			Start: 1650991982000,
+			End:   1651078382000,
+			Step:  60,
+			CompositeQuery: &v3.CompositeQuery{
+				PanelType: v3.PanelTypeGraph,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:          "A",
+						StepInterval:       60,
+						DataSource:         v3.DataSourceMetrics,
+						AggregateAttribute: v3.AttributeKey{Key: "signoz_calls_total"},
+						AggregateOperator:  v3.AggregateOperatorSumRate,
+						GroupBy:            []v3.AttributeKey{{Key: "service_name"}},
+						Expression:         "A",
+						Limit:              10,
+						LimitMode:          v3.LimitModeSeries,
+					},
+				},
+			},
+		}
+		qbOptions := QueryBuilderOptions{
+			BuildMetricQuery: metricsv3.PrepareMetricQuery,
+		}
+		qb := NewQueryBuilder(qbOptions)
+
+		queries, err := qb.PrepareQueries(q)
+		require.NoError(t, err)
+
+		require.True(t, strings.HasPrefix(queries["A"], "SELECT * FROM (SELECT service_name,  ts, sum(value) as value FROM ("))
+		require.Contains(t, queries["A"], "WHERE (`service_name`) GLOBAL IN (SELECT `service_name` FROM (")
+		require.True(t, strings.HasSuffix(queries["A"], "GROUP BY `service_name` ORDER BY sum(value) DESC, `service_name` ASC LIMIT 10)"))
+		require.NotContains(t, queries["A"], "UNION ALL")
+	})
+}
+
+func TestBuildQueryWithSeriesLimitAndOthers(t *testing.T) {
+	t.Run("TestBuildQueryWithSeriesLimitAndOthers", func(t *testing.T) {
+		q := &v3.QueryRangeParamsV3{
+			Start: 1650991982000,
+			End:   1651078382000,
+			Step:  60,
+			CompositeQuery: &v3.CompositeQuery{
+				PanelType: v3.PanelTypeGraph,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": {
+						QueryName:         "A",
+						StepInterval:      60,
+						DataSource:        v3.DataSourceLogs,
+						AggregateOperator: v3.AggregateOperatorAvg,
+						AggregateAttribute: v3.AttributeKey{
+							Key: "bytes", DataType: v3.AttributeKeyDataTypeFloat64, Type: v3.AttributeKeyTypeTag,
+						},
+						GroupBy:     []v3.AttributeKey{{Key: "host", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeResource}},
+						OrderBy:     []v3.OrderBy{{ColumnName: "#SIGNOZ_VALUE", Order: "asc"}},
+						Expression:  "A",
+						Limit:       5,
+						LimitMode:   v3.LimitModeSeries,
+						LimitOthers: true,
+					},
+				},
+			},
+		}
+		qbOptions := QueryBuilderOptions{
+			BuildLogQuery: logsv3.PrepareLogsQuery,
+		}
+		qb := NewQueryBuilder(qbOptions)
+
+		queries, err := qb.PrepareQueries(q)
+		require.NoError(t, err)
+
+		topGroups := "SELECT `host` FROM (SELECT toStartOfInterval"
+		require.True(t, strings.HasPrefix(queries["A"], "SELECT toString(`host`) as `host`, ts, value FROM (SELECT * FROM ("))
+		require.Contains(t, queries["A"], "WHERE (`host`) GLOBAL IN ("+topGroups)
+		require.Contains(t, queries["A"], " UNION ALL SELECT '__other__' as `host`, ts, avg(value) as value FROM (SELECT * FROM (")
+		require.Contains(t, queries["A"], "WHERE (`host`) GLOBAL NOT IN ("+topGroups)
+		require.Contains(t, queries["A"], "GROUP BY `host` ORDER BY avg(value) ASC, `host` ASC LIMIT 5)")
+		require.True(t, strings.HasSuffix(queries["A"], "GROUP BY ts"))
+	})
+}
+
 func TestBuildQueryWithIncorrectQueryRef(t *testing.T) {
 	t.Run("TestBuildQueryWithFilters", func(t *testing.T) {
 		q := &v3.QueryRangeParamsV3{
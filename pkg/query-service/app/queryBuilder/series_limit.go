@@ -0,0 +1,101 @@
+package queryBuilder
+
+import (
+	"fmt"
+	"strings"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils"
+)
+
+// OtherSeriesValue is the value of the group by labels of the series the
+// groups beyond the series limit are folded into
+const OtherSeriesValue = "__other__"
+
+// limitAggregate returns the function the points of a group are combined
+// with to rank the group over the whole window, and the series of the rest
+// of the groups are combined with at each ts
+func limitAggregate(operator v3.AggregateOperator) string {
+	switch operator {
+	case v3.AggregateOperatorCount, v3.AggregateOperatorCountDistinct, v3.AggregateOperatorSum,
+		v3.AggregateOperatorRate, v3.AggregateOperatorSumRate, v3.AggregateOperatorRateSum:
+		return "sum"
+	case v3.AggregateOperatorMax, v3.AggregateOperatorMaxRate, v3.AggregateOperatorRateMax:
+		return "max"
+	case v3.AggregateOperatorMin, v3.AggregateOperatorMinRate, v3.AggregateOperatorRateMin:
+		return "min"
+	default:
+		return "avg"
+	}
+}
+
+// seriesLimitOrder orders the groups by the order of the query, by the
+// ranked value in descending order when the query does not order by it
+func seriesLimitOrder(mq *v3.BuilderQuery, rank string, tags []string) string {
+	isTag := make(map[string]bool, len(mq.GroupBy))
+	for _, tag := range mq.GroupBy {
+		isTag[tag.Key] = true
+	}
+
+	var order []string
+	orderedByValue := false
+	for _, item := range mq.OrderBy {
+		direction := "ASC"
+		if strings.EqualFold(item.Order, "desc") {
+			direction = "DESC"
+		}
+		switch {
+		case item.ColumnName == constants.SigNozOrderByValue:
+			order = append(order, fmt.Sprintf("%s %s", rank, direction))
+			orderedByValue = true
+		case isTag[item.ColumnName]:
+			order = append(order, fmt.Sprintf("%s %s", utils.QuoteIdentifier(item.ColumnName), direction))
+		}
+	}
+	if !orderedByValue {
+		order = append(order, rank+" DESC")
+	}
+	// ties are broken by the group so the same groups are kept every time
+	for _, tag := range tags {
+		order = append(order, tag+" ASC")
+	}
+	return strings.Join(order, ", ")
+}
+
+// addSeriesLimit keeps only the series of the top groups of the time series
+// query, optionally with the rest of the groups folded into a single series
+// labelled __other__. The query must select the group by tags, ts and value.
+func addSeriesLimit(query string, mq *v3.BuilderQuery) string {
+	tags := make([]string, 0, len(mq.GroupBy))
+	for _, tag := range mq.GroupBy {
+		tags = append(tags, utils.QuoteIdentifier(tag.Key))
+	}
+	groups := strings.Join(tags, ", ")
+	aggregate := limitAggregate(mq.AggregateOperator)
+	rank := fmt.Sprintf("%s(value)", aggregate)
+
+	topGroups := fmt.Sprintf(
+		"SELECT %s FROM (%s) GROUP BY %s ORDER BY %s LIMIT %d",
+		groups, query, groups, seriesLimitOrder(mq, rank, tags), mq.Limit,
+	)
+	if !mq.LimitOthers {
+		return fmt.Sprintf("SELECT * FROM (%s) WHERE (%s) GLOBAL IN (%s)", query, groups, topGroups)
+	}
+
+	// the group by values are selected as strings in both parts of the
+	// union since the other series has string values for them
+	topTags := make([]string, 0, len(tags))
+	otherTags := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		topTags = append(topTags, fmt.Sprintf("toString(%s) as %s", tag, tag))
+		otherTags = append(otherTags, fmt.Sprintf("'%s' as %s", OtherSeriesValue, tag))
+	}
+	return fmt.Sprintf(
+		"SELECT %s, ts, value FROM (SELECT * FROM (%s) WHERE (%s) GLOBAL IN (%s))"+
+			" UNION ALL "+
+			"SELECT %s, ts, %s(value) as value FROM (SELECT * FROM (%s) WHERE (%s) GLOBAL NOT IN (%s)) GROUP BY ts",
+		strings.Join(topTags, ", "), query, groups, topGroups,
+		strings.Join(otherTags, ", "), aggregate, query, groups, topGroups,
+	)
+}
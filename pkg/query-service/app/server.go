@@ -21,11 +21,15 @@ import (
 	"go.signoz.io/signoz/pkg/query-service/agentConf"
 	"go.signoz.io/signoz/pkg/query-service/app/clickhouseReader"
 	"go.signoz.io/signoz/pkg/query-service/app/dashboards"
+	"go.signoz.io/signoz/pkg/query-service/app/logparsingpipeline"
 	opamp "go.signoz.io/signoz/pkg/query-service/app/opamp"
 	opAmpModel "go.signoz.io/signoz/pkg/query-service/app/opamp/model"
+	"go.signoz.io/signoz/pkg/query-service/app/querier"
+	"go.signoz.io/signoz/pkg/query-service/app/queryBuilder"
 
 	"go.signoz.io/signoz/pkg/query-service/app/explorer"
 	"go.signoz.io/signoz/pkg/query-service/auth"
+	"go.signoz.io/signoz/pkg/query-service/cache"
 	"go.signoz.io/signoz/pkg/query-service/constants"
 	"go.signoz.io/signoz/pkg/query-service/dao"
 	"go.signoz.io/signoz/pkg/query-service/featureManager"
@@ -48,6 +52,11 @@ type ServerOptions struct {
 	// alert specific params
 	DisableRules bool
 	RuleRepoURL  string
+	// directory of Prometheus rule files imported on start
+	RuleImportDir string
+	// query cache params
+	CacheConfigPath string
+	FluxInterval    string
 }
 
 // Server runs HTTP, Mux and a grpc server
@@ -115,8 +124,33 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 		}
 	}
 
+	var c cache.Cache
+	if serverOptions.CacheConfigPath != "" {
+		cacheOpts, err := cache.LoadFromYAMLCacheConfigFile(serverOptions.CacheConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		c = cache.NewCache(cacheOpts)
+		if c == nil {
+			return nil, fmt.Errorf("cache provider: %s is not supported", cacheOpts.Provider)
+		}
+		if err := c.Connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	fluxInterval, err := time.ParseDuration(serverOptions.FluxInterval)
+	if err != nil {
+		return nil, err
+	}
+
 	<-readerReady
-	rm, err := makeRulesManager(serverOptions.PromConfigPath, constants.GetAlertManagerApiPrefix(), serverOptions.RuleRepoURL, localDB, reader, serverOptions.DisableRules, fm)
+	rm, err := makeRulesManager(serverOptions.PromConfigPath, constants.GetAlertManagerApiPrefix(), serverOptions.RuleRepoURL, localDB, reader, c, fluxInterval, serverOptions.DisableRules, fm)
+	if err != nil {
+		return nil, err
+	}
+
+	logParsingPipelineController, err := logparsingpipeline.NewLogParsingPipelinesController(localDB, "sqlite")
 	if err != nil {
 		return nil, err
 	}
@@ -128,6 +162,10 @@ func NewServer(serverOptions *ServerOptions) (*Server, error) {
 		AppDao:       dao.DB(),
 		RuleManager:  rm,
 		FeatureFlags: fm,
+		Cache:        c,
+		FluxInterval: fluxInterval,
+
+		LogsParsingPipelineController: logParsingPipelineController,
 	})
 	if err != nil {
 		return nil, err
@@ -207,6 +245,7 @@ func (s *Server) createPublicServer(api *APIHandler) (*http.Server, error) {
 	api.RegisterMetricsRoutes(r, am)
 	api.RegisterLogsRoutes(r, am)
 	api.RegisterQueryRangeV3Routes(r, am)
+	api.RegisterPrometheusRoutes(r, am)
 
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
@@ -410,6 +449,12 @@ func (s *Server) Start() error {
 		zap.S().Info("msg: Rules disabled as rules.disable is set to TRUE")
 	}
 
+	if s.serverOptions.RuleImportDir != "" {
+		if err := s.ruleManager.ImportPromRulesDir(s.serverOptions.RuleImportDir); err != nil {
+			return err
+		}
+	}
+
 	err := s.initListeners()
 	if err != nil {
 		return err
@@ -501,6 +546,8 @@ func makeRulesManager(
 	ruleRepoURL string,
 	db *sqlx.DB,
 	ch interfaces.Reader,
+	c cache.Cache,
+	fluxInterval time.Duration,
 	disableRules bool,
 	fm interfaces.FeatureLookup) (*rules.Manager, error) {
 
@@ -510,6 +557,14 @@ func makeRulesManager(
 		return nil, fmt.Errorf("failed to create pql engine : %v", err)
 	}
 
+	// the querier uses the cache only when one is configured
+	q := querier.NewQuerier(querier.QuerierOptions{
+		Reader:       ch,
+		Cache:        c,
+		KeyGenerator: queryBuilder.NewKeyGenerator(),
+		FluxInterval: fluxInterval,
+	})
+
 	// notifier opts
 	notifierOpts := am.NotifierOptions{
 		QueueCapacity:    10000,
@@ -523,6 +578,7 @@ func makeRulesManager(
 		Queriers: &rules.Queriers{
 			PqlEngine: pqle,
 			Ch:        ch.GetConn(),
+			Querier:   q,
 		},
 		RepoURL:      ruleRepoURL,
 		DBConn:       db,
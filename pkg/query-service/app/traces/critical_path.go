@@ -0,0 +1,390 @@
+package traces
+
+import (
+	"fmt"
+	"sort"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// spanNode is a span of the trace tree, start and end are the interval of
+// the span clamped to the interval of its parent
+type spanNode struct {
+	span         *model.SearchSpanResponseItem
+	parentID     string
+	parent       *spanNode
+	children     []*spanNode
+	start        int64
+	end          int64
+	orphan       bool
+	skewed       bool
+	selfTime     int64
+	criticalPath int64
+}
+
+func (n *spanNode) rawStart() int64 {
+	return int64(n.span.TimeUnixNano)
+}
+
+func (n *spanNode) rawEnd() int64 {
+	if n.span.DurationNano < 0 {
+		return n.rawStart()
+	}
+	return n.rawStart() + n.span.DurationNano
+}
+
+// parentSpanID returns the span the span is a child of, the CHILD_OF
+// reference is preferred over the FOLLOWS_FROM one
+func parentSpanID(span *model.SearchSpanResponseItem) string {
+	var followsFrom string
+	for _, ref := range span.References {
+		if ref.SpanId == "" || ref.SpanId == span.SpanID || (ref.TraceId != "" && ref.TraceId != span.TraceID) {
+			continue
+		}
+		if ref.RefType == "CHILD_OF" {
+			return ref.SpanId
+		}
+		if followsFrom == "" {
+			followsFrom = ref.SpanId
+		}
+	}
+	return followsFrom
+}
+
+// AnalyzeTrace builds the span tree of the trace from the references of the
+// spans and returns its critical path, the self time of each span and the
+// time each service and operation spent on the critical path.
+//
+// The critical path starts at the longest root span and is walked back from
+// its end: the time is given to the child that finished last, and to the
+// span itself when none of its children was running. The spans that do not
+// fit in their parent, because of clock skew or because they ran after it,
+// are clamped to the parent. The spans whose parent is missing are orphans,
+// they have their self time but are not on the critical path.
+func AnalyzeTrace(traceID string, spans []model.SearchSpanResponseItem) (*model.TraceAnalysis, error) {
+	nodes, ordered, roots := spanTree(spans)
+	if len(ordered) == 0 {
+		return nil, fmt.Errorf("trace %s has no spans", traceID)
+	}
+
+	for _, root := range roots {
+		clamp(root)
+	}
+	for _, node := range ordered {
+		node.selfTime = selfTime(node)
+	}
+
+	root := mainRoot(roots)
+	path := criticalPath(root)
+	for _, segment := range path {
+		nodes[segment.SpanID].criticalPath += segment.DurationNano
+	}
+
+	traceStart, traceEnd := traceBounds(ordered)
+	analysis := &model.TraceAnalysis{
+		TraceID:           traceID,
+		RootSpanID:        root.span.SpanID,
+		StartTimeUnixNano: uint64(traceStart),
+		DurationNano:      traceEnd - traceStart,
+		CriticalPath:      path,
+		Spans:             make([]model.SpanTimes, 0, len(ordered)),
+		OrphanSpanIDs:     []string{},
+		SkewedSpanIDs:     []string{},
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].rawStart() != ordered[j].rawStart() {
+			return ordered[i].rawStart() < ordered[j].rawStart()
+		}
+		return ordered[i].span.SpanID < ordered[j].span.SpanID
+	})
+	for _, node := range ordered {
+		analysis.Spans = append(analysis.Spans, model.SpanTimes{
+			SpanID:            node.span.SpanID,
+			ParentSpanID:      node.parentID,
+			ServiceName:       node.span.ServiceName,
+			Name:              node.span.Name,
+			StartTimeUnixNano: node.span.TimeUnixNano,
+			DurationNano:      node.rawEnd() - node.rawStart(),
+			SelfTimeNano:      node.selfTime,
+			CriticalPathNano:  node.criticalPath,
+			Orphan:            node.orphan,
+		})
+		if node.orphan {
+			analysis.OrphanSpanIDs = append(analysis.OrphanSpanIDs, node.span.SpanID)
+		}
+		if node.skewed {
+			analysis.SkewedSpanIDs = append(analysis.SkewedSpanIDs, node.span.SpanID)
+		}
+	}
+	analysis.Services, analysis.Operations = breakdown(ordered)
+	return analysis, nil
+}
+
+// spanTree builds the tree of the spans, the duplicate spans are dropped.
+// It returns the spans by id, the spans in the order they were given and
+// the roots of the tree.
+func spanTree(spans []model.SearchSpanResponseItem) (map[string]*spanNode, []*spanNode, []*spanNode) {
+	nodes := make(map[string]*spanNode, len(spans))
+	ordered := make([]*spanNode, 0, len(spans))
+	for i := range spans {
+		span := &spans[i]
+		if span.SpanID == "" || nodes[span.SpanID] != nil {
+			continue
+		}
+		node := &spanNode{span: span, parentID: parentSpanID(span)}
+		nodes[span.SpanID] = node
+		ordered = append(ordered, node)
+	}
+
+	for _, node := range ordered {
+		if node.parentID == "" {
+			continue
+		}
+		if parent, ok := nodes[node.parentID]; ok {
+			node.parent = parent
+		} else {
+			node.orphan = true
+		}
+	}
+	breakCycles(ordered)
+
+	var roots []*spanNode
+	for _, node := range ordered {
+		if node.parent == nil {
+			roots = append(roots, node)
+			continue
+		}
+		node.parent.children = append(node.parent.children, node)
+	}
+	return nodes, ordered, roots
+}
+
+// traceBounds returns the start of the first span and the end of the last
+// span of the trace, the spans are not clamped
+func traceBounds(nodes []*spanNode) (int64, int64) {
+	start, end := nodes[0].rawStart(), nodes[0].rawEnd()
+	for _, node := range nodes {
+		if node.rawStart() < start {
+			start = node.rawStart()
+		}
+		if node.rawEnd() > end {
+			end = node.rawEnd()
+		}
+	}
+	return start, end
+}
+
+// breakCycles detaches the spans whose ancestors refer back to them, the
+// references of such spans are broken so they are treated as orphans
+func breakCycles(nodes []*spanNode) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*spanNode]int, len(nodes))
+	for _, node := range nodes {
+		var chain []*spanNode
+		for cur := node; cur != nil && state[cur] == unvisited; cur = cur.parent {
+			state[cur] = visiting
+			chain = append(chain, cur)
+			if cur.parent != nil && state[cur.parent] == visiting {
+				cur.parent = nil
+				cur.orphan = true
+			}
+		}
+		for _, cur := range chain {
+			state[cur] = visited
+		}
+	}
+}
+
+// clamp sets the intervals of the spans of the tree, each span is clamped
+// to the interval of its parent
+func clamp(root *spanNode) {
+	root.start, root.end = root.rawStart(), root.rawEnd()
+	queue := []*spanNode{root}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, child := range parent.children {
+			start, end := child.rawStart(), child.rawEnd()
+			if start < parent.start || end > parent.end {
+				child.skewed = true
+			}
+			child.start = clampTo(start, parent.start, parent.end)
+			child.end = clampTo(end, parent.start, parent.end)
+			queue = append(queue, child)
+		}
+		sort.SliceStable(parent.children, func(i, j int) bool {
+			return parent.children[i].start < parent.children[j].start
+		})
+	}
+}
+
+func clampTo(v, min, max int64) int64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// selfTime returns the time the span was not waiting on any of its children
+func selfTime(node *spanNode) int64 {
+	var covered int64
+	coveredUntil := node.start
+	// the children are sorted by their start
+	for _, child := range node.children {
+		start := child.start
+		if start < coveredUntil {
+			start = coveredUntil
+		}
+		if child.end > start {
+			covered += child.end - start
+			coveredUntil = child.end
+		}
+	}
+	return node.end - node.start - covered
+}
+
+// mainRoot returns the root the critical path starts from, the longest root
+// span with a preference for the spans that are not orphans
+func mainRoot(roots []*spanNode) *spanNode {
+	var main *spanNode
+	for _, root := range roots {
+		switch {
+		case main == nil:
+			main = root
+		case main.orphan != root.orphan:
+			if !root.orphan {
+				main = root
+			}
+		case root.end-root.start > main.end-main.start,
+			root.end-root.start == main.end-main.start && root.start < main.start:
+			main = root
+		}
+	}
+	return main
+}
+
+// criticalPath returns the critical path of the tree in the order of time
+func criticalPath(root *spanNode) []model.CriticalPathSegment {
+	var reversed []model.CriticalPathSegment
+	walkCriticalPath(root, root.end, &reversed)
+
+	path := make([]model.CriticalPathSegment, 0, len(reversed))
+	for i := len(reversed) - 1; i >= 0; i-- {
+		segment := reversed[i]
+		last := len(path) - 1
+		if last >= 0 && path[last].SpanID == segment.SpanID &&
+			int64(path[last].StartTimeUnixNano)+path[last].DurationNano == int64(segment.StartTimeUnixNano) {
+			path[last].DurationNano += segment.DurationNano
+			continue
+		}
+		path = append(path, segment)
+	}
+	return path
+}
+
+// walkCriticalPath walks the critical path of the span back from end, the
+// segments are appended latest first
+func walkCriticalPath(node *spanNode, end int64, reversed *[]model.CriticalPathSegment) {
+	cursor := end
+	for cursor > node.start {
+		var last *spanNode
+		var lastEnd int64
+		for _, child := range node.children {
+			if child.start >= cursor || child.end <= child.start {
+				continue
+			}
+			childEnd := child.end
+			if childEnd > cursor {
+				childEnd = cursor
+			}
+			if last == nil || childEnd > lastEnd || (childEnd == lastEnd && child.start > last.start) {
+				last, lastEnd = child, childEnd
+			}
+		}
+		if last == nil {
+			break
+		}
+		if lastEnd < cursor {
+			*reversed = append(*reversed, segment(node, lastEnd, cursor))
+		}
+		walkCriticalPath(last, lastEnd, reversed)
+		cursor = last.start
+	}
+	if cursor > node.start {
+		*reversed = append(*reversed, segment(node, node.start, cursor))
+	}
+}
+
+func segment(node *spanNode, start, end int64) model.CriticalPathSegment {
+	return model.CriticalPathSegment{
+		SpanID:            node.span.SpanID,
+		ServiceName:       node.span.ServiceName,
+		Name:              node.span.Name,
+		StartTimeUnixNano: uint64(start),
+		DurationNano:      end - start,
+	}
+}
+
+// breakdown returns the time of each service and operation on the critical
+// path and by itself, the items are sorted by their time on the critical path
+func breakdown(nodes []*spanNode) ([]model.TimeBreakdownItem, []model.TimeBreakdownItem) {
+	services := map[string]*model.TimeBreakdownItem{}
+	operations := map[[2]string]*model.TimeBreakdownItem{}
+	var total int64
+	for _, node := range nodes {
+		service, ok := services[node.span.ServiceName]
+		if !ok {
+			service = &model.TimeBreakdownItem{ServiceName: node.span.ServiceName}
+			services[node.span.ServiceName] = service
+		}
+		key := [2]string{node.span.ServiceName, node.span.Name}
+		operation, ok := operations[key]
+		if !ok {
+			operation = &model.TimeBreakdownItem{ServiceName: node.span.ServiceName, Name: node.span.Name}
+			operations[key] = operation
+		}
+		for _, item := range []*model.TimeBreakdownItem{service, operation} {
+			item.CriticalPathNano += node.criticalPath
+			item.SelfTimeNano += node.selfTime
+			item.SpanCount++
+		}
+		total += node.criticalPath
+	}
+
+	serviceItems := make([]model.TimeBreakdownItem, 0, len(services))
+	for _, item := range services {
+		serviceItems = append(serviceItems, *item)
+	}
+	operationItems := make([]model.TimeBreakdownItem, 0, len(operations))
+	for _, item := range operations {
+		operationItems = append(operationItems, *item)
+	}
+	for _, items := range [][]model.TimeBreakdownItem{serviceItems, operationItems} {
+		for i := range items {
+			if total > 0 {
+				items[i].CriticalPathPercent = float64(items[i].CriticalPathNano) * 100 / float64(total)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].CriticalPathNano != items[j].CriticalPathNano {
+				return items[i].CriticalPathNano > items[j].CriticalPathNano
+			}
+			if items[i].SelfTimeNano != items[j].SelfTimeNano {
+				return items[i].SelfTimeNano > items[j].SelfTimeNano
+			}
+			if items[i].ServiceName != items[j].ServiceName {
+				return items[i].ServiceName < items[j].ServiceName
+			}
+			return items[i].Name < items[j].Name
+		})
+	}
+	return serviceItems, operationItems
+}
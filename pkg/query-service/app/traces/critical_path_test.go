@@ -0,0 +1,208 @@
+package traces
+
+import (
+	"reflect"
+	"testing"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func testSpan(id, parent, service string, start, end int64) model.SearchSpanResponseItem {
+	span := model.SearchSpanResponseItem{
+		TimeUnixNano: uint64(start),
+		DurationNano: end - start,
+		SpanID:       id,
+		TraceID:      "trace",
+		ServiceName:  service,
+		Name:         id,
+	}
+	if parent != "" {
+		span.References = []model.OtelSpanRef{{TraceId: "trace", SpanId: parent, RefType: "CHILD_OF"}}
+	}
+	return span
+}
+
+type pathSegment struct {
+	spanID     string
+	start, end int64
+}
+
+func segments(path []model.CriticalPathSegment) []pathSegment {
+	var result []pathSegment
+	for _, s := range path {
+		result = append(result, pathSegment{s.SpanID, int64(s.StartTimeUnixNano), int64(s.StartTimeUnixNano) + s.DurationNano})
+	}
+	return result
+}
+
+func selfTimes(analysis *model.TraceAnalysis) map[string]int64 {
+	result := map[string]int64{}
+	for _, s := range analysis.Spans {
+		result[s.SpanID] = s.SelfTimeNano
+	}
+	return result
+}
+
+func TestAnalyzeTrace(t *testing.T) {
+	testCases := []struct {
+		name         string
+		spans        []model.SearchSpanResponseItem
+		root         string
+		criticalPath []pathSegment
+		selfTimes    map[string]int64
+		orphans      []string
+		skewed       []string
+	}{
+		{
+			name: "sequential children",
+			spans: []model.SearchSpanResponseItem{
+				testSpan("a", "", "frontend", 0, 100),
+				testSpan("c", "a", "db", 50, 90),
+				testSpan("b", "a", "cache", 10, 40),
+			},
+			root:         "a",
+			criticalPath: []pathSegment{{"a", 0, 10}, {"b", 10, 40}, {"a", 40, 50}, {"c", 50, 90}, {"a", 90, 100}},
+			selfTimes:    map[string]int64{"a": 30, "b": 30, "c": 40},
+		},
+		{
+			name: "parallel children",
+			spans: []model.SearchSpanResponseItem{
+				testSpan("a", "", "frontend", 0, 100),
+				testSpan("b", "a", "cache", 10, 80),
+				testSpan("c", "a", "db", 20, 60),
+				testSpan("d", "b", "cache", 70, 75),
+			},
+			root:         "a",
+			criticalPath: []pathSegment{{"a", 0, 10}, {"b", 10, 70}, {"d", 70, 75}, {"b", 75, 80}, {"a", 80, 100}},
+			selfTimes:    map[string]int64{"a": 30, "b": 65, "c": 40, "d": 5},
+		},
+		{
+			name: "overlapping children",
+			spans: []model.SearchSpanResponseItem{
+				testSpan("a", "", "frontend", 0, 100),
+				testSpan("b", "a", "cache", 10, 50),
+				testSpan("c", "a", "db", 40, 90),
+			},
+			root:         "a",
+			criticalPath: []pathSegment{{"a", 0, 10}, {"b", 10, 40}, {"c", 40, 90}, {"a", 90, 100}},
+			selfTimes:    map[string]int64{"a": 20, "b": 40, "c": 50},
+		},
+		{
+			name: "clock skew",
+			spans: []model.SearchSpanResponseItem{
+				testSpan("a", "", "frontend", 0, 100),
+				testSpan("b", "a", "cache", -20, 30),
+				testSpan("c", "a", "db", 80, 150),
+				testSpan("d", "a", "db", 200, 250),
+			},
+			root:         "a",
+			criticalPath: []pathSegment{{"b", 0, 30}, {"a", 30, 80}, {"c", 80, 100}},
+			selfTimes:    map[string]int64{"a": 50, "b": 30, "c": 20, "d": 0},
+			skewed:       []string{"b", "c", "d"},
+		},
+		{
+			name: "orphan spans",
+			spans: []model.SearchSpanResponseItem{
+				testSpan("a", "", "frontend", 0, 100),
+				testSpan("b", "a", "cache", 10, 40),
+				testSpan("x", "missing", "db", 20, 300),
+			},
+			root:         "a",
+			criticalPath: []pathSegment{{"a", 0, 10}, {"b", 10, 40}, {"a", 40, 100}},
+			selfTimes:    map[string]int64{"a": 70, "b": 30, "x": 280},
+			orphans:      []string{"x"},
+		},
+		{
+			name: "no root span",
+			spans: []model.SearchSpanResponseItem{
+				testSpan("x", "missing", "db", 0, 50),
+				testSpan("y", "missing", "db", 10, 100),
+			},
+			root:         "y",
+			criticalPath: []pathSegment{{"y", 10, 100}},
+			selfTimes:    map[string]int64{"x": 50, "y": 90},
+			orphans:      []string{"x", "y"},
+		},
+		{
+			name: "cycle and duplicates",
+			spans: []model.SearchSpanResponseItem{
+				testSpan("a", "b", "frontend", 0, 100),
+				testSpan("b", "a", "cache", 10, 40),
+				testSpan("b", "a", "cache", 10, 40),
+			},
+			root:         "b",
+			criticalPath: []pathSegment{{"a", 10, 40}},
+			selfTimes:    map[string]int64{"a": 30, "b": 0},
+			orphans:      []string{"b"},
+			skewed:       []string{"a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analysis, err := AnalyzeTrace("trace", tc.spans)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if analysis.RootSpanID != tc.root {
+				t.Errorf("expected root %s, got %s", tc.root, analysis.RootSpanID)
+			}
+			if got := segments(analysis.CriticalPath); !reflect.DeepEqual(got, tc.criticalPath) {
+				t.Errorf("expected critical path %v, got %v", tc.criticalPath, got)
+			}
+			if got := selfTimes(analysis); !reflect.DeepEqual(got, tc.selfTimes) {
+				t.Errorf("expected self times %v, got %v", tc.selfTimes, got)
+			}
+			if tc.orphans == nil {
+				tc.orphans = []string{}
+			}
+			if !reflect.DeepEqual(analysis.OrphanSpanIDs, tc.orphans) {
+				t.Errorf("expected orphans %v, got %v", tc.orphans, analysis.OrphanSpanIDs)
+			}
+			if tc.skewed == nil {
+				tc.skewed = []string{}
+			}
+			if !reflect.DeepEqual(analysis.SkewedSpanIDs, tc.skewed) {
+				t.Errorf("expected skewed spans %v, got %v", tc.skewed, analysis.SkewedSpanIDs)
+			}
+		})
+	}
+}
+
+func TestAnalyzeTraceBreakdown(t *testing.T) {
+	analysis, err := AnalyzeTrace("trace", []model.SearchSpanResponseItem{
+		testSpan("a", "", "frontend", 0, 100),
+		testSpan("b", "a", "db", 10, 40),
+		testSpan("c", "a", "db", 50, 90),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedServices := []model.TimeBreakdownItem{
+		{ServiceName: "db", CriticalPathNano: 70, CriticalPathPercent: 70, SelfTimeNano: 70, SpanCount: 2},
+		{ServiceName: "frontend", CriticalPathNano: 30, CriticalPathPercent: 30, SelfTimeNano: 30, SpanCount: 1},
+	}
+	if !reflect.DeepEqual(analysis.Services, expectedServices) {
+		t.Errorf("expected services %+v, got %+v", expectedServices, analysis.Services)
+	}
+
+	expectedOperations := []model.TimeBreakdownItem{
+		{ServiceName: "db", Name: "c", CriticalPathNano: 40, CriticalPathPercent: 40, SelfTimeNano: 40, SpanCount: 1},
+		{ServiceName: "db", Name: "b", CriticalPathNano: 30, CriticalPathPercent: 30, SelfTimeNano: 30, SpanCount: 1},
+		{ServiceName: "frontend", Name: "a", CriticalPathNano: 30, CriticalPathPercent: 30, SelfTimeNano: 30, SpanCount: 1},
+	}
+	if !reflect.DeepEqual(analysis.Operations, expectedOperations) {
+		t.Errorf("expected operations %+v, got %+v", expectedOperations, analysis.Operations)
+	}
+
+	if analysis.DurationNano != 100 {
+		t.Errorf("expected trace duration 100, got %d", analysis.DurationNano)
+	}
+}
+
+func TestAnalyzeTraceWithoutSpans(t *testing.T) {
+	if _, err := AnalyzeTrace("trace", nil); err == nil {
+		t.Errorf("expected an error for a trace without spans")
+	}
+}
@@ -0,0 +1,226 @@
+package traces
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// operationKey is the service and operation name spans are aligned by
+type operationKey struct {
+	serviceName string
+	name        string
+}
+
+func nodeKey(node *spanNode) operationKey {
+	return operationKey{serviceName: node.span.ServiceName, name: node.span.Name}
+}
+
+// spanPair is a span of the base trace and the span of the target trace it
+// is aligned with, one of them is nil when the span was added or removed
+type spanPair struct {
+	base   *spanNode
+	target *spanNode
+}
+
+// DiffTraces aligns the span trees of the base and the target traces and
+// reports the spans that were added or removed, the duration changes of
+// the aligned spans and the new errors.
+//
+// The children of two aligned spans, and the roots of the traces, are
+// aligned by their service and operation name: the n-th child of a name in
+// the base trace is aligned with the n-th child of the same name in the
+// target trace, in the order of their start. The spans left over are
+// added or removed along with their descendants.
+func DiffTraces(baseTraceID string, baseSpans []model.SearchSpanResponseItem, targetTraceID string, targetSpans []model.SearchSpanResponseItem) (*model.TraceDiff, error) {
+	_, baseOrdered, baseRoots := spanTree(baseSpans)
+	if len(baseOrdered) == 0 {
+		return nil, fmt.Errorf("trace %s has no spans", baseTraceID)
+	}
+	_, targetOrdered, targetRoots := spanTree(targetSpans)
+	if len(targetOrdered) == 0 {
+		return nil, fmt.Errorf("trace %s has no spans", targetTraceID)
+	}
+
+	baseStart, baseEnd := traceBounds(baseOrdered)
+	targetStart, targetEnd := traceBounds(targetOrdered)
+	diff := &model.TraceDiff{
+		BaseTraceID:        baseTraceID,
+		TargetTraceID:      targetTraceID,
+		BaseDurationNano:   baseEnd - baseStart,
+		TargetDurationNano: targetEnd - targetStart,
+		DurationChangeNano: (targetEnd - targetStart) - (baseEnd - baseStart),
+		Spans:              []model.SpanDiff{},
+	}
+	diffChildren(diff, baseRoots, targetRoots, baseStart, targetStart, 0)
+	return diff, nil
+}
+
+// diffChildren aligns the children of two aligned spans and appends their
+// diffs, each followed by the diffs of its children
+func diffChildren(diff *model.TraceDiff, base, target []*spanNode, baseStart, targetStart int64, depth int) {
+	baseByKey := map[operationKey][]*spanNode{}
+	for _, node := range byStart(base) {
+		baseByKey[nodeKey(node)] = append(baseByKey[nodeKey(node)], node)
+	}
+
+	var pairs []spanPair
+	for _, node := range byStart(target) {
+		key := nodeKey(node)
+		pair := spanPair{target: node}
+		if candidates := baseByKey[key]; len(candidates) > 0 {
+			pair.base = candidates[0]
+			baseByKey[key] = candidates[1:]
+		}
+		pairs = append(pairs, pair)
+	}
+	for _, node := range byStart(base) {
+		if candidates := baseByKey[nodeKey(node)]; len(candidates) > 0 && candidates[0] == node {
+			pairs = append(pairs, spanPair{base: node})
+			baseByKey[nodeKey(node)] = candidates[1:]
+		}
+	}
+
+	// the pairs are ordered by the start of their span relative to the
+	// start of the parent
+	offset := func(pair spanPair) int64 {
+		if pair.target != nil {
+			return pair.target.rawStart() - targetStart
+		}
+		return pair.base.rawStart() - baseStart
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return offset(pairs[i]) < offset(pairs[j])
+	})
+
+	for _, pair := range pairs {
+		item := model.SpanDiff{Depth: depth}
+		var baseChildren, targetChildren []*spanNode
+		var childBaseStart, childTargetStart int64
+		if pair.base != nil {
+			item.ServiceName, item.Name = pair.base.span.ServiceName, pair.base.span.Name
+			item.BaseSpanID = pair.base.span.SpanID
+			item.BaseDurationNano = pair.base.rawEnd() - pair.base.rawStart()
+			item.BaseHasError = pair.base.span.HasError
+			baseChildren, childBaseStart = pair.base.children, pair.base.rawStart()
+		}
+		if pair.target != nil {
+			item.ServiceName, item.Name = pair.target.span.ServiceName, pair.target.span.Name
+			item.TargetSpanID = pair.target.span.SpanID
+			item.TargetDurationNano = pair.target.rawEnd() - pair.target.rawStart()
+			item.TargetHasError = pair.target.span.HasError
+			targetChildren, childTargetStart = pair.target.children, pair.target.rawStart()
+		}
+		item.DurationChangeNano = item.TargetDurationNano - item.BaseDurationNano
+		item.NewError = item.TargetHasError && !item.BaseHasError
+
+		switch {
+		case pair.base == nil:
+			item.Status = model.DiffStatusAdded
+			diff.AddedCount++
+		case pair.target == nil:
+			item.Status = model.DiffStatusRemoved
+			diff.RemovedCount++
+		default:
+			item.Status = model.DiffStatusMatched
+		}
+		if item.NewError {
+			diff.NewErrorCount++
+		}
+
+		diff.Spans = append(diff.Spans, item)
+		diffChildren(diff, baseChildren, targetChildren, childBaseStart, childTargetStart, depth+1)
+	}
+}
+
+// byStart returns the spans sorted by their start
+func byStart(nodes []*spanNode) []*spanNode {
+	sorted := make([]*spanNode, len(nodes))
+	copy(sorted, nodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].rawStart() < sorted[j].rawStart()
+	})
+	return sorted
+}
+
+// CompareOperationWindows compares the latency of the root operation and of
+// the operations of its traces in the base and the target windows. The
+// operations are sorted by how much their p95 changed, the largest change
+// first, and at most limit of them are returned when limit is positive.
+func CompareOperationWindows(serviceName, operation string, base, target *model.OperationWindowStats, limit int) *model.OperationWindowsDiff {
+	diff := &model.OperationWindowsDiff{
+		ServiceName: serviceName,
+		Operation:   operation,
+		Root:        operationStatsDiff(&base.Root, &target.Root),
+		Operations:  []model.OperationStatsDiff{},
+	}
+
+	baseByKey := make(map[operationKey]*model.OperationStatsItem, len(base.Operations))
+	for i := range base.Operations {
+		item := &base.Operations[i]
+		baseByKey[operationKey{item.ServiceName, item.Name}] = item
+	}
+	for i := range target.Operations {
+		item := &target.Operations[i]
+		key := operationKey{item.ServiceName, item.Name}
+		baseItem, ok := baseByKey[key]
+		if !ok {
+			baseItem = &model.OperationStatsItem{ServiceName: item.ServiceName, Name: item.Name}
+		}
+		delete(baseByKey, key)
+		diff.Operations = append(diff.Operations, operationStatsDiff(baseItem, item))
+	}
+	for i := range base.Operations {
+		item := &base.Operations[i]
+		if _, ok := baseByKey[operationKey{item.ServiceName, item.Name}]; ok {
+			diff.Operations = append(diff.Operations, operationStatsDiff(item, &model.OperationStatsItem{ServiceName: item.ServiceName, Name: item.Name}))
+		}
+	}
+
+	items := diff.Operations
+	sort.Slice(items, func(i, j int) bool {
+		if p95i, p95j := math.Abs(items[i].P95Change), math.Abs(items[j].P95Change); p95i != p95j {
+			return p95i > p95j
+		}
+		if p50i, p50j := math.Abs(items[i].P50Change), math.Abs(items[j].P50Change); p50i != p50j {
+			return p50i > p50j
+		}
+		if items[i].ServiceName != items[j].ServiceName {
+			return items[i].ServiceName < items[j].ServiceName
+		}
+		return items[i].Name < items[j].Name
+	})
+	if limit > 0 && len(diff.Operations) > limit {
+		diff.Operations = diff.Operations[:limit]
+	}
+	return diff
+}
+
+// operationStatsDiff compares the stats of an operation, the operation is
+// missing from a window when it was not called in it
+func operationStatsDiff(base, target *model.OperationStatsItem) model.OperationStatsDiff {
+	status := model.DiffStatusMatched
+	switch {
+	case base.NumCalls == 0 && target.NumCalls > 0:
+		status = model.DiffStatusAdded
+	case base.NumCalls > 0 && target.NumCalls == 0:
+		status = model.DiffStatusRemoved
+	}
+	return model.OperationStatsDiff{
+		ServiceName:      target.ServiceName,
+		Name:             target.Name,
+		Status:           status,
+		BaseP50:          base.Percentile50,
+		TargetP50:        target.Percentile50,
+		P50Change:        target.Percentile50 - base.Percentile50,
+		BaseP95:          base.Percentile95,
+		TargetP95:        target.Percentile95,
+		P95Change:        target.Percentile95 - base.Percentile95,
+		BaseNumCalls:     base.NumCalls,
+		TargetNumCalls:   target.NumCalls,
+		BaseErrorCount:   base.ErrorCount,
+		TargetErrorCount: target.ErrorCount,
+	}
+}
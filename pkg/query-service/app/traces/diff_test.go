@@ -0,0 +1,130 @@
+package traces
+
+import (
+	"reflect"
+	"testing"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func testNamedSpan(id, parent, service, name string, start, end int64, hasError bool) model.SearchSpanResponseItem {
+	span := testSpan(id, parent, service, start, end)
+	span.Name = name
+	span.HasError = hasError
+	return span
+}
+
+type spanDiff struct {
+	name           string
+	depth          int
+	status         string
+	base, target   string
+	durationChange int64
+	newError       bool
+}
+
+func spanDiffs(diff *model.TraceDiff) []spanDiff {
+	var result []spanDiff
+	for _, s := range diff.Spans {
+		result = append(result, spanDiff{s.Name, s.Depth, s.Status, s.BaseSpanID, s.TargetSpanID, s.DurationChangeNano, s.NewError})
+	}
+	return result
+}
+
+func TestDiffTraces(t *testing.T) {
+	base := []model.SearchSpanResponseItem{
+		testNamedSpan("a1", "", "frontend", "GET /checkout", 0, 100, false),
+		testNamedSpan("b1", "a1", "cart", "get cart", 10, 30, false),
+		testNamedSpan("c1", "a1", "db", "SELECT", 30, 40, false),
+		testNamedSpan("c2", "a1", "db", "SELECT", 40, 50, false),
+		testNamedSpan("d1", "a1", "cache", "GET", 60, 70, false),
+	}
+	target := []model.SearchSpanResponseItem{
+		testNamedSpan("a2", "", "frontend", "GET /checkout", 1000, 1300, true),
+		testNamedSpan("b2", "a2", "cart", "get cart", 1010, 1030, false),
+		testNamedSpan("e2", "b2", "db", "UPDATE", 1015, 1025, true),
+		testNamedSpan("c3", "a2", "db", "SELECT", 1030, 1200, true),
+		testNamedSpan("p2", "a2", "payment", "charge", 1200, 1290, false),
+	}
+
+	diff, err := DiffTraces("base", base, "target", target)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []spanDiff{
+		{"GET /checkout", 0, model.DiffStatusMatched, "a1", "a2", 200, true},
+		{"get cart", 1, model.DiffStatusMatched, "b1", "b2", 0, false},
+		{"UPDATE", 2, model.DiffStatusAdded, "", "e2", 10, true},
+		{"SELECT", 1, model.DiffStatusMatched, "c1", "c3", 160, true},
+		{"SELECT", 1, model.DiffStatusRemoved, "c2", "", -10, false},
+		{"GET", 1, model.DiffStatusRemoved, "d1", "", -10, false},
+		{"charge", 1, model.DiffStatusAdded, "", "p2", 90, false},
+	}
+	if got := spanDiffs(diff); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected spans\n%v\ngot\n%v", expected, got)
+	}
+	if diff.AddedCount != 2 || diff.RemovedCount != 2 || diff.NewErrorCount != 3 {
+		t.Errorf("expected 2 added, 2 removed and 3 new errors, got %d, %d and %d", diff.AddedCount, diff.RemovedCount, diff.NewErrorCount)
+	}
+	if diff.BaseDurationNano != 100 || diff.TargetDurationNano != 300 || diff.DurationChangeNano != 200 {
+		t.Errorf("expected durations 100 and 300, got %d and %d", diff.BaseDurationNano, diff.TargetDurationNano)
+	}
+}
+
+func TestDiffTracesWithoutSpans(t *testing.T) {
+	spans := []model.SearchSpanResponseItem{testSpan("a", "", "frontend", 0, 100)}
+	if _, err := DiffTraces("base", nil, "target", spans); err == nil {
+		t.Errorf("expected an error for a base trace without spans")
+	}
+	if _, err := DiffTraces("base", spans, "target", nil); err == nil {
+		t.Errorf("expected an error for a target trace without spans")
+	}
+}
+
+func TestCompareOperationWindows(t *testing.T) {
+	base := &model.OperationWindowStats{
+		Root: model.OperationStatsItem{ServiceName: "frontend", Name: "GET /checkout", Percentile50: 100, Percentile95: 200, NumCalls: 10},
+		Operations: []model.OperationStatsItem{
+			{ServiceName: "db", Name: "SELECT", Percentile50: 10, Percentile95: 20, NumCalls: 30},
+			{ServiceName: "cart", Name: "get cart", Percentile50: 20, Percentile95: 40, NumCalls: 10},
+			{ServiceName: "cache", Name: "GET", Percentile50: 1, Percentile95: 2, NumCalls: 10},
+		},
+	}
+	target := &model.OperationWindowStats{
+		Root: model.OperationStatsItem{ServiceName: "frontend", Name: "GET /checkout", Percentile50: 150, Percentile95: 400, NumCalls: 12, ErrorCount: 1},
+		Operations: []model.OperationStatsItem{
+			{ServiceName: "cart", Name: "get cart", Percentile50: 25, Percentile95: 45, NumCalls: 12},
+			{ServiceName: "db", Name: "SELECT", Percentile50: 50, Percentile95: 180, NumCalls: 36},
+			{ServiceName: "payment", Name: "charge", Percentile50: 30, Percentile95: 60, NumCalls: 12},
+		},
+	}
+
+	diff := CompareOperationWindows("frontend", "GET /checkout", base, target, 0)
+	if diff.Root.Status != model.DiffStatusMatched || diff.Root.P50Change != 50 || diff.Root.P95Change != 200 {
+		t.Errorf("unexpected root diff %+v", diff.Root)
+	}
+
+	type operation struct {
+		name      string
+		status    string
+		p95Change float64
+	}
+	var got []operation
+	for _, item := range diff.Operations {
+		got = append(got, operation{item.Name, item.Status, item.P95Change})
+	}
+	expected := []operation{
+		{"SELECT", model.DiffStatusMatched, 160},
+		{"charge", model.DiffStatusAdded, 60},
+		{"get cart", model.DiffStatusMatched, 5},
+		{"GET", model.DiffStatusRemoved, -2},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected operations %v, got %v", expected, got)
+	}
+
+	if limited := CompareOperationWindows("frontend", "GET /checkout", base, target, 2); len(limited.Operations) != 2 {
+		t.Errorf("expected 2 operations, got %d", len(limited.Operations))
+	}
+}
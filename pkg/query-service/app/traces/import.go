@@ -0,0 +1,226 @@
+package traces
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// The formats traces are exported to and imported from
+const (
+	TraceFormatOTLP   = "otlp"
+	TraceFormatJaeger = "jaeger"
+)
+
+const (
+	traceIDLength      = 32
+	spanIDLength       = 16
+	refTypeChildOf     = "CHILD_OF"
+	refTypeFollowsFrom = "FOLLOWS_FROM"
+	unknownServiceName = "unknown_service"
+	exceptionEventName = "exception"
+)
+
+// ImportTraces converts the spans of an OTLP JSON or a Jaeger JSON file, the
+// format is detected from the document when it is empty. The root span of
+// the traces is set on their spans.
+func ImportTraces(format string, data []byte) ([]model.ImportedSpan, error) {
+	if format == "" {
+		var document map[string]json.RawMessage
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		switch {
+		case document["resourceSpans"] != nil:
+			format = TraceFormatOTLP
+		case document["data"] != nil:
+			format = TraceFormatJaeger
+		default:
+			return nil, fmt.Errorf("unknown trace format, expected OTLP JSON or Jaeger JSON")
+		}
+	}
+
+	var spans []model.ImportedSpan
+	var err error
+	switch format {
+	case TraceFormatOTLP:
+		spans, err = importOTLP(data)
+	case TraceFormatJaeger:
+		spans, err = importJaeger(data)
+	default:
+		return nil, fmt.Errorf("unsupported trace format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(spans) == 0 {
+		return nil, fmt.Errorf("no spans to import")
+	}
+	setRootSpans(spans)
+	return spans, nil
+}
+
+// setRootSpans sets the root span of the traces on their spans, the root is
+// the first span without a parent or the first span when all have one
+func setRootSpans(spans []model.ImportedSpan) {
+	roots := map[string]int{}
+	for i := range spans {
+		j, ok := roots[spans[i].Span.TraceID]
+		if !ok {
+			roots[spans[i].Span.TraceID] = i
+			continue
+		}
+		iRoot, jRoot := spans[i].ParentSpanID == "", spans[j].ParentSpanID == ""
+		if (iRoot && !jRoot) || (iRoot == jRoot && spans[i].Span.TimeUnixNano < spans[j].Span.TimeUnixNano) {
+			roots[spans[i].Span.TraceID] = i
+		}
+	}
+	for i := range spans {
+		root := spans[roots[spans[i].Span.TraceID]].Span
+		spans[i].Span.RootSpanID = root.SpanID
+		spans[i].Span.RootName = root.Name
+	}
+}
+
+// normalizeID returns the lower case hex id padded to length, the ids
+// encoded in base64 by the protobuf JSON encoders are accepted too
+func normalizeID(id string, length int) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("id is empty")
+	}
+	if len(id) <= length {
+		if _, err := hex.DecodeString(padID(id)); err == nil {
+			return strings.ToLower(strings.Repeat("0", length-len(id)) + id), nil
+		}
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(id); err == nil && len(decoded) == length/2 {
+		return hex.EncodeToString(decoded), nil
+	}
+	return "", fmt.Errorf("%s is not a hex id", id)
+}
+
+// padID pads the id to an even length so it can be decoded
+func padID(id string) string {
+	if len(id)%2 == 1 {
+		return "0" + id
+	}
+	return id
+}
+
+// tagSet is the attributes of a span by type, all holds all of them as
+// strings like the tag map of the span
+type tagSet struct {
+	all     map[string]string
+	raw     map[string]interface{}
+	strings map[string]string
+	numbers map[string]float64
+	bools   map[string]bool
+}
+
+func newTagSet() *tagSet {
+	return &tagSet{
+		all:     map[string]string{},
+		raw:     map[string]interface{}{},
+		strings: map[string]string{},
+		numbers: map[string]float64{},
+		bools:   map[string]bool{},
+	}
+}
+
+// add adds an OTLP attribute, the arrays and maps are kept as JSON strings
+func (t *tagSet) add(key string, value OTLPAnyValue) {
+	switch {
+	case value.StringValue != nil:
+		t.addValue(key, *value.StringValue)
+	case value.BoolValue != nil:
+		t.addValue(key, *value.BoolValue)
+	case value.IntValue != nil:
+		t.addValue(key, float64(*value.IntValue))
+	case value.DoubleValue != nil:
+		t.addValue(key, *value.DoubleValue)
+	case value.BytesValue != nil:
+		t.addValue(key, *value.BytesValue)
+	case value.ArrayValue != nil:
+		t.addValue(key, string(value.ArrayValue))
+	case value.KvlistValue != nil:
+		t.addValue(key, string(value.KvlistValue))
+	default:
+		t.addValue(key, "")
+	}
+}
+
+// addValue adds a string, bool or number attribute, any other value is
+// added as a string
+func (t *tagSet) addValue(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		t.strings[key] = v
+		t.all[key] = v
+	case bool:
+		t.bools[key] = v
+		t.all[key] = strconv.FormatBool(v)
+	case float64:
+		t.numbers[key] = v
+		t.all[key] = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		s := fmt.Sprint(v)
+		t.strings[key] = s
+		t.all[key] = s
+		value = s
+	}
+	t.raw[key] = value
+}
+
+// tagMap returns the attributes as strings merged with the ones of the
+// resource, the attributes of the span take precedence
+func (t *tagSet) tagMap(resource *tagSet) map[string]string {
+	tags := make(map[string]string, len(t.all))
+	if resource != nil {
+		for key, value := range resource.all {
+			tags[key] = value
+		}
+	}
+	for key, value := range t.all {
+		tags[key] = value
+	}
+	return tags
+}
+
+// spanEvents returns the events of the span, the events are stored as JSON
+func spanEvents(span *model.SearchSpanResponseItem) []model.Event {
+	var events []model.Event
+	for _, data := range span.Events {
+		var event model.Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// addEvent adds the event to the span, the exceptions are errors
+func addEvent(span *model.SearchSpanResponseItem, event model.Event) error {
+	event.IsError = event.Name == exceptionEventName
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("invalid event %s of span %s: %w", event.Name, span.SpanID, err)
+	}
+	span.Events = append(span.Events, string(data))
+	return nil
+}
+
+func sortedEventKeys(attributes map[string]interface{}) []string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,202 @@
+package traces
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testRootID  = "00f067aa0ba902b7"
+	testChildID = "b7ad6b7169203331"
+	testLinkID  = "53995c3f42cd8ad8"
+)
+
+func testExportSpans() []model.SearchSpanResponseItem {
+	return []model.SearchSpanResponseItem{
+		{
+			TimeUnixNano: 1680066360726000000,
+			DurationNano: 5000000,
+			SpanID:       testRootID,
+			TraceID:      testTraceID,
+			Kind:         2,
+			ServiceName:  "frontend",
+			Name:         "GET /checkout",
+			References:   []model.OtelSpanRef{{TraceId: testTraceID, RefType: "CHILD_OF"}},
+			TagMap:       map[string]string{"http.method": "GET", "service.name": "frontend"},
+			Events:       []string{},
+		},
+		{
+			TimeUnixNano: 1680066360727000000,
+			DurationNano: 2000000,
+			SpanID:       testChildID,
+			TraceID:      testTraceID,
+			HasError:     true,
+			Kind:         3,
+			ServiceName:  "payment",
+			Name:         "charge",
+			References: []model.OtelSpanRef{
+				{TraceId: testTraceID, SpanId: testRootID, RefType: "CHILD_OF"},
+				{TraceId: testTraceID, SpanId: testLinkID, RefType: "FOLLOWS_FROM"},
+			},
+			TagMap: map[string]string{"peer.service": "stripe"},
+			Events: []string{`{"name":"exception","timeUnixNano":1680066360728000000,"attributeMap":{"exception.message":"declined"},"isError":true}`},
+		},
+	}
+}
+
+// importedSpan is the part of an imported span kept by the exports
+type importedSpan struct {
+	spanID, parentID, serviceName, name string
+	kind                                int32
+	hasError                            bool
+	start                               uint64
+	duration                            int64
+	root                                string
+	references                          int
+	events                              int
+}
+
+func importedSpans(spans []model.ImportedSpan) []importedSpan {
+	var result []importedSpan
+	for _, s := range spans {
+		result = append(result, importedSpan{
+			s.Span.SpanID, s.ParentSpanID, s.Span.ServiceName, s.Span.Name, s.Span.Kind, s.Span.HasError,
+			s.Span.TimeUnixNano, s.Span.DurationNano, s.Span.RootSpanID, len(s.Span.References), len(s.Span.Events),
+		})
+	}
+	return result
+}
+
+func TestExportImportTraces(t *testing.T) {
+	expected := []importedSpan{
+		{testRootID, "", "frontend", "GET /checkout", 2, false, 1680066360726000000, 5000000, testRootID, 0, 0},
+		{testChildID, testRootID, "payment", "charge", 3, true, 1680066360727000000, 2000000, testRootID, 2, 1},
+	}
+
+	exports := map[string]interface{}{
+		TraceFormatOTLP:   ExportOTLP(testExportSpans()),
+		TraceFormatJaeger: ExportJaeger(testTraceID, testExportSpans()),
+	}
+	for format, export := range exports {
+		t.Run(format, func(t *testing.T) {
+			data, err := json.Marshal(export)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			spans, err := ImportTraces("", data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := importedSpans(spans); !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected spans\n%+v\ngot\n%+v", expected, got)
+			}
+
+			child := spans[1]
+			if child.StatusCode != 2 {
+				t.Errorf("expected the error status code, got %d", child.StatusCode)
+			}
+			if child.StringTagMap["peer.service"] != "stripe" || child.Span.TagMap["service.name"] != "payment" {
+				t.Errorf("unexpected tags %v", child.Span.TagMap)
+			}
+			if child.ResourceTagMap["service.name"] != "payment" {
+				t.Errorf("unexpected resource tags %v", child.ResourceTagMap)
+			}
+			var event model.Event
+			if err := json.Unmarshal([]byte(child.Span.Events[0]), &event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if event.Name != "exception" || !event.IsError || event.AttributeMap["exception.message"] != "declined" {
+				t.Errorf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+func TestExportOTLP(t *testing.T) {
+	data, err := json.Marshal(ExportOTLP(testExportSpans()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"resourceSpans":[` +
+		`{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"frontend"}}]},"scopeSpans":[{"scope":{},"spans":[` +
+		`{"traceId":"` + testTraceID + `","spanId":"` + testRootID + `","name":"GET /checkout","kind":2,` +
+		`"startTimeUnixNano":"1680066360726000000","endTimeUnixNano":"1680066360731000000",` +
+		`"attributes":[{"key":"http.method","value":{"stringValue":"GET"}}],"status":{}}]}]},` +
+		`{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"payment"}}]},"scopeSpans":[{"scope":{},"spans":[` +
+		`{"traceId":"` + testTraceID + `","spanId":"` + testChildID + `","parentSpanId":"` + testRootID + `","name":"charge","kind":3,` +
+		`"startTimeUnixNano":"1680066360727000000","endTimeUnixNano":"1680066360729000000",` +
+		`"attributes":[{"key":"peer.service","value":{"stringValue":"stripe"}}],` +
+		`"events":[{"timeUnixNano":"1680066360728000000","name":"exception","attributes":[{"key":"exception.message","value":{"stringValue":"declined"}}]}],` +
+		`"links":[{"traceId":"` + testTraceID + `","spanId":"` + testLinkID + `"}],"status":{"code":2}}]}]}]}`
+	if string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, data)
+	}
+}
+
+func TestImportJaegerTags(t *testing.T) {
+	data := `{"data":[{"traceID":"a3ce929d0e0e4736","spans":[{"traceID":"a3ce929d0e0e4736","spanID":"ba902b7","operationName":"SELECT",` +
+		`"references":[],"startTime":1680066360726000,"duration":1500,"processID":"p1","tags":[` +
+		`{"key":"span.kind","type":"string","value":"client"},{"key":"otel.status_code","type":"string","value":"ERROR"},` +
+		`{"key":"db.rows","type":"int64","value":42},{"key":"db.cached","type":"bool","value":false}]}],` +
+		`"processes":{"p1":{"serviceName":"db","tags":[{"key":"host.name","type":"string","value":"db-1"}]}}}]}`
+
+	spans, err := ImportTraces(TraceFormatJaeger, []byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	span := spans[0]
+	if span.Span.TraceID != "0000000000000000a3ce929d0e0e4736" || span.Span.SpanID != "000000000ba902b7" {
+		t.Errorf("expected padded ids, got %s and %s", span.Span.TraceID, span.Span.SpanID)
+	}
+	if span.Span.Kind != 3 || !span.Span.HasError || span.Span.DurationNano != 1500000 {
+		t.Errorf("unexpected span %+v", span.Span)
+	}
+	if span.NumberTagMap["db.rows"] != 42 || span.BoolTagMap["db.cached"] || span.Span.TagMap["db.rows"] != "42" {
+		t.Errorf("unexpected tags %v, %v and %v", span.NumberTagMap, span.BoolTagMap, span.Span.TagMap)
+	}
+	if span.ResourceTagMap["host.name"] != "db-1" || span.Span.TagMap["host.name"] != "db-1" {
+		t.Errorf("unexpected resource tags %v", span.ResourceTagMap)
+	}
+}
+
+func TestImportTracesErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{name: "invalid json", data: `{"resourceSpans":`},
+		{name: "unknown format", data: `{"spans":[]}`},
+		{name: "unsupported format", format: "zipkin", data: `{}`},
+		{name: "no spans", data: `{"resourceSpans":[]}`},
+		{name: "invalid id", data: `{"resourceSpans":[{"scopeSpans":[{"spans":[{"traceId":"xyz","spanId":"` + testRootID + `"}]}]}]}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ImportTraces(tc.format, []byte(tc.data)); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestNormalizeID(t *testing.T) {
+	testCases := []struct {
+		id       string
+		expected string
+	}{
+		{"4BF92F3577B34DA6A3CE929D0E0E4736", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"a3ce929d0e0e4736", "0000000000000000a3ce929d0e0e4736"},
+		{"S/kvNXezTaajzpKdDg5HNg==", "4bf92f3577b34da6a3ce929d0e0e4736"},
+	}
+	for _, tc := range testCases {
+		got, err := normalizeID(tc.id, traceIDLength)
+		if err != nil || got != tc.expected {
+			t.Errorf("expected %s for %s, got %s (%v)", tc.expected, tc.id, got, err)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package traces
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// The JSON the Jaeger UI loads and downloads, the times are in microseconds
+
+type JaegerTraces struct {
+	Data   []JaegerTrace `json:"data"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+	Errors []interface{} `json:"errors"`
+}
+
+type JaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []JaegerSpan             `json:"spans"`
+	Processes map[string]JaegerProcess `json:"processes"`
+	Warnings  []string                 `json:"warnings"`
+}
+
+type JaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	Flags         uint32            `json:"flags,omitempty"`
+	OperationName string            `json:"operationName"`
+	References    []JaegerReference `json:"references"`
+	StartTime     uint64            `json:"startTime"`
+	Duration      uint64            `json:"duration"`
+	Tags          []JaegerKeyValue  `json:"tags"`
+	Logs          []JaegerLog       `json:"logs"`
+	ProcessID     string            `json:"processID"`
+	Warnings      []string          `json:"warnings"`
+}
+
+type JaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type JaegerProcess struct {
+	ServiceName string           `json:"serviceName"`
+	Tags        []JaegerKeyValue `json:"tags"`
+}
+
+type JaegerKeyValue struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type JaegerLog struct {
+	Timestamp uint64           `json:"timestamp"`
+	Fields    []JaegerKeyValue `json:"fields"`
+}
+
+const (
+	jaegerSpanKindKey   = "span.kind"
+	jaegerErrorKey      = "error"
+	jaegerEventKey      = "event"
+	jaegerStatusCodeKey = "otel.status_code"
+)
+
+// jaegerSpanKinds are the span.kind tags of the OTLP span kinds
+var jaegerSpanKinds = map[int32]string{
+	1: "internal",
+	2: "server",
+	3: "client",
+	4: "producer",
+	5: "consumer",
+}
+
+// jaegerTag returns the tag of a value, the numbers are float64 tags
+func jaegerTag(key string, value interface{}) JaegerKeyValue {
+	switch v := value.(type) {
+	case string:
+		return JaegerKeyValue{Key: key, Type: "string", Value: v}
+	case bool:
+		return JaegerKeyValue{Key: key, Type: "bool", Value: v}
+	case float64:
+		return JaegerKeyValue{Key: key, Type: "float64", Value: v}
+	default:
+		return JaegerKeyValue{Key: key, Type: "string", Value: fmt.Sprint(v)}
+	}
+}
+
+// ExportJaeger converts the spans of a trace to the JSON of the Jaeger UI,
+// the services are the processes of the trace. The span kind and the error
+// are tags, and the events are logs.
+func ExportJaeger(traceID string, spans []model.SearchSpanResponseItem) *JaegerTraces {
+	trace := JaegerTrace{
+		TraceID:   traceID,
+		Spans:     make([]JaegerSpan, 0, len(spans)),
+		Processes: map[string]JaegerProcess{},
+	}
+	processes := map[string]string{}
+	for i := range spans {
+		span := &spans[i]
+		processID, ok := processes[span.ServiceName]
+		if !ok {
+			processID = fmt.Sprintf("p%d", len(processes)+1)
+			processes[span.ServiceName] = processID
+			trace.Processes[processID] = JaegerProcess{ServiceName: span.ServiceName, Tags: []JaegerKeyValue{}}
+		}
+		trace.Spans = append(trace.Spans, jaegerSpan(span, processID))
+	}
+	return &JaegerTraces{Data: []JaegerTrace{trace}}
+}
+
+func jaegerSpan(span *model.SearchSpanResponseItem, processID string) JaegerSpan {
+	result := JaegerSpan{
+		TraceID:       span.TraceID,
+		SpanID:        span.SpanID,
+		OperationName: span.Name,
+		References:    []JaegerReference{},
+		StartTime:     span.TimeUnixNano / 1000,
+		Duration:      uint64(span.DurationNano) / 1000,
+		Tags:          []JaegerKeyValue{},
+		Logs:          []JaegerLog{},
+		ProcessID:     processID,
+	}
+	parentID := parentSpanID(span)
+	if parentID != "" {
+		result.References = append(result.References, JaegerReference{RefType: refTypeChildOf, TraceID: span.TraceID, SpanID: parentID})
+	}
+	for _, ref := range span.References {
+		if ref.SpanId == "" || ref.SpanId == parentID {
+			continue
+		}
+		traceID := ref.TraceId
+		if traceID == "" {
+			traceID = span.TraceID
+		}
+		result.References = append(result.References, JaegerReference{RefType: refTypeFollowsFrom, TraceID: traceID, SpanID: ref.SpanId})
+	}
+	for _, key := range sortedKeys(span.TagMap) {
+		if key == serviceNameKey || key == jaegerSpanKindKey || key == jaegerErrorKey {
+			continue
+		}
+		result.Tags = append(result.Tags, jaegerTag(key, span.TagMap[key]))
+	}
+	if kind, ok := jaegerSpanKinds[span.Kind]; ok {
+		result.Tags = append(result.Tags, jaegerTag(jaegerSpanKindKey, kind))
+	}
+	if span.HasError {
+		result.Tags = append(result.Tags, jaegerTag(jaegerErrorKey, true))
+	}
+	for _, event := range spanEvents(span) {
+		log := JaegerLog{Timestamp: event.TimeUnixNano / 1000, Fields: []JaegerKeyValue{jaegerTag(jaegerEventKey, event.Name)}}
+		for _, key := range sortedEventKeys(event.AttributeMap) {
+			log.Fields = append(log.Fields, jaegerTag(key, event.AttributeMap[key]))
+		}
+		result.Logs = append(result.Logs, log)
+	}
+	return result
+}
+
+// jaegerValue returns the value of a tag as a string, bool or float64
+func jaegerValue(tag JaegerKeyValue) interface{} {
+	switch v := tag.Value.(type) {
+	case string:
+		switch tag.Type {
+		case "bool":
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		case "int64", "float64":
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		}
+		return v
+	case bool, float64:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// importJaeger converts the spans of the Jaeger JSON, the tags of the
+// process are the resource of its spans and span.kind and error are the
+// kind and the status of the span
+func importJaeger(data []byte) ([]model.ImportedSpan, error) {
+	var traces JaegerTraces
+	if err := json.Unmarshal(data, &traces); err != nil {
+		return nil, fmt.Errorf("invalid Jaeger JSON: %w", err)
+	}
+
+	var spans []model.ImportedSpan
+	for _, trace := range traces.Data {
+		resources := make(map[string]*tagSet, len(trace.Processes))
+		for processID, process := range trace.Processes {
+			resource := newTagSet()
+			for _, tag := range process.Tags {
+				resource.addValue(tag.Key, jaegerValue(tag))
+			}
+			serviceName := process.ServiceName
+			if serviceName == "" {
+				serviceName = unknownServiceName
+			}
+			resource.addValue(serviceNameKey, serviceName)
+			resources[processID] = resource
+		}
+		for _, item := range trace.Spans {
+			resource, ok := resources[item.ProcessID]
+			if !ok {
+				resource = newTagSet()
+				resource.addValue(serviceNameKey, unknownServiceName)
+			}
+			span, err := importJaegerSpan(item, resource)
+			if err != nil {
+				return nil, err
+			}
+			spans = append(spans, span)
+		}
+	}
+	return spans, nil
+}
+
+func importJaegerSpan(jaegerSpan JaegerSpan, resource *tagSet) (model.ImportedSpan, error) {
+	traceID, err := normalizeID(jaegerSpan.TraceID, traceIDLength)
+	if err != nil {
+		return model.ImportedSpan{}, fmt.Errorf("invalid trace id of span %s: %w", jaegerSpan.SpanID, err)
+	}
+	spanID, err := normalizeID(jaegerSpan.SpanID, spanIDLength)
+	if err != nil {
+		return model.ImportedSpan{}, fmt.Errorf("invalid span id of trace %s: %w", traceID, err)
+	}
+
+	tags := newTagSet()
+	var kind int32
+	var hasError bool
+	for _, tag := range jaegerSpan.Tags {
+		value := jaegerValue(tag)
+		switch tag.Key {
+		case jaegerSpanKindKey:
+			for k, name := range jaegerSpanKinds {
+				if name == value {
+					kind = k
+				}
+			}
+			continue
+		case jaegerErrorKey:
+			hasError = value == true || value == "true"
+			continue
+		case jaegerStatusCodeKey:
+			hasError = hasError || value == "ERROR"
+		}
+		tags.addValue(tag.Key, value)
+	}
+
+	serviceName := resource.strings[serviceNameKey]
+	span := model.ImportedSpan{
+		Span: model.SearchSpanResponseItem{
+			TimeUnixNano: jaegerSpan.StartTime * 1000,
+			DurationNano: int64(jaegerSpan.Duration * 1000),
+			SpanID:       spanID,
+			TraceID:      traceID,
+			HasError:     hasError,
+			Kind:         kind,
+			ServiceName:  serviceName,
+			Name:         jaegerSpan.OperationName,
+			References:   []model.OtelSpanRef{},
+			TagMap:       tags.tagMap(resource),
+			Events:       []string{},
+		},
+		StringTagMap:   tags.strings,
+		NumberTagMap:   tags.numbers,
+		BoolTagMap:     tags.bools,
+		ResourceTagMap: resource.tagMap(nil),
+	}
+	if hasError {
+		span.StatusCode = otlpStatusCodeError
+	}
+	for _, ref := range jaegerSpan.References {
+		refTraceID, err := normalizeID(ref.TraceID, traceIDLength)
+		if err != nil {
+			return model.ImportedSpan{}, fmt.Errorf("invalid reference of span %s: %w", spanID, err)
+		}
+		refSpanID, err := normalizeID(ref.SpanID, spanIDLength)
+		if err != nil {
+			return model.ImportedSpan{}, fmt.Errorf("invalid reference of span %s: %w", spanID, err)
+		}
+		refType := refTypeFollowsFrom
+		if ref.RefType == refTypeChildOf && refTraceID == traceID && span.ParentSpanID == "" {
+			refType = refTypeChildOf
+			span.ParentSpanID = refSpanID
+		}
+		span.Span.References = append(span.Span.References, model.OtelSpanRef{TraceId: refTraceID, SpanId: refSpanID, RefType: refType})
+	}
+	for _, log := range jaegerSpan.Logs {
+		attributes := newTagSet()
+		event := model.Event{TimeUnixNano: log.Timestamp * 1000}
+		for _, field := range log.Fields {
+			if field.Key == jaegerEventKey {
+				event.Name = fmt.Sprint(jaegerValue(field))
+				continue
+			}
+			attributes.addValue(field.Key, jaegerValue(field))
+		}
+		event.AttributeMap = attributes.raw
+		if err := addEvent(&span.Span, event); err != nil {
+			return model.ImportedSpan{}, err
+		}
+	}
+	return span, nil
+}
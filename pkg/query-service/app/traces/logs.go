@@ -0,0 +1,104 @@
+package traces
+
+import (
+	"fmt"
+	"sort"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// SummarizeTrace returns the root span of the trace, the longest one when
+// there are several, and the number of spans, services and errors
+func SummarizeTrace(traceID string, spans []model.SearchSpanResponseItem) (*model.TraceSummary, error) {
+	_, ordered, roots := spanTree(spans)
+	if len(ordered) == 0 {
+		return nil, fmt.Errorf("trace %s has no spans", traceID)
+	}
+	for _, root := range roots {
+		clamp(root)
+	}
+	root := mainRoot(roots)
+	start, end := traceBounds(ordered)
+
+	summary := &model.TraceSummary{
+		TraceID:           traceID,
+		RootSpanID:        root.span.SpanID,
+		RootServiceName:   root.span.ServiceName,
+		RootName:          root.span.Name,
+		StartTimeUnixNano: uint64(start),
+		DurationNano:      end - start,
+		SpanCount:         len(ordered),
+	}
+	services := map[string]bool{}
+	for _, node := range ordered {
+		services[node.span.ServiceName] = true
+		if node.span.HasError {
+			summary.ErrorCount++
+		}
+	}
+	summary.ServiceCount = len(services)
+	return summary, nil
+}
+
+// LogsTimeRange returns the time range (ms) the logs of the trace are looked
+// for in, from a bit before the first span to a bit after the last one
+func LogsTimeRange(spans []model.SearchSpanResponseItem) (int64, int64) {
+	_, ordered, _ := spanTree(spans)
+	if len(ordered) == 0 {
+		return 0, 0
+	}
+	start, end := traceBounds(ordered)
+	margin := constants.TraceLogsMargin.Nanoseconds()
+	return (start - margin) / 1000000, (end + margin) / 1000000
+}
+
+// GroupLogsBySpan groups the logs of the trace by the span they were written
+// in. The spans of the trace are in the order of their start, followed by
+// the spans that are not in the trace and then by the logs without a span.
+func GroupLogsBySpan(traceID string, spans []model.SearchSpanResponseItem, logs []model.GetLogsResponse) *model.TraceLogs {
+	result := &model.TraceLogs{TraceID: traceID, LogCount: len(logs), Spans: []model.SpanLogs{}}
+
+	bySpan := map[string][]model.GetLogsResponse{}
+	var unknown []string
+	for _, log := range logs {
+		if _, ok := bySpan[log.SpanID]; !ok {
+			unknown = append(unknown, log.SpanID)
+		}
+		bySpan[log.SpanID] = append(bySpan[log.SpanID], log)
+	}
+
+	sorted := make([]*model.SearchSpanResponseItem, 0, len(spans))
+	for i := range spans {
+		sorted = append(sorted, &spans[i])
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TimeUnixNano < sorted[j].TimeUnixNano
+	})
+	for _, span := range sorted {
+		spanLogs, ok := bySpan[span.SpanID]
+		if !ok || span.SpanID == "" {
+			continue
+		}
+		result.Spans = append(result.Spans, model.SpanLogs{
+			SpanID:      span.SpanID,
+			ServiceName: span.ServiceName,
+			Name:        span.Name,
+			Logs:        spanLogs,
+		})
+		delete(bySpan, span.SpanID)
+	}
+
+	for _, spanID := range unknown {
+		if spanID == "" {
+			continue
+		}
+		if spanLogs, ok := bySpan[spanID]; ok {
+			result.Spans = append(result.Spans, model.SpanLogs{SpanID: spanID, Logs: spanLogs})
+		}
+	}
+	if spanLogs, ok := bySpan[""]; ok {
+		result.Spans = append(result.Spans, model.SpanLogs{Logs: spanLogs})
+	}
+	return result
+}
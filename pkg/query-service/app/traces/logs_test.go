@@ -0,0 +1,93 @@
+package traces
+
+import (
+	"reflect"
+	"testing"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+func TestSummarizeTrace(t *testing.T) {
+	spans := []model.SearchSpanResponseItem{
+		testSpan("b", "a", "cache", 10, 40),
+		testSpan("a", "", "frontend", 0, 100),
+		testSpan("c", "a", "db", 50, 120),
+		testSpan("x", "missing", "db", 200, 300),
+	}
+	spans[2].HasError = true
+
+	summary, err := SummarizeTrace("trace", spans)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &model.TraceSummary{
+		TraceID:           "trace",
+		RootSpanID:        "a",
+		RootServiceName:   "frontend",
+		RootName:          "a",
+		StartTimeUnixNano: 0,
+		DurationNano:      300,
+		SpanCount:         4,
+		ServiceCount:      3,
+		ErrorCount:        1,
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("expected %+v, got %+v", expected, summary)
+	}
+
+	if _, err := SummarizeTrace("trace", nil); err == nil {
+		t.Errorf("expected an error for a trace without spans")
+	}
+}
+
+func TestLogsTimeRange(t *testing.T) {
+	start, end := LogsTimeRange([]model.SearchSpanResponseItem{
+		testSpan("a", "", "frontend", 1680066360000000000, 1680066361000000000),
+		testSpan("b", "a", "db", 1680066360500000000, 1680066362000000000),
+	})
+	if start != 1680066300000 || end != 1680066422000 {
+		t.Errorf("expected 1680066300000 to 1680066422000, got %d to %d", start, end)
+	}
+}
+
+func TestGroupLogsBySpan(t *testing.T) {
+	spans := []model.SearchSpanResponseItem{
+		testSpan("b", "a", "cache", 10, 40),
+		testSpan("a", "", "frontend", 0, 100),
+		testSpan("c", "a", "db", 50, 90),
+	}
+	logs := []model.GetLogsResponse{
+		{ID: "1", SpanID: "b"},
+		{ID: "2", SpanID: ""},
+		{ID: "3", SpanID: "a"},
+		{ID: "4", SpanID: "missing"},
+		{ID: "5", SpanID: "b"},
+	}
+
+	result := GroupLogsBySpan("trace", spans, logs)
+	if result.TraceID != "trace" || result.LogCount != 5 {
+		t.Errorf("unexpected trace logs %+v", result)
+	}
+
+	type group struct {
+		spanID, serviceName string
+		ids                 []string
+	}
+	var got []group
+	for _, s := range result.Spans {
+		g := group{spanID: s.SpanID, serviceName: s.ServiceName}
+		for _, log := range s.Logs {
+			g.ids = append(g.ids, log.ID)
+		}
+		got = append(got, g)
+	}
+	expected := []group{
+		{"a", "frontend", []string{"3"}},
+		{"b", "cache", []string{"1", "5"}},
+		{"missing", "", []string{"4"}},
+		{"", "", []string{"2"}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
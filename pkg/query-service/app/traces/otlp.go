@@ -0,0 +1,316 @@
+package traces
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// The OTLP JSON encoding of an ExportTraceServiceRequest. The ids are hex
+// encoded and the 64 bit integers are strings, as in the OTLP/JSON spec.
+
+type OTLPTraces struct {
+	ResourceSpans []OTLPResourceSpans `json:"resourceSpans"`
+}
+
+type OTLPResourceSpans struct {
+	Resource   OTLPResource     `json:"resource"`
+	ScopeSpans []OTLPScopeSpans `json:"scopeSpans"`
+	// InstrumentationLibrarySpans is the name of ScopeSpans before OTLP 0.19
+	InstrumentationLibrarySpans []OTLPScopeSpans `json:"instrumentationLibrarySpans,omitempty"`
+}
+
+type OTLPResource struct {
+	Attributes []OTLPKeyValue `json:"attributes"`
+}
+
+type OTLPScopeSpans struct {
+	Scope OTLPScope  `json:"scope"`
+	Spans []OTLPSpan `json:"spans"`
+}
+
+type OTLPScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type OTLPSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int32          `json:"kind"`
+	StartTimeUnixNano otlpInt        `json:"startTimeUnixNano"`
+	EndTimeUnixNano   otlpInt        `json:"endTimeUnixNano"`
+	Attributes        []OTLPKeyValue `json:"attributes,omitempty"`
+	Events            []OTLPEvent    `json:"events,omitempty"`
+	Links             []OTLPLink     `json:"links,omitempty"`
+	Status            OTLPStatus     `json:"status"`
+}
+
+type OTLPEvent struct {
+	TimeUnixNano otlpInt        `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []OTLPKeyValue `json:"attributes,omitempty"`
+}
+
+type OTLPLink struct {
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	Attributes []OTLPKeyValue `json:"attributes,omitempty"`
+}
+
+type OTLPStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int32  `json:"code,omitempty"`
+}
+
+type OTLPKeyValue struct {
+	Key   string       `json:"key"`
+	Value OTLPAnyValue `json:"value"`
+}
+
+type OTLPAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *otlpInt        `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	BytesValue  *string         `json:"bytesValue,omitempty"`
+	ArrayValue  json.RawMessage `json:"arrayValue,omitempty"`
+	KvlistValue json.RawMessage `json:"kvlistValue,omitempty"`
+}
+
+// otlpInt is a 64 bit integer encoded as a string, numbers are accepted too
+type otlpInt int64
+
+func (i otlpInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(i), 10))
+}
+
+func (i *otlpInt) UnmarshalJSON(data []byte) error {
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		u, uerr := strconv.ParseUint(s, 10, 64)
+		if uerr != nil {
+			return fmt.Errorf("invalid integer %s", s)
+		}
+		v = int64(u)
+	}
+	*i = otlpInt(v)
+	return nil
+}
+
+const (
+	otlpStatusCodeError = 2
+	serviceNameKey      = "service.name"
+)
+
+func otlpString(key, value string) OTLPKeyValue {
+	return OTLPKeyValue{Key: key, Value: OTLPAnyValue{StringValue: &value}}
+}
+
+// otlpValue returns the OTLP value of an event attribute
+func otlpValue(value interface{}) OTLPAnyValue {
+	switch v := value.(type) {
+	case string:
+		return OTLPAnyValue{StringValue: &v}
+	case bool:
+		return OTLPAnyValue{BoolValue: &v}
+	case float64:
+		return OTLPAnyValue{DoubleValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return OTLPAnyValue{StringValue: &s}
+	}
+}
+
+// sortedKeys returns the keys of the tags in order
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ExportOTLP converts the spans of a trace to OTLP JSON, the spans are
+// grouped in a resource by service. The tags of a span are its attributes,
+// the project does not keep the resource attributes apart from them.
+func ExportOTLP(spans []model.SearchSpanResponseItem) *OTLPTraces {
+	traces := &OTLPTraces{ResourceSpans: []OTLPResourceSpans{}}
+	resources := map[string]int{}
+	for i := range spans {
+		span := &spans[i]
+		idx, ok := resources[span.ServiceName]
+		if !ok {
+			idx = len(traces.ResourceSpans)
+			resources[span.ServiceName] = idx
+			traces.ResourceSpans = append(traces.ResourceSpans, OTLPResourceSpans{
+				Resource:   OTLPResource{Attributes: []OTLPKeyValue{otlpString(serviceNameKey, span.ServiceName)}},
+				ScopeSpans: []OTLPScopeSpans{{Spans: []OTLPSpan{}}},
+			})
+		}
+		scope := &traces.ResourceSpans[idx].ScopeSpans[0]
+		scope.Spans = append(scope.Spans, otlpSpan(span))
+	}
+	return traces
+}
+
+func otlpSpan(span *model.SearchSpanResponseItem) OTLPSpan {
+	parentID := parentSpanID(span)
+	result := OTLPSpan{
+		TraceID:           span.TraceID,
+		SpanID:            span.SpanID,
+		ParentSpanID:      parentID,
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: otlpInt(span.TimeUnixNano),
+		EndTimeUnixNano:   otlpInt(int64(span.TimeUnixNano) + span.DurationNano),
+	}
+	if span.HasError {
+		result.Status.Code = otlpStatusCodeError
+	}
+	for _, key := range sortedKeys(span.TagMap) {
+		if key == serviceNameKey {
+			continue
+		}
+		result.Attributes = append(result.Attributes, otlpString(key, span.TagMap[key]))
+	}
+	for _, ref := range span.References {
+		if ref.SpanId == "" || ref.SpanId == parentID {
+			continue
+		}
+		traceID := ref.TraceId
+		if traceID == "" {
+			traceID = span.TraceID
+		}
+		result.Links = append(result.Links, OTLPLink{TraceID: traceID, SpanID: ref.SpanId})
+	}
+	for _, event := range spanEvents(span) {
+		otlpEvent := OTLPEvent{TimeUnixNano: otlpInt(event.TimeUnixNano), Name: event.Name}
+		for _, key := range sortedEventKeys(event.AttributeMap) {
+			otlpEvent.Attributes = append(otlpEvent.Attributes, OTLPKeyValue{Key: key, Value: otlpValue(event.AttributeMap[key])})
+		}
+		result.Events = append(result.Events, otlpEvent)
+	}
+	return result
+}
+
+// importOTLP converts the spans of the OTLP JSON, the attributes of the
+// resource are added to the tags of its spans like the exporter does
+func importOTLP(data []byte) ([]model.ImportedSpan, error) {
+	var traces OTLPTraces
+	if err := json.Unmarshal(data, &traces); err != nil {
+		return nil, fmt.Errorf("invalid OTLP JSON: %w", err)
+	}
+
+	var spans []model.ImportedSpan
+	for _, resourceSpans := range traces.ResourceSpans {
+		resource := newTagSet()
+		for _, kv := range resourceSpans.Resource.Attributes {
+			resource.add(kv.Key, kv.Value)
+		}
+		serviceName := resource.strings[serviceNameKey]
+		if serviceName == "" {
+			serviceName = unknownServiceName
+		}
+
+		for _, scopeSpans := range append(resourceSpans.ScopeSpans, resourceSpans.InstrumentationLibrarySpans...) {
+			for _, item := range scopeSpans.Spans {
+				span, err := importOTLPSpan(item, serviceName, resource)
+				if err != nil {
+					return nil, err
+				}
+				spans = append(spans, span)
+			}
+		}
+	}
+	return spans, nil
+}
+
+func importOTLPSpan(otlpSpan OTLPSpan, serviceName string, resource *tagSet) (model.ImportedSpan, error) {
+	traceID, err := normalizeID(otlpSpan.TraceID, traceIDLength)
+	if err != nil {
+		return model.ImportedSpan{}, fmt.Errorf("invalid trace id of span %s: %w", otlpSpan.SpanID, err)
+	}
+	spanID, err := normalizeID(otlpSpan.SpanID, spanIDLength)
+	if err != nil {
+		return model.ImportedSpan{}, fmt.Errorf("invalid span id of trace %s: %w", traceID, err)
+	}
+	var parentID string
+	if otlpSpan.ParentSpanID != "" {
+		if parentID, err = normalizeID(otlpSpan.ParentSpanID, spanIDLength); err != nil {
+			return model.ImportedSpan{}, fmt.Errorf("invalid parent span id of span %s: %w", spanID, err)
+		}
+	}
+
+	tags := newTagSet()
+	for _, kv := range otlpSpan.Attributes {
+		tags.add(kv.Key, kv.Value)
+	}
+	span := model.ImportedSpan{
+		Span: model.SearchSpanResponseItem{
+			TimeUnixNano: uint64(otlpSpan.StartTimeUnixNano),
+			DurationNano: int64(otlpSpan.EndTimeUnixNano - otlpSpan.StartTimeUnixNano),
+			SpanID:       spanID,
+			TraceID:      traceID,
+			HasError:     otlpSpan.Status.Code == otlpStatusCodeError,
+			Kind:         otlpSpan.Kind,
+			ServiceName:  serviceName,
+			Name:         otlpSpan.Name,
+			References:   []model.OtelSpanRef{},
+			TagMap:       tags.tagMap(resource),
+			Events:       []string{},
+		},
+		ParentSpanID:   parentID,
+		StatusCode:     int16(otlpSpan.Status.Code),
+		StringTagMap:   tags.strings,
+		NumberTagMap:   tags.numbers,
+		BoolTagMap:     tags.bools,
+		ResourceTagMap: resource.tagMap(nil),
+	}
+	if span.Span.DurationNano < 0 {
+		span.Span.DurationNano = 0
+	}
+	if parentID != "" {
+		span.Span.References = append(span.Span.References, model.OtelSpanRef{TraceId: traceID, SpanId: parentID, RefType: refTypeChildOf})
+	}
+	for _, link := range otlpSpan.Links {
+		linkTraceID, err := normalizeID(link.TraceID, traceIDLength)
+		if err != nil {
+			return model.ImportedSpan{}, fmt.Errorf("invalid link of span %s: %w", spanID, err)
+		}
+		linkSpanID, err := normalizeID(link.SpanID, spanIDLength)
+		if err != nil {
+			return model.ImportedSpan{}, fmt.Errorf("invalid link of span %s: %w", spanID, err)
+		}
+		span.Span.References = append(span.Span.References, model.OtelSpanRef{TraceId: linkTraceID, SpanId: linkSpanID, RefType: refTypeFollowsFrom})
+	}
+	for _, otlpEvent := range otlpSpan.Events {
+		attributes := newTagSet()
+		for _, kv := range otlpEvent.Attributes {
+			attributes.add(kv.Key, kv.Value)
+		}
+		event := model.Event{
+			Name:         otlpEvent.Name,
+			TimeUnixNano: uint64(otlpEvent.TimeUnixNano),
+			AttributeMap: attributes.raw,
+		}
+		if err := addEvent(&span.Span, event); err != nil {
+			return model.ImportedSpan{}, err
+		}
+	}
+	return span, nil
+}
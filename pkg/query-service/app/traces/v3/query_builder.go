@@ -47,17 +47,17 @@ var tracesOperatorMappingV3 = map[v3.FilterOperator]string{
 	v3.FilterOperatorNotLike:         "NOT ILIKE",
 	v3.FilterOperatorContains:        "ILIKE",
 	v3.FilterOperatorNotContains:     "NOT ILIKE",
-	v3.FilterOperatorExists:          "has(%s%s, '%s')",
-	v3.FilterOperatorNotExists:       "NOT has(%s%s, '%s')",
+	v3.FilterOperatorExists:          "has(%s%s, %s)",
+	v3.FilterOperatorNotExists:       "NOT has(%s%s, %s)",
 }
 
 func getColumnName(key v3.AttributeKey, keys map[string]v3.AttributeKey) string {
 	key = enrichKeyWithMetadata(key, keys)
 	if key.IsColumn {
-		return key.Key
+		return utils.ClickHouseFormattedColumn(key.Key)
 	}
 	filterType, filterDataType := getClickhouseTracesColumnDataTypeAndType(key)
-	return fmt.Sprintf("%s%s['%s']", filterDataType, filterType, key.Key)
+	return fmt.Sprintf("%s%s[%s]", filterDataType, filterType, utils.QuoteString(key.Key))
 }
 
 func getClickhouseTracesColumnDataTypeAndType(key v3.AttributeKey) (v3.AttributeKeyType, string) {
@@ -102,7 +102,7 @@ func getSelectLabels(aggregatorOperator v3.AggregateOperator, groupBy []v3.Attri
 	} else {
 		for _, tag := range groupBy {
 			filterName := getColumnName(tag, keys)
-			selectLabels += fmt.Sprintf(", %s as `%s`", filterName, tag.Key)
+			selectLabels += fmt.Sprintf(", %s as %s", filterName, utils.QuoteIdentifier(tag.Key))
 		}
 	}
 	return selectLabels
@@ -112,7 +112,7 @@ func getSelectColumns(sc []v3.AttributeKey, keys map[string]v3.AttributeKey) str
 	var columns []string
 	for _, tag := range sc {
 		columnName := getColumnName(tag, keys)
-		columns = append(columns, fmt.Sprintf("%s as `%s` ", columnName, tag.Key))
+		columns = append(columns, fmt.Sprintf("%s as %s ", columnName, utils.QuoteIdentifier(tag.Key)))
 	}
 	return strings.Join(columns, ",")
 }
@@ -134,47 +134,46 @@ func getZerosForEpochNano(epoch int64) int64 {
 func buildTracesFilterQuery(fs *v3.FilterSet, keys map[string]v3.AttributeKey) (string, error) {
 	var conditions []string
 
-	if fs != nil && len(fs.Items) != 0 {
-		for _, item := range fs.Items {
-			val := item.Value
-			// generate the key
-			columnName := getColumnName(item.Key, keys)
-			var fmtVal string
-			key := enrichKeyWithMetadata(item.Key, keys)
-			if item.Operator != v3.FilterOperatorExists && item.Operator != v3.FilterOperatorNotExists {
-				var err error
-				val, err = utils.ValidateAndCastValue(val, key.DataType)
-				if err != nil {
-					return "", fmt.Errorf("invalid value for key %s: %v", item.Key.Key, err)
-				}
-			}
-			if val != nil {
-				fmtVal = utils.ClickHouseFormattedValue(val)
+	filterCondition, err := fs.BuildCondition(func(item v3.FilterItem) (string, error) {
+		val := item.Value
+		// generate the key
+		columnName := getColumnName(item.Key, keys)
+		var fmtVal string
+		key := enrichKeyWithMetadata(item.Key, keys)
+		if item.Operator != v3.FilterOperatorExists && item.Operator != v3.FilterOperatorNotExists {
+			var err error
+			val, err = utils.ValidateAndCastValue(val, key.DataType)
+			if err != nil {
+				return "", fmt.Errorf("invalid value for key %s: %v", item.Key.Key, err)
 			}
-			if operator, ok := tracesOperatorMappingV3[item.Operator]; ok {
-				switch item.Operator {
-				case v3.FilterOperatorContains, v3.FilterOperatorNotContains:
-					conditions = append(conditions, fmt.Sprintf("%s %s '%%%s%%'", columnName, operator, item.Value))
-
-				case v3.FilterOperatorExists, v3.FilterOperatorNotExists:
-					if key.IsColumn {
-						subQuery, err := existsSubQueryForFixedColumn(key, item.Operator)
-						if err != nil {
-							return "", err
-						}
-						conditions = append(conditions, subQuery)
-					} else {
-						columnType, columnDataType := getClickhouseTracesColumnDataTypeAndType(key)
-						conditions = append(conditions, fmt.Sprintf(operator, columnDataType, columnType, key.Key))
-					}
-
-				default:
-					conditions = append(conditions, fmt.Sprintf("%s %s %s", columnName, operator, fmtVal))
-				}
-			} else {
-				return "", fmt.Errorf("unsupported operator %s", item.Operator)
+		}
+		if val != nil {
+			fmtVal = utils.ClickHouseFormattedValue(val)
+		}
+		operator, ok := tracesOperatorMappingV3[item.Operator]
+		if !ok {
+			return "", fmt.Errorf("unsupported operator %s", item.Operator)
+		}
+		switch item.Operator {
+		case v3.FilterOperatorContains, v3.FilterOperatorNotContains:
+			return fmt.Sprintf("%s %s %s", columnName, operator, utils.QuoteString(fmt.Sprintf("%%%v%%", item.Value))), nil
+
+		case v3.FilterOperatorExists, v3.FilterOperatorNotExists:
+			if key.IsColumn {
+				return existsSubQueryForFixedColumn(key, item.Operator)
 			}
+			columnType, columnDataType := getClickhouseTracesColumnDataTypeAndType(key)
+			return fmt.Sprintf(operator, columnDataType, columnType, utils.QuoteString(key.Key)), nil
+
+		default:
+			return fmt.Sprintf("%s %s %s", columnName, operator, fmtVal), nil
 		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if filterCondition != "" {
+		conditions = append(conditions, filterCondition)
 	}
 	queryString := strings.Join(conditions, " AND ")
 
@@ -187,9 +186,9 @@ func buildTracesFilterQuery(fs *v3.FilterSet, keys map[string]v3.AttributeKey) (
 func existsSubQueryForFixedColumn(key v3.AttributeKey, op v3.FilterOperator) (string, error) {
 	if key.DataType == v3.AttributeKeyDataTypeString {
 		if op == v3.FilterOperatorExists {
-			return fmt.Sprintf("%s %s ''", key.Key, tracesOperatorMappingV3[v3.FilterOperatorNotEqual]), nil
+			return fmt.Sprintf("%s %s ''", utils.ClickHouseFormattedColumn(key.Key), tracesOperatorMappingV3[v3.FilterOperatorNotEqual]), nil
 		} else {
-			return fmt.Sprintf("%s %s ''", key.Key, tracesOperatorMappingV3[v3.FilterOperatorEqual]), nil
+			return fmt.Sprintf("%s %s ''", utils.ClickHouseFormattedColumn(key.Key), tracesOperatorMappingV3[v3.FilterOperatorEqual]), nil
 		}
 	} else {
 		return "", fmt.Errorf("unsupported operation, exists and not exists can only be applied on custom attributes or string type columns")
@@ -235,9 +234,10 @@ func buildTracesQuery(start, end, step int64, mq *v3.BuilderQuery, tableName str
 		having = " having " + having
 	}
 
-	// Select the aggregate value for interval
+	// Select the aggregate value for interval, the keys in the labels can
+	// have % in them so it is escaped in the template
 	queryTmpl :=
-		"SELECT toStartOfInterval(timestamp, INTERVAL %d SECOND) AS ts" + selectLabels +
+		"SELECT toStartOfInterval(timestamp, INTERVAL %d SECOND) AS ts" + strings.ReplaceAll(selectLabels, "%", "%%") +
 			", %s as value " +
 			"from " + constants.SIGNOZ_TRACE_DBNAME + "." + constants.SIGNOZ_SPAN_INDEX_TABLENAME +
 			" where " + spanIndexTableTimeFilter + "%s " +
@@ -277,8 +277,13 @@ func buildTracesQuery(start, end, step int64, mq *v3.BuilderQuery, tableName str
 		v3.AggregateOperatorP75,
 		v3.AggregateOperatorP90,
 		v3.AggregateOperatorP95,
-		v3.AggregateOperatorP99:
-		op := fmt.Sprintf("quantile(%v)(%s)", aggregateOperatorToPercentile[mq.AggregateOperator], aggregationKey)
+		v3.AggregateOperatorP99,
+		v3.AggregateOperatorQuantile:
+		percentile, err := mq.Percentile(aggregateOperatorToPercentile)
+		if err != nil {
+			return "", err
+		}
+		op := fmt.Sprintf("quantile(%v)(%s)", percentile, aggregationKey)
 		query := fmt.Sprintf(queryTmpl, step, op, filterSubQuery, groupBy, having, orderBy)
 		return query, nil
 	case v3.AggregateOperatorAvg, v3.AggregateOperatorSum, v3.AggregateOperatorMin, v3.AggregateOperatorMax:
@@ -295,7 +300,7 @@ func buildTracesQuery(start, end, step int64, mq *v3.BuilderQuery, tableName str
 				}
 			} else {
 				columnType, columnDataType := getClickhouseTracesColumnDataTypeAndType(key)
-				filterSubQuery = fmt.Sprintf("%s AND has(%s%s, '%s')", filterSubQuery, columnDataType, columnType, mq.AggregateAttribute.Key)
+				filterSubQuery = fmt.Sprintf("%s AND has(%s%s, %s)", filterSubQuery, columnDataType, columnType, utils.QuoteString(mq.AggregateAttribute.Key))
 			}
 		}
 		op := "toFloat64(count())"
@@ -319,8 +324,8 @@ func buildTracesQuery(start, end, step int64, mq *v3.BuilderQuery, tableName str
 				return "", fmt.Errorf("select columns cannot be empty for panelType %s", panelType)
 			}
 			selectColumns := getSelectColumns(mq.SelectColumns, keys)
-			queryNoOpTmpl := fmt.Sprintf("SELECT timestamp as timestamp_datetime, spanID, traceID, "+"%s ", selectColumns) + "from " + constants.SIGNOZ_TRACE_DBNAME + "." + constants.SIGNOZ_SPAN_INDEX_TABLENAME + " where %s %s" + " order by %s"
-			query = fmt.Sprintf(queryNoOpTmpl, spanIndexTableTimeFilter, filterSubQuery, orderBy)
+			queryNoOpTmpl := "SELECT timestamp as timestamp_datetime, spanID, traceID, %s from " + constants.SIGNOZ_TRACE_DBNAME + "." + constants.SIGNOZ_SPAN_INDEX_TABLENAME + " where %s %s" + " order by %s"
+			query = fmt.Sprintf(queryNoOpTmpl, selectColumns, spanIndexTableTimeFilter, filterSubQuery, orderBy)
 		} else {
 			return "", fmt.Errorf("unsupported aggregate operator %s for panelType %s", mq.AggregateOperator, panelType)
 		}
@@ -358,7 +363,7 @@ func groupBy(tags ...string) string {
 func groupByAttributeKeyTags(keys map[string]v3.AttributeKey, tags ...v3.AttributeKey) string {
 	groupTags := []string{}
 	for _, tag := range tags {
-		groupTags = append(groupTags, fmt.Sprintf("`%s`", tag.Key))
+		groupTags = append(groupTags, utils.QuoteIdentifier(tag.Key))
 	}
 	return groupBy(groupTags...)
 }
@@ -380,10 +385,10 @@ func orderBy(panelType v3.PanelType, items []v3.OrderBy, tags []string, keys map
 
 	for _, tag := range tags {
 		if item, ok := itemsLookup[tag]; ok {
-			orderBy = append(orderBy, fmt.Sprintf("`%s` %s", item.ColumnName, item.Order))
+			orderBy = append(orderBy, fmt.Sprintf("%s %s", utils.QuoteIdentifier(item.ColumnName), item.Order))
 			addedToOrderBy[item.ColumnName] = true
 		} else {
-			orderBy = append(orderBy, fmt.Sprintf("`%s` ASC", tag))
+			orderBy = append(orderBy, fmt.Sprintf("%s ASC", utils.QuoteIdentifier(tag)))
 		}
 	}
 
@@ -405,7 +410,7 @@ func orderBy(panelType v3.PanelType, items []v3.OrderBy, tags []string, keys map
 				name := getColumnName(attr, keys)
 				
 				if item.IsColumn {
-					orderBy = append(orderBy, fmt.Sprintf("`%s` %s", name, item.Order))
+					orderBy = append(orderBy, fmt.Sprintf("%s %s", utils.QuoteIdentifier(item.ColumnName), item.Order))
 				} else {
 					orderBy = append(orderBy, fmt.Sprintf("%s %s", name, item.Order))
 				}
@@ -436,7 +441,7 @@ func having(items []v3.Having) string {
 	// aggregate something and filter on that aggregate
 	var having []string
 	for _, item := range items {
-		having = append(having, fmt.Sprintf("value %s %s", item.Operator, utils.ClickHouseFormattedValue(item.Value)))
+		having = append(having, fmt.Sprintf("value %s %s", item.SQLOperator(), utils.ClickHouseFormattedValue(item.Value)))
 	}
 	return strings.Join(having, " AND ")
 }
@@ -473,6 +478,17 @@ func addOffsetToQuery(query string, offset uint64) string {
 }
 
 func PrepareTracesQuery(start, end int64, queryType v3.QueryType, panelType v3.PanelType, mq *v3.BuilderQuery, keys map[string]v3.AttributeKey) (string, error) {
+	if mq.TraceQuery != nil {
+		query, err := buildTraceQuery(start, end, mq, keys)
+		if err != nil {
+			return "", err
+		}
+		query = addLimitToQuery(query, mq.Limit, panelType)
+		if mq.Offset != 0 {
+			query = addOffsetToQuery(query, mq.Offset)
+		}
+		return query, nil
+	}
 	query, err := buildTracesQuery(start, end, mq.StepInterval, mq, constants.SIGNOZ_SPAN_INDEX_TABLENAME, keys, panelType)
 	if err != nil {
 		return "", err
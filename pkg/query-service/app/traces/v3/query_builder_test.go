@@ -107,6 +107,30 @@ var buildFilterQueryData = []struct {
 		}},
 		ExpectedFilter: " AND stringTagMap['host'] NOT ILIKE '%102.%'",
 	},
+	{
+		Name: "Test OR of nested groups",
+		FilterSet: &v3.FilterSet{Operator: "OR", Groups: []v3.FilterSet{
+			{Items: []v3.FilterItem{
+				{Key: v3.AttributeKey{Key: "serviceName", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true}, Value: "a", Operator: "="},
+				{Key: v3.AttributeKey{Key: "httpCode", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true}, Value: "500", Operator: ">="},
+			}},
+			{Items: []v3.FilterItem{
+				{Key: v3.AttributeKey{Key: "serviceName", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true}, Value: "b", Operator: "="},
+				{Key: v3.AttributeKey{Key: "durationNano", DataType: v3.AttributeKeyDataTypeFloat64, Type: v3.AttributeKeyTypeTag, IsColumn: true}, Value: 2000000000, Operator: ">"},
+			}},
+		}},
+		ExpectedFilter: " AND ((serviceName = 'a' AND httpCode >= '500') OR (serviceName = 'b' AND durationNano > 2000000000.000000))",
+	},
+	{
+		Name: "Test NOT of an OR group",
+		FilterSet: &v3.FilterSet{Operator: "NOT", Groups: []v3.FilterSet{
+			{Operator: "OR", Items: []v3.FilterItem{
+				{Key: v3.AttributeKey{Key: "host", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "a", Operator: "="},
+				{Key: v3.AttributeKey{Key: "host", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "b", Operator: "="},
+			}},
+		}},
+		ExpectedFilter: " AND NOT ((stringTagMap['host'] = 'a' OR stringTagMap['host'] = 'b'))",
+	},
 }
 
 func TestBuildTracesFilterQuery(t *testing.T) {
@@ -810,6 +834,28 @@ var testBuildTracesQueryData = []struct {
 			"order by `method` ASC,ts",
 		PanelType: v3.PanelTypeGraph,
 	},
+	{
+		Name:  "Test aggregate quantile",
+		Start: 1680066360726210000,
+		End:   1680066458000000000,
+		Step:  60,
+		BuilderQuery: &v3.BuilderQuery{
+			QueryName:          "A",
+			AggregateAttribute: v3.AttributeKey{Key: "durationNano", IsColumn: true, DataType: v3.AttributeKeyDataTypeFloat64, Type: v3.AttributeKeyTypeTag},
+			AggregateOperator:  v3.AggregateOperatorQuantile,
+			Quantile:           0.999,
+			Expression:         "A",
+			Filters:            &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{}},
+		},
+		TableName: "signoz_traces.distributed_signoz_index_v2",
+		ExpectedQuery: "SELECT toStartOfInterval(timestamp, INTERVAL 60 SECOND) AS ts, " +
+			"quantile(0.999)(durationNano) as value " +
+			"from signoz_traces.distributed_signoz_index_v2 " +
+			"where (timestamp >= '1680066360726210000' AND timestamp <= '1680066458000000000') " +
+			"group by ts " +
+			"order by ts",
+		PanelType: v3.PanelTypeGraph,
+	},
 	{
 		Name:  "Test aggregate RateSum",
 		Start: 1680066360726210000,
@@ -928,6 +974,34 @@ var testBuildTracesQueryData = []struct {
 			"AND stringTagMap['method'] = 'GET' AND has(stringTagMap, 'name') group by ts having value > 10 order by ts",
 		PanelType: v3.PanelTypeGraph,
 	},
+	{
+		Name:  "Test count aggregate with having clause NOT_IN",
+		Start: 1680066360726210000,
+		End:   1680066458000000000,
+		Step:  60,
+		BuilderQuery: &v3.BuilderQuery{
+			QueryName:          "A",
+			AggregateAttribute: v3.AttributeKey{Key: "name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag},
+			AggregateOperator:  v3.AggregateOperatorCount,
+			Expression:         "A",
+			Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{
+				{Key: v3.AttributeKey{Key: "method", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag}, Value: "GET", Operator: "="},
+			},
+			},
+			Having: []v3.Having{
+				{
+					ColumnName: "name",
+					Operator:   "NOT_IN",
+					Value:      []interface{}{10, 20},
+				},
+			},
+		},
+		TableName: "signoz_traces.distributed_signoz_index_v2",
+		ExpectedQuery: "SELECT toStartOfInterval(timestamp, INTERVAL 60 SECOND) AS ts, toFloat64(count()) as value from " +
+			"signoz_traces.distributed_signoz_index_v2 where (timestamp >= '1680066360726210000' AND timestamp <= '1680066458000000000') " +
+			"AND stringTagMap['method'] = 'GET' AND has(stringTagMap, 'name') group by ts having value NOT IN [10,20] order by ts",
+		PanelType: v3.PanelTypeGraph,
+	},
 	{
 		Name:  "Test count distinct aggregate with having clause and filters",
 		Start: 1680066360726210000,
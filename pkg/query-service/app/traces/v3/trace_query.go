@@ -0,0 +1,223 @@
+package v3
+
+import (
+	"fmt"
+	"strings"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils"
+)
+
+// traceQueryColumns are the columns of the summary of a trace the trace
+// queries can be ordered by, the names are the ones of the trace panel
+var traceQueryColumns = map[string]bool{
+	"timestamp":     true,
+	"durationNano":  true,
+	"span_count":    true,
+	"service_count": true,
+	"error_count":   true,
+	"duration":      true,
+}
+
+// spanCondition returns the condition of the filters on a span, empty when
+// every span matches
+func spanCondition(fs *v3.FilterSet, keys map[string]v3.AttributeKey) (string, error) {
+	condition, err := buildTracesFilterQuery(fs, keys)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(condition, " AND "), nil
+}
+
+// aggregateIf returns the aggregate function applied to the spans matching
+// the condition, or to all the spans when it is empty
+func aggregateIf(function, args, condition string) string {
+	if condition == "" {
+		if args == "" {
+			return function + "()"
+		}
+		return fmt.Sprintf("%s(%s)", function, args)
+	}
+	if args == "" {
+		return fmt.Sprintf("%sIf(%s)", function, condition)
+	}
+	return fmt.Sprintf("%sIf(%s, %s)", function, args, condition)
+}
+
+// traceAggregate returns the expression of the trace level aggregate over
+// the spans of the trace matching the condition
+func traceAggregate(aggregate v3.TraceAggregate, condition string) (string, error) {
+	switch aggregate {
+	case v3.TraceAggregateSpanCount:
+		return aggregateIf("count", "", condition), nil
+	case v3.TraceAggregateServiceCount:
+		return aggregateIf("uniq", "serviceName", condition), nil
+	case v3.TraceAggregateErrorCount:
+		if condition == "" {
+			return "countIf(hasError)", nil
+		}
+		return fmt.Sprintf("countIf(hasError AND (%s))", condition), nil
+	case v3.TraceAggregateRootDuration:
+		return "anyIf(durationNano, parentSpanID = '')", nil
+	case v3.TraceAggregateDuration:
+		return "max(toUnixTimestamp64Nano(timestamp) + toInt64(durationNano)) - min(toUnixTimestamp64Nano(timestamp))", nil
+	default:
+		return "", fmt.Errorf("unsupported trace aggregate %s", aggregate)
+	}
+}
+
+// descendantCondition returns the condition of the spans of childParents
+// having an ancestor in parentIDs, the ancestors are looked up level by
+// level through the parents of the spans of the trace
+func descendantCondition(parentIDs, childParents string, relation int) string {
+	levels := []string{childParents}
+	previous := childParents
+	for level := 2; level <= v3.MaxSpanRelationDepth; level++ {
+		alias := fmt.Sprintf("relation_%d_level_%d", relation, level)
+		levels = append(levels, fmt.Sprintf("arrayMap(p -> parent_ids[indexOf(span_ids, p)], %s) AS %s", previous, alias))
+		previous = alias
+	}
+	return fmt.Sprintf("hasAny(%s, arrayConcat(%s))", parentIDs, strings.Join(levels, ", "))
+}
+
+// buildTraceQuery builds the query of a trace query. The spans of the time
+// range are grouped by trace, the traces without a span of each of the
+// named spans or not matching the aggregates are dropped in the having and
+// the relations are matched on the span and parent ids of the traces.
+func buildTraceQuery(start, end int64, mq *v3.BuilderQuery, keys map[string]v3.AttributeKey) (string, error) {
+	tq := mq.TraceQuery
+	table := constants.SIGNOZ_TRACE_DBNAME + "." + constants.SIGNOZ_SPAN_INDEX_TABLENAME
+	timeFilter := fmt.Sprintf("(timestamp >= '%d' AND timestamp <= '%d')", start*getZerosForEpochNano(start), end*getZerosForEpochNano(end))
+
+	filter, err := spanCondition(mq.Filters, keys)
+	if err != nil {
+		return "", err
+	}
+	var having []string
+	if filter != "" {
+		having = append(having, fmt.Sprintf("countIf(%s) > 0", filter))
+	}
+
+	spanConditions := make(map[string]string, len(tq.Spans))
+	spanIndexes := make(map[string]int, len(tq.Spans))
+	for idx, span := range tq.Spans {
+		condition, err := spanCondition(span.Filters, keys)
+		if err != nil {
+			return "", fmt.Errorf("span %s: %w", span.Name, err)
+		}
+		spanConditions[span.Name] = condition
+		spanIndexes[span.Name] = idx
+		if condition != "" {
+			having = append(having, fmt.Sprintf("countIf(%s) > 0", condition))
+		}
+	}
+
+	for _, item := range tq.Having {
+		aggregate, err := traceAggregate(item.Aggregate, spanConditions[item.Span])
+		if err != nil {
+			return "", err
+		}
+		having = append(having, fmt.Sprintf("%s %s %s", aggregate, item.Operator, utils.ClickHouseFormattedValue(item.Value)))
+	}
+
+	// the arrays of the span ids of the parents and the parent ids of the
+	// children of the relations
+	selectArrays := []string{}
+	added := map[string]bool{}
+	addArray := func(alias, expression string) {
+		if !added[alias] {
+			added[alias] = true
+			selectArrays = append(selectArrays, fmt.Sprintf("%s AS %s", expression, alias))
+		}
+	}
+	var relations []string
+	for idx, relation := range tq.Relations {
+		parentIDs := fmt.Sprintf("span_ids_%d", spanIndexes[relation.Parent])
+		childParents := fmt.Sprintf("parent_ids_%d", spanIndexes[relation.Child])
+		addArray(parentIDs, aggregateIf("groupArray", "spanID", spanConditions[relation.Parent]))
+		addArray(childParents, aggregateIf("groupArray", "parentSpanID", spanConditions[relation.Child]))
+		switch relation.Type {
+		case v3.SpanRelationChild:
+			relations = append(relations, fmt.Sprintf("hasAny(%s, %s)", parentIDs, childParents))
+		case v3.SpanRelationDescendant:
+			addArray("span_ids", "groupArray(spanID)")
+			addArray("parent_ids", "groupArray(parentSpanID)")
+			relations = append(relations, descendantCondition(parentIDs, childParents, idx))
+		default:
+			return "", fmt.Errorf("unsupported relation type %s", relation.Type)
+		}
+	}
+
+	spanCount, _ := traceAggregate(v3.TraceAggregateSpanCount, "")
+	serviceCount, _ := traceAggregate(v3.TraceAggregateServiceCount, "")
+	errorCount, _ := traceAggregate(v3.TraceAggregateErrorCount, "")
+	rootDuration, _ := traceAggregate(v3.TraceAggregateRootDuration, "")
+	duration, _ := traceAggregate(v3.TraceAggregateDuration, "")
+	selectColumns := []string{
+		"traceID",
+		"min(timestamp) AS trace_start",
+		"anyIf(serviceName, parentSpanID = '') AS root_service_name",
+		"anyIf(name, parentSpanID = '') AS root_name",
+		rootDuration + " AS root_duration",
+		spanCount + " AS trace_span_count",
+		serviceCount + " AS trace_service_count",
+		errorCount + " AS trace_error_count",
+		duration + " AS trace_duration",
+	}
+	selectColumns = append(selectColumns, selectArrays...)
+
+	where := timeFilter
+	// the traces are narrowed down to the ones with a span matching the
+	// filters, or the first of the named spans, before they are grouped
+	prefilter := filter
+	for _, span := range tq.Spans {
+		if prefilter == "" {
+			prefilter = spanConditions[span.Name]
+		}
+	}
+	if prefilter != "" {
+		where += fmt.Sprintf(" AND traceID GLOBAL IN (SELECT traceID FROM %s WHERE %s AND %s)", table, timeFilter, prefilter)
+	}
+
+	innerQuery := fmt.Sprintf("SELECT %s FROM %s WHERE %s GROUP BY traceID", strings.Join(selectColumns, ", "), table, where)
+	if len(having) > 0 {
+		innerQuery += " HAVING " + strings.Join(having, " AND ")
+	}
+
+	orderBy, err := traceQueryOrderBy(mq.OrderBy)
+	if err != nil {
+		return "", err
+	}
+	query := "SELECT trace_start AS timestamp_datetime, traceID, root_service_name AS serviceName, root_name AS name, " +
+		"root_duration AS durationNano, trace_span_count AS span_count, trace_service_count AS service_count, " +
+		"trace_error_count AS error_count, trace_duration AS duration FROM (" + innerQuery + ")"
+	if len(relations) > 0 {
+		query += " WHERE " + strings.Join(relations, " AND ")
+	}
+	return query + " ORDER BY " + orderBy, nil
+}
+
+// traceQueryOrderBy returns the order of the traces, the longest traces
+// first when no order is given
+func traceQueryOrderBy(items []v3.OrderBy) (string, error) {
+	var orderBy []string
+	for _, item := range items {
+		if !traceQueryColumns[item.ColumnName] {
+			return "", fmt.Errorf("trace query can not be ordered by %s", item.ColumnName)
+		}
+		column := item.ColumnName
+		if column == "timestamp" {
+			column = "timestamp_datetime"
+		}
+		order := "ASC"
+		if strings.EqualFold(item.Order, "desc") {
+			order = "DESC"
+		}
+		orderBy = append(orderBy, column+" "+order)
+	}
+	if len(orderBy) == 0 {
+		return "durationNano DESC", nil
+	}
+	return strings.Join(orderBy, ", "), nil
+}
@@ -0,0 +1,149 @@
+package v3
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+var traceQueryKeys = map[string]v3.AttributeKey{
+	"name":     {Key: "name", DataType: v3.AttributeKeyDataTypeString, Type: v3.AttributeKeyTypeTag, IsColumn: true},
+	"hasError": {Key: "hasError", DataType: v3.AttributeKeyDataTypeBool, Type: v3.AttributeKeyTypeTag, IsColumn: true},
+}
+
+func traceQuerySpan(name string, hasError bool) v3.TraceSpan {
+	items := []v3.FilterItem{{Key: v3.AttributeKey{Key: "name"}, Value: name, Operator: "="}}
+	if hasError {
+		items = append(items, v3.FilterItem{Key: v3.AttributeKey{Key: "hasError"}, Value: true, Operator: "="})
+	}
+	return v3.TraceSpan{Name: name, Filters: &v3.FilterSet{Operator: "AND", Items: items}}
+}
+
+const traceQuerySummary = "SELECT trace_start AS timestamp_datetime, traceID, root_service_name AS serviceName, root_name AS name, " +
+	"root_duration AS durationNano, trace_span_count AS span_count, trace_service_count AS service_count, " +
+	"trace_error_count AS error_count, trace_duration AS duration FROM (SELECT traceID, min(timestamp) AS trace_start, " +
+	"anyIf(serviceName, parentSpanID = '') AS root_service_name, anyIf(name, parentSpanID = '') AS root_name, " +
+	"anyIf(durationNano, parentSpanID = '') AS root_duration, count() AS trace_span_count, uniq(serviceName) AS trace_service_count, " +
+	"countIf(hasError) AS trace_error_count, max(toUnixTimestamp64Nano(timestamp) + toInt64(durationNano)) - " +
+	"min(toUnixTimestamp64Nano(timestamp)) AS trace_duration"
+
+const traceQueryTimeFilter = "(timestamp >= '1680066360726000000' AND timestamp <= '1680066458000000000')"
+
+var testBuildTraceQueryData = []struct {
+	Name          string
+	TraceQuery    *v3.TraceQuery
+	Filters       *v3.FilterSet
+	OrderBy       []v3.OrderBy
+	ExpectedQuery string
+}{
+	{
+		Name: "Test child relation",
+		TraceQuery: &v3.TraceQuery{
+			Spans:     []v3.TraceSpan{traceQuerySpan("checkout", false), traceQuerySpan("payment", true)},
+			Relations: []v3.SpanRelation{{Parent: "checkout", Child: "payment", Type: v3.SpanRelationChild}},
+		},
+		ExpectedQuery: traceQuerySummary + ", groupArrayIf(spanID, name = 'checkout') AS span_ids_0, " +
+			"groupArrayIf(parentSpanID, name = 'payment' AND hasError = true) AS parent_ids_1 " +
+			"FROM signoz_traces.distributed_signoz_index_v2 WHERE " + traceQueryTimeFilter + " AND traceID GLOBAL IN " +
+			"(SELECT traceID FROM signoz_traces.distributed_signoz_index_v2 WHERE " + traceQueryTimeFilter + " AND name = 'checkout') " +
+			"GROUP BY traceID HAVING countIf(name = 'checkout') > 0 AND countIf(name = 'payment' AND hasError = true) > 0) " +
+			"WHERE hasAny(span_ids_0, parent_ids_1) ORDER BY durationNano DESC",
+	},
+	{
+		Name: "Test span count of a span",
+		TraceQuery: &v3.TraceQuery{
+			Spans:  []v3.TraceSpan{traceQuerySpan("SELECT", false)},
+			Having: []v3.TraceHaving{{Aggregate: v3.TraceAggregateSpanCount, Span: "SELECT", Operator: ">", Value: 50}},
+		},
+		OrderBy: []v3.OrderBy{{ColumnName: "span_count", Order: "desc"}, {ColumnName: "timestamp", Order: "asc"}},
+		ExpectedQuery: traceQuerySummary + " FROM signoz_traces.distributed_signoz_index_v2 WHERE " + traceQueryTimeFilter +
+			" AND traceID GLOBAL IN (SELECT traceID FROM signoz_traces.distributed_signoz_index_v2 WHERE " + traceQueryTimeFilter +
+			" AND name = 'SELECT') GROUP BY traceID HAVING countIf(name = 'SELECT') > 0 AND countIf(name = 'SELECT') > 50) " +
+			"ORDER BY span_count DESC, timestamp_datetime ASC",
+	},
+	{
+		Name:    "Test trace aggregates without spans",
+		Filters: &v3.FilterSet{Operator: "AND", Items: []v3.FilterItem{{Key: v3.AttributeKey{Key: "hasError"}, Value: true, Operator: "="}}},
+		TraceQuery: &v3.TraceQuery{
+			Having: []v3.TraceHaving{
+				{Aggregate: v3.TraceAggregateServiceCount, Operator: ">=", Value: 3},
+				{Aggregate: v3.TraceAggregateDuration, Operator: "<", Value: 1000000},
+			},
+		},
+		ExpectedQuery: traceQuerySummary + " FROM signoz_traces.distributed_signoz_index_v2 WHERE " + traceQueryTimeFilter +
+			" AND traceID GLOBAL IN (SELECT traceID FROM signoz_traces.distributed_signoz_index_v2 WHERE " + traceQueryTimeFilter +
+			" AND hasError = true) GROUP BY traceID HAVING countIf(hasError = true) > 0 AND uniq(serviceName) >= 3 AND " +
+			"max(toUnixTimestamp64Nano(timestamp) + toInt64(durationNano)) - min(toUnixTimestamp64Nano(timestamp)) < 1000000) " +
+			"ORDER BY durationNano DESC",
+	},
+}
+
+func TestBuildTraceQuery(t *testing.T) {
+	for _, tt := range testBuildTraceQueryData {
+		Convey("TestBuildTraceQuery", t, func() {
+			mq := &v3.BuilderQuery{
+				QueryName:         "A",
+				DataSource:        v3.DataSourceTraces,
+				AggregateOperator: v3.AggregateOperatorNoOp,
+				Filters:           tt.Filters,
+				OrderBy:           tt.OrderBy,
+				TraceQuery:        tt.TraceQuery,
+			}
+			query, err := buildTraceQuery(1680066360726, 1680066458000, mq, traceQueryKeys)
+			So(err, ShouldBeNil)
+			So(query, ShouldEqual, tt.ExpectedQuery)
+		})
+	}
+}
+
+func TestBuildTraceQueryDescendant(t *testing.T) {
+	Convey("TestBuildTraceQueryDescendant", t, func() {
+		mq := &v3.BuilderQuery{
+			QueryName:         "A",
+			DataSource:        v3.DataSourceTraces,
+			AggregateOperator: v3.AggregateOperatorNoOp,
+			TraceQuery: &v3.TraceQuery{
+				Spans:     []v3.TraceSpan{traceQuerySpan("checkout", false), traceQuerySpan("payment", true)},
+				Relations: []v3.SpanRelation{{Parent: "checkout", Child: "payment", Type: v3.SpanRelationDescendant}},
+			},
+		}
+		query, err := buildTraceQuery(1680066360726, 1680066458000, mq, traceQueryKeys)
+		So(err, ShouldBeNil)
+		So(query, ShouldContainSubstring, "groupArray(spanID) AS span_ids, groupArray(parentSpanID) AS parent_ids FROM")
+		So(query, ShouldContainSubstring, "WHERE hasAny(span_ids_0, arrayConcat(parent_ids_1, "+
+			"arrayMap(p -> parent_ids[indexOf(span_ids, p)], parent_ids_1) AS relation_0_level_2, "+
+			"arrayMap(p -> parent_ids[indexOf(span_ids, p)], relation_0_level_2) AS relation_0_level_3, ")
+		So(query, ShouldEndWith, "AS relation_0_level_16)) ORDER BY durationNano DESC")
+	})
+}
+
+func TestBuildTraceQueryOrderBy(t *testing.T) {
+	Convey("TestBuildTraceQueryOrderBy", t, func() {
+		mq := &v3.BuilderQuery{
+			QueryName:         "A",
+			DataSource:        v3.DataSourceTraces,
+			AggregateOperator: v3.AggregateOperatorNoOp,
+			OrderBy:           []v3.OrderBy{{ColumnName: "httpMethod", Order: "desc"}},
+			TraceQuery:        &v3.TraceQuery{Spans: []v3.TraceSpan{traceQuerySpan("checkout", false)}},
+		}
+		_, err := buildTraceQuery(1680066360726, 1680066458000, mq, traceQueryKeys)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestPrepareTraceQuery(t *testing.T) {
+	Convey("TestPrepareTraceQuery", t, func() {
+		mq := &v3.BuilderQuery{
+			QueryName:         "A",
+			DataSource:        v3.DataSourceTraces,
+			AggregateOperator: v3.AggregateOperatorNoOp,
+			Limit:             10,
+			Offset:            20,
+			TraceQuery:        &v3.TraceQuery{Spans: []v3.TraceSpan{traceQuerySpan("checkout", false)}},
+		}
+		query, err := PrepareTracesQuery(1680066360726, 1680066458000, v3.QueryTypeBuilder, v3.PanelTypeTrace, mq, traceQueryKeys)
+		So(err, ShouldBeNil)
+		So(query, ShouldEndWith, "ORDER BY durationNano DESC LIMIT 10 OFFSET 20")
+	})
+}
@@ -61,6 +61,19 @@ func GetUserFromRequest(r *http.Request) (*model.UserPayload, error) {
 	return user, nil
 }
 
+type userContextKey struct{}
+
+// AttachUserToContext returns a copy of ctx carrying the authenticated user
+func AttachUserToContext(ctx context.Context, user *model.UserPayload) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// GetUserFromContext returns the user attached to ctx by the auth middleware
+func GetUserFromContext(ctx context.Context) (*model.UserPayload, bool) {
+	user, ok := ctx.Value(userContextKey{}).(*model.UserPayload)
+	return user, ok && user != nil
+}
+
 func IsSelfAccessRequest(user *model.UserPayload, id string) bool { return user.Id == id }
 
 func IsViewer(user *model.UserPayload) bool { return user.GroupId == AuthCacheObj.ViewerGroupId }
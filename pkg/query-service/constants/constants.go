@@ -3,6 +3,7 @@ package constants
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"go.signoz.io/signoz/pkg/query-service/model"
@@ -100,6 +101,63 @@ func GetContextTimeout() time.Duration {
 
 var ContextTimeout = GetContextTimeout()
 
+// GetQueryRangeTimeout returns the deadline applied to all the queries of a
+// single query range request
+func GetQueryRangeTimeout() time.Duration {
+	queryRangeTimeoutStr := GetOrDefaultEnv("QUERY_RANGE_TIMEOUT", "55")
+	queryRangeTimeoutDuration, err := time.ParseDuration(queryRangeTimeoutStr + "s")
+	if err != nil {
+		return 55 * time.Second
+	}
+	return queryRangeTimeoutDuration
+}
+
+var QueryRangeTimeout = GetQueryRangeTimeout()
+
+func getOrDefaultEnvInt(key string, fallback int) int {
+	v, err := strconv.Atoi(GetOrDefaultEnv(key, strconv.Itoa(fallback)))
+	if err != nil || v <= 0 {
+		return fallback
+	}
+	return v
+}
+
+// MaxConcurrentQueriesPerRequest is the number of queries of a composite
+// query that are run in parallel
+var MaxConcurrentQueriesPerRequest = getOrDefaultEnvInt("MAX_CONCURRENT_QUERIES_PER_REQUEST", 4)
+
+// MaxConcurrentQueriesPerUser is the number of clickhouse queries a single
+// user can have in flight across all of their requests
+var MaxConcurrentQueriesPerUser = getOrDefaultEnvInt("MAX_CONCURRENT_QUERIES_PER_USER", 8)
+
+// RuleStateHistoryRetentionDays is how long the state changes of the alerts
+// are kept for the rule timelines
+var RuleStateHistoryRetentionDays = getOrDefaultEnvInt("RULE_STATE_HISTORY_RETENTION_DAYS", 30)
+
+// RawQueryAllowedDatabases are the databases the raw clickhouse queries of
+// the dashboards and the dashboard variables can read from
+var RawQueryAllowedDatabases = strings.Split(GetOrDefaultEnv("RAW_QUERY_ALLOWED_DATABASES", "signoz_metrics,signoz_traces,signoz_logs"), ",")
+
+// RawQueryMaxExecutionTime is the max_execution_time (seconds) of the raw
+// clickhouse queries
+var RawQueryMaxExecutionTime = getOrDefaultEnvInt("RAW_QUERY_MAX_EXECUTION_TIME", 30)
+
+// RawQueryMaxRowsToRead is the max_rows_to_read of the raw clickhouse
+// queries
+var RawQueryMaxRowsToRead = getOrDefaultEnvInt("RAW_QUERY_MAX_ROWS_TO_READ", 1000000000)
+
+// MaxTraceImportSize is the size (bytes) of the largest trace file that can
+// be imported
+var MaxTraceImportSize = int64(getOrDefaultEnvInt("MAX_TRACE_IMPORT_SIZE", 64<<20))
+
+// MaxRuleImportSize is the size (bytes) of the largest Prometheus rule file
+// that can be imported
+var MaxRuleImportSize = int64(getOrDefaultEnvInt("MAX_RULE_IMPORT_SIZE", 4<<20))
+
+// TraceLogsMargin is how long before the first span and after the last span
+// of a trace the logs of the trace are looked for
+const TraceLogsMargin = time.Minute
+
 const (
 	TraceID                        = "traceID"
 	ServiceName                    = "serviceName"
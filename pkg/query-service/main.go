@@ -34,10 +34,18 @@ func main() {
 	// the url used to build link in the alert messages in slack and other systems
 	var ruleRepoURL string
 
+	// the directory of prometheus rule files imported on start
+	var ruleImportDir string
+
+	var cacheConfigPath, fluxInterval string
+
 	flag.StringVar(&promConfigPath, "config", "./config/prometheus.yml", "(prometheus config to read metrics)")
 	flag.StringVar(&skipTopLvlOpsPath, "skip-top-level-ops", "", "(config file to skip top level operations)")
 	flag.BoolVar(&disableRules, "rules.disable", false, "(disable rule evaluation)")
 	flag.StringVar(&ruleRepoURL, "rules.repo-url", constants.AlertHelpPage, "(host address used to build rule link in alert messages)")
+	flag.StringVar(&ruleImportDir, "rules.import-dir", "", "(directory of prometheus rule files to import on start)")
+	flag.StringVar(&cacheConfigPath, "cache-config", "", "(cache config to use for query range results)")
+	flag.StringVar(&fluxInterval, "flux-interval", "5m", "(the interval to exclude data from being cached to avoid incorrect cache for data in motion)")
 	flag.Parse()
 
 	loggerMgr := initZapLog()
@@ -54,6 +62,9 @@ func main() {
 		PrivateHostPort:   constants.PrivateHostPort,
 		DisableRules:      disableRules,
 		RuleRepoURL:       ruleRepoURL,
+		RuleImportDir:     ruleImportDir,
+		CacheConfigPath:   cacheConfigPath,
+		FluxInterval:      fluxInterval,
 	}
 
 	// Read the jwt secret key
@@ -61,6 +61,8 @@ type PipelineOperator struct {
 	Routes      *[]Route         `json:"routes,omitempty" yaml:"routes,omitempty"`
 	Fields      []string         `json:"fields,omitempty" yaml:"fields,omitempty"`
 	Default     string           `json:"default,omitempty" yaml:"default,omitempty"`
+	Layout      string           `json:"layout,omitempty" yaml:"layout,omitempty"`
+	LayoutType  string           `json:"layout_type,omitempty" yaml:"layout_type,omitempty"`
 }
 
 type TimestampParser struct {
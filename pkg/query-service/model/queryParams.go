@@ -153,6 +153,17 @@ type MetricAutocompleteTagParams struct {
 	TagKey     string
 }
 
+// PromLabelParams are the params of the label lookups of the Prometheus API,
+// the time series are the ones with samples between Start and End (unix milli)
+type PromLabelParams struct {
+	MetricName string
+	MetricTags metricTags
+	LabelName  string
+	Start      int64
+	End        int64
+	Limit      int
+}
+
 type GetTopOperationsParams struct {
 	StartTime   string `json:"start"`
 	EndTime     string `json:"end"`
@@ -163,6 +174,27 @@ type GetTopOperationsParams struct {
 	Limit       int             `json:"limit"`
 }
 
+type TraceWindowsDiffParams struct {
+	ServiceName     string `json:"service"`
+	Operation       string `json:"operation"`
+	BaseStartTime   string `json:"baseStart"`
+	BaseEndTime     string `json:"baseEnd"`
+	TargetStartTime string `json:"targetStart"`
+	TargetEndTime   string `json:"targetEnd"`
+	Limit           int    `json:"limit"`
+	BaseStart       *time.Time
+	BaseEnd         *time.Time
+	TargetStart     *time.Time
+	TargetEnd       *time.Time
+}
+
+type OperationWindowStatsParams struct {
+	ServiceName string
+	Operation   string
+	Start       *time.Time
+	End         *time.Time
+}
+
 type GetUsageParams struct {
 	StartTime   string
 	EndTime     string
@@ -172,6 +172,13 @@ type ServiceOverviewItem struct {
 type SearchSpansResult struct {
 	Columns []string        `json:"columns"`
 	Events  [][]interface{} `json:"events"`
+	// SpanLogCounts is the number of logs of each span that has logs
+	SpanLogCounts map[string]uint64 `json:"spanLogCounts,omitempty"`
+}
+
+type SpanLogCountItem struct {
+	SpanID string `ch:"span_id"`
+	Count  uint64 `ch:"count"`
 }
 
 type GetFilterSpansResponseItem struct {
@@ -228,6 +235,194 @@ type OtelSpanRef struct {
 	RefType string `json:"refType,omitempty"`
 }
 
+// TraceAnalysis is the critical path and the self time of the spans of a
+// trace. The spans that started before or ended after their parent because
+// of clock skew are clamped to the parent, and the spans whose parent is
+// missing are reported as orphans.
+type TraceAnalysis struct {
+	TraceID           string                `json:"traceId"`
+	RootSpanID        string                `json:"rootSpanId"`
+	StartTimeUnixNano uint64                `json:"startTimeUnixNano"`
+	DurationNano      int64                 `json:"durationNano"`
+	CriticalPath      []CriticalPathSegment `json:"criticalPath"`
+	Spans             []SpanTimes           `json:"spans"`
+	Services          []TimeBreakdownItem   `json:"services"`
+	Operations        []TimeBreakdownItem   `json:"operations"`
+	OrphanSpanIDs     []string              `json:"orphanSpanIds"`
+	SkewedSpanIDs     []string              `json:"skewedSpanIds"`
+}
+
+// CriticalPathSegment is a part of the critical path the span spent by
+// itself, not waiting on its children
+type CriticalPathSegment struct {
+	SpanID            string `json:"spanId"`
+	ServiceName       string `json:"serviceName"`
+	Name              string `json:"name"`
+	StartTimeUnixNano uint64 `json:"startTimeUnixNano"`
+	DurationNano      int64  `json:"durationNano"`
+}
+
+// SpanTimes is the exclusive (self) time of a span and its time on the
+// critical path
+type SpanTimes struct {
+	SpanID            string `json:"spanId"`
+	ParentSpanID      string `json:"parentSpanId"`
+	ServiceName       string `json:"serviceName"`
+	Name              string `json:"name"`
+	StartTimeUnixNano uint64 `json:"startTimeUnixNano"`
+	DurationNano      int64  `json:"durationNano"`
+	SelfTimeNano      int64  `json:"selfTimeNano"`
+	CriticalPathNano  int64  `json:"criticalPathNano"`
+	Orphan            bool   `json:"orphan,omitempty"`
+}
+
+// TimeBreakdownItem is the time a service or an operation spent on the
+// critical path and by itself
+type TimeBreakdownItem struct {
+	ServiceName         string  `json:"serviceName"`
+	Name                string  `json:"name,omitempty"`
+	CriticalPathNano    int64   `json:"criticalPathNano"`
+	CriticalPathPercent float64 `json:"criticalPathPercent"`
+	SelfTimeNano        int64   `json:"selfTimeNano"`
+	SpanCount           int     `json:"spanCount"`
+}
+
+// TraceSummary is the root span of a trace and the counts of its spans
+type TraceSummary struct {
+	TraceID           string `json:"traceId"`
+	RootSpanID        string `json:"rootSpanId"`
+	RootServiceName   string `json:"rootServiceName"`
+	RootName          string `json:"rootName"`
+	StartTimeUnixNano uint64 `json:"startTimeUnixNano"`
+	DurationNano      int64  `json:"durationNano"`
+	SpanCount         int    `json:"spanCount"`
+	ServiceCount      int    `json:"serviceCount"`
+	ErrorCount        int    `json:"errorCount"`
+}
+
+// TraceLogs is the logs of a trace grouped by the span they were written in,
+// the logs without a span id are in the group with an empty span id
+type TraceLogs struct {
+	TraceID  string     `json:"traceId"`
+	LogCount int        `json:"logCount"`
+	Spans    []SpanLogs `json:"spans"`
+}
+
+type SpanLogs struct {
+	SpanID      string            `json:"spanId"`
+	ServiceName string            `json:"serviceName,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Logs        []GetLogsResponse `json:"logs"`
+}
+
+// LogTrace is a log with the trace and the span it was written in, Span is
+// nil when the span is not in the trace
+type LogTrace struct {
+	Log   GetLogsResponse         `json:"log"`
+	Trace *TraceSummary           `json:"trace"`
+	Span  *SearchSpanResponseItem `json:"span"`
+}
+
+// ImportedSpan is a span of an imported trace with the columns of the span
+// index, the attributes are split by type like the exporter splits them
+type ImportedSpan struct {
+	Span           SearchSpanResponseItem
+	ParentSpanID   string
+	StatusCode     int16
+	StringTagMap   map[string]string
+	NumberTagMap   map[string]float64
+	BoolTagMap     map[string]bool
+	ResourceTagMap map[string]string
+}
+
+// ImportTracesResponse is the number of traces and spans that were imported
+type ImportTracesResponse struct {
+	TraceCount int `json:"traceCount"`
+	SpanCount  int `json:"spanCount"`
+}
+
+const (
+	DiffStatusMatched = "matched"
+	DiffStatusAdded   = "added"
+	DiffStatusRemoved = "removed"
+)
+
+// TraceDiff compares the span trees of two traces, the spans are aligned by
+// their service and operation name under aligned parents
+type TraceDiff struct {
+	BaseTraceID        string     `json:"baseTraceId"`
+	TargetTraceID      string     `json:"targetTraceId"`
+	BaseDurationNano   int64      `json:"baseDurationNano"`
+	TargetDurationNano int64      `json:"targetDurationNano"`
+	DurationChangeNano int64      `json:"durationChangeNano"`
+	AddedCount         int        `json:"addedCount"`
+	RemovedCount       int        `json:"removedCount"`
+	NewErrorCount      int        `json:"newErrorCount"`
+	Spans              []SpanDiff `json:"spans"`
+}
+
+// SpanDiff is a span of the base trace aligned with a span of the target
+// trace, the spans are in the order of the tree and Depth is the depth of
+// the span in it
+type SpanDiff struct {
+	ServiceName        string `json:"serviceName"`
+	Name               string `json:"name"`
+	Depth              int    `json:"depth"`
+	Status             string `json:"status"`
+	BaseSpanID         string `json:"baseSpanId,omitempty"`
+	TargetSpanID       string `json:"targetSpanId,omitempty"`
+	BaseDurationNano   int64  `json:"baseDurationNano"`
+	TargetDurationNano int64  `json:"targetDurationNano"`
+	DurationChangeNano int64  `json:"durationChangeNano"`
+	BaseHasError       bool   `json:"baseHasError"`
+	TargetHasError     bool   `json:"targetHasError"`
+	NewError           bool   `json:"newError"`
+}
+
+// OperationStatsItem is the latency of an operation in the traces of a root
+// operation
+type OperationStatsItem struct {
+	ServiceName  string  `json:"serviceName" ch:"serviceName"`
+	Name         string  `json:"name" ch:"name"`
+	Percentile50 float64 `json:"p50" ch:"p50"`
+	Percentile95 float64 `json:"p95" ch:"p95"`
+	NumCalls     uint64  `json:"numCalls" ch:"numCalls"`
+	ErrorCount   uint64  `json:"errorCount" ch:"errorCount"`
+}
+
+// OperationWindowStats is the latency of a root operation and of the
+// operations of its traces in a time window
+type OperationWindowStats struct {
+	Root       OperationStatsItem
+	Operations []OperationStatsItem
+}
+
+// OperationStatsDiff compares the latency of an operation in two windows
+type OperationStatsDiff struct {
+	ServiceName      string  `json:"serviceName"`
+	Name             string  `json:"name"`
+	Status           string  `json:"status"`
+	BaseP50          float64 `json:"baseP50"`
+	TargetP50        float64 `json:"targetP50"`
+	P50Change        float64 `json:"p50Change"`
+	BaseP95          float64 `json:"baseP95"`
+	TargetP95        float64 `json:"targetP95"`
+	P95Change        float64 `json:"p95Change"`
+	BaseNumCalls     uint64  `json:"baseNumCalls"`
+	TargetNumCalls   uint64  `json:"targetNumCalls"`
+	BaseErrorCount   uint64  `json:"baseErrorCount"`
+	TargetErrorCount uint64  `json:"targetErrorCount"`
+}
+
+// OperationWindowsDiff compares the traces of a root operation in two time
+// windows, the operations are sorted by how much their p95 changed
+type OperationWindowsDiff struct {
+	ServiceName string               `json:"serviceName"`
+	Operation   string               `json:"operation"`
+	Root        OperationStatsDiff   `json:"root"`
+	Operations  []OperationStatsDiff `json:"operations"`
+}
+
 func (ref *OtelSpanRef) ToString() string {
 
 	retString := fmt.Sprintf(`{TraceId=%s, SpanId=%s, RefType=%s}`, ref.TraceId, ref.SpanId, ref.RefType)
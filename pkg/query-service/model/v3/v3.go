@@ -3,8 +3,10 @@ package v3
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -60,6 +62,10 @@ const (
 	AggregateOperatorHistQuant90   AggregateOperator = "hist_quantile_90"
 	AggregateOperatorHistQuant95   AggregateOperator = "hist_quantile_95"
 	AggregateOperatorHistQuant99   AggregateOperator = "hist_quantile_99"
+	// AggregateOperatorQuantile and AggregateOperatorHistQuant take the
+	// quantile from the query instead of the operator name
+	AggregateOperatorQuantile  AggregateOperator = "quantile"
+	AggregateOperatorHistQuant AggregateOperator = "hist_quantile"
 )
 
 func (a AggregateOperator) Validate() error {
@@ -93,13 +99,43 @@ func (a AggregateOperator) Validate() error {
 		AggregateOperatorHistQuant75,
 		AggregateOperatorHistQuant90,
 		AggregateOperatorHistQuant95,
-		AggregateOperatorHistQuant99:
+		AggregateOperatorHistQuant99,
+		AggregateOperatorQuantile,
+		AggregateOperatorHistQuant:
 		return nil
 	default:
 		return fmt.Errorf("invalid operator: %s", a)
 	}
 }
 
+// IsParameterisedQuantile returns true if the aggregate operator takes the
+// quantile from the query
+func (a AggregateOperator) IsParameterisedQuantile() bool {
+	return a == AggregateOperatorQuantile || a == AggregateOperatorHistQuant
+}
+
+// ValidateQuantile checks the quantile of the aggregate operators that take
+// it from the query
+func (b *BuilderQuery) ValidateQuantile() error {
+	if b.AggregateOperator.IsParameterisedQuantile() && (b.Quantile <= 0 || b.Quantile > 1) {
+		return fmt.Errorf("quantile should be greater than 0 and at most 1")
+	}
+	return nil
+}
+
+// Percentile returns the quantile the query aggregates with, the named
+// percentile operators take it from percentiles and the quantile operators
+// from the query
+func (b *BuilderQuery) Percentile(percentiles map[AggregateOperator]float64) (float64, error) {
+	if !b.AggregateOperator.IsParameterisedQuantile() {
+		return percentiles[b.AggregateOperator], nil
+	}
+	if err := b.ValidateQuantile(); err != nil {
+		return 0, err
+	}
+	return b.Quantile, nil
+}
+
 // RequireAttribute returns true if the aggregate operator requires an attribute
 // to be specified.
 func (a AggregateOperator) RequireAttribute(dataSource DataSource) bool {
@@ -153,6 +189,27 @@ func (r ReduceToOperator) Validate() error {
 	}
 }
 
+// LimitMode is how the limit of a builder query applies to time series
+type LimitMode string
+
+const (
+	// LimitModeRows limits the rows of the query, the default
+	LimitModeRows LimitMode = "rows"
+	// LimitModeSeries ranks the groups by the aggregate over the whole
+	// window in the order of the query and keeps the full series of the
+	// top groups
+	LimitModeSeries LimitMode = "series"
+)
+
+func (l LimitMode) Validate() error {
+	switch l {
+	case "", LimitModeRows, LimitModeSeries:
+		return nil
+	default:
+		return fmt.Errorf("invalid limit mode: %s", l)
+	}
+}
+
 type QueryType string
 
 const (
@@ -333,6 +390,9 @@ type PromQuery struct {
 	Stats    string `json:"stats,omitempty"`
 	Disabled bool   `json:"disabled"`
 	Legend   string `json:"legend,omitempty"`
+	// TimeShift in seconds runs the query that much earlier and moves the
+	// points forward, e.g. 86400 to compare with the day before
+	TimeShift int64 `json:"timeShift,omitempty"`
 }
 
 func (p *PromQuery) Validate() error {
@@ -344,6 +404,10 @@ func (p *PromQuery) Validate() error {
 		return fmt.Errorf("query is empty")
 	}
 
+	if p.TimeShift < 0 {
+		return fmt.Errorf("time shift should not be negative")
+	}
+
 	return nil
 }
 
@@ -387,6 +451,15 @@ func (c *CompositeQuery) Validate() error {
 			if err := query.Validate(); err != nil {
 				return fmt.Errorf("builder query %s is invalid: %w", name, err)
 			}
+			if query.TimeShift != 0 && (c.PanelType == PanelTypeList || c.PanelType == PanelTypeTrace) {
+				return fmt.Errorf("builder query %s is invalid: time shift is not supported for %s panels", name, c.PanelType)
+			}
+			if query.LimitMode == LimitModeSeries && c.PanelType != PanelTypeGraph {
+				return fmt.Errorf("builder query %s is invalid: series limit is supported only for %s panels", name, PanelTypeGraph)
+			}
+			if query.TraceQuery != nil && c.PanelType != PanelTypeList && c.PanelType != PanelTypeTrace {
+				return fmt.Errorf("builder query %s is invalid: trace query is supported only for %s and %s panels", name, PanelTypeList, PanelTypeTrace)
+			}
 		}
 	}
 
@@ -435,6 +508,26 @@ type BuilderQuery struct {
 	OrderBy            []OrderBy         `json:"orderBy,omitempty"`
 	ReduceTo           ReduceToOperator  `json:"reduceTo,omitempty"`
 	SelectColumns      []AttributeKey    `json:"selectColumns,omitempty"`
+	// Quantile of the quantile and hist_quantile aggregate operators, e.g. 0.999
+	Quantile float64 `json:"quantile,omitempty"`
+	// TimeShift in seconds runs the query that much earlier and moves the
+	// points forward, e.g. 604800 to compare with the week before
+	TimeShift int64 `json:"timeShift,omitempty"`
+	// Functions are applied in order to the series of the query after it
+	// is run
+	Functions []Function `json:"functions,omitempty"`
+	// LimitMode series keeps the series of the top Limit groups and
+	// LimitOthers folds the series of the rest into an __other__ series
+	LimitMode   LimitMode `json:"limitMode,omitempty"`
+	LimitOthers bool      `json:"limitOthers,omitempty"`
+	// TraceQuery selects the traces by the relations between their spans
+	// and by trace level aggregates, the query returns a row per trace
+	TraceQuery *TraceQuery `json:"traceQuery,omitempty"`
+}
+
+// IsSeriesLimit reports whether the limit of the query keeps the top series
+func (b *BuilderQuery) IsSeriesLimit() bool {
+	return b.LimitMode == LimitModeSeries && b.Limit > 0 && len(b.GroupBy) > 0
 }
 
 func (b *BuilderQuery) Validate() error {
@@ -457,6 +550,40 @@ func (b *BuilderQuery) Validate() error {
 		if b.AggregateAttribute == (AttributeKey{}) && b.AggregateOperator.RequireAttribute(b.DataSource) {
 			return fmt.Errorf("aggregate attribute is required")
 		}
+		if err := b.ValidateQuantile(); err != nil {
+			return err
+		}
+		if b.AggregateOperator == AggregateOperatorHistQuant && b.DataSource != DataSourceMetrics {
+			return fmt.Errorf("hist_quantile is supported only for metrics")
+		}
+	} else if b.TimeShift != 0 {
+		return fmt.Errorf("time shift is not supported for formulas, shift the queries it refers to")
+	} else if b.LimitMode == LimitModeSeries {
+		return fmt.Errorf("series limit is not supported for formulas, limit the queries it refers to")
+	}
+
+	if err := b.LimitMode.Validate(); err != nil {
+		return err
+	}
+	if b.LimitMode == LimitModeSeries {
+		if b.Limit == 0 {
+			return fmt.Errorf("series limit requires a limit")
+		}
+		if len(b.GroupBy) == 0 {
+			return fmt.Errorf("series limit requires a group by")
+		}
+	} else if b.LimitOthers {
+		return fmt.Errorf("the other series requires the series limit mode")
+	}
+
+	if b.TimeShift < 0 {
+		return fmt.Errorf("time shift should not be negative")
+	}
+
+	for _, function := range b.Functions {
+		if err := function.Validate(); err != nil {
+			return fmt.Errorf("function %s is invalid: %w", function.Name, err)
+		}
 	}
 
 	if b.Filters != nil {
@@ -486,29 +613,393 @@ func (b *BuilderQuery) Validate() error {
 		}
 	}
 
+	if b.TraceQuery != nil {
+		if b.DataSource != DataSourceTraces || b.AggregateOperator != AggregateOperatorNoOp {
+			return fmt.Errorf("trace query requires the traces data source and the noop aggregate operator")
+		}
+		if err := b.TraceQuery.Validate(); err != nil {
+			return fmt.Errorf("trace query is invalid: %w", err)
+		}
+	}
+
+	for _, orderBy := range b.OrderBy {
+		if err := orderBy.Validate(); err != nil {
+			return fmt.Errorf("order by is invalid %w", err)
+		}
+	}
+
+	for _, having := range b.Having {
+		if err := having.Validate(); err != nil {
+			return fmt.Errorf("having is invalid %w", err)
+		}
+	}
+
 	if b.Expression == "" {
 		return fmt.Errorf("expression is required")
 	}
 	return nil
 }
 
+// TraceQuery selects traces by the relations between their spans and by
+// trace level aggregates. Spans names the spans matching filters, a trace
+// matches when it has at least one span of each. The relations and the
+// aggregates refer to the spans by name.
+type TraceQuery struct {
+	Spans     []TraceSpan    `json:"spans,omitempty"`
+	Relations []SpanRelation `json:"relations,omitempty"`
+	Having    []TraceHaving  `json:"having,omitempty"`
+}
+
+// TraceSpan names the spans of a trace matching the filters
+type TraceSpan struct {
+	Name    string     `json:"name"`
+	Filters *FilterSet `json:"filters,omitempty"`
+}
+
+type SpanRelationType string
+
+const (
+	// SpanRelationChild is matched by the direct children of the parent
+	SpanRelationChild SpanRelationType = "child"
+	// SpanRelationDescendant is matched by the children of the parent and
+	// their descendants, up to MaxSpanRelationDepth levels down
+	SpanRelationDescendant SpanRelationType = "descendant"
+)
+
+// MaxSpanRelationDepth is how many levels down a descendant is looked for
+const MaxSpanRelationDepth = 16
+
+// SpanRelation is matched by a trace when one of the Child spans is a child,
+// or a descendant, of one of the Parent spans, i.e. Parent calls Child
+type SpanRelation struct {
+	Parent string           `json:"parent"`
+	Child  string           `json:"child"`
+	Type   SpanRelationType `json:"type"`
+}
+
+type TraceAggregate string
+
+const (
+	TraceAggregateSpanCount    TraceAggregate = "span_count"
+	TraceAggregateServiceCount TraceAggregate = "service_count"
+	TraceAggregateErrorCount   TraceAggregate = "error_count"
+	TraceAggregateRootDuration TraceAggregate = "root_duration"
+	TraceAggregateDuration     TraceAggregate = "duration"
+)
+
+// IsCount reports whether the aggregate counts spans, the counts can be
+// restricted to the spans of a TraceSpan
+func (a TraceAggregate) IsCount() bool {
+	switch a {
+	case TraceAggregateSpanCount, TraceAggregateServiceCount, TraceAggregateErrorCount:
+		return true
+	}
+	return false
+}
+
+func (a TraceAggregate) Validate() error {
+	switch a {
+	case TraceAggregateSpanCount, TraceAggregateServiceCount, TraceAggregateErrorCount,
+		TraceAggregateRootDuration, TraceAggregateDuration:
+		return nil
+	default:
+		return fmt.Errorf("invalid trace aggregate: %s", a)
+	}
+}
+
+// TraceHaving compares a trace level aggregate with the value, e.g. the
+// traces with more than 50 spans of a TraceSpan named db. The durations are
+// in nanoseconds.
+type TraceHaving struct {
+	Aggregate TraceAggregate `json:"aggregate"`
+	Span      string         `json:"span,omitempty"`
+	Operator  string         `json:"op"`
+	Value     interface{}    `json:"value"`
+}
+
+func (t *TraceQuery) Validate() error {
+	if len(t.Spans) == 0 && len(t.Having) == 0 {
+		return fmt.Errorf("spans or having are required")
+	}
+	names := make(map[string]bool, len(t.Spans))
+	for _, span := range t.Spans {
+		if span.Name == "" {
+			return fmt.Errorf("span name is required")
+		}
+		if names[span.Name] {
+			return fmt.Errorf("duplicate span name %s", span.Name)
+		}
+		names[span.Name] = true
+		if err := span.Filters.Validate(); err != nil {
+			return fmt.Errorf("filters of span %s are invalid: %w", span.Name, err)
+		}
+	}
+	for _, relation := range t.Relations {
+		if !names[relation.Parent] || !names[relation.Child] {
+			return fmt.Errorf("relation refers to an unknown span: %s, %s", relation.Parent, relation.Child)
+		}
+		if relation.Type != SpanRelationChild && relation.Type != SpanRelationDescendant {
+			return fmt.Errorf("invalid relation type: %s", relation.Type)
+		}
+	}
+	for _, having := range t.Having {
+		if err := having.Aggregate.Validate(); err != nil {
+			return err
+		}
+		if having.Span != "" {
+			if !names[having.Span] {
+				return fmt.Errorf("having refers to an unknown span: %s", having.Span)
+			}
+			if !having.Aggregate.IsCount() {
+				return fmt.Errorf("%s can not be restricted to a span", having.Aggregate)
+			}
+		}
+		switch having.Operator {
+		case "=", "!=", ">", ">=", "<", "<=":
+		default:
+			return fmt.Errorf("invalid operator: %s", having.Operator)
+		}
+		switch having.Value.(type) {
+		case float64, float32, int, int64, int32, uint64:
+		default:
+			return fmt.Errorf("value of %s should be a number", having.Aggregate)
+		}
+	}
+	return nil
+}
+
+// FilterSet combines its items and nested groups with the operator, AND
+// when empty. NOT negates the items and groups combined with AND.
 type FilterSet struct {
 	Operator string       `json:"op,omitempty"`
 	Items    []FilterItem `json:"items"`
+	Groups   []FilterSet  `json:"groups,omitempty"`
 }
 
+const (
+	FilterSetOperatorAnd = "AND"
+	FilterSetOperatorOr  = "OR"
+	FilterSetOperatorNot = "NOT"
+)
+
 func (f *FilterSet) Validate() error {
 	if f == nil {
 		return nil
 	}
-	if f.Operator != "" && f.Operator != "AND" && f.Operator != "OR" {
-		return fmt.Errorf("operator must be AND or OR")
+	switch strings.ToUpper(f.Operator) {
+	case "", FilterSetOperatorAnd, FilterSetOperatorOr, FilterSetOperatorNot:
+	default:
+		return fmt.Errorf("operator must be AND, OR or NOT")
 	}
 	for _, item := range f.Items {
 		if err := item.Key.Validate(); err != nil {
 			return fmt.Errorf("filter item key is invalid: %w", err)
 		}
 	}
+	for idx := range f.Groups {
+		if err := f.Groups[idx].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsEmpty returns true when the set and its groups have no items
+func (f *FilterSet) IsEmpty() bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Items) != 0 {
+		return false
+	}
+	for idx := range f.Groups {
+		if !f.Groups[idx].IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// Walk calls fn with the items of the set and of its groups, fn can
+// modify the items
+func (f *FilterSet) Walk(fn func(item *FilterItem)) {
+	if f == nil {
+		return
+	}
+	for idx := range f.Items {
+		fn(&f.Items[idx])
+	}
+	for idx := range f.Groups {
+		f.Groups[idx].Walk(fn)
+	}
+}
+
+// BuildCondition combines the conditions built for the items with the
+// operators of the set and its groups. Items with an empty condition are
+// skipped. The condition of a flat AND set is the item conditions joined
+// with AND, the other conditions are enclosed in parentheses so they can
+// be joined with other conditions.
+func (f *FilterSet) BuildCondition(itemCondition func(item FilterItem) (string, error)) (string, error) {
+	condition, _, err := f.buildCondition(itemCondition)
+	return condition, err
+}
+
+// buildCondition also returns whether the condition is several conditions
+// joined with AND, which need parentheses when nested in an OR
+func (f *FilterSet) buildCondition(itemCondition func(item FilterItem) (string, error)) (string, bool, error) {
+	if f == nil {
+		return "", false, nil
+	}
+	var conditions []string
+	for _, item := range f.Items {
+		condition, err := itemCondition(item)
+		if err != nil {
+			return "", false, err
+		}
+		if condition != "" {
+			conditions = append(conditions, condition)
+		}
+	}
+	for idx := range f.Groups {
+		condition, isAnd, err := f.Groups[idx].buildCondition(itemCondition)
+		if err != nil {
+			return "", false, err
+		}
+		if condition == "" {
+			continue
+		}
+		if isAnd {
+			condition = "(" + condition + ")"
+		}
+		conditions = append(conditions, condition)
+	}
+
+	switch {
+	case len(conditions) == 0:
+		return "", false, nil
+	case strings.ToUpper(f.Operator) == FilterSetOperatorNot:
+		return "NOT (" + strings.Join(conditions, " AND ") + ")", false, nil
+	case len(conditions) == 1:
+		return conditions[0], false, nil
+	case strings.ToUpper(f.Operator) == FilterSetOperatorOr:
+		return "(" + strings.Join(conditions, " OR ") + ")", false, nil
+	default:
+		return strings.Join(conditions, " AND "), true, nil
+	}
+}
+
+func (f *FilterSet) CacheKey() string {
+	if f == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(f.Items)+len(f.Groups))
+	for idx, item := range f.Items {
+		parts = append(parts, fmt.Sprintf("item-%d=%s", idx, item.CacheKey()))
+	}
+	for idx := range f.Groups {
+		parts = append(parts, fmt.Sprintf("group-%d=(%s)", idx, f.Groups[idx].CacheKey()))
+	}
+	return fmt.Sprintf("op:%s,%s", strings.ToUpper(f.Operator), strings.Join(parts, ","))
+}
+
+type FunctionName string
+
+const (
+	FunctionNameMovingAvg    FunctionName = "movingAvg"
+	FunctionNameMovingMedian FunctionName = "movingMedian"
+	FunctionNameEWMA         FunctionName = "ewma"
+	FunctionNameClampMin     FunctionName = "clampMin"
+	FunctionNameClampMax     FunctionName = "clampMax"
+	FunctionNameCutOffMin    FunctionName = "cutOffMin"
+	FunctionNameCutOffMax    FunctionName = "cutOffMax"
+	FunctionNameAbsolute     FunctionName = "absolute"
+	FunctionNameLog          FunctionName = "log"
+	FunctionNameCumSum       FunctionName = "cumSum"
+	FunctionNameRunningDiff  FunctionName = "runningDiff"
+	FunctionNameTimeShift    FunctionName = "timeShift"
+	FunctionNameFillZero     FunctionName = "fillZero"
+	FunctionNameFillPrevious FunctionName = "fillPrevious"
+)
+
+// Function transforms the series of a query, the args are:
+//   - movingAvg, movingMedian: the number of points of the window
+//   - ewma: the smoothing factor, greater than 0 and at most 1
+//   - clampMin, clampMax: the value the points are clamped to
+//   - cutOffMin, cutOffMax: the value below or above which points are dropped
+//   - log: the base, 10 when not given
+//   - timeShift: the seconds the points are moved forward by
+//
+// The other functions take no args.
+type Function struct {
+	Name FunctionName  `json:"name"`
+	Args []interface{} `json:"args,omitempty"`
+}
+
+// Arg returns the idx arg as a number, ok is false when it is not given
+func (f *Function) Arg(idx int) (value float64, ok bool, err error) {
+	if idx >= len(f.Args) {
+		return 0, false, nil
+	}
+	switch v := f.Args[idx].(type) {
+	case float64:
+		return v, true, nil
+	case int:
+		return float64(v), true, nil
+	case int64:
+		return float64(v), true, nil
+	case string:
+		value, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("arg %d should be a number", idx)
+		}
+		return value, true, nil
+	default:
+		return 0, false, fmt.Errorf("arg %d should be a number", idx)
+	}
+}
+
+func (f *Function) Validate() error {
+	var required bool
+	switch f.Name {
+	case FunctionNameMovingAvg, FunctionNameMovingMedian, FunctionNameEWMA,
+		FunctionNameClampMin, FunctionNameClampMax, FunctionNameCutOffMin, FunctionNameCutOffMax,
+		FunctionNameTimeShift:
+		required = true
+	case FunctionNameLog:
+	case FunctionNameAbsolute, FunctionNameCumSum, FunctionNameRunningDiff,
+		FunctionNameFillZero, FunctionNameFillPrevious:
+		if len(f.Args) != 0 {
+			return fmt.Errorf("no args are expected")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown function")
+	}
+
+	value, ok, err := f.Arg(0)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if required {
+			return fmt.Errorf("arg 0 is required")
+		}
+		return nil
+	}
+	switch f.Name {
+	case FunctionNameMovingAvg, FunctionNameMovingMedian:
+		if value < 1 || value != math.Trunc(value) {
+			return fmt.Errorf("window should be a positive integer")
+		}
+	case FunctionNameEWMA:
+		if value <= 0 || value > 1 {
+			return fmt.Errorf("smoothing factor should be greater than 0 and at most 1")
+		}
+	case FunctionNameLog:
+		if value <= 0 || value == 1 {
+			return fmt.Errorf("base should be positive and not 1")
+		}
+	}
 	return nil
 }
 
@@ -554,12 +1045,42 @@ type OrderBy struct {
 	IsColumn   bool                 `json:"-"`
 }
 
+// Validate checks the order, it is written to the query as it is
+func (o OrderBy) Validate() error {
+	switch strings.ToLower(o.Order) {
+	case "", "asc", "desc":
+		return nil
+	default:
+		return fmt.Errorf("invalid order: %s", o.Order)
+	}
+}
+
 type Having struct {
 	ColumnName string      `json:"columnName"`
 	Operator   string      `json:"op"`
 	Value      interface{} `json:"value"`
 }
 
+// SQLOperator returns the operator written to the query, the frontend sends
+// NOT_IN for NOT IN
+func (h Having) SQLOperator() string {
+	if strings.ToUpper(h.Operator) == "NOT_IN" {
+		return "NOT IN"
+	}
+	return h.Operator
+}
+
+// Validate checks the operator, it is written to the query as returned by
+// SQLOperator
+func (h Having) Validate() error {
+	switch strings.ToUpper(h.SQLOperator()) {
+	case "=", "!=", "<>", ">", ">=", "<", "<=", "IN", "NOT IN":
+		return nil
+	default:
+		return fmt.Errorf("invalid operator: %s", h.Operator)
+	}
+}
+
 func (h *Having) CacheKey() string {
 	return fmt.Sprintf("column:%s,op:%s,value:%v", h.ColumnName, h.Operator, h.Value)
 }
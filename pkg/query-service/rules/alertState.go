@@ -0,0 +1,136 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	plabels "github.com/prometheus/prometheus/model/labels"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// StoredAlert is the state of an alert of a rule saved when the alerts of
+// the rule change, so that the rule can continue from where it was when the
+// query service restarts
+type StoredAlert struct {
+	State        AlertState        `json:"state"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	GeneratorURL string            `json:"generatorURL"`
+	Receivers    []string          `json:"receivers"`
+	Value        float64           `json:"value"`
+	ActiveAt     time.Time         `json:"activeAt"`
+	FiredAt      time.Time         `json:"firedAt"`
+	ResolvedAt   time.Time         `json:"resolvedAt"`
+	LastSentAt   time.Time         `json:"lastSentAt"`
+	ValidUntil   time.Time         `json:"validUntil"`
+}
+
+// SaveStateFunc saves the state of the alerts of a rule
+type SaveStateFunc func(ctx context.Context, rule Rule)
+
+// statefulRule is implemented by the rules whose alerts can be saved and
+// restored
+type statefulRule interface {
+	storedAlerts() []StoredAlert
+	restoreAlerts(alerts []StoredAlert)
+}
+
+func newStoredAlert(a *Alert) StoredAlert {
+	stored := StoredAlert{
+		State:        a.State,
+		GeneratorURL: a.GeneratorURL,
+		Receivers:    a.Receivers,
+		Value:        a.Value,
+		ActiveAt:     a.ActiveAt,
+		FiredAt:      a.FiredAt,
+		ResolvedAt:   a.ResolvedAt,
+		LastSentAt:   a.LastSentAt,
+		ValidUntil:   a.ValidUntil,
+	}
+	if a.Labels != nil {
+		stored.Labels = a.Labels.Map()
+	}
+	if a.Annotations != nil {
+		stored.Annotations = a.Annotations.Map()
+	}
+	// json has no representation for these
+	if math.IsNaN(stored.Value) || math.IsInf(stored.Value, 0) {
+		stored.Value = 0
+	}
+	return stored
+}
+
+// storedAlertsKey identifies the alerts by their labels, states and state
+// change times. The values and send times change on every evaluation and
+// are left out, they are saved with the next change of the alerts.
+func storedAlertsKey(alerts []StoredAlert) string {
+	keys := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		keys = append(keys, fmt.Sprintf("%d:%s:%d:%d:%d", labels.FromMap(a.Labels).Hash(), a.State,
+			unixMilli(a.ActiveAt), unixMilli(a.FiredAt), unixMilli(a.ResolvedAt)))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func (s StoredAlert) alert(lbls, annotations labels.BaseLabels) *Alert {
+	return &Alert{
+		State:        s.State,
+		Labels:       lbls,
+		Annotations:  annotations,
+		GeneratorURL: s.GeneratorURL,
+		Receivers:    s.Receivers,
+		Value:        s.Value,
+		ActiveAt:     s.ActiveAt,
+		FiredAt:      s.FiredAt,
+		ResolvedAt:   s.ResolvedAt,
+		LastSentAt:   s.LastSentAt,
+		ValidUntil:   s.ValidUntil,
+	}
+}
+
+func (r *ThresholdRule) storedAlerts() []StoredAlert {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	alerts := make([]StoredAlert, 0, len(r.active))
+	for _, a := range r.active {
+		alerts = append(alerts, newStoredAlert(a))
+	}
+	return alerts
+}
+
+func (r *ThresholdRule) restoreAlerts(alerts []StoredAlert) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, s := range alerts {
+		lbls := labels.FromMap(s.Labels)
+		r.active[lbls.Hash()] = s.alert(lbls, labels.FromMap(s.Annotations))
+	}
+}
+
+func (r *PromRule) storedAlerts() []StoredAlert {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	alerts := make([]StoredAlert, 0, len(r.active))
+	for _, a := range r.active {
+		alerts = append(alerts, newStoredAlert(a))
+	}
+	return alerts
+}
+
+func (r *PromRule) restoreAlerts(alerts []StoredAlert) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, s := range alerts {
+		lbls := plabels.FromMap(s.Labels)
+		r.active[lbls.Hash()] = s.alert(lbls, plabels.FromMap(s.Annotations))
+	}
+}
@@ -0,0 +1,124 @@
+package rules
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// countingRuleDB counts the saves of the alert state
+type countingRuleDB struct {
+	RuleDB
+	saves int
+}
+
+func (db *countingRuleDB) SaveAlertState(ctx context.Context, ruleId string, alerts []StoredAlert) error {
+	db.saves++
+	return db.RuleDB.SaveAlertState(ctx, ruleId, alerts)
+}
+
+// evalTestAlerts makes the rule alert for frontend from ts and for payment
+// from a minute later, so frontend is firing and payment pending
+func evalTestAlerts(t *testing.T, rule *ThresholdRule, ts time.Time) {
+	frontend := Sample{Point: Point{V: 20}, Metric: labels.FromMap(map[string]string{"service_name": "frontend"})}
+	payment := Sample{Point: Point{V: 30}, Metric: labels.FromMap(map[string]string{"service_name": "payment"})}
+	for _, res := range []Vector{{frontend}, {frontend, payment}} {
+		if _, err := rule.updateAlerts(context.Background(), ts, res); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ts = ts.Add(time.Minute)
+	}
+}
+
+func sortedStoredAlerts(alerts []StoredAlert) []StoredAlert {
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Labels["service_name"] < alerts[j].Labels["service_name"]
+	})
+	return alerts
+}
+
+func TestSaveAndRestoreAlertState(t *testing.T) {
+	m := newTestManager(newTestRuleDB(t))
+	rule := newTestThresholdRule(t, "1")
+	evalTestAlerts(t, rule, time.Unix(1680066360, 0))
+	m.prepareSaveStateFunc()(context.Background(), rule)
+
+	restored := newTestThresholdRule(t, "1")
+	m.restoreState(newTask(TaskTypeCh, "1-groupname", taskNamesuffix, time.Minute, []Rule{restored}, m.opts, nil, nil, nil))
+
+	expected := sortedStoredAlerts(rule.storedAlerts())
+	got := sortedStoredAlerts(restored.storedAlerts())
+	if len(got) != 2 || got[0].State != StateFiring || got[1].State != StatePending {
+		t.Fatalf("expected a firing and a pending alert, got %+v", got)
+	}
+	for idx := range expected {
+		if !reflect.DeepEqual(got[idx].Labels, expected[idx].Labels) || !got[idx].ActiveAt.Equal(expected[idx].ActiveAt) ||
+			!got[idx].FiredAt.Equal(expected[idx].FiredAt) || got[idx].Value != expected[idx].Value {
+			t.Errorf("expected %+v, got %+v", expected[idx], got[idx])
+		}
+	}
+}
+
+func TestSaveAlertStateOnChange(t *testing.T) {
+	db := &countingRuleDB{RuleDB: newTestRuleDB(t)}
+	m := newTestManager(db)
+	saveState := m.prepareSaveStateFunc()
+	rule := newTestThresholdRule(t, "1")
+
+	ts := time.Unix(1680066360, 0)
+	frontend := Vector{{Point: Point{V: 20}, Metric: labels.FromMap(map[string]string{"service_name": "frontend"})}}
+	evals := []struct {
+		res   Vector
+		saves int
+	}{
+		// pending
+		{res: frontend, saves: 1},
+		// still pending with another value
+		{res: Vector{{Point: Point{V: 25}, Metric: frontend[0].Metric}}, saves: 1},
+		// firing
+		{res: frontend, saves: 2},
+		{res: frontend, saves: 2},
+		// resolved
+		{saves: 3},
+		{saves: 3},
+	}
+	for idx, eval := range evals {
+		if _, err := rule.updateAlerts(context.Background(), ts.Add(time.Duration(idx)*30*time.Second), eval.res); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		saveState(context.Background(), rule)
+		if db.saves != eval.saves {
+			t.Errorf("expected %d saves after evaluation %d, got %d", eval.saves, idx, db.saves)
+		}
+	}
+}
+
+func TestDeleteTaskDeletesAlertState(t *testing.T) {
+	db := newTestRuleDB(t)
+	m := newTestManager(db)
+	rule := newTestThresholdRule(t, "1")
+	evalTestAlerts(t, rule, time.Unix(1680066360, 0))
+	m.prepareSaveStateFunc()(context.Background(), rule)
+
+	task := newTask(TaskTypeCh, "1-groupname", taskNamesuffix, time.Minute, []Rule{rule}, m.opts, nil, nil, nil)
+	m.tasks["1-groupname"] = task
+	m.rules["1"] = rule
+	go task.Run(context.Background())
+
+	m.deleteTask("1-groupname")
+
+	alerts, err := db.GetAlertState(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if alerts != nil {
+		t.Errorf("expected the saved alerts to be deleted, got %v", alerts)
+	}
+	if _, ok := m.savedAlertsKey("1"); ok {
+		t.Errorf("expected the key of the saved alerts to be deleted")
+	}
+}
@@ -33,6 +33,9 @@ type RuleType string
 const (
 	RuleTypeThreshold = "threshold_rule"
 	RuleTypeProm      = "promql_rule"
+	RuleTypeAnomaly   = "anomaly_rule"
+	RuleTypeSLO       = "slo_rule"
+	RuleTypeRecording = "recording_rule"
 )
 
 type RuleHealth string
@@ -143,6 +146,119 @@ type RuleCondition struct {
 	CompareOp      CompareOp          `yaml:"op,omitempty" json:"op,omitempty"`
 	Target         *float64           `yaml:"target,omitempty" json:"target,omitempty"`
 	MatchType      `json:"matchType,omitempty"`
+
+	// Anomaly is the condition of the anomaly rules, which compare the
+	// current window with the same window in the past instead of a target
+	Anomaly *AnomalyCondition `yaml:"anomaly,omitempty" json:"anomaly,omitempty"`
+
+	// SLO is the condition of the slo rules, which alert when the error
+	// budget burns too fast instead of comparing with a target
+	SLO *SLOCondition `yaml:"slo,omitempty" json:"slo,omitempty"`
+}
+
+type AnomalyMethod string
+
+const (
+	// AnomalyZScore measures the deviation in standard deviations of the
+	// values in the past windows
+	AnomalyZScore AnomalyMethod = "zscore"
+	// AnomalyPercent measures the deviation in percent of the mean of the
+	// values in the past windows
+	AnomalyPercent AnomalyMethod = "percent"
+)
+
+// AnomalyCondition compares the mean of the current window with the same
+// window one day and one week earlier
+type AnomalyCondition struct {
+	Method AnomalyMethod `yaml:"method" json:"method"`
+	// Limit of the deviation, the alert fires when the deviation goes
+	// beyond it
+	Limit float64 `yaml:"limit" json:"limit"`
+	// Direction of the deviation that is anomalous, ValueIsAbove or
+	// ValueIsBelow. Both directions are anomalous when empty.
+	Direction CompareOp `yaml:"direction,omitempty" json:"direction,omitempty"`
+}
+
+func (ac *AnomalyCondition) IsValid() error {
+	if ac.Method != AnomalyZScore && ac.Method != AnomalyPercent {
+		return fmt.Errorf("anomaly method should be one of (%s, %s)", AnomalyZScore, AnomalyPercent)
+	}
+	if ac.Limit <= 0 {
+		return fmt.Errorf("anomaly limit should be a positive number")
+	}
+	switch ac.Direction {
+	case "", CompareOpNone, ValueIsAbove, ValueIsBelow:
+	default:
+		return fmt.Errorf("anomaly direction should be one of (%s, %s) or empty", ValueIsAbove, ValueIsBelow)
+	}
+	return nil
+}
+
+// SLOCondition defines a service level objective as the ratio of the good
+// events to the total events, each counted by a builder query of the
+// composite query
+type SLOCondition struct {
+	// Target is the percent of the events that have to be good, e.g. 99.9
+	Target float64 `yaml:"target" json:"target"`
+	// Window is the compliance window the error budget is computed for
+	Window Duration `yaml:"window,omitempty" json:"window,omitempty"`
+	// GoodQuery and TotalQuery are the names of the builder queries
+	// counting the good and the total events
+	GoodQuery  string `yaml:"goodQuery" json:"goodQuery"`
+	TotalQuery string `yaml:"totalQuery" json:"totalQuery"`
+	// BurnRates are the window pairs the alert fires for, the defaults
+	// are used when empty
+	BurnRates []BurnRateWindow `yaml:"burnRates,omitempty" json:"burnRates,omitempty"`
+}
+
+// BurnRateWindow fires the alert when the error budget burns faster than
+// Factor times the rate that exhausts it at the end of the compliance
+// window in both the long and the short window
+type BurnRateWindow struct {
+	Long   Duration `yaml:"long" json:"long"`
+	Short  Duration `yaml:"short" json:"short"`
+	Factor float64  `yaml:"factor" json:"factor"`
+}
+
+const defaultSLOWindow = Duration(30 * 24 * time.Hour)
+
+// defaultBurnRates are the windows recommended in the SRE workbook for a
+// 30 day compliance window, they fire when 2% of the budget is spent in an
+// hour, 5% in six hours, 10% in a day and 10% in three days
+var defaultBurnRates = []BurnRateWindow{
+	{Long: Duration(time.Hour), Short: Duration(5 * time.Minute), Factor: 14.4},
+	{Long: Duration(6 * time.Hour), Short: Duration(30 * time.Minute), Factor: 6},
+	{Long: Duration(24 * time.Hour), Short: Duration(2 * time.Hour), Factor: 3},
+	{Long: Duration(3 * 24 * time.Hour), Short: Duration(6 * time.Hour), Factor: 1},
+}
+
+func (sc *SLOCondition) IsValid(cq *v3.CompositeQuery) error {
+	if sc.Target <= 0 || sc.Target >= 100 {
+		return fmt.Errorf("slo target should be between 0 and 100")
+	}
+	if sc.Window < 0 {
+		return fmt.Errorf("slo window should be a positive duration")
+	}
+	if cq == nil || cq.QueryType != v3.QueryTypeBuilder {
+		return fmt.Errorf("slo rules need builder queries")
+	}
+	for _, name := range []string{sc.GoodQuery, sc.TotalQuery} {
+		if _, ok := cq.BuilderQueries[name]; !ok {
+			return fmt.Errorf("slo query %q is not a builder query of the composite query", name)
+		}
+	}
+	if sc.GoodQuery == sc.TotalQuery {
+		return fmt.Errorf("slo good and total queries should be different")
+	}
+	for _, br := range sc.BurnRates {
+		if br.Short <= 0 || br.Long <= br.Short {
+			return fmt.Errorf("burn rate short window should be positive and shorter than the long window")
+		}
+		if br.Factor <= 0 {
+			return fmt.Errorf("burn rate factor should be a positive number")
+		}
+	}
+	return nil
 }
 
 func (rc *RuleCondition) IsValid() bool {
@@ -151,6 +267,16 @@ func (rc *RuleCondition) IsValid() bool {
 		return false
 	}
 
+	if rc.Anomaly != nil {
+		// anomaly rules compare with the past windows instead of a target
+		return rc.Anomaly.IsValid() == nil
+	}
+
+	if rc.SLO != nil {
+		// slo rules compare the burn rates with the burn rate factors
+		return rc.SLO.IsValid(rc.CompositeQuery) == nil
+	}
+
 	if rc.QueryType() == v3.QueryTypeBuilder {
 		if rc.Target == nil {
 			return false
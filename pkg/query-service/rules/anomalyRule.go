@@ -0,0 +1,220 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+	querytemplate "go.signoz.io/signoz/pkg/query-service/utils/queryTemplate"
+)
+
+// anomalySeasons are how far back the windows the current window is
+// compared with are
+var anomalySeasons = []time.Duration{24 * time.Hour, 7 * 24 * time.Hour}
+
+// AnomalyRule compares each series of the rule query in the current window
+// with the same series in the same window one day and one week earlier.
+// Series deviating beyond the limit of the anomaly condition alert, the
+// alerts go through the same states as the threshold rule alerts.
+type AnomalyRule struct {
+	*ThresholdRule
+}
+
+func NewAnomalyRule(
+	id string,
+	p *PostableRule,
+	opts ThresholdRuleOpts,
+) (*AnomalyRule, error) {
+
+	if p.RuleCondition == nil || p.RuleCondition.Anomaly == nil {
+		return nil, fmt.Errorf("no anomaly condition")
+	}
+	if err := p.RuleCondition.Anomaly.IsValid(); err != nil {
+		return nil, err
+	}
+
+	tr, err := NewThresholdRule(id, p, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &AnomalyRule{ThresholdRule: tr}, nil
+}
+
+func (r *AnomalyRule) Type() RuleType {
+	return RuleTypeAnomaly
+}
+
+func (r *AnomalyRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (interface{}, error) {
+	if queriers.Querier == nil {
+		return nil, fmt.Errorf("anomaly rules need the v3 querier")
+	}
+
+	res, err := r.runAnomalyQueries(ctx, ts, queriers.Querier)
+	if err != nil {
+		r.SetHealth(HealthBad)
+		r.SetLastError(err)
+		zap.S().Debugf("ruleid:", r.ID(), "\t failure in runAnomalyQueries:", err)
+		return nil, err
+	}
+
+	return r.updateAlerts(ctx, ts, res)
+}
+
+// runAnomalyQueries returns the deviation of the series that are anomalous
+func (r *AnomalyRule) runAnomalyQueries(ctx context.Context, ts time.Time, querier interfaces.Querier) (Vector, error) {
+	current, err := r.querySeries(ctx, ts, 0, querier)
+	if err != nil {
+		return nil, err
+	}
+
+	baselines := make(map[uint64][]float64, len(current))
+	for _, season := range anomalySeasons {
+		past, err := r.querySeries(ctx, ts, season, querier)
+		if err != nil {
+			return nil, err
+		}
+		for h, series := range past {
+			if _, ok := current[h]; ok {
+				baselines[h] = append(baselines[h], r.seriesValues(series)...)
+			}
+		}
+	}
+
+	var result Vector
+	for h, series := range current {
+		values := r.seriesValues(series)
+		if len(values) == 0 || len(baselines[h]) == 0 {
+			continue
+		}
+		deviation, ok := anomalyDeviation(r.ruleCondition.Anomaly.Method, mean(values), baselines[h])
+		if !ok {
+			zap.S().Debugf("ruleid:", r.ID(), "\t msg: deviation is undefined for the series", series.Labels)
+			continue
+		}
+		if r.opts.SendUnmatched || r.isAnomalous(deviation) {
+			result = append(result, Sample{
+				Point:  Point{T: ts.Unix(), V: deviation},
+				Metric: labels.FromMap(series.Labels),
+			})
+		}
+	}
+	return result, nil
+}
+
+// querySeries runs the rule query for the window ending offset before ts
+// and returns the series of the target query by the hash of their labels.
+// The past windows build the same queries as the current window, so they
+// would share its cache key, they are run without the cache.
+func (r *AnomalyRule) querySeries(ctx context.Context, ts time.Time, offset time.Duration, querier interfaces.Querier) (map[uint64]*v3.Series, error) {
+	params := r.prepareQueryRange(ts.Add(-offset))
+	params.Variables = make(map[string]interface{})
+	params.NoCache = offset != 0
+	querytemplate.AssignReservedVarsV3(params)
+
+	if r.ruleCondition.QueryType() == v3.QueryTypeClickHouseSQL {
+		// the querier runs clickhouse queries as they are, the time range
+		// variables have to be replaced for each window
+		compositeQuery := *params.CompositeQuery
+		compositeQuery.ClickHouseQueries = make(map[string]*v3.ClickHouseQuery, len(params.CompositeQuery.ClickHouseQueries))
+		for name, chQuery := range params.CompositeQuery.ClickHouseQueries {
+			tmpl, err := template.New("clickhouse-query").Parse(chQuery.Query)
+			if err != nil {
+				return nil, err
+			}
+			var query bytes.Buffer
+			if err := tmpl.Execute(&query, params.Variables); err != nil {
+				return nil, err
+			}
+			q := *chQuery
+			q.Query = query.String()
+			compositeQuery.ClickHouseQueries[name] = &q
+		}
+		params.CompositeQuery = &compositeQuery
+	}
+
+	results, err, errQueriesByName := querier.QueryRange(ctx, params, map[string]v3.AttributeKey{})
+	if err != nil {
+		zap.S().Errorf("ruleid:", r.ID(), "\t msg: failed to run queries", zap.Error(err), zap.Any("errQueriesByName", errQueriesByName))
+		return nil, fmt.Errorf("failed to run queries: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no queries could be built with the rule config")
+	}
+
+	target := targetResult(results)
+	series := make(map[uint64]*v3.Series, len(target.Series))
+	for _, s := range target.Series {
+		series[labels.FromMap(s.Labels).Hash()] = s
+	}
+	return series, nil
+}
+
+// seriesValues returns the values of the series, the first point of the
+// builder queries is skipped to support rate cases as the threshold rules do
+func (r *AnomalyRule) seriesValues(series *v3.Series) []float64 {
+	series.SortPoints()
+	values := make([]float64, 0, len(series.Points))
+	for idx, point := range series.Points {
+		if idx == 0 && r.ruleCondition.QueryType() == v3.QueryTypeBuilder {
+			continue
+		}
+		if math.IsNaN(point.Value) || math.IsInf(point.Value, 0) {
+			continue
+		}
+		values = append(values, point.Value)
+	}
+	return values
+}
+
+func (r *AnomalyRule) isAnomalous(deviation float64) bool {
+	limit := r.ruleCondition.Anomaly.Limit
+	switch r.ruleCondition.Anomaly.Direction {
+	case ValueIsAbove:
+		return deviation > limit
+	case ValueIsBelow:
+		return deviation < -limit
+	default:
+		return math.Abs(deviation) > limit
+	}
+}
+
+// anomalyDeviation returns how far value is from the baseline values, it
+// is undefined when the baseline does not vary for z-score or its mean is
+// zero for percent
+func anomalyDeviation(method AnomalyMethod, value float64, baseline []float64) (float64, bool) {
+	m := mean(baseline)
+	switch method {
+	case AnomalyZScore:
+		var sum float64
+		for _, v := range baseline {
+			sum += (v - m) * (v - m)
+		}
+		stddev := math.Sqrt(sum / float64(len(baseline)))
+		if stddev == 0 {
+			return 0, false
+		}
+		return (value - m) / stddev, true
+	case AnomalyPercent:
+		if m == 0 {
+			return 0, false
+		}
+		return (value - m) / math.Abs(m) * 100, true
+	}
+	return 0, false
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
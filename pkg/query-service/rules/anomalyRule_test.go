@@ -0,0 +1,157 @@
+package rules
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// testQuerier returns the series of the results function for the params
+// of each query range and keeps the params it was called with
+type testQuerier struct {
+	mu      sync.Mutex
+	params  []*v3.QueryRangeParamsV3
+	results func(params *v3.QueryRangeParamsV3) []*v3.Result
+}
+
+func (q *testQuerier) QueryRange(ctx context.Context, params *v3.QueryRangeParamsV3, keys map[string]v3.AttributeKey) ([]*v3.Result, error, map[string]string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.params = append(q.params, params)
+	return q.results(params), nil, nil
+}
+
+func (q *testQuerier) QueriesExecuted() []string {
+	return nil
+}
+
+func testBuilderQuery(name string) *v3.BuilderQuery {
+	return &v3.BuilderQuery{
+		QueryName:          name,
+		DataSource:         v3.DataSourceMetrics,
+		AggregateAttribute: v3.AttributeKey{Key: "http_server_requests_count", IsColumn: true},
+		AggregateOperator:  v3.AggregateOperatorSumRate,
+		Expression:         name,
+	}
+}
+
+func testSeries(service string, values ...float64) *v3.Series {
+	series := &v3.Series{Labels: map[string]string{"service_name": service}}
+	for idx, value := range values {
+		series.Points = append(series.Points, v3.Point{Timestamp: int64(idx) * 60000, Value: value})
+	}
+	return series
+}
+
+func newTestAnomalyRule(t *testing.T, condition *AnomalyCondition) *AnomalyRule {
+	rule, err := NewAnomalyRule("1", &PostableRule{
+		Alert: "anomaly",
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType:      v3.QueryTypeBuilder,
+				PanelType:      v3.PanelTypeGraph,
+				BuilderQueries: map[string]*v3.BuilderQuery{"A": testBuilderQuery("A")},
+			},
+			Anomaly: condition,
+		},
+	}, ThresholdRuleOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return rule
+}
+
+func TestAnomalyRuleQueries(t *testing.T) {
+	ts := time.Unix(1680066360, 0)
+	rule := newTestAnomalyRule(t, &AnomalyCondition{Method: AnomalyPercent, Limit: 50, Direction: ValueIsAbove})
+
+	end := rule.prepareQueryRange(ts).End
+	querier := &testQuerier{results: func(params *v3.QueryRangeParamsV3) []*v3.Result {
+		// the first point of the builder queries is skipped
+		if params.End == end {
+			return []*v3.Result{{QueryName: "A", Series: []*v3.Series{
+				testSeries("frontend", 0, 30, 30),
+				testSeries("payment", 0, 11, 11),
+			}}}
+		}
+		return []*v3.Result{{QueryName: "A", Series: []*v3.Series{
+			testSeries("frontend", 0, 10, 10),
+			testSeries("payment", 0, 10, 10),
+		}}}
+	}}
+
+	result, err := rule.runAnomalyQueries(context.Background(), ts, querier)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result) != 1 || result[0].Metric.Get("service_name") != "frontend" || result[0].V != 200 {
+		t.Errorf("expected the frontend series to deviate by 200 percent, got %v", result)
+	}
+
+	if len(querier.params) != 1+len(anomalySeasons) {
+		t.Fatalf("expected %d query ranges, got %d", 1+len(anomalySeasons), len(querier.params))
+	}
+	if querier.params[0].NoCache {
+		t.Errorf("expected the current window to use the cache")
+	}
+	for idx, season := range anomalySeasons {
+		params := querier.params[idx+1]
+		if !params.NoCache {
+			t.Errorf("expected the window %s earlier to skip the cache", season)
+		}
+		if params.End != end-season.Milliseconds() {
+			t.Errorf("expected the window %s earlier to end at %d, got %d", season, end-season.Milliseconds(), params.End)
+		}
+	}
+}
+
+func TestAnomalyDeviation(t *testing.T) {
+	testCases := []struct {
+		name      string
+		method    AnomalyMethod
+		value     float64
+		baseline  []float64
+		deviation float64
+		ok        bool
+	}{
+		{name: "zscore", method: AnomalyZScore, value: 14, baseline: []float64{8, 12}, deviation: 2, ok: true},
+		{name: "zscore below", method: AnomalyZScore, value: 6, baseline: []float64{8, 12}, deviation: -2, ok: true},
+		{name: "zscore constant baseline", method: AnomalyZScore, value: 14, baseline: []float64{10, 10}},
+		{name: "percent", method: AnomalyPercent, value: 15, baseline: []float64{8, 12}, deviation: 50, ok: true},
+		{name: "percent negative mean", method: AnomalyPercent, value: -5, baseline: []float64{-10}, deviation: 50, ok: true},
+		{name: "percent zero mean", method: AnomalyPercent, value: 15, baseline: []float64{-1, 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deviation, ok := anomalyDeviation(tc.method, tc.value, tc.baseline)
+			if ok != tc.ok || math.Abs(deviation-tc.deviation) > 1e-9 {
+				t.Errorf("expected %v (%v), got %v (%v)", tc.deviation, tc.ok, deviation, ok)
+			}
+		})
+	}
+}
+
+func TestAnomalyRuleIsAnomalous(t *testing.T) {
+	testCases := []struct {
+		direction CompareOp
+		deviation float64
+		anomalous bool
+	}{
+		{direction: ValueIsAbove, deviation: 3, anomalous: true},
+		{direction: ValueIsAbove, deviation: -3},
+		{direction: ValueIsBelow, deviation: -3, anomalous: true},
+		{direction: ValueIsBelow, deviation: 3},
+		{deviation: -3, anomalous: true},
+		{deviation: 1},
+	}
+	for _, tc := range testCases {
+		rule := newTestAnomalyRule(t, &AnomalyCondition{Method: AnomalyZScore, Limit: 2, Direction: tc.direction})
+		if got := rule.isAnomalous(tc.deviation); got != tc.anomalous {
+			t.Errorf("expected %v for %v in direction %q, got %v", tc.anomalous, tc.deviation, tc.direction, got)
+		}
+	}
+}
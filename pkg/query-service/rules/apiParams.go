@@ -30,14 +30,19 @@ func newApiErrorBadData(err error) *model.ApiError {
 	return &model.ApiError{Typ: model.ErrorBadData, Err: err}
 }
 
-// PostableRule is used to create alerting rule from HTTP api
+// PostableRule is used to create alerting rule from HTTP api, recording
+// rules are named by the metric they Record instead of the Alert. Rules
+// imported from a Prometheus rule file keep the name of their Group.
 type PostableRule struct {
 	Alert       string   `yaml:"alert,omitempty" json:"alert,omitempty"`
+	Record      string   `yaml:"record,omitempty" json:"record,omitempty"`
 	AlertType   string   `yaml:"alertType,omitempty" json:"alertType,omitempty"`
 	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
 	RuleType    RuleType `yaml:"ruleType,omitempty" json:"ruleType,omitempty"`
 	EvalWindow  Duration `yaml:"evalWindow,omitempty" json:"evalWindow,omitempty"`
 	Frequency   Duration `yaml:"frequency,omitempty" json:"frequency,omitempty"`
+	For         Duration `yaml:"for,omitempty" json:"for,omitempty"`
+	Group       string   `yaml:"group,omitempty" json:"group,omitempty"`
 
 	RuleCondition *RuleCondition    `yaml:"condition,omitempty" json:"condition,omitempty"`
 	Labels        map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
@@ -111,9 +116,23 @@ func parseIntoRule(initRule PostableRule, content []byte, kind string) (*Postabl
 	}
 
 	if rule.RuleCondition != nil {
-		if rule.RuleCondition.CompositeQuery.QueryType == v3.QueryTypeBuilder {
+		switch {
+		case rule.RuleType == RuleTypeRecording:
+			// recording rules work with builder and promql queries
+		case rule.RuleType == RuleTypeAnomaly:
+			// anomaly rules work with any query type
+		case rule.RuleType == RuleTypeSLO:
+			if slo := rule.RuleCondition.SLO; slo != nil {
+				if slo.Window == 0 {
+					slo.Window = defaultSLOWindow
+				}
+				if len(slo.BurnRates) == 0 {
+					slo.BurnRates = append([]BurnRateWindow{}, defaultBurnRates...)
+				}
+			}
+		case rule.RuleCondition.CompositeQuery.QueryType == v3.QueryTypeBuilder:
 			rule.RuleType = RuleTypeThreshold
-		} else if rule.RuleCondition.CompositeQuery.QueryType == v3.QueryTypePromQL {
+		case rule.RuleCondition.CompositeQuery.QueryType == v3.QueryTypePromQL:
 			rule.RuleType = RuleTypeProm
 		}
 
@@ -171,6 +190,28 @@ func (r *PostableRule) Validate() (errs []error) {
 		}
 	}
 
+	if r.RuleType == RuleTypeRecording && r.RuleCondition != nil {
+		if err := validateRecordingRule(r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if r.RuleType == RuleTypeAnomaly && r.RuleCondition != nil {
+		if r.RuleCondition.Anomaly == nil {
+			errs = append(errs, errors.Errorf("rule condition missing the anomaly config"))
+		} else if err := r.RuleCondition.Anomaly.IsValid(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if r.RuleType == RuleTypeSLO && r.RuleCondition != nil {
+		if r.RuleCondition.SLO == nil {
+			errs = append(errs, errors.Errorf("rule condition missing the slo config"))
+		} else if err := r.RuleCondition.SLO.IsValid(r.RuleCondition.CompositeQuery); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	for k, v := range r.Labels {
 		if !isValidLabelName(k) {
 			errs = append(errs, errors.Errorf("invalid label name: %s", k))
@@ -1,11 +1,15 @@
 package rules
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
-	"github.com/jmoiron/sqlx"
-	"go.uber.org/zap"
 	"strconv"
 	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
 )
 
 // Data store to capture user alert rule settings
@@ -24,6 +28,30 @@ type RuleDB interface {
 
 	// GetStoredRule for a given ID from DB
 	GetStoredRule(id string) (*StoredRule, error)
+
+	// AddStateHistory stores the state changes of alerts
+	AddStateHistory(ctx context.Context, changes []StateChange) error
+
+	// GetRuleStateHistory fetches the state changes of the alerts of a rule
+	// in the given time range (unix milli)
+	GetRuleStateHistory(ctx context.Context, ruleId string, start, end int64) ([]StateChange, error)
+
+	// GetFiringAlerts fetches the alerts that were firing at any time in the
+	// given time range (unix milli), resolved at is set for resolved alerts
+	GetFiringAlerts(ctx context.Context, start, end int64) ([]StateChange, error)
+
+	// DeleteStateHistory deletes the state changes of alerts before the given
+	// time (unix milli) and returns the number of deleted changes
+	DeleteStateHistory(ctx context.Context, before int64) (int64, error)
+
+	// SaveAlertState replaces the saved state of the alerts of a rule
+	SaveAlertState(ctx context.Context, ruleId string, alerts []StoredAlert) error
+
+	// GetAlertState fetches the saved state of the alerts of a rule
+	GetAlertState(ctx context.Context, ruleId string) ([]StoredAlert, error)
+
+	// DeleteAlertState deletes the saved state of the alerts of a rule
+	DeleteAlertState(ctx context.Context, ruleId string) error
 }
 
 type StoredRule struct {
@@ -185,3 +213,117 @@ func (r *ruleDB) GetStoredRule(id string) (*StoredRule, error) {
 
 	return rule, nil
 }
+
+func (r *ruleDB) AddStateHistory(ctx context.Context, changes []StateChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	tx, err := r.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareNamedContext(ctx, `INSERT INTO rule_state_history
+		(rule_id, rule_name, fingerprint, state, state_changed_at, labels, value, receivers, active_at, fired_at, resolved_at)
+		VALUES (:rule_id, :rule_name, :fingerprint, :state, :state_changed_at, :labels, :value, :receivers, :active_at, :fired_at, :resolved_at);`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, change := range changes {
+		if _, err := stmt.ExecContext(ctx, change); err != nil {
+			zap.S().Errorf("Error in inserting rule state history: %v", err)
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *ruleDB) GetRuleStateHistory(ctx context.Context, ruleId string, start, end int64) ([]StateChange, error) {
+	changes := []StateChange{}
+
+	query := `SELECT id, rule_id, rule_name, fingerprint, state, state_changed_at, labels, value, receivers, active_at, fired_at, resolved_at
+		FROM rule_state_history
+		WHERE rule_id = $1 AND state_changed_at >= $2 AND state_changed_at <= $3
+		ORDER BY state_changed_at ASC, id ASC`
+
+	if err := r.SelectContext(ctx, &changes, query, ruleId, start, end); err != nil {
+		zap.S().Error("Error in processing sql query: ", err)
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *ruleDB) GetFiringAlerts(ctx context.Context, start, end int64) ([]StateChange, error) {
+	changes := []StateChange{}
+
+	// an alert fired at f.state_changed_at keeps firing until the next
+	// inactive state change with the same rule and fingerprint
+	query := `SELECT * FROM (
+			SELECT f.id, f.rule_id, f.rule_name, f.fingerprint, f.state, f.state_changed_at, f.labels, f.value, f.receivers, f.active_at, f.fired_at,
+				COALESCE((SELECT MIN(r.state_changed_at) FROM rule_state_history r
+					WHERE r.rule_id = f.rule_id AND r.fingerprint = f.fingerprint AND r.state = 'inactive' AND r.state_changed_at >= f.state_changed_at), 0) AS resolved_at
+			FROM rule_state_history f
+			WHERE f.state = 'firing' AND f.state_changed_at <= $1
+		) WHERE resolved_at = 0 OR resolved_at >= $2
+		ORDER BY state_changed_at ASC, id ASC`
+
+	if err := r.SelectContext(ctx, &changes, query, end, start); err != nil {
+		zap.S().Error("Error in processing sql query: ", err)
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *ruleDB) DeleteStateHistory(ctx context.Context, before int64) (int64, error) {
+	// the last change of the alerts that are still pending or firing is
+	// kept, the firing alerts are found from it
+	result, err := r.ExecContext(ctx, `DELETE FROM rule_state_history
+		WHERE state_changed_at < $1 AND id NOT IN (
+			SELECT h.id FROM rule_state_history h
+			WHERE h.state != 'inactive' AND h.id = (SELECT MAX(l.id) FROM rule_state_history l
+				WHERE l.rule_id = h.rule_id AND l.fingerprint = h.fingerprint)
+		)`, before)
+	if err != nil {
+		zap.S().Error("Error in deleting rule state history: ", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *ruleDB) SaveAlertState(ctx context.Context, ruleId string, alerts []StoredAlert) error {
+	data, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.ExecContext(ctx, `INSERT INTO rule_alert_state (rule_id, updated_at, data) VALUES ($1, $2, $3)
+		ON CONFLICT(rule_id) DO UPDATE SET updated_at=excluded.updated_at, data=excluded.data;`, ruleId, time.Now(), string(data))
+	return err
+}
+
+func (r *ruleDB) GetAlertState(ctx context.Context, ruleId string) ([]StoredAlert, error) {
+	var data string
+	err := r.GetContext(ctx, &data, `SELECT data FROM rule_alert_state WHERE rule_id=$1`, ruleId)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []StoredAlert{}
+	if err := json.Unmarshal([]byte(data), &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+func (r *ruleDB) DeleteAlertState(ctx context.Context, ruleId string) error {
+	_, err := r.ExecContext(ctx, `DELETE FROM rule_alert_state WHERE rule_id=$1`, ruleId)
+	return err
+}
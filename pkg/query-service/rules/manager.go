@@ -20,6 +20,7 @@ import (
 	"github.com/pkg/errors"
 
 	// opentracing "github.com/opentracing/opentracing-go"
+	"go.signoz.io/signoz/pkg/query-service/constants"
 	am "go.signoz.io/signoz/pkg/query-service/integrations/alertManager"
 	"go.signoz.io/signoz/pkg/query-service/interfaces"
 	"go.signoz.io/signoz/pkg/query-service/model"
@@ -80,6 +81,13 @@ type Manager struct {
 	// pause all rule tasks
 	pause  bool
 	logger log.Logger
+	// done stops the state history pruning
+	done chan struct{}
+
+	// key of the alerts last saved for each rule, the alerts are saved
+	// only when they change
+	savedAlertsMtx sync.Mutex
+	savedAlerts    map[string]string
 
 	featureFlags interfaces.FeatureLookup
 }
@@ -120,6 +128,8 @@ func NewManager(o *ManagerOptions) (*Manager, error) {
 		ruleDB:       db,
 		opts:         o,
 		block:        make(chan struct{}),
+		done:         make(chan struct{}),
+		savedAlerts:  map[string]string{},
 		logger:       o.Logger,
 		featureFlags: o.FeatureFlags,
 	}
@@ -205,6 +215,36 @@ func (m *Manager) run() {
 
 	// initiate blocked tasks
 	close(m.block)
+
+	go m.pruneStateHistory()
+}
+
+// stateHistoryPruneInterval is how often the state changes older than the
+// retention are deleted
+const stateHistoryPruneInterval = time.Hour
+
+// pruneStateHistory deletes the state changes older than the retention
+// until the manager is stopped
+func (m *Manager) pruneStateHistory() {
+	ticker := time.NewTicker(stateHistoryPruneInterval)
+	defer ticker.Stop()
+
+	retention := time.Duration(constants.RuleStateHistoryRetentionDays) * 24 * time.Hour
+	for {
+		before := time.Now().Add(-retention).UnixMilli()
+		deleted, err := m.ruleDB.DeleteStateHistory(m.opts.Context, before)
+		if err != nil {
+			zap.S().Errorf("msg: failed to prune the rule state history", "\t err:", err)
+		} else if deleted > 0 {
+			zap.S().Info("msg: pruned the rule state history", "\t deleted:", deleted)
+		}
+
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 // Stop the rule manager's rule evaluation cycles.
@@ -217,6 +257,7 @@ func (m *Manager) Stop() {
 	for _, t := range m.tasks {
 		t.Stop()
 	}
+	close(m.done)
 
 	zap.S().Info("msg: ", "Rule manager stopped")
 }
@@ -357,6 +398,10 @@ func (m *Manager) deleteTask(taskName string) {
 		oldg.Stop()
 		delete(m.tasks, taskName)
 		delete(m.rules, ruleIdFromTaskName(taskName))
+		if err := m.ruleDB.DeleteAlertState(m.opts.Context, ruleIdFromTaskName(taskName)); err != nil {
+			zap.S().Errorf("msg:", "failed to delete the saved state of the alerts", "\t task name:", taskName, "\t err:", err)
+		}
+		m.deleteSavedAlertsKey(ruleIdFromTaskName(taskName))
 		zap.S().Debugf("msg:", "rule task deleted", "\t task name:", taskName)
 	} else {
 		zap.S().Info("msg: ", "rule not found for deletion", "\t name:", taskName)
@@ -474,6 +519,8 @@ func (m *Manager) addTask(rule *PostableRule, taskName string) error {
 		return fmt.Errorf("a rule with the same name already exists")
 	}
 
+	m.restoreState(newTask)
+
 	go func() {
 		// Wait with starting evaluation until the rule manager
 		// is told to run. This is necessary to avoid running
@@ -497,7 +544,7 @@ func (m *Manager) prepareTask(acquireLock bool, r *PostableRule, taskName string
 	rules := make([]Rule, 0)
 	var task Task
 
-	if r.Alert == "" {
+	if r.Alert == "" && r.Record == "" {
 		zap.S().Errorf("msg:", "task load failed, at least one rule must be set", "\t task name:", taskName)
 		return task, fmt.Errorf("task load failed, at least one rule must be set")
 	}
@@ -518,7 +565,7 @@ func (m *Manager) prepareTask(acquireLock bool, r *PostableRule, taskName string
 		rules = append(rules, tr)
 
 		// create ch rule task for evalution
-		task = newTask(TaskTypeCh, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc())
+		task = newTask(TaskTypeCh, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc(), m.prepareStateHistoryFunc(), m.prepareSaveStateFunc())
 
 		// add rule to memory
 		m.rules[ruleId] = tr
@@ -540,13 +587,68 @@ func (m *Manager) prepareTask(acquireLock bool, r *PostableRule, taskName string
 		rules = append(rules, pr)
 
 		// create promql rule task for evalution
-		task = newTask(TaskTypeProm, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc())
+		task = newTask(TaskTypeProm, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc(), m.prepareStateHistoryFunc(), m.prepareSaveStateFunc())
 
 		// add rule to memory
 		m.rules[ruleId] = pr
 
+	} else if r.RuleType == RuleTypeAnomaly {
+		// create an anomaly rule
+		ar, err := NewAnomalyRule(
+			ruleId,
+			r,
+			ThresholdRuleOpts{},
+		)
+
+		if err != nil {
+			return task, err
+		}
+
+		rules = append(rules, ar)
+
+		// anomaly rules are evaluated with the ch rule tasks
+		task = newTask(TaskTypeCh, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc(), m.prepareStateHistoryFunc(), m.prepareSaveStateFunc())
+
+		// add rule to memory
+		m.rules[ruleId] = ar
+
+	} else if r.RuleType == RuleTypeSLO {
+		// create an slo rule
+		sr, err := NewSLORule(
+			ruleId,
+			r,
+			ThresholdRuleOpts{},
+		)
+
+		if err != nil {
+			return task, err
+		}
+
+		rules = append(rules, sr)
+
+		// slo rules are evaluated with the ch rule tasks
+		task = newTask(TaskTypeCh, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc(), m.prepareStateHistoryFunc(), m.prepareSaveStateFunc())
+
+		// add rule to memory
+		m.rules[ruleId] = sr
+
+	} else if r.RuleType == RuleTypeRecording {
+		// create a recording rule
+		rr, err := NewRecordingRule(ruleId, r)
+		if err != nil {
+			return task, err
+		}
+
+		rules = append(rules, rr)
+
+		// recording rules are evaluated with the ch rule tasks
+		task = newTask(TaskTypeCh, taskName, taskNamesuffix, time.Duration(r.Frequency), rules, m.opts, m.prepareNotifyFunc(), m.prepareStateHistoryFunc(), m.prepareSaveStateFunc())
+
+		// add rule to memory
+		m.rules[ruleId] = rr
+
 	} else {
-		return nil, fmt.Errorf(fmt.Sprintf("unsupported rule type. Supported types: %s, %s", RuleTypeProm, RuleTypeThreshold))
+		return nil, fmt.Errorf(fmt.Sprintf("unsupported rule type. Supported types: %s, %s, %s, %s, %s", RuleTypeProm, RuleTypeThreshold, RuleTypeAnomaly, RuleTypeSLO, RuleTypeRecording))
 	}
 
 	return task, nil
@@ -654,6 +756,75 @@ func (m *Manager) prepareNotifyFunc() NotifyFunc {
 	}
 }
 
+// prepareStateHistoryFunc implements the StateHistoryFunc for the rule db
+func (m *Manager) prepareStateHistoryFunc() StateHistoryFunc {
+	return func(ctx context.Context, changes ...StateChange) {
+		if err := m.ruleDB.AddStateHistory(ctx, changes); err != nil {
+			zap.S().Errorf("msg: failed to store the alert state changes", "\t rule:", changes[0].RuleId, "\t err:", err)
+		}
+	}
+}
+
+// prepareSaveStateFunc implements the SaveStateFunc for the rule db, the
+// alerts are saved when they differ from the last saved alerts
+func (m *Manager) prepareSaveStateFunc() SaveStateFunc {
+	return func(ctx context.Context, rule Rule) {
+		sr, ok := rule.(statefulRule)
+		if !ok {
+			return
+		}
+		alerts := sr.storedAlerts()
+		key := storedAlertsKey(alerts)
+		if saved, ok := m.savedAlertsKey(rule.ID()); ok && saved == key {
+			return
+		}
+		if err := m.ruleDB.SaveAlertState(ctx, rule.ID(), alerts); err != nil {
+			zap.S().Errorf("msg: failed to save the state of the alerts", "\t rule:", rule.ID(), "\t err:", err)
+			return
+		}
+		m.setSavedAlertsKey(rule.ID(), key)
+	}
+}
+
+func (m *Manager) savedAlertsKey(ruleId string) (string, bool) {
+	m.savedAlertsMtx.Lock()
+	defer m.savedAlertsMtx.Unlock()
+	key, ok := m.savedAlerts[ruleId]
+	return key, ok
+}
+
+func (m *Manager) setSavedAlertsKey(ruleId, key string) {
+	m.savedAlertsMtx.Lock()
+	defer m.savedAlertsMtx.Unlock()
+	m.savedAlerts[ruleId] = key
+}
+
+func (m *Manager) deleteSavedAlertsKey(ruleId string) {
+	m.savedAlertsMtx.Lock()
+	defer m.savedAlertsMtx.Unlock()
+	delete(m.savedAlerts, ruleId)
+}
+
+// restoreState loads the saved state of the alerts of the task rules, so
+// that alerts pending or firing before a restart continue where they were
+func (m *Manager) restoreState(task Task) {
+	for _, rule := range task.Rules() {
+		sr, ok := rule.(statefulRule)
+		if !ok {
+			continue
+		}
+		alerts, err := m.ruleDB.GetAlertState(m.opts.Context, rule.ID())
+		if err != nil {
+			zap.S().Errorf("msg: failed to get the saved state of the alerts", "\t rule:", rule.ID(), "\t err:", err)
+			continue
+		}
+		sr.restoreAlerts(alerts)
+		if alerts != nil {
+			m.setSavedAlertsKey(rule.ID(), storedAlertsKey(alerts))
+		}
+	}
+}
+
 func (m *Manager) ListActiveRules() ([]Rule, error) {
 	ruleList := []Rule{}
 
@@ -711,6 +882,63 @@ func (m *Manager) GetRule(id string) (*GettableRule, error) {
 	return r, nil
 }
 
+// GetRuleStateTimeline returns the state changes of the alerts of a rule
+// in the given time range (unix milli)
+func (m *Manager) GetRuleStateTimeline(ctx context.Context, ruleId string, start, end int64) (*RuleStateTimeline, error) {
+	changes, err := m.ruleDB.GetRuleStateHistory(ctx, ruleId, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := &RuleStateTimeline{
+		RuleId: ruleId,
+		Start:  start,
+		End:    end,
+		Items:  changes,
+		Total:  len(changes),
+	}
+	for _, c := range changes {
+		if c.State == StateFiring.String() {
+			timeline.FiredCount++
+		}
+	}
+	return timeline, nil
+}
+
+// GetFiringAlerts returns the alerts of all rules that were firing at any
+// time in the given time range (unix milli)
+func (m *Manager) GetFiringAlerts(ctx context.Context, start, end int64) ([]StateChange, error) {
+	return m.ruleDB.GetFiringAlerts(ctx, start, end)
+}
+
+// GetSLOErrorBudget returns the error budget of an slo rule for the
+// compliance window ending now, disabled rules are included
+func (m *Manager) GetSLOErrorBudget(ctx context.Context, ruleId string) (*SLOErrorBudget, *model.ApiError) {
+	s, err := m.ruleDB.GetStoredRule(ruleId)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+
+	parsedRule, errs := ParsePostableRule([]byte(s.Data))
+	if len(errs) > 0 {
+		return nil, newApiErrorInternal(errs[0])
+	}
+	if parsedRule.RuleType != RuleTypeSLO {
+		return nil, newApiErrorBadData(fmt.Errorf("rule %s is not an slo rule", ruleId))
+	}
+
+	rule, err := NewSLORule(ruleId, parsedRule, ThresholdRuleOpts{})
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+
+	budget, err := rule.ErrorBudget(ctx, time.Now().UTC(), m.opts.Queriers.Querier)
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+	return budget, nil
+}
+
 // syncRuleStateWithTask ensures that the state of a stored rule matches
 // the task state. For example - if a stored rule is disabled, then
 // there is no task running against it.
@@ -879,6 +1107,55 @@ func (m *Manager) TestNotification(ctx context.Context, ruleStr string) (int, *m
 			zap.S().Errorf("msg: failed to prepare a new promql rule for test:", "\t error: ", err)
 			return 0, newApiErrorBadData(err)
 		}
+	} else if parsedRule.RuleType == RuleTypeAnomaly {
+
+		// add special labels for test alerts
+		anomaly := parsedRule.RuleCondition.Anomaly
+		parsedRule.Labels[labels.AlertAdditionalInfoLabel] = fmt.Sprintf("The rule alerts when the %s deviation is beyond %.4f, and the observed deviation is {{$value}}.", anomaly.Method, anomaly.Limit)
+		parsedRule.Annotations[labels.AlertSummaryLabel] = fmt.Sprintf("The rule alerts when the %s deviation is beyond %.4f, and the observed deviation is {{$value}}.", anomaly.Method, anomaly.Limit)
+		parsedRule.Labels[labels.RuleSourceLabel] = ""
+		parsedRule.Labels[labels.AlertRuleIdLabel] = ""
+
+		// create an anomaly rule
+		rule, err = NewAnomalyRule(
+			alertname,
+			parsedRule,
+			ThresholdRuleOpts{
+				SendUnmatched: true,
+				SendAlways:    true,
+			},
+		)
+
+		if err != nil {
+			zap.S().Errorf("msg: failed to prepare a new anomaly rule for test:", "\t error: ", err)
+			return 0, newApiErrorBadData(err)
+		}
+
+	} else if parsedRule.RuleType == RuleTypeSLO {
+
+		// add special labels for test alerts
+		parsedRule.Labels[labels.AlertAdditionalInfoLabel] = fmt.Sprintf("The slo target is set to %.4f, and the observed burn rate is {{$value}}.", parsedRule.RuleCondition.SLO.Target)
+		parsedRule.Annotations[labels.AlertSummaryLabel] = fmt.Sprintf("The slo target is set to %.4f, and the observed burn rate is {{$value}}.", parsedRule.RuleCondition.SLO.Target)
+		parsedRule.Labels[labels.RuleSourceLabel] = ""
+		parsedRule.Labels[labels.AlertRuleIdLabel] = ""
+
+		// create an slo rule
+		rule, err = NewSLORule(
+			alertname,
+			parsedRule,
+			ThresholdRuleOpts{
+				SendUnmatched: true,
+				SendAlways:    true,
+			},
+		)
+
+		if err != nil {
+			zap.S().Errorf("msg: failed to prepare a new slo rule for test:", "\t error: ", err)
+			return 0, newApiErrorBadData(err)
+		}
+
+	} else if parsedRule.RuleType == RuleTypeRecording {
+		return 0, newApiErrorBadData(fmt.Errorf("recording rules do not send notifications"))
 	} else {
 		return 0, newApiErrorBadData(fmt.Errorf("failed to derive ruletype with given information"))
 	}
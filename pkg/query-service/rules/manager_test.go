@@ -0,0 +1,30 @@
+package rules
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"go.signoz.io/signoz/pkg/query-service/app/dashboards"
+)
+
+func newTestRuleDB(t *testing.T) RuleDB {
+	db, err := dashboards.InitDB(filepath.Join(t.TempDir(), "signoz.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return newRuleDB(db)
+}
+
+func newTestManager(db RuleDB) *Manager {
+	return &Manager{
+		tasks:       map[string]Task{},
+		rules:       map[string]Rule{},
+		ruleDB:      db,
+		opts:        &ManagerOptions{Context: context.Background()},
+		savedAlerts: map[string]string{},
+	}
+}
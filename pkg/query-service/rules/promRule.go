@@ -49,6 +49,9 @@ type PromRule struct {
 	// map of active alerts
 	active map[uint64]*Alert
 
+	// state changes of the alerts not yet collected
+	stateChanges []StateChange
+
 	logger log.Logger
 	opts   PromRuleOpts
 }
@@ -72,6 +75,7 @@ func NewPromRule(
 		source:            postableRule.Source,
 		ruleCondition:     postableRule.RuleCondition,
 		evalWindow:        time.Duration(postableRule.EvalWindow),
+		holdDuration:      time.Duration(postableRule.For),
 		labels:            plabels.FromMap(postableRule.Labels),
 		annotations:       plabels.FromMap(postableRule.Annotations),
 		preferredChannels: postableRule.PreferredChannels,
@@ -255,6 +259,15 @@ func (r *PromRule) ActiveAlerts() []*Alert {
 	return res
 }
 
+// StateChanges returns the state changes of the alerts since the last call
+func (r *PromRule) StateChanges() []StateChange {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	changes := r.stateChanges
+	r.stateChanges = nil
+	return changes
+}
+
 // ForEachActiveAlert runs the given function on each alert.
 // This should be used when you want to use the actual alerts from the ThresholdRule
 // and not on its copy.
@@ -409,6 +422,7 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 		}
 
 		r.active[h] = a
+		r.stateChanges = append(r.stateChanges, newStateChange(r.ID(), r.Name(), a, ts))
 
 	}
 
@@ -423,6 +437,7 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 			if a.State != StateInactive {
 				a.State = StateInactive
 				a.ResolvedAt = ts
+				r.stateChanges = append(r.stateChanges, newStateChange(r.ID(), r.Name(), a, ts))
 			}
 			continue
 		}
@@ -430,6 +445,7 @@ func (r *PromRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (
 		if a.State == StatePending && ts.Sub(a.ActiveAt) >= r.holdDuration {
 			a.State = StateFiring
 			a.FiredAt = ts
+			r.stateChanges = append(r.stateChanges, newStateChange(r.ID(), r.Name(), a, ts))
 		}
 
 	}
@@ -445,6 +461,7 @@ func (r *PromRule) String() string {
 		Alert:             r.name,
 		RuleCondition:     r.ruleCondition,
 		EvalWindow:        Duration(r.evalWindow),
+		For:               Duration(r.holdDuration),
 		Labels:            r.labels.Map(),
 		Annotations:       r.annotations.Map(),
 		PreferredChannels: r.preferredChannels,
@@ -0,0 +1,281 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	promModel "github.com/prometheus/common/model"
+	promParser "github.com/prometheus/prometheus/promql/parser"
+	"go.uber.org/zap"
+	yaml "gopkg.in/yaml.v2"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+// metricBasedAlert is the alert type the frontend shows promql rules with
+const metricBasedAlert = "METRIC_BASED_ALERT"
+
+// promRuleGroups is the content of a Prometheus rule file
+type promRuleGroups struct {
+	Groups []promRuleGroup `yaml:"groups"`
+}
+
+type promRuleGroup struct {
+	Name     string             `yaml:"name"`
+	Interval promModel.Duration `yaml:"interval,omitempty"`
+	Rules    []promRuleDef      `yaml:"rules"`
+}
+
+// promRuleDef is an alerting or a recording rule of a Prometheus rule group
+type promRuleDef struct {
+	Record      string             `yaml:"record,omitempty"`
+	Alert       string             `yaml:"alert,omitempty"`
+	Expr        string             `yaml:"expr"`
+	For         promModel.Duration `yaml:"for,omitempty"`
+	Labels      map[string]string  `yaml:"labels,omitempty"`
+	Annotations map[string]string  `yaml:"annotations,omitempty"`
+}
+
+func (rd *promRuleDef) name() string {
+	if rd.Alert != "" {
+		return rd.Alert
+	}
+	return rd.Record
+}
+
+// RuleImportStatus is the outcome of importing a rule
+type RuleImportStatus string
+
+const (
+	RuleImportCreated RuleImportStatus = "created"
+	RuleImportUpdated RuleImportStatus = "updated"
+	RuleImportFailed  RuleImportStatus = "failed"
+)
+
+// ImportedRule reports the outcome of importing a rule of a rule group
+type ImportedRule struct {
+	Group  string           `json:"group"`
+	Name   string           `json:"name"`
+	Status RuleImportStatus `json:"status"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// RuleImportResult reports the outcome of importing each rule of a
+// Prometheus rule file
+type RuleImportResult struct {
+	Created int            `json:"created"`
+	Updated int            `json:"updated"`
+	Failed  int            `json:"failed"`
+	Rules   []ImportedRule `json:"rules"`
+}
+
+func (res *RuleImportResult) add(group, name string, status RuleImportStatus, err error) {
+	imported := ImportedRule{Group: group, Name: name, Status: status}
+	switch status {
+	case RuleImportCreated:
+		res.Created++
+	case RuleImportUpdated:
+		res.Updated++
+	case RuleImportFailed:
+		res.Failed++
+		imported.Error = err.Error()
+	}
+	res.Rules = append(res.Rules, imported)
+}
+
+// importKey identifies an imported rule by its group and name
+func importKey(group, name string) string {
+	return fmt.Sprintf("%s/%s", group, name)
+}
+
+// toPostableRule converts the rule into a promql rule, alerting rules fire
+// for every series the expression returns like they do in Prometheus
+func (rd *promRuleDef) toPostableRule(group *promRuleGroup) (*PostableRule, error) {
+	if rd.Alert != "" && rd.Record != "" {
+		return nil, fmt.Errorf("only one of alert and record can be set")
+	}
+	if rd.Alert == "" && rd.Record == "" {
+		return nil, fmt.Errorf("one of alert or record must be set")
+	}
+	if rd.Expr == "" {
+		return nil, fmt.Errorf("expr is required")
+	}
+	if _, err := promParser.ParseExpr(rd.Expr); err != nil {
+		return nil, fmt.Errorf("invalid expr: %v", err)
+	}
+
+	rule := &PostableRule{
+		Alert:       rd.Alert,
+		Record:      rd.Record,
+		Group:       group.Name,
+		RuleType:    RuleTypeProm,
+		EvalWindow:  Duration(5 * time.Minute),
+		Frequency:   Duration(group.Interval),
+		Labels:      rd.Labels,
+		Annotations: rd.Annotations,
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypePromQL,
+				PanelType: v3.PanelTypeGraph,
+				PromQueries: map[string]*v3.PromQuery{
+					"A": {
+						Query: rd.Expr,
+					},
+				},
+			},
+		},
+	}
+	if rule.Frequency == 0 {
+		rule.Frequency = Duration(time.Minute)
+	}
+	if rd.Record != "" {
+		if rd.For != 0 || len(rd.Annotations) > 0 {
+			return nil, fmt.Errorf("for and annotations are not allowed in recording rules")
+		}
+		rule.RuleType = RuleTypeRecording
+	} else {
+		rule.AlertType = metricBasedAlert
+		rule.For = Duration(rd.For)
+	}
+	return rule, nil
+}
+
+// importedRuleIds returns the ids of the stored rules imported from rule
+// groups by their import key
+func (m *Manager) importedRuleIds() (map[string]int, map[int]*PostableRule, error) {
+	storedRules, err := m.ruleDB.GetStoredRules()
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make(map[string]int)
+	rules := make(map[int]*PostableRule)
+	for _, s := range storedRules {
+		rule := &PostableRule{}
+		if err := json.Unmarshal([]byte(s.Data), rule); err != nil || rule.Group == "" {
+			continue
+		}
+		name := rule.Alert
+		if name == "" {
+			name = rule.Record
+		}
+		ids[importKey(rule.Group, name)] = s.Id
+		rules[s.Id] = rule
+	}
+	return ids, rules, nil
+}
+
+// ImportPromRules creates or updates a rule for every rule of the groups of
+// a Prometheus rule file. The rules are matched with the stored rules by
+// group and name so importing the same file again updates the rules. The
+// failures are reported per rule, the rest of the rules are imported.
+func (m *Manager) ImportPromRules(content []byte) (*RuleImportResult, *model.ApiError) {
+	var file promRuleGroups
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, newApiErrorBadData(fmt.Errorf("failed to load rule groups: %v", err))
+	}
+	if len(file.Groups) == 0 {
+		return nil, newApiErrorBadData(fmt.Errorf("no rule groups found"))
+	}
+
+	ids, storedRules, err := m.importedRuleIds()
+	if err != nil {
+		return nil, newApiErrorInternal(err)
+	}
+
+	result := &RuleImportResult{Rules: []ImportedRule{}}
+	seen := make(map[string]bool)
+	for gi := range file.Groups {
+		group := &file.Groups[gi]
+		for ri := range group.Rules {
+			rd := &group.Rules[ri]
+			name := rd.name()
+			if group.Name == "" {
+				result.add(group.Name, name, RuleImportFailed, fmt.Errorf("rule group name is required"))
+				continue
+			}
+			key := importKey(group.Name, name)
+			if name != "" && seen[key] {
+				result.add(group.Name, name, RuleImportFailed, fmt.Errorf("duplicate rule %s in group %s", name, group.Name))
+				continue
+			}
+			seen[key] = true
+
+			rule, err := rd.toPostableRule(group)
+			if err != nil {
+				result.add(group.Name, name, RuleImportFailed, err)
+				continue
+			}
+
+			id, exists := ids[key]
+			if exists {
+				// the state changed in the app is kept on re-import
+				rule.Disabled = storedRules[id].Disabled
+				rule.PreferredChannels = storedRules[id].PreferredChannels
+			}
+			ruleJSON, err := json.Marshal(rule)
+			if err != nil {
+				result.add(group.Name, name, RuleImportFailed, err)
+				continue
+			}
+
+			if exists {
+				err = m.EditRule(string(ruleJSON), fmt.Sprintf("%d", id))
+				if err == nil {
+					result.add(group.Name, name, RuleImportUpdated, nil)
+				}
+			} else {
+				err = m.CreateRule(string(ruleJSON))
+				if err == nil {
+					result.add(group.Name, name, RuleImportCreated, nil)
+				}
+			}
+			if err != nil {
+				zap.S().Errorf("msg: failed to import rule", "\t group:", group.Name, "\t rule:", name, "\t error:", err)
+				result.add(group.Name, name, RuleImportFailed, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ImportPromRulesDir imports the Prometheus rule files (.yml and .yaml) of
+// the directory, the failures of the files and rules are logged
+func (m *Manager) ImportPromRulesDir(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("failed to read the rules directory: %v", err)
+	}
+	var files []string
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			zap.S().Errorf("msg: failed to read rule file", "\t file:", file, "\t error:", err)
+			continue
+		}
+		result, apiErr := m.ImportPromRules(content)
+		if apiErr != nil {
+			zap.S().Errorf("msg: failed to import rule file", "\t file:", file, "\t error:", apiErr.Err)
+			continue
+		}
+		for _, r := range result.Rules {
+			if r.Status == RuleImportFailed {
+				zap.S().Errorf("msg: failed to import rule", "\t file:", file, "\t group:", r.Group, "\t rule:", r.Name, "\t error:", r.Error)
+			}
+		}
+		zap.S().Info("msg: imported rule file", "\t file:", file, "\t created:", result.Created, "\t updated:", result.Updated, "\t failed:", result.Failed)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	promModel "github.com/prometheus/common/model"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func TestToPostableRule(t *testing.T) {
+	group := &promRuleGroup{Name: "api", Interval: promModel.Duration(30 * time.Second)}
+	testCases := []struct {
+		name     string
+		group    *promRuleGroup
+		rule     promRuleDef
+		expected *PostableRule
+		err      bool
+	}{
+		{
+			name:  "alert",
+			group: group,
+			rule: promRuleDef{
+				Alert:       "HighErrorRate",
+				Expr:        `sum(rate(http_requests_total{code="500"}[5m])) > 1`,
+				For:         promModel.Duration(10 * time.Minute),
+				Labels:      map[string]string{"severity": "critical"},
+				Annotations: map[string]string{"summary": "error rate is {{ $value }}"},
+			},
+			expected: &PostableRule{
+				Alert:       "HighErrorRate",
+				AlertType:   metricBasedAlert,
+				Group:       "api",
+				RuleType:    RuleTypeProm,
+				EvalWindow:  Duration(5 * time.Minute),
+				Frequency:   Duration(30 * time.Second),
+				For:         Duration(10 * time.Minute),
+				Labels:      map[string]string{"severity": "critical"},
+				Annotations: map[string]string{"summary": "error rate is {{ $value }}"},
+			},
+		},
+		{
+			name:  "record",
+			group: &promRuleGroup{Name: "api"},
+			rule: promRuleDef{
+				Record: "job:http_requests:rate5m",
+				Expr:   "sum by (job) (rate(http_requests_total[5m]))",
+				Labels: map[string]string{"team": "web"},
+			},
+			expected: &PostableRule{
+				Record:     "job:http_requests:rate5m",
+				Group:      "api",
+				RuleType:   RuleTypeRecording,
+				EvalWindow: Duration(5 * time.Minute),
+				// the default interval of the groups
+				Frequency: Duration(time.Minute),
+				Labels:    map[string]string{"team": "web"},
+			},
+		},
+		{
+			name:  "record with for",
+			group: group,
+			rule:  promRuleDef{Record: "job:up", Expr: "up", For: promModel.Duration(time.Minute)},
+			err:   true,
+		},
+		{
+			name:  "record with annotations",
+			group: group,
+			rule:  promRuleDef{Record: "job:up", Expr: "up", Annotations: map[string]string{"summary": "up"}},
+			err:   true,
+		},
+		{
+			name:  "alert and record",
+			group: group,
+			rule:  promRuleDef{Alert: "Down", Record: "job:up", Expr: "up"},
+			err:   true,
+		},
+		{
+			name:  "neither alert nor record",
+			group: group,
+			rule:  promRuleDef{Expr: "up"},
+			err:   true,
+		},
+		{
+			name:  "no expr",
+			group: group,
+			rule:  promRuleDef{Alert: "Down"},
+			err:   true,
+		},
+		{
+			name:  "invalid expr",
+			group: group,
+			rule:  promRuleDef{Alert: "Down", Expr: "sum(up"},
+			err:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := tc.rule.toPostableRule(tc.group)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tc.expected.RuleCondition = &RuleCondition{
+				CompositeQuery: &v3.CompositeQuery{
+					QueryType:   v3.QueryTypePromQL,
+					PanelType:   v3.PanelTypeGraph,
+					PromQueries: map[string]*v3.PromQuery{"A": {Query: tc.rule.Expr}},
+				},
+			}
+			if !reflect.DeepEqual(rule, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, rule)
+			}
+		})
+	}
+}
+
+const testPromRuleFile = `
+groups:
+  - name: api
+    interval: 30s
+    rules:
+      - alert: HighErrorRate
+        expr: sum(rate(http_requests_total{code="500"}[5m])) > %d
+        for: 5m
+        labels:
+          severity: critical
+      - record: job:http_requests:rate5m
+        expr: sum by (job) (rate(http_requests_total[5m]))
+      - alert: HighErrorRate
+        expr: sum(rate(http_requests_total{code="500"}[5m])) > 10
+  - name: db
+    rules:
+      - alert: HighErrorRate
+        expr: sum(rate(db_errors_total[5m])) > 1
+      - alert: Broken
+        expr: sum(rate(db_errors_total[5m]) > 1
+  - rules:
+      - alert: NoGroup
+        expr: up == 0
+`
+
+func importedStatuses(result *RuleImportResult) []string {
+	statuses := []string{}
+	for _, r := range result.Rules {
+		statuses = append(statuses, fmt.Sprintf("%s/%s:%s", r.Group, r.Name, r.Status))
+	}
+	return statuses
+}
+
+func TestImportPromRules(t *testing.T) {
+	db := newTestRuleDB(t)
+	m := newTestManager(db)
+	m.opts.DisableRules = true
+
+	result, apiErr := m.ImportPromRules([]byte(fmt.Sprintf(testPromRuleFile, 1)))
+	if apiErr != nil {
+		t.Fatalf("unexpected error: %v", apiErr.Err)
+	}
+	expected := []string{
+		"api/HighErrorRate:created",
+		"api/job:http_requests:rate5m:created",
+		// the same name in the same group
+		"api/HighErrorRate:failed",
+		// the same name in another group
+		"db/HighErrorRate:created",
+		"db/Broken:failed",
+		"/NoGroup:failed",
+	}
+	if got := importedStatuses(result); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if result.Created != 3 || result.Updated != 0 || result.Failed != 3 {
+		t.Errorf("expected 3 created and 3 failed rules, got %+v", result)
+	}
+
+	// the rule disabled in the app stays disabled when imported again
+	ids, storedRules, err := m.importedRuleIds()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	id := ids[importKey("api", "HighErrorRate")]
+	disabled := *storedRules[id]
+	disabled.Disabled = true
+	ruleJSON, _ := json.Marshal(disabled)
+	if err := m.EditRule(string(ruleJSON), fmt.Sprintf("%d", id)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, apiErr = m.ImportPromRules([]byte(fmt.Sprintf(testPromRuleFile, 5)))
+	if apiErr != nil {
+		t.Fatalf("unexpected error: %v", apiErr.Err)
+	}
+	if result.Created != 0 || result.Updated != 3 || result.Failed != 3 {
+		t.Errorf("expected 3 updated and 3 failed rules, got %+v", result)
+	}
+
+	stored, err := db.GetStoredRules()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stored) != 3 {
+		t.Errorf("expected 3 stored rules, got %d", len(stored))
+	}
+	_, storedRules, err = m.importedRuleIds()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	updated := storedRules[id]
+	if !updated.Disabled || updated.RuleCondition.CompositeQuery.PromQueries["A"].Query != `sum(rate(http_requests_total{code="500"}[5m])) > 5` {
+		t.Errorf("expected the disabled rule to be updated, got %+v", updated)
+	}
+}
+
+func TestImportPromRulesErrors(t *testing.T) {
+	m := newTestManager(newTestRuleDB(t))
+	m.opts.DisableRules = true
+
+	for _, content := range []string{"groups: [", "groups: []"} {
+		if _, apiErr := m.ImportPromRules([]byte(content)); apiErr == nil {
+			t.Errorf("expected an error for %q", content)
+		}
+	}
+}
@@ -32,14 +32,17 @@ type PromRuleTask struct {
 	terminated  chan struct{}
 	managerDone chan struct{}
 
-	pause  bool
-	logger log.Logger
-	notify NotifyFunc
+	pause   bool
+	logger  log.Logger
+	notify  NotifyFunc
+	history StateHistoryFunc
+	// saveState saves the alerts of the rules when they change
+	saveState SaveStateFunc
 }
 
 // newPromRuleTask holds rules that have promql condition
 // and evalutes the rule at a given frequency
-func newPromRuleTask(name, file string, frequency time.Duration, rules []Rule, opts *ManagerOptions, notify NotifyFunc) *PromRuleTask {
+func newPromRuleTask(name, file string, frequency time.Duration, rules []Rule, opts *ManagerOptions, notify NotifyFunc, history StateHistoryFunc, saveState SaveStateFunc) *PromRuleTask {
 	zap.S().Info("Initiating a new rule group:", name, "\t frequency:", frequency)
 
 	if time.Now() == time.Now().Add(frequency) {
@@ -57,6 +60,8 @@ func newPromRuleTask(name, file string, frequency time.Duration, rules []Rule, o
 		done:                 make(chan struct{}),
 		terminated:           make(chan struct{}),
 		notify:               notify,
+		history:              history,
+		saveState:            saveState,
 		logger:               log.With(opts.Logger, "group", name),
 	}
 }
@@ -282,11 +287,11 @@ func (g *PromRuleTask) CopyState(fromTask Task) error {
 		g.seriesInPreviousEval[i] = from.seriesInPreviousEval[fi]
 		ruleMap[nameAndLabels] = indexes[1:]
 
-		ar, ok := rule.(*ThresholdRule)
+		ar, ok := rule.(*PromRule)
 		if !ok {
 			continue
 		}
-		far, ok := from.rules[fi].(*ThresholdRule)
+		far, ok := from.rules[fi].(*PromRule)
 		if !ok {
 			continue
 		}
@@ -349,8 +354,16 @@ func (g *PromRuleTask) Eval(ctx context.Context, ts time.Time) {
 				//}
 				return
 			}
+			if changes := rule.StateChanges(); len(changes) > 0 && g.history != nil {
+				g.history(ctx, changes...)
+			}
+
 			rule.SendAlerts(ctx, ts, g.opts.ResendDelay, g.frequency, g.notify)
 
+			if g.saveState != nil {
+				g.saveState(ctx, rule)
+			}
+
 		}(i, rule)
 	}
 }
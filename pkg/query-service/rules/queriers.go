@@ -2,6 +2,7 @@ package rules
 
 import (
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
 	pqle "go.signoz.io/signoz/pkg/query-service/pqlEngine"
 )
 
@@ -16,6 +17,9 @@ type Queriers struct {
 	// promql engine
 	PqlEngine *pqle.PqlEngine
 
-	// metric querier
+	// metric querier, recording rules also write their series with it
 	Ch clickhouse.Conn
+
+	// v3 querier, used for builder queries when set and by anomaly rules
+	Querier interfaces.Querier
 }
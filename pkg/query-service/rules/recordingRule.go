@@ -0,0 +1,393 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"go.uber.org/zap"
+	yaml "gopkg.in/yaml.v2"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// recordedSeriesRefresh is how often the time series of a recorded series
+// is written again, the samples of the series are written on every
+// evaluation
+const recordedSeriesRefresh = time.Hour
+
+// RecordingRule evaluates the rule query on every tick and writes the
+// series of the target query into the samples and time series tables as
+// the metric named by the rule, with the labels of the rule added. The
+// recorded metrics can then be queried like any other metric.
+type RecordingRule struct {
+	id            string
+	name          string
+	ruleCondition *RuleCondition
+	evalWindow    time.Duration
+	labels        labels.Labels
+
+	mtx                 sync.Mutex
+	evaluationDuration  time.Duration
+	evaluationTimestamp time.Time
+
+	health    RuleHealth
+	lastError error
+
+	// last time the time series of each fingerprint was written
+	seriesWrittenAt map[uint64]time.Time
+}
+
+func NewRecordingRule(id string, p *PostableRule) (*RecordingRule, error) {
+	if p.RuleCondition == nil {
+		return nil, fmt.Errorf("no rule condition")
+	}
+	if err := validateRecordingRule(p); err != nil {
+		return nil, err
+	}
+
+	r := &RecordingRule{
+		id:              id,
+		name:            p.Record,
+		ruleCondition:   p.RuleCondition,
+		evalWindow:      time.Duration(p.EvalWindow),
+		labels:          labels.FromMap(p.Labels),
+		health:          HealthUnknown,
+		seriesWrittenAt: map[uint64]time.Time{},
+	}
+	if r.evalWindow == 0 {
+		r.evalWindow = 5 * time.Minute
+	}
+
+	zap.S().Info("msg:", "creating new recording rule", "\t name:", r.name, "\t condition:", r.ruleCondition.String())
+	return r, nil
+}
+
+// validateRecordingRule checks the metric name and the query of a
+// recording rule, only builder and promql queries can be recorded
+func validateRecordingRule(p *PostableRule) error {
+	if !isValidMetricName(p.Record) {
+		return fmt.Errorf("invalid metric name to record: %q", p.Record)
+	}
+	if p.RuleCondition == nil || p.RuleCondition.CompositeQuery == nil {
+		return fmt.Errorf("composite metric query is required")
+	}
+	switch p.RuleCondition.QueryType() {
+	case v3.QueryTypeBuilder:
+		if len(p.RuleCondition.CompositeQuery.BuilderQueries) == 0 {
+			return fmt.Errorf("builder queries are required")
+		}
+	case v3.QueryTypePromQL:
+		if len(p.RuleCondition.CompositeQuery.PromQueries) == 0 {
+			return fmt.Errorf("promql queries are required")
+		}
+	default:
+		return fmt.Errorf("recording rules support only builder and promql queries")
+	}
+	return nil
+}
+
+func isValidMetricName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i, b := range name {
+		if !((b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b == '_' || b == ':' || (b >= '0' && b <= '9' && i > 0)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *RecordingRule) Name() string {
+	return r.name
+}
+
+func (r *RecordingRule) ID() string {
+	return r.id
+}
+
+func (r *RecordingRule) Type() RuleType {
+	return RuleTypeRecording
+}
+
+func (r *RecordingRule) Condition() *RuleCondition {
+	return r.ruleCondition
+}
+
+// Labels returns the labels added to the recorded series.
+func (r *RecordingRule) Labels() labels.BaseLabels {
+	return r.labels
+}
+
+func (r *RecordingRule) Annotations() labels.BaseLabels {
+	return labels.Labels{}
+}
+
+// State is always inactive as recording rules have no alerts
+func (r *RecordingRule) State() AlertState {
+	return StateInactive
+}
+
+func (r *RecordingRule) ActiveAlerts() []*Alert {
+	return nil
+}
+
+func (r *RecordingRule) StateChanges() []StateChange {
+	return nil
+}
+
+func (r *RecordingRule) PreferredChannels() []string {
+	return nil
+}
+
+func (r *RecordingRule) SendAlerts(ctx context.Context, ts time.Time, resendDelay time.Duration, interval time.Duration, notifyFunc NotifyFunc) {
+}
+
+func (r *RecordingRule) SetLastError(err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.lastError = err
+}
+
+func (r *RecordingRule) LastError() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.lastError
+}
+
+func (r *RecordingRule) SetHealth(health RuleHealth) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.health = health
+}
+
+func (r *RecordingRule) Health() RuleHealth {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.health
+}
+
+func (r *RecordingRule) SetEvaluationDuration(dur time.Duration) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.evaluationDuration = dur
+}
+
+func (r *RecordingRule) GetEvaluationDuration() time.Duration {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.evaluationDuration
+}
+
+func (r *RecordingRule) SetEvaluationTimestamp(ts time.Time) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.evaluationTimestamp = ts
+}
+
+func (r *RecordingRule) GetEvaluationTimestamp() time.Time {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.evaluationTimestamp
+}
+
+func (r *RecordingRule) String() string {
+	ar := PostableRule{
+		Record:        r.name,
+		RuleCondition: r.ruleCondition,
+		EvalWindow:    Duration(r.evalWindow),
+		Labels:        r.labels.Map(),
+	}
+
+	byt, err := yaml.Marshal(ar)
+	if err != nil {
+		return fmt.Sprintf("error marshaling recording rule: %s", err.Error())
+	}
+
+	return string(byt)
+}
+
+// prepareQueryRange prepares the query range for the window ending at ts,
+// delayed the same way as the threshold rule queries to wait for the data
+func (r *RecordingRule) prepareQueryRange(ts time.Time) *v3.QueryRangeParamsV3 {
+	end := ts.UnixMilli() - 2*60*1000
+	end = end - (end % (60 * 1000))
+	start := end - r.evalWindow.Milliseconds()
+
+	// the builder queries are copied so the step of the stored rule
+	// condition is left as it is
+	compositeQuery := *r.ruleCondition.CompositeQuery
+	if compositeQuery.BuilderQueries != nil {
+		compositeQuery.BuilderQueries = make(map[string]*v3.BuilderQuery, len(r.ruleCondition.CompositeQuery.BuilderQueries))
+		for name, q := range r.ruleCondition.CompositeQuery.BuilderQueries {
+			bq := *q
+			bq.StepInterval = 60
+			compositeQuery.BuilderQueries[name] = &bq
+		}
+	}
+
+	return &v3.QueryRangeParamsV3{
+		Start:          start,
+		End:            end,
+		Step:           60,
+		CompositeQuery: &compositeQuery,
+	}
+}
+
+// recordedAt is the timestamp of the points recorded for the query range.
+// The last interval of the builder queries is partial, their last complete
+// interval is recorded, promql queries are evaluated at the end.
+func (r *RecordingRule) recordedAt(params *v3.QueryRangeParamsV3) int64 {
+	if r.ruleCondition.QueryType() == v3.QueryTypeBuilder {
+		return params.End - params.Step*1000
+	}
+	return params.End
+}
+
+func (r *RecordingRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (interface{}, error) {
+	if queriers.Querier == nil {
+		return nil, fmt.Errorf("recording rules need the v3 querier")
+	}
+	if queriers.Ch == nil {
+		return nil, fmt.Errorf("recording rules need the clickhouse connection")
+	}
+
+	params := r.prepareQueryRange(ts)
+	results, err, errQueriesByName := queriers.Querier.QueryRange(ctx, params, map[string]v3.AttributeKey{})
+	if err != nil {
+		zap.S().Errorf("ruleid:", r.ID(), "\t msg: failed to run queries", zap.Error(err), zap.Any("errQueriesByName", errQueriesByName))
+		return nil, fmt.Errorf("failed to run queries: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no queries could be built with the rule config")
+	}
+
+	samples := r.samples(targetResult(results), r.recordedAt(params))
+	if err := r.write(ctx, queriers.Ch, samples, ts); err != nil {
+		return nil, err
+	}
+
+	r.SetHealth(HealthGood)
+	r.SetLastError(nil)
+	return len(samples), nil
+}
+
+// samples picks the point at ts of each series of the result, labelled
+// with the labels of the rule and the recorded metric name
+func (r *RecordingRule) samples(result *v3.Result, ts int64) Vector {
+	var vec Vector
+	for _, series := range result.Series {
+		for _, point := range series.Points {
+			if point.Timestamp != ts || math.IsNaN(point.Value) || math.IsInf(point.Value, 0) {
+				continue
+			}
+			lb := labels.NewBuilder(labels.FromMap(series.Labels))
+			for _, l := range r.labels {
+				lb.Set(l.Name, l.Value)
+			}
+			lb.Set(labels.MetricNameLabel, r.name)
+			vec = append(vec, Sample{
+				Point:  Point{T: point.Timestamp, V: point.Value},
+				Metric: lb.Labels(),
+			})
+			break
+		}
+	}
+	return vec
+}
+
+// write inserts the samples and the time series not written in the last
+// recordedSeriesRefresh
+func (r *RecordingRule) write(ctx context.Context, conn clickhouse.Conn, samples Vector, ts time.Time) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	newSeries := r.seriesToWrite(samples, ts)
+	if len(newSeries) > 0 {
+		if err := r.writeSeries(ctx, conn, newSeries); err != nil {
+			return err
+		}
+	}
+	if err := r.writeSamples(ctx, conn, samples); err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	for _, s := range newSeries {
+		r.seriesWrittenAt[s.Metric.Hash()] = ts
+	}
+	r.mtx.Unlock()
+	return nil
+}
+
+// seriesToWrite returns the samples whose time series was not written in
+// the last recordedSeriesRefresh. The series written before that are
+// forgotten, so the series the rule no longer records do not pile up.
+func (r *RecordingRule) seriesToWrite(samples Vector, ts time.Time) Vector {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for fingerprint, writtenAt := range r.seriesWrittenAt {
+		if ts.Sub(writtenAt) >= recordedSeriesRefresh {
+			delete(r.seriesWrittenAt, fingerprint)
+		}
+	}
+
+	var newSeries Vector
+	for _, s := range samples {
+		if _, ok := r.seriesWrittenAt[s.Metric.Hash()]; !ok {
+			newSeries = append(newSeries, s)
+		}
+	}
+	return newSeries
+}
+
+func (r *RecordingRule) writeSeries(ctx context.Context, conn clickhouse.Conn, series Vector) error {
+	batch, err := conn.PrepareBatch(ctx, fmt.Sprintf("INSERT INTO %s.%s (metric_name, fingerprint, timestamp_ms, labels)", constants.SIGNOZ_METRIC_DBNAME, constants.SIGNOZ_TIMESERIES_TABLENAME))
+	if err != nil {
+		return fmt.Errorf("failed to prepare the time series batch: %v", err)
+	}
+	// releases the connection of the batch when it is not sent
+	defer batch.Abort()
+
+	for _, s := range series {
+		lbls, err := json.Marshal(s.Metric.Map())
+		if err != nil {
+			return err
+		}
+		if err := batch.Append(r.name, s.Metric.Hash(), s.T, string(lbls)); err != nil {
+			return fmt.Errorf("failed to append the time series: %v", err)
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to write the time series: %v", err)
+	}
+	return nil
+}
+
+func (r *RecordingRule) writeSamples(ctx context.Context, conn clickhouse.Conn, samples Vector) error {
+	batch, err := conn.PrepareBatch(ctx, fmt.Sprintf("INSERT INTO %s.%s (metric_name, fingerprint, timestamp_ms, value)", constants.SIGNOZ_METRIC_DBNAME, constants.SIGNOZ_SAMPLES_TABLENAME))
+	if err != nil {
+		return fmt.Errorf("failed to prepare the samples batch: %v", err)
+	}
+	// releases the connection of the batch when it is not sent
+	defer batch.Abort()
+
+	for _, s := range samples {
+		if err := batch.Append(r.name, s.Metric.Hash(), s.T, s.V); err != nil {
+			return fmt.Errorf("failed to append the sample: %v", err)
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("failed to write the samples: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// testConn prepares testBatch batches, the other methods of the
+// connection are not implemented
+type testConn struct {
+	driver.Conn
+	appendErr error
+	batches   []*testBatch
+}
+
+func (c *testConn) PrepareBatch(ctx context.Context, query string) (driver.Batch, error) {
+	batch := &testBatch{appendErr: c.appendErr}
+	c.batches = append(c.batches, batch)
+	return batch, nil
+}
+
+type testBatch struct {
+	driver.Batch
+	appendErr error
+	rows      int
+	sent      bool
+	aborted   bool
+}
+
+func (b *testBatch) Append(v ...interface{}) error {
+	if b.appendErr != nil {
+		return b.appendErr
+	}
+	b.rows++
+	return nil
+}
+
+func (b *testBatch) Send() error {
+	b.sent = true
+	return nil
+}
+
+func (b *testBatch) Abort() error {
+	if !b.sent {
+		b.aborted = true
+	}
+	return nil
+}
+
+func newTestRecordingRule(t *testing.T, queryType v3.QueryType) *RecordingRule {
+	compositeQuery := &v3.CompositeQuery{QueryType: queryType, PanelType: v3.PanelTypeGraph}
+	if queryType == v3.QueryTypeBuilder {
+		compositeQuery.BuilderQueries = map[string]*v3.BuilderQuery{"A": testBuilderQuery("A")}
+	} else {
+		compositeQuery.PromQueries = map[string]*v3.PromQuery{"A": {Query: "sum(rate(http_server_requests_count[5m]))"}}
+	}
+	rule, err := NewRecordingRule("1", &PostableRule{
+		Record:        "job:http_requests:rate5m",
+		Labels:        map[string]string{"team": "checkout"},
+		RuleCondition: &RuleCondition{CompositeQuery: compositeQuery},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return rule
+}
+
+func TestValidateRecordingRule(t *testing.T) {
+	builderQueries := map[string]*v3.BuilderQuery{"A": testBuilderQuery("A")}
+	testCases := []struct {
+		name      string
+		record    string
+		condition *RuleCondition
+		valid     bool
+	}{
+		{
+			name:      "builder query",
+			record:    "job:http_requests:rate5m",
+			condition: &RuleCondition{CompositeQuery: &v3.CompositeQuery{QueryType: v3.QueryTypeBuilder, BuilderQueries: builderQueries}},
+			valid:     true,
+		},
+		{
+			name:      "promql query",
+			record:    "http_requests_rate",
+			condition: &RuleCondition{CompositeQuery: &v3.CompositeQuery{QueryType: v3.QueryTypePromQL, PromQueries: map[string]*v3.PromQuery{"A": {Query: "up"}}}},
+			valid:     true,
+		},
+		{
+			name:      "metric name starting with a digit",
+			record:    "5m_rate",
+			condition: &RuleCondition{CompositeQuery: &v3.CompositeQuery{QueryType: v3.QueryTypeBuilder, BuilderQueries: builderQueries}},
+		},
+		{
+			name:      "metric name with a dash",
+			record:    "http-requests",
+			condition: &RuleCondition{CompositeQuery: &v3.CompositeQuery{QueryType: v3.QueryTypeBuilder, BuilderQueries: builderQueries}},
+		},
+		{
+			name:      "no metric name",
+			condition: &RuleCondition{CompositeQuery: &v3.CompositeQuery{QueryType: v3.QueryTypeBuilder, BuilderQueries: builderQueries}},
+		},
+		{
+			name:   "no composite query",
+			record: "http_requests_rate",
+		},
+		{
+			name:      "no builder queries",
+			record:    "http_requests_rate",
+			condition: &RuleCondition{CompositeQuery: &v3.CompositeQuery{QueryType: v3.QueryTypeBuilder}},
+		},
+		{
+			name:      "no promql queries",
+			record:    "http_requests_rate",
+			condition: &RuleCondition{CompositeQuery: &v3.CompositeQuery{QueryType: v3.QueryTypePromQL}},
+		},
+		{
+			name:      "clickhouse query",
+			record:    "http_requests_rate",
+			condition: &RuleCondition{CompositeQuery: &v3.CompositeQuery{QueryType: v3.QueryTypeClickHouseSQL, ClickHouseQueries: map[string]*v3.ClickHouseQuery{"A": {Query: "SELECT 1"}}}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRecordingRule(&PostableRule{Record: tc.record, RuleCondition: tc.condition})
+			if tc.valid && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestRecordingRuleRecordedAt(t *testing.T) {
+	ts := time.Unix(1680066390, 0)
+	testCases := []struct {
+		queryType  v3.QueryType
+		recordedAt int64
+	}{
+		// the window ends 2 minutes before ts rounded to the minute
+		{queryType: v3.QueryTypeBuilder, recordedAt: 1680066240000 - 60000},
+		{queryType: v3.QueryTypePromQL, recordedAt: 1680066240000},
+	}
+	for _, tc := range testCases {
+		rule := newTestRecordingRule(t, tc.queryType)
+		params := rule.prepareQueryRange(ts)
+		if params.End-params.Start != (5 * time.Minute).Milliseconds() {
+			t.Errorf("expected a 5 minute window, got [%d, %d]", params.Start, params.End)
+		}
+		if got := rule.recordedAt(params); got != tc.recordedAt {
+			t.Errorf("expected %s queries to be recorded at %d, got %d", tc.queryType, tc.recordedAt, got)
+		}
+	}
+}
+
+func TestRecordingRuleSamples(t *testing.T) {
+	rule := newTestRecordingRule(t, v3.QueryTypeBuilder)
+	result := &v3.Result{QueryName: "A", Series: []*v3.Series{
+		{Labels: map[string]string{"service_name": "frontend", "team": "web"}, Points: []v3.Point{{Timestamp: 0, Value: 1}, {Timestamp: 60000, Value: 2}}},
+		{Labels: map[string]string{"service_name": "payment"}, Points: []v3.Point{{Timestamp: 60000, Value: math.NaN()}}},
+		{Labels: map[string]string{"service_name": "cart"}, Points: []v3.Point{{Timestamp: 0, Value: 3}}},
+	}}
+
+	samples := rule.samples(result, 60000)
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %v", samples)
+	}
+	expected := labels.FromMap(map[string]string{
+		labels.MetricNameLabel: "job:http_requests:rate5m",
+		"service_name":         "frontend",
+		"team":                 "checkout",
+	})
+	if !labels.Equal(samples[0].Metric, expected) || samples[0].T != 60000 || samples[0].V != 2 {
+		t.Errorf("expected %v at 60000 with value 2, got %v", expected, samples[0])
+	}
+}
+
+func TestRecordingRuleSeriesToWrite(t *testing.T) {
+	rule := newTestRecordingRule(t, v3.QueryTypeBuilder)
+	ts := time.Unix(1680066390, 0)
+	frontend := Sample{Metric: labels.FromMap(map[string]string{"service_name": "frontend"})}
+	payment := Sample{Metric: labels.FromMap(map[string]string{"service_name": "payment"})}
+	rule.seriesWrittenAt[frontend.Metric.Hash()] = ts.Add(-time.Minute)
+	rule.seriesWrittenAt[payment.Metric.Hash()] = ts.Add(-recordedSeriesRefresh)
+
+	newSeries := rule.seriesToWrite(Vector{frontend, payment}, ts)
+	if len(newSeries) != 1 || newSeries[0].Metric.Get("service_name") != "payment" {
+		t.Errorf("expected the payment series to be written again, got %v", newSeries)
+	}
+	if _, ok := rule.seriesWrittenAt[payment.Metric.Hash()]; ok || len(rule.seriesWrittenAt) != 1 {
+		t.Errorf("expected the series written before the refresh to be forgotten, got %v", rule.seriesWrittenAt)
+	}
+}
+
+func TestRecordingRuleWrite(t *testing.T) {
+	ts := time.Unix(1680066390, 0)
+	samples := Vector{
+		{Point: Point{T: 60000, V: 1}, Metric: labels.FromMap(map[string]string{"service_name": "frontend"})},
+		{Point: Point{T: 60000, V: 2}, Metric: labels.FromMap(map[string]string{"service_name": "payment"})},
+	}
+
+	t.Run("writes the series once", func(t *testing.T) {
+		rule := newTestRecordingRule(t, v3.QueryTypeBuilder)
+		conn := &testConn{}
+		for _, at := range []time.Time{ts, ts.Add(time.Minute)} {
+			if err := rule.write(context.Background(), conn, samples, at); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		// the time series and the samples, then the samples only
+		if len(conn.batches) != 3 {
+			t.Fatalf("expected 3 batches, got %d", len(conn.batches))
+		}
+		for _, batch := range conn.batches {
+			if !batch.sent || batch.aborted || batch.rows != 2 {
+				t.Errorf("expected 2 rows to be sent, got %+v", batch)
+			}
+		}
+	})
+
+	t.Run("aborts the batch on error", func(t *testing.T) {
+		rule := newTestRecordingRule(t, v3.QueryTypeBuilder)
+		conn := &testConn{appendErr: fmt.Errorf("block is full")}
+		if err := rule.write(context.Background(), conn, samples, ts); err == nil {
+			t.Fatalf("expected an error")
+		}
+		if len(conn.batches) != 1 || !conn.batches[0].aborted {
+			t.Errorf("expected the batch to be aborted, got %+v", conn.batches)
+		}
+		if len(rule.seriesWrittenAt) != 0 {
+			t.Errorf("expected no series to be marked as written, got %v", rule.seriesWrittenAt)
+		}
+	})
+}
@@ -19,6 +19,9 @@ type Rule interface {
 	Condition() *RuleCondition
 	State() AlertState
 	ActiveAlerts() []*Alert
+	// StateChanges returns the state changes of the alerts since the
+	// last call
+	StateChanges() []StateChange
 
 	PreferredChannels() []string
 
@@ -30,14 +30,17 @@ type RuleTask struct {
 	terminated  chan struct{}
 	managerDone chan struct{}
 
-	pause  bool
-	notify NotifyFunc
+	pause   bool
+	notify  NotifyFunc
+	history StateHistoryFunc
+	// saveState saves the alerts of the rules when they change
+	saveState SaveStateFunc
 }
 
 const DefaultFrequency = 1 * time.Minute
 
 // newRuleTask makes a new RuleTask with the given name, options, and rules.
-func newRuleTask(name, file string, frequency time.Duration, rules []Rule, opts *ManagerOptions, notify NotifyFunc) *RuleTask {
+func newRuleTask(name, file string, frequency time.Duration, rules []Rule, opts *ManagerOptions, notify NotifyFunc, history StateHistoryFunc, saveState SaveStateFunc) *RuleTask {
 
 	if time.Now() == time.Now().Add(frequency) {
 		frequency = DefaultFrequency
@@ -54,6 +57,8 @@ func newRuleTask(name, file string, frequency time.Duration, rules []Rule, opts
 		done:       make(chan struct{}),
 		terminated: make(chan struct{}),
 		notify:     notify,
+		history:    history,
+		saveState:  saveState,
 	}
 }
 
@@ -162,7 +167,7 @@ func (g *RuleTask) ThresholdRules() []*ThresholdRule {
 	defer g.mtx.Unlock()
 	var alerts []*ThresholdRule
 	for _, rule := range g.rules {
-		if tr, ok := rule.(*ThresholdRule); ok {
+		if tr, ok := thresholdRuleOf(rule); ok {
 			alerts = append(alerts, tr)
 		}
 	}
@@ -174,13 +179,28 @@ func (g *RuleTask) ThresholdRules() []*ThresholdRule {
 	return alerts
 }
 
+// thresholdRuleOf returns the threshold rule the alerts of the rule are
+// kept in, anomaly and slo rules keep their alerts in the embedded
+// threshold rule
+func thresholdRuleOf(rule Rule) (*ThresholdRule, bool) {
+	switch r := rule.(type) {
+	case *ThresholdRule:
+		return r, true
+	case *AnomalyRule:
+		return r.ThresholdRule, true
+	case *SLORule:
+		return r.ThresholdRule, true
+	}
+	return nil, false
+}
+
 // HasAlertingRules returns true if the group contains at least one AlertingRule.
 func (g *RuleTask) HasAlertingRules() bool {
 	g.mtx.Lock()
 	defer g.mtx.Unlock()
 
 	for _, rule := range g.rules {
-		if _, ok := rule.(*ThresholdRule); ok {
+		if _, ok := thresholdRuleOf(rule); ok {
 			return true
 		}
 	}
@@ -274,11 +294,11 @@ func (g *RuleTask) CopyState(fromTask Task) error {
 		fi := indexes[0]
 		ruleMap[nameAndLabels] = indexes[1:]
 
-		ar, ok := rule.(*ThresholdRule)
+		ar, ok := thresholdRuleOf(rule)
 		if !ok {
 			continue
 		}
-		far, ok := from.rules[fi].(*ThresholdRule)
+		far, ok := thresholdRuleOf(from.rules[fi])
 		if !ok {
 			continue
 		}
@@ -333,8 +353,16 @@ func (g *RuleTask) Eval(ctx context.Context, ts time.Time) {
 				return
 			}
 
+			if changes := rule.StateChanges(); len(changes) > 0 && g.history != nil {
+				g.history(ctx, changes...)
+			}
+
 			rule.SendAlerts(ctx, ts, g.opts.ResendDelay, g.frequency, g.notify)
 
+			if g.saveState != nil {
+				g.saveState(ctx, rule)
+			}
+
 		}(i, rule)
 	}
 }
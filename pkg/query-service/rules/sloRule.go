@@ -0,0 +1,321 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+// sloPointsPerWindow is roughly the number of points queried for each
+// window, the step grows with the window so long windows stay cheap
+const sloPointsPerWindow = 300
+
+// SLORule alerts when the error budget of a service level objective burns
+// too fast. For each burn rate window pair the alert fires only when both
+// the long and the short window burn faster than the factor of the pair,
+// the long window makes sure enough budget is spent and the short window
+// makes sure it is still being spent. The alerts go through the same
+// states as the threshold rule alerts.
+type SLORule struct {
+	*ThresholdRule
+}
+
+// SLOBudget is the error budget of the events of a series, or of all the
+// events when it has no labels
+type SLOBudget struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Good   float64           `json:"good"`
+	Total  float64           `json:"total"`
+	// SLI is the percent of good events
+	SLI float64 `json:"sli"`
+	// Consumed and Remaining are the percent of the error budget spent
+	// and left, Remaining is negative when the budget is exhausted
+	Consumed  float64 `json:"consumed"`
+	Remaining float64 `json:"remaining"`
+}
+
+// SLOErrorBudget is the error budget of an slo rule in its compliance
+// window ending at End (unix milli)
+type SLOErrorBudget struct {
+	RuleId string   `json:"ruleId"`
+	Target float64  `json:"target"`
+	Window Duration `json:"window"`
+	Start  int64    `json:"start"`
+	End    int64    `json:"end"`
+	SLOBudget
+	Series []SLOBudget `json:"series"`
+}
+
+// sloEvents are the good and total events of a series in a window
+type sloEvents struct {
+	labels map[string]string
+	good   float64
+	total  float64
+}
+
+func NewSLORule(
+	id string,
+	p *PostableRule,
+	opts ThresholdRuleOpts,
+) (*SLORule, error) {
+
+	if p.RuleCondition == nil || p.RuleCondition.SLO == nil {
+		return nil, fmt.Errorf("no slo condition")
+	}
+	if err := p.RuleCondition.SLO.IsValid(p.RuleCondition.CompositeQuery); err != nil {
+		return nil, err
+	}
+
+	tr, err := NewThresholdRule(id, p, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &SLORule{ThresholdRule: tr}, nil
+}
+
+func (r *SLORule) Type() RuleType {
+	return RuleTypeSLO
+}
+
+func (r *SLORule) slo() *SLOCondition {
+	return r.ruleCondition.SLO
+}
+
+func (r *SLORule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (interface{}, error) {
+	if queriers.Querier == nil {
+		return nil, fmt.Errorf("slo rules need the v3 querier")
+	}
+
+	res, err := r.runBurnRateQueries(ctx, ts, queriers.Querier)
+	if err != nil {
+		r.SetHealth(HealthBad)
+		r.SetLastError(err)
+		zap.S().Debugf("ruleid:", r.ID(), "\t failure in runBurnRateQueries:", err)
+		return nil, err
+	}
+
+	return r.updateAlerts(ctx, ts, res)
+}
+
+// runBurnRateQueries returns the long window burn rate of the series that
+// burn too fast in both windows of a burn rate window pair
+func (r *SLORule) runBurnRateQueries(ctx context.Context, ts time.Time, querier interfaces.Querier) (Vector, error) {
+	// windows shared by several pairs are queried once
+	events := make(map[Duration]map[uint64]*sloEvents)
+	windowEvents := func(window Duration) (map[uint64]*sloEvents, error) {
+		if e, ok := events[window]; ok {
+			return e, nil
+		}
+		e, err := r.queryEvents(ctx, ts, time.Duration(window), querier)
+		if err != nil {
+			return nil, err
+		}
+		events[window] = e
+		return e, nil
+	}
+
+	type burn struct {
+		labels map[string]string
+		rate   float64
+		fired  bool
+	}
+	burns := make(map[uint64]*burn)
+
+	for _, br := range r.slo().BurnRates {
+		long, err := windowEvents(br.Long)
+		if err != nil {
+			return nil, err
+		}
+		short, err := windowEvents(br.Short)
+		if err != nil {
+			return nil, err
+		}
+
+		for h, le := range long {
+			longRate, ok := r.burnRate(le)
+			if !ok {
+				continue
+			}
+			b, ok := burns[h]
+			if !ok {
+				b = &burn{labels: le.labels, rate: longRate}
+				burns[h] = b
+			}
+
+			se, ok := short[h]
+			if !ok {
+				continue
+			}
+			shortRate, ok := r.burnRate(se)
+			if !ok {
+				continue
+			}
+			if longRate > br.Factor && shortRate > br.Factor {
+				if !b.fired || longRate > b.rate {
+					b.rate = longRate
+				}
+				b.fired = true
+			}
+		}
+	}
+
+	var result Vector
+	for _, b := range burns {
+		if r.opts.SendUnmatched || b.fired {
+			result = append(result, Sample{
+				Point:  Point{T: ts.Unix(), V: b.rate},
+				Metric: labels.FromMap(b.labels),
+			})
+		}
+	}
+	return result, nil
+}
+
+// burnRate is how many times faster than allowed the error budget is spent
+func (r *SLORule) burnRate(e *sloEvents) (float64, bool) {
+	if e.total <= 0 {
+		return 0, false
+	}
+	errorRatio := 1 - e.good/e.total
+	return errorRatio / (1 - r.slo().Target/100), true
+}
+
+// ErrorBudget returns the error budget of the rule for the compliance
+// window ending at ts
+func (r *SLORule) ErrorBudget(ctx context.Context, ts time.Time, querier interfaces.Querier) (*SLOErrorBudget, error) {
+	if querier == nil {
+		return nil, fmt.Errorf("slo rules need the v3 querier")
+	}
+
+	window := time.Duration(r.slo().Window)
+	events, err := r.queryEvents(ctx, ts, window, querier)
+	if err != nil {
+		return nil, err
+	}
+
+	params := r.windowQueryRange(ts, window)
+	budget := &SLOErrorBudget{
+		RuleId: r.ID(),
+		Target: r.slo().Target,
+		Window: r.slo().Window,
+		Start:  params.Start,
+		End:    params.End,
+		Series: make([]SLOBudget, 0, len(events)),
+	}
+
+	var all sloEvents
+	for _, e := range events {
+		all.good += e.good
+		all.total += e.total
+		budget.Series = append(budget.Series, r.budget(e))
+	}
+	budget.SLOBudget = r.budget(&all)
+	return budget, nil
+}
+
+func (r *SLORule) budget(e *sloEvents) SLOBudget {
+	b := SLOBudget{
+		Labels:    e.labels,
+		Good:      e.good,
+		Total:     e.total,
+		SLI:       100,
+		Remaining: 100,
+	}
+	if rate, ok := r.burnRate(e); ok {
+		b.SLI = e.good / e.total * 100
+		b.Consumed = rate * 100
+		b.Remaining = 100 - b.Consumed
+	}
+	return b
+}
+
+// windowQueryRange prepares the query range for the window ending at ts,
+// delayed the same way as the threshold rule queries to wait for the data.
+// The windows are run without the querier cache, the short windows have the
+// same step and so the same cache key as the long ones.
+func (r *SLORule) windowQueryRange(ts time.Time, window time.Duration) *v3.QueryRangeParamsV3 {
+	end := ts.UnixMilli() - 2*60*1000
+	end = end - (end % (60 * 1000))
+	start := end - window.Milliseconds()
+
+	step := int64(window.Seconds()) / sloPointsPerWindow
+	step = step - (step % 60)
+	if step < 60 {
+		step = 60
+	}
+
+	// the builder queries are copied as the step differs for each window,
+	// the good and total queries run even when hidden in the rule chart
+	compositeQuery := *r.ruleCondition.CompositeQuery
+	compositeQuery.BuilderQueries = make(map[string]*v3.BuilderQuery, len(r.ruleCondition.CompositeQuery.BuilderQueries))
+	for name, q := range r.ruleCondition.CompositeQuery.BuilderQueries {
+		bq := *q
+		bq.StepInterval = step
+		if name == r.slo().GoodQuery || name == r.slo().TotalQuery {
+			bq.Disabled = false
+		}
+		compositeQuery.BuilderQueries[name] = &bq
+	}
+
+	return &v3.QueryRangeParamsV3{
+		Start:          start,
+		End:            end,
+		Step:           step,
+		CompositeQuery: &compositeQuery,
+		NoCache:        true,
+	}
+}
+
+// queryEvents counts the good and total events of each series in the
+// window ending at ts, series are matched by their labels
+func (r *SLORule) queryEvents(ctx context.Context, ts time.Time, window time.Duration, querier interfaces.Querier) (map[uint64]*sloEvents, error) {
+	params := r.windowQueryRange(ts, window)
+	results, err, errQueriesByName := querier.QueryRange(ctx, params, map[string]v3.AttributeKey{})
+	if err != nil {
+		zap.S().Errorf("ruleid:", r.ID(), "\t msg: failed to run queries", zap.Error(err), zap.Any("errQueriesByName", errQueriesByName))
+		return nil, fmt.Errorf("failed to run queries: %v", err)
+	}
+
+	events := make(map[uint64]*sloEvents)
+	eventsOf := func(series *v3.Series) *sloEvents {
+		h := labels.FromMap(series.Labels).Hash()
+		e, ok := events[h]
+		if !ok {
+			e = &sloEvents{labels: series.Labels}
+			events[h] = e
+		}
+		return e
+	}
+
+	for _, result := range results {
+		switch result.QueryName {
+		case r.slo().TotalQuery:
+			for _, series := range result.Series {
+				eventsOf(series).total += sumPoints(series)
+			}
+		case r.slo().GoodQuery:
+			for _, series := range result.Series {
+				eventsOf(series).good += sumPoints(series)
+			}
+		}
+	}
+	return events, nil
+}
+
+func sumPoints(series *v3.Series) float64 {
+	var sum float64
+	for _, p := range series.Points {
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+		sum += p.Value
+	}
+	return sum
+}
@@ -0,0 +1,175 @@
+package rules
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+)
+
+func newTestSLORule(t *testing.T) *SLORule {
+	rule, err := NewSLORule("1", &PostableRule{
+		Alert: "slo",
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType: v3.QueryTypeBuilder,
+				PanelType: v3.PanelTypeGraph,
+				BuilderQueries: map[string]*v3.BuilderQuery{
+					"A": testBuilderQuery("A"),
+					"B": testBuilderQuery("B"),
+				},
+			},
+			SLO: &SLOCondition{
+				Target:     99,
+				Window:     Duration(30 * 24 * time.Hour),
+				GoodQuery:  "A",
+				TotalQuery: "B",
+				BurnRates: []BurnRateWindow{
+					{Long: Duration(time.Hour), Short: Duration(5 * time.Minute), Factor: 14.4},
+					{Long: Duration(6 * time.Hour), Short: Duration(30 * time.Minute), Factor: 6},
+				},
+			},
+		},
+	}, ThresholdRuleOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return rule
+}
+
+// sloResults returns the good and total series of the services, events
+// are the good and total events of each service
+func sloResults(events map[string][2]float64) []*v3.Result {
+	good := &v3.Result{QueryName: "A"}
+	total := &v3.Result{QueryName: "B"}
+	for service, e := range events {
+		// the events are split in two points to check they are summed
+		good.Series = append(good.Series, testSeries(service, e[0]/2, e[0]/2))
+		total.Series = append(total.Series, testSeries(service, e[1]/2, e[1]/2))
+	}
+	return []*v3.Result{good, total}
+}
+
+func TestSLORuleBurnRate(t *testing.T) {
+	rule := newTestSLORule(t)
+	testCases := []struct {
+		good, total float64
+		rate        float64
+		ok          bool
+	}{
+		{good: 1000, total: 1000, rate: 0, ok: true},
+		{good: 990, total: 1000, rate: 1, ok: true},
+		{good: 900, total: 1000, rate: 10, ok: true},
+		{good: 0, total: 1000, rate: 100, ok: true},
+		{good: 0, total: 0},
+	}
+	for _, tc := range testCases {
+		rate, ok := rule.burnRate(&sloEvents{good: tc.good, total: tc.total})
+		if ok != tc.ok || math.Abs(rate-tc.rate) > 1e-9 {
+			t.Errorf("expected %v (%v) for %v good of %v, got %v (%v)", tc.rate, tc.ok, tc.good, tc.total, rate, ok)
+		}
+	}
+}
+
+func TestSLORuleBudget(t *testing.T) {
+	rule := newTestSLORule(t)
+	testCases := []struct {
+		name   string
+		events sloEvents
+		sli    float64
+		spent  float64
+		left   float64
+	}{
+		{name: "half spent", events: sloEvents{good: 995, total: 1000}, sli: 99.5, spent: 50, left: 50},
+		{name: "exhausted", events: sloEvents{good: 980, total: 1000}, sli: 98, spent: 200, left: -100},
+		{name: "no events", events: sloEvents{}, sli: 100, spent: 0, left: 100},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := rule.budget(&tc.events)
+			if math.Abs(b.SLI-tc.sli) > 1e-9 || math.Abs(b.Consumed-tc.spent) > 1e-9 || math.Abs(b.Remaining-tc.left) > 1e-9 {
+				t.Errorf("expected sli %v, consumed %v and remaining %v, got %+v", tc.sli, tc.spent, tc.left, b)
+			}
+		})
+	}
+}
+
+func TestSLORuleBurnRateQueries(t *testing.T) {
+	ts := time.Unix(1680066360, 0)
+	rule := newTestSLORule(t)
+
+	querier := &testQuerier{results: func(params *v3.QueryRangeParamsV3) []*v3.Result {
+		switch time.Duration(params.End-params.Start) * time.Millisecond {
+		case time.Hour:
+			return sloResults(map[string][2]float64{
+				"frontend": {800, 1000},
+				"checkout": {800, 1000},
+				"payment":  {990, 1000},
+			})
+		case 5 * time.Minute:
+			return sloResults(map[string][2]float64{
+				"frontend": {80, 100},
+				"checkout": {99, 100},
+				"payment":  {90, 100},
+			})
+		default:
+			return sloResults(map[string][2]float64{
+				"frontend": {990, 1000},
+				"checkout": {990, 1000},
+				"payment":  {990, 1000},
+			})
+		}
+	}}
+
+	result, err := rule.runBurnRateQueries(context.Background(), ts, querier)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// checkout burns fast in the long window only, payment in the short one
+	if len(result) != 1 || result[0].Metric.Get("service_name") != "frontend" || math.Abs(result[0].V-20) > 1e-9 {
+		t.Errorf("expected frontend to burn 20 times too fast, got %v", result)
+	}
+
+	if len(querier.params) != 4 {
+		t.Fatalf("expected the 4 windows to be queried once, got %d", len(querier.params))
+	}
+	for _, params := range querier.params {
+		if !params.NoCache {
+			t.Errorf("expected the window [%d, %d] to skip the cache", params.Start, params.End)
+		}
+	}
+}
+
+func TestSLORuleErrorBudget(t *testing.T) {
+	ts := time.Unix(1680066360, 0)
+	rule := newTestSLORule(t)
+
+	querier := &testQuerier{results: func(params *v3.QueryRangeParamsV3) []*v3.Result {
+		return sloResults(map[string][2]float64{
+			"frontend": {2985, 3000},
+			"payment":  {970, 1000},
+		})
+	}}
+
+	budget, err := rule.ErrorBudget(context.Background(), ts, querier)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if budget.End-budget.Start != (30 * 24 * time.Hour).Milliseconds() {
+		t.Errorf("expected a 30 day window, got [%d, %d]", budget.Start, budget.End)
+	}
+	if budget.Good != 3955 || budget.Total != 4000 || math.Abs(budget.Consumed-112.5) > 1e-9 {
+		t.Errorf("expected 112.5 percent of the budget to be consumed, got %+v", budget.SLOBudget)
+	}
+	if len(budget.Series) != 2 {
+		t.Fatalf("expected the budget of 2 series, got %v", budget.Series)
+	}
+	for _, b := range budget.Series {
+		expected := map[string]float64{"frontend": 50, "payment": 300}[b.Labels["service_name"]]
+		if math.Abs(b.Consumed-expected) > 1e-9 {
+			t.Errorf("expected %v percent of the budget of %s to be consumed, got %v", expected, b.Labels["service_name"], b.Consumed)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package rules
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// StateChange records an alert of a rule moving from one state to
+// another. Timestamps are unix milliseconds, zero when not set.
+type StateChange struct {
+	Id             int64       `json:"id" db:"id"`
+	RuleId         string      `json:"ruleId" db:"rule_id"`
+	RuleName       string      `json:"ruleName" db:"rule_name"`
+	Fingerprint    string      `json:"fingerprint" db:"fingerprint"`
+	State          string      `json:"state" db:"state"`
+	StateChangedAt int64       `json:"stateChangedAt" db:"state_changed_at"`
+	Labels         LabelsJSON  `json:"labels" db:"labels"`
+	Value          float64     `json:"value" db:"value"`
+	Receivers      StringsJSON `json:"receivers" db:"receivers"`
+	ActiveAt       int64       `json:"activeAt" db:"active_at"`
+	FiredAt        int64       `json:"firedAt" db:"fired_at"`
+	ResolvedAt     int64       `json:"resolvedAt" db:"resolved_at"`
+}
+
+// RuleStateTimeline is the history of the alerts of a rule in a time range
+type RuleStateTimeline struct {
+	RuleId string        `json:"ruleId"`
+	Start  int64         `json:"start"`
+	End    int64         `json:"end"`
+	Items  []StateChange `json:"items"`
+	Total  int           `json:"total"`
+	// FiredCount is the number of times alerts of the rule started firing
+	FiredCount int `json:"firedCount"`
+}
+
+// StateHistoryFunc stores the state changes of the alerts of a rule
+type StateHistoryFunc func(ctx context.Context, changes ...StateChange)
+
+// newStateChange captures the current state of the alert
+func newStateChange(ruleId, ruleName string, a *Alert, ts time.Time) StateChange {
+	change := StateChange{
+		RuleId:         ruleId,
+		RuleName:       ruleName,
+		State:          a.State.String(),
+		StateChangedAt: ts.UnixMilli(),
+		Labels:         LabelsJSON{},
+		Value:          a.Value,
+		Receivers:      StringsJSON(a.Receivers),
+		ActiveAt:       unixMilli(a.ActiveAt),
+		FiredAt:        unixMilli(a.FiredAt),
+		ResolvedAt:     unixMilli(a.ResolvedAt),
+	}
+	if a.Labels != nil {
+		change.Labels = a.Labels.Map()
+		change.Fingerprint = strconv.FormatUint(a.Labels.Hash(), 10)
+	}
+	if change.Receivers == nil {
+		change.Receivers = StringsJSON{}
+	}
+	return change
+}
+
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// LabelsJSON is a label set stored as json
+type LabelsJSON map[string]string
+
+func (l LabelsJSON) Value() (driver.Value, error) {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (l *LabelsJSON) Scan(src interface{}) error {
+	return scanJSON(src, l)
+}
+
+// StringsJSON is a list of strings stored as json
+type StringsJSON []string
+
+func (s StringsJSON) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (s *StringsJSON) Scan(src interface{}) error {
+	return scanJSON(src, s)
+}
+
+func scanJSON(src interface{}, dest interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("unsupported type %T for a json column", src)
+	}
+	return json.Unmarshal(data, dest)
+}
@@ -0,0 +1,227 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
+	"go.signoz.io/signoz/pkg/query-service/utils/labels"
+)
+
+func testStateChange(ruleId, fingerprint string, state AlertState, at int64) StateChange {
+	return StateChange{
+		RuleId:         ruleId,
+		RuleName:       "rule " + ruleId,
+		Fingerprint:    fingerprint,
+		State:          state.String(),
+		StateChangedAt: at,
+		Labels:         LabelsJSON{"service_name": fingerprint},
+		Receivers:      StringsJSON{},
+	}
+}
+
+// addTestStateHistory stores the changes of two alerts of rule 1, a
+// resolved at 5000 and b still firing, and of an alert c of rule 2
+// resolved at 3500
+func addTestStateHistory(t *testing.T, db RuleDB) {
+	err := db.AddStateHistory(context.Background(), []StateChange{
+		testStateChange("1", "a", StatePending, 1000),
+		testStateChange("1", "b", StatePending, 1500),
+		testStateChange("1", "a", StateFiring, 2000),
+		testStateChange("1", "b", StateFiring, 2500),
+		testStateChange("2", "c", StateFiring, 3000),
+		testStateChange("2", "c", StateInactive, 3500),
+		testStateChange("1", "a", StateInactive, 5000),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// changesOf returns the fingerprint, state and time of the changes
+func changesOf(changes []StateChange) [][3]interface{} {
+	result := [][3]interface{}{}
+	for _, c := range changes {
+		result = append(result, [3]interface{}{c.Fingerprint, c.State, c.StateChangedAt})
+	}
+	return result
+}
+
+func TestGetRuleStateTimeline(t *testing.T) {
+	db := newTestRuleDB(t)
+	addTestStateHistory(t, db)
+	m := newTestManager(db)
+
+	timeline, err := m.GetRuleStateTimeline(context.Background(), "1", 0, 10000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if timeline.Total != 5 || timeline.FiredCount != 2 {
+		t.Errorf("expected 5 changes and 2 firing alerts, got %d and %d", timeline.Total, timeline.FiredCount)
+	}
+	if labels := timeline.Items[0].Labels; labels["service_name"] != "a" {
+		t.Errorf("expected the labels to be read back, got %v", labels)
+	}
+
+	timeline, err = m.GetRuleStateTimeline(context.Background(), "1", 1800, 2600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := [][3]interface{}{{"a", "firing", int64(2000)}, {"b", "firing", int64(2500)}}
+	if got := changesOf(timeline.Items); !equalChanges(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestGetFiringAlerts(t *testing.T) {
+	db := newTestRuleDB(t)
+	addTestStateHistory(t, db)
+
+	testCases := []struct {
+		name       string
+		start, end int64
+		expected   [][3]interface{}
+		resolvedAt []int64
+	}{
+		{
+			name:       "resolved and still firing",
+			start:      4000,
+			end:        6000,
+			expected:   [][3]interface{}{{"a", "firing", int64(2000)}, {"b", "firing", int64(2500)}},
+			resolvedAt: []int64{5000, 0},
+		},
+		{
+			name:       "all",
+			start:      0,
+			end:        10000,
+			expected:   [][3]interface{}{{"a", "firing", int64(2000)}, {"b", "firing", int64(2500)}, {"c", "firing", int64(3000)}},
+			resolvedAt: []int64{5000, 0, 3500},
+		},
+		{
+			name:     "before firing",
+			start:    0,
+			end:      1999,
+			expected: [][3]interface{}{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			alerts, err := db.GetFiringAlerts(context.Background(), tc.start, tc.end)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := changesOf(alerts); !equalChanges(got, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+			for idx, alert := range alerts {
+				if alert.ResolvedAt != tc.resolvedAt[idx] {
+					t.Errorf("expected %s to be resolved at %d, got %d", alert.Fingerprint, tc.resolvedAt[idx], alert.ResolvedAt)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteStateHistory(t *testing.T) {
+	db := newTestRuleDB(t)
+	addTestStateHistory(t, db)
+
+	deleted, err := db.DeleteStateHistory(context.Background(), 4000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the firing change of b is kept as b is still firing
+	if deleted != 5 {
+		t.Errorf("expected 5 changes to be deleted, got %d", deleted)
+	}
+
+	alerts, err := db.GetFiringAlerts(context.Background(), 0, 10000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := [][3]interface{}{{"b", "firing", int64(2500)}}
+	if got := changesOf(alerts); !equalChanges(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	changes, err := db.GetRuleStateHistory(context.Background(), "1", 0, 10000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = [][3]interface{}{{"b", "firing", int64(2500)}, {"a", "inactive", int64(5000)}}
+	if got := changesOf(changes); !equalChanges(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func equalChanges(a, b [][3]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func newTestThresholdRule(t *testing.T, id string) *ThresholdRule {
+	target := 10.0
+	rule, err := NewThresholdRule(id, &PostableRule{
+		Alert: "threshold",
+		For:   Duration(time.Minute),
+		RuleCondition: &RuleCondition{
+			CompositeQuery: &v3.CompositeQuery{
+				QueryType:      v3.QueryTypeBuilder,
+				PanelType:      v3.PanelTypeGraph,
+				BuilderQueries: map[string]*v3.BuilderQuery{"A": testBuilderQuery("A")},
+			},
+			CompareOp: ValueIsAbove,
+			MatchType: AtleastOnce,
+			Target:    &target,
+		},
+	}, ThresholdRuleOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return rule
+}
+
+func TestThresholdRuleStateChanges(t *testing.T) {
+	rule := newTestThresholdRule(t, "1")
+
+	ts := time.Unix(1680066360, 0)
+	sample := Vector{{Point: Point{T: ts.Unix(), V: 20}, Metric: labels.FromMap(map[string]string{"service_name": "frontend"})}}
+	evals := []struct {
+		at     time.Time
+		result Vector
+		states []string
+	}{
+		{at: ts, result: sample, states: []string{"pending"}},
+		{at: ts.Add(30 * time.Second), result: sample, states: []string{}},
+		{at: ts.Add(time.Minute), result: sample, states: []string{"firing"}},
+		{at: ts.Add(2 * time.Minute), states: []string{"inactive"}},
+	}
+	for _, eval := range evals {
+		if _, err := rule.updateAlerts(context.Background(), eval.at, eval.result); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		changes := rule.StateChanges()
+		states := []string{}
+		for _, c := range changes {
+			states = append(states, c.State)
+			if c.StateChangedAt != eval.at.UnixMilli() || c.RuleId != "1" || c.Labels["service_name"] != "frontend" {
+				t.Errorf("unexpected state change %+v", c)
+			}
+		}
+		if len(states) != len(eval.states) || (len(states) > 0 && states[0] != eval.states[0]) {
+			t.Errorf("expected the changes %v at %s, got %v", eval.states, eval.at, states)
+		}
+		// the changes are returned once
+		if changes := rule.StateChanges(); len(changes) != 0 {
+			t.Errorf("expected the changes to be drained, got %v", changes)
+		}
+	}
+}
@@ -17,6 +17,7 @@ import (
 
 	"go.signoz.io/signoz/pkg/query-service/app/queryBuilder"
 	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/interfaces"
 	v3 "go.signoz.io/signoz/pkg/query-service/model/v3"
 	"go.signoz.io/signoz/pkg/query-service/utils/labels"
 	querytemplate "go.signoz.io/signoz/pkg/query-service/utils/queryTemplate"
@@ -52,6 +53,9 @@ type ThresholdRule struct {
 	// map of active alerts
 	active map[uint64]*Alert
 
+	// state changes of the alerts not yet collected
+	stateChanges []StateChange
+
 	queryBuilder *queryBuilder.QueryBuilder
 
 	opts ThresholdRuleOpts
@@ -86,6 +90,7 @@ func NewThresholdRule(
 		source:            p.Source,
 		ruleCondition:     p.RuleCondition,
 		evalWindow:        time.Duration(p.EvalWindow),
+		holdDuration:      time.Duration(p.For),
 		labels:            labels.FromMap(p.Labels),
 		annotations:       labels.FromMap(p.Annotations),
 		preferredChannels: p.PreferredChannels,
@@ -138,6 +143,12 @@ func (r *ThresholdRule) target() *float64 {
 }
 
 func (r *ThresholdRule) targetVal() float64 {
+	if r.ruleCondition != nil && r.ruleCondition.Anomaly != nil {
+		return r.ruleCondition.Anomaly.Limit
+	}
+	if r.ruleCondition != nil && r.ruleCondition.SLO != nil {
+		return r.ruleCondition.SLO.Target
+	}
 	if r.ruleCondition == nil || r.ruleCondition.Target == nil {
 		return 0
 	}
@@ -291,6 +302,15 @@ func (r *ThresholdRule) ActiveAlerts() []*Alert {
 	return res
 }
 
+// StateChanges returns the state changes of the alerts since the last call
+func (r *ThresholdRule) StateChanges() []StateChange {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	changes := r.stateChanges
+	r.stateChanges = nil
+	return changes
+}
+
 // ForEachActiveAlert runs the given function on each alert.
 // This should be used when you want to use the actual alerts from the ThresholdRule
 // and not on its copy.
@@ -681,9 +701,110 @@ func (r *ThresholdRule) buildAndRunQuery(ctx context.Context, ts time.Time, ch c
 	return nil, fmt.Errorf("this is unexpected, invalid query label")
 }
 
+// runQuerier evaluates the builder queries through the cache backed
+// querier and reduces each series of the target query to a sample
+func (r *ThresholdRule) runQuerier(ctx context.Context, ts time.Time, querier interfaces.Querier) (Vector, error) {
+	if r.ruleCondition == nil || r.ruleCondition.CompositeQuery == nil {
+		r.SetHealth(HealthBad)
+		return nil, fmt.Errorf("invalid rule condition")
+	}
+
+	params := r.prepareQueryRange(ts)
+	results, err, errQueriesByName := querier.QueryRange(ctx, params, map[string]v3.AttributeKey{})
+	if err != nil {
+		zap.S().Errorf("ruleid:", r.ID(), "\t msg: failed to run queries", zap.Error(err), zap.Any("errQueriesByName", errQueriesByName))
+		return nil, fmt.Errorf("failed to run queries: %v", err)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no queries could be built with the rule config")
+	}
+
+	target := targetResult(results)
+
+	zap.S().Debugf("ruleId: ", r.ID(), "\t result query label:", target.QueryName)
+
+	var result Vector
+	for _, series := range target.Series {
+		sample, ok := r.reduceSeries(series)
+		if !ok {
+			continue
+		}
+		// check alert rule condition before dumping results, if sendUnmatchedResults
+		// is set then add results irrespective of condition
+		if r.opts.SendUnmatched || r.CheckCondition(sample.Point.V) {
+			result = append(result, sample)
+		}
+	}
+	return result, nil
+}
+
+// targetResult finds the result of the target query, formula F1 takes
+// precedence over the query label with max ascii val
+func targetResult(results []*v3.Result) *v3.Result {
+	var target *v3.Result
+	for _, result := range results {
+		if result.QueryName == "F1" {
+			return result
+		}
+		if target == nil || result.QueryName > target.QueryName {
+			target = result
+		}
+	}
+	return target
+}
+
+// reduceSeries walks through the points of the series the same way
+// runChQuery walks through the rows of a builder query and returns
+// the value used to compare with the rule target
+func (r *ThresholdRule) reduceSeries(series *v3.Series) (Sample, bool) {
+	var sample Sample
+	var found bool
+
+	series.SortPoints()
+	// the first point is skipped to support rate cases correctly
+	for idx, point := range series.Points {
+		if idx == 0 || math.IsNaN(point.Value) {
+			continue
+		}
+		if !found {
+			sample.Point = Point{T: point.Timestamp / 1000, V: point.Value}
+			found = true
+			continue
+		}
+		switch r.matchType() {
+		case AllTheTimes:
+			if r.compareOp() == ValueIsAbove {
+				sample.Point.V = math.Min(sample.Point.V, point.Value)
+			} else if r.compareOp() == ValueIsBelow {
+				sample.Point.V = math.Max(sample.Point.V, point.Value)
+			}
+		case AtleastOnce:
+			if r.compareOp() == ValueIsAbove {
+				sample.Point.V = math.Max(sample.Point.V, point.Value)
+			} else if r.compareOp() == ValueIsBelow {
+				sample.Point.V = math.Min(sample.Point.V, point.Value)
+			}
+		case OnAverage:
+			sample.Point.V = (sample.Point.V + point.Value) / 2
+		case InTotal:
+			sample.Point.V = sample.Point.V + point.Value
+		}
+		sample.Point.T = point.Timestamp / 1000
+	}
+	sample.Metric = labels.FromMap(series.Labels)
+	return sample, found
+}
+
 func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Queriers) (interface{}, error) {
 
-	res, err := r.buildAndRunQuery(ctx, ts, queriers.Ch)
+	var res Vector
+	var err error
+	if queriers.Querier != nil && r.ruleCondition.QueryType() == v3.QueryTypeBuilder {
+		res, err = r.runQuerier(ctx, ts, queriers.Querier)
+	} else {
+		res, err = r.buildAndRunQuery(ctx, ts, queriers.Ch)
+	}
 
 	if err != nil {
 		r.SetHealth(HealthBad)
@@ -692,6 +813,14 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 		return nil, err
 	}
 
+	return r.updateAlerts(ctx, ts, res)
+}
+
+// updateAlerts moves the alerts of the rule through pending, firing and
+// inactive states based on the samples that satisfy the rule condition
+func (r *ThresholdRule) updateAlerts(ctx context.Context, ts time.Time, res Vector) (interface{}, error) {
+	var err error
+
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
@@ -783,6 +912,7 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 		}
 
 		r.active[h] = a
+		r.stateChanges = append(r.stateChanges, newStateChange(r.ID(), r.Name(), a, ts))
 
 	}
 
@@ -797,6 +927,7 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 			if a.State != StateInactive {
 				a.State = StateInactive
 				a.ResolvedAt = ts
+				r.stateChanges = append(r.stateChanges, newStateChange(r.ID(), r.Name(), a, ts))
 			}
 			continue
 		}
@@ -804,6 +935,7 @@ func (r *ThresholdRule) Eval(ctx context.Context, ts time.Time, queriers *Querie
 		if a.State == StatePending && ts.Sub(a.ActiveAt) >= r.holdDuration {
 			a.State = StateFiring
 			a.FiredAt = ts
+			r.stateChanges = append(r.stateChanges, newStateChange(r.ID(), r.Name(), a, ts))
 		}
 
 	}
@@ -819,6 +951,7 @@ func (r *ThresholdRule) String() string {
 		Alert:             r.name,
 		RuleCondition:     r.ruleCondition,
 		EvalWindow:        Duration(r.evalWindow),
+		For:               Duration(r.holdDuration),
 		Labels:            r.labels.Map(),
 		Annotations:       r.annotations.Map(),
 		PreferredChannels: r.preferredChannels,
@@ -141,6 +141,56 @@ func ValidateAndCastValue(v interface{}, dataType v3.AttributeKeyDataType) (inte
 	}
 }
 
+// QuoteString returns the string as a clickhouse string literal, the
+// backslashes and the single quotes in it are escaped so it can not end the
+// literal
+func QuoteString(s string) string {
+	return "'" + stringEscaper.Replace(s) + "'"
+}
+
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// QuoteIdentifier returns the name as a clickhouse identifier quoted with
+// backticks, the backslashes and the backticks in it are escaped
+func QuoteIdentifier(name string) string {
+	return "`" + identifierEscaper.Replace(name) + "`"
+}
+
+var identifierEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+
+// ClickHouseFormattedIdentifier formats the name to be used as an identifier
+// in clickhouse query, the names that are not plain identifiers are quoted
+func ClickHouseFormattedIdentifier(name string) string {
+	if isPlainIdentifier(name) {
+		return name
+	}
+	return QuoteIdentifier(name)
+}
+
+// ClickHouseFormattedColumn formats the name to be used as a column in
+// clickhouse query, the names of the nested columns like `user.name` are
+// kept as they are and the rest are formatted as identifiers
+func ClickHouseFormattedColumn(name string) string {
+	for _, part := range strings.Split(name, ".") {
+		if !isPlainIdentifier(part) {
+			return QuoteIdentifier(name)
+		}
+	}
+	return name
+}
+
+func isPlainIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
 // ClickHouseFormattedValue formats the value to be used in clickhouse query
 func ClickHouseFormattedValue(v interface{}) string {
 	switch x := v.(type) {
@@ -149,7 +199,7 @@ func ClickHouseFormattedValue(v interface{}) string {
 	case float32, float64:
 		return fmt.Sprintf("%f", x)
 	case string:
-		return fmt.Sprintf("'%s'", x)
+		return QuoteString(x)
 	case bool:
 		return fmt.Sprintf("%v", x)
 	case []interface{}:
@@ -160,7 +210,7 @@ func ClickHouseFormattedValue(v interface{}) string {
 		case string:
 			str := "["
 			for idx, sVal := range x {
-				str += fmt.Sprintf("'%s'", sVal)
+				str += QuoteString(fmt.Sprint(sVal))
 				if idx != len(x)-1 {
 					str += ","
 				}
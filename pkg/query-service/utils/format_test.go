@@ -291,3 +291,44 @@ func TestValidateAndCastValue(t *testing.T) {
 		})
 	}
 }
+
+func TestQuoteString(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{in: "service.name", want: `'service.name'`},
+		{in: "it's", want: `'it\'s'`},
+		{in: `a\'); DROP TABLE x; --`, want: `'a\\\'); DROP TABLE x; --'`},
+		{in: `ends with \`, want: `'ends with \\'`},
+	}
+	for _, tc := range testCases {
+		if got := QuoteString(tc.in); got != tc.want {
+			t.Errorf("QuoteString(%q) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestClickHouseFormattedIdentifier(t *testing.T) {
+	testCases := []struct {
+		in         string
+		identifier string
+		column     string
+	}{
+		{in: "service_name", identifier: "service_name", column: "service_name"},
+		{in: "k8s.pod.name", identifier: "`k8s.pod.name`", column: "k8s.pod.name"},
+		{in: "1abc", identifier: "`1abc`", column: "`1abc`"},
+		{in: "a b", identifier: "`a b`", column: "`a b`"},
+		{in: "a.", identifier: "`a.`", column: "`a.`"},
+		{in: "x` FROM system.users --", identifier: "`x\\` FROM system.users --`", column: "`x\\` FROM system.users --`"},
+		{in: `x\`, identifier: "`x\\\\`", column: "`x\\\\`"},
+	}
+	for _, tc := range testCases {
+		if got := ClickHouseFormattedIdentifier(tc.in); got != tc.identifier {
+			t.Errorf("ClickHouseFormattedIdentifier(%q) = %s, want %s", tc.in, got, tc.identifier)
+		}
+		if got := ClickHouseFormattedColumn(tc.in); got != tc.column {
+			t.Errorf("ClickHouseFormattedColumn(%q) = %s, want %s", tc.in, got, tc.column)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package queryguard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"go.signoz.io/signoz/pkg/query-service/constants"
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+// allowedTableFunctions are the table functions that read no data
+var allowedTableFunctions = map[string]bool{
+	"numbers": true,
+	"zeros":   true,
+	"values":  true,
+}
+
+// dictionaryFunctionPrefixes are the prefixes of the functions that read
+// the dictionary or the join table named by their first argument
+var dictionaryFunctionPrefixes = []string{"dictget", "dicthas", "dictisin", "joinget"}
+
+// tableListEnd are the keywords that end the tables of a FROM or a JOIN,
+// anything else after a table, e.g. its alias, FINAL, SAMPLE or the USING
+// columns, is skipped up to the comma of the next table
+var tableListEnd = map[string]bool{
+	"WHERE": true, "PREWHERE": true, "GROUP": true, "ORDER": true, "LIMIT": true, "HAVING": true,
+	"JOIN": true, "ARRAY": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "SETTINGS": true,
+	"FORMAT": true, "WINDOW": true, "QUALIFY": true, "INTO": true,
+}
+
+// defaultDatabase is the database of the dictionaries named without one
+const defaultDatabase = "default"
+
+func badData(err error) *model.ApiError {
+	return &model.ApiError{Typ: model.ErrorBadData, Err: err}
+}
+
+func forbidden(format string, args ...interface{}) *model.ApiError {
+	return &model.ApiError{Typ: model.ErrorForbidden, Err: fmt.Errorf(format, args...)}
+}
+
+// Check returns an error when the query is not a single SELECT statement
+// that reads only from the allowed databases. The tables of the FROM, JOIN
+// and IN clauses, the table functions and the dictionaries are checked.
+func Check(query string, allowedDatabases []string) *model.ApiError {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return badData(err)
+	}
+	for len(tokens) > 0 && tokens[len(tokens)-1].isPunct(";") {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return badData(fmt.Errorf("query is required"))
+	}
+	for _, t := range tokens {
+		if t.isPunct(";") {
+			return badData(fmt.Errorf("multiple statements are not allowed"))
+		}
+	}
+
+	first := 0
+	for first < len(tokens) && tokens[first].isPunct("(") {
+		first++
+	}
+	if first == len(tokens) || !(tokens[first].is("SELECT") || tokens[first].is("WITH")) {
+		return forbidden("only SELECT queries are allowed")
+	}
+
+	g := &guard{
+		tokens:    tokens,
+		databases: make(map[string]bool, len(allowedDatabases)),
+		ctes:      cteNames(tokens),
+	}
+	for _, db := range allowedDatabases {
+		g.databases[strings.TrimSpace(db)] = true
+	}
+	return g.check()
+}
+
+// WithLimits returns the context to run the raw queries with, its settings
+// make clickhouse reject any write and stop the queries that read too much
+func WithLimits(ctx context.Context) context.Context {
+	return clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"max_execution_time": constants.RawQueryMaxExecutionTime,
+		"max_rows_to_read":   constants.RawQueryMaxRowsToRead,
+		"readonly":           1,
+	}))
+}
+
+type guard struct {
+	tokens    []token
+	databases map[string]bool
+	ctes      map[string]bool
+}
+
+// cteNames returns the names of the common table expressions of the query,
+// they can be read from without a database
+func cteNames(tokens []token) map[string]bool {
+	ctes := make(map[string]bool)
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].isName() && tokens[i+1].is("AS") && tokens[i+2].isPunct("(") {
+			ctes[tokens[i].text] = true
+		}
+	}
+	return ctes
+}
+
+func (g *guard) check() *model.ApiError {
+	// whether each open parenthesis holds a query or an expression, the
+	// FROM of the expressions like extract(DAY FROM ts) reads no table
+	queryScope := []bool{true}
+	for i, t := range g.tokens {
+		switch {
+		case t.isPunct("("):
+			queryScope = append(queryScope, g.at(i+1).is("SELECT") || g.at(i+1).is("WITH"))
+		case t.isPunct(")"):
+			if len(queryScope) > 1 {
+				queryScope = queryScope[:len(queryScope)-1]
+			}
+		case t.is("INTO") && g.at(i+1).is("OUTFILE"):
+			return forbidden("INTO OUTFILE is not allowed")
+		case t.kind == tokenWord && isDictionaryFunction(t.text) && g.at(i+1).isPunct("("):
+			if err := g.checkDictionary(i + 2); err != nil {
+				return err
+			}
+		case t.is("IN"):
+			if err := g.checkInTable(i + 1); err != nil {
+				return err
+			}
+		case !queryScope[len(queryScope)-1]:
+			continue
+		case t.is("FROM"), t.is("JOIN") && !g.at(i-1).is("ARRAY"):
+			if err := g.checkTables(i + 1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// at returns the token at i, or an empty token out of the range
+func (g *guard) at(i int) token {
+	if i < 0 || i >= len(g.tokens) {
+		return token{kind: tokenPunct}
+	}
+	return g.tokens[i]
+}
+
+// closing returns the index of the parenthesis closing the one at i
+func (g *guard) closing(i int) int {
+	depth := 0
+	for j := i; j < len(g.tokens); j++ {
+		switch {
+		case g.tokens[j].isPunct("("):
+			depth++
+		case g.tokens[j].isPunct(")"):
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(g.tokens) - 1
+}
+
+// checkTables checks the comma separated tables of a FROM or a JOIN
+// starting at i, the tables of the subqueries are checked on their own. The
+// JOINs are checked from their own keyword.
+func (g *guard) checkTables(i int) *model.ApiError {
+	for i < len(g.tokens) {
+		t := g.tokens[i]
+		switch {
+		case t.isPunct("("):
+			i = g.closing(i) + 1
+		case t.isName():
+			next, err := g.checkTable(i)
+			if err != nil {
+				return err
+			}
+			i = next
+		default:
+			return nil
+		}
+
+		// a USING a, b without parentheses is checked as tables, which
+		// rejects b as it has no database
+		for ; i < len(g.tokens) && !g.tokens[i].isPunct(","); i++ {
+			t := g.tokens[i]
+			switch {
+			case t.isPunct("("):
+				i = g.closing(i)
+			case t.isPunct(")"):
+				return nil
+			case t.kind == tokenWord && tableListEnd[strings.ToUpper(t.text)]:
+				return nil
+			}
+		}
+		i++
+	}
+	return nil
+}
+
+// checkTable checks the table or the table function at i and returns the
+// index of the token after it
+func (g *guard) checkTable(i int) (int, *model.ApiError) {
+	name := g.tokens[i]
+	switch {
+	case g.at(i + 1).isPunct("("):
+		if name.kind == tokenWord && allowedTableFunctions[strings.ToLower(name.text)] {
+			return g.closing(i+1) + 1, nil
+		}
+		return 0, forbidden("table function %s is not allowed", name.text)
+	case g.at(i+1).isPunct(".") && g.at(i+2).isName():
+		if !g.databases[name.text] {
+			return 0, forbidden("database %s is not allowed", name.text)
+		}
+		return i + 3, nil
+	case g.ctes[name.text]:
+		return i + 1, nil
+	}
+	return 0, forbidden("table %s must be qualified with its database", name.text)
+}
+
+// checkInTable checks the database of the table the IN at i-1 reads from,
+// the identifiers without a database are aliases of the query
+func (g *guard) checkInTable(i int) *model.ApiError {
+	if g.at(i).isName() && g.at(i+1).isPunct(".") && g.at(i+2).isName() && !g.at(i+3).isPunct("(") {
+		if !g.databases[g.tokens[i].text] {
+			return forbidden("database %s is not allowed", g.tokens[i].text)
+		}
+	}
+	return nil
+}
+
+// checkDictionary checks the database of the dictionary named by the first
+// argument (at i) of a dictionary function, the name must be a string so
+// that it is known before the query runs
+func (g *guard) checkDictionary(i int) *model.ApiError {
+	arg := g.at(i)
+	if arg.kind != tokenString || !(g.at(i+1).isPunct(",") || g.at(i+1).isPunct(")")) {
+		return forbidden("the dictionary of %s must be a string", g.at(i-2).text)
+	}
+	database := defaultDatabase
+	if idx := strings.Index(arg.text, "."); idx >= 0 {
+		database = arg.text[:idx]
+	}
+	if !g.databases[database] {
+		return forbidden("database %s is not allowed", database)
+	}
+	return nil
+}
+
+func isDictionaryFunction(name string) bool {
+	name = strings.ToLower(name)
+	for _, prefix := range dictionaryFunctionPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package queryguard
+
+import (
+	"testing"
+
+	"go.signoz.io/signoz/pkg/query-service/model"
+)
+
+var allowedDatabases = []string{"signoz_metrics", "signoz_traces", "signoz_logs"}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   string
+		errType model.ErrorType
+	}{
+		{
+			name:  "select from an allowed database",
+			query: "SELECT toStartOfInterval(timestamp, INTERVAL 1 MINUTE) AS ts, count() AS value FROM signoz_traces.distributed_signoz_index_v2 GROUP BY ts;",
+		},
+		{
+			name:  "with and subqueries",
+			query: "WITH top AS (SELECT serviceName FROM signoz_traces.distributed_signoz_index_v2 LIMIT 5) SELECT * FROM top JOIN (SELECT serviceName FROM signoz_traces.distributed_top_level_operations) AS ops USING serviceName",
+		},
+		{
+			name:  "from inside expressions is not a table",
+			query: "SELECT extract(DAY FROM timestamp), trim(BOTH ' ' FROM body) FROM signoz_logs.distributed_logs",
+		},
+		{
+			name:  "array join",
+			query: "SELECT tag FROM signoz_traces.distributed_signoz_index_v2 ARRAY JOIN tagMap.keys AS tag",
+		},
+		{
+			name:  "keywords inside strings and comments",
+			query: "SELECT 'DROP TABLE x; from system.tables' AS s /* FROM system.users */ -- ; DELETE\nFROM signoz_logs.distributed_logs",
+		},
+		{
+			name:  "quoted identifiers",
+			query: "SELECT `value` FROM `signoz_metrics`.`distributed_samples_v2`",
+		},
+		{
+			name:  "numbers table function",
+			query: "SELECT number FROM numbers(10)",
+		},
+		{
+			name:  "sample and settings",
+			query: "SELECT count() FROM signoz_traces.distributed_signoz_index_v2 AS t FINAL SAMPLE 1/10 OFFSET 0, signoz_traces.distributed_top_level_operations WHERE t.serviceName = name SETTINGS max_threads = 1, readonly = 1",
+		},
+		{
+			name:  "array join of several arrays",
+			query: "SELECT k, v FROM signoz_traces.distributed_signoz_index_v2 ARRAY JOIN tagMap.keys AS k, tagMap.values AS v LIMIT 10, 20",
+		},
+		{
+			name:  "dictionary of an allowed database",
+			query: "SELECT dictGet('signoz_traces.services', 'team', serviceName) FROM signoz_traces.distributed_signoz_index_v2",
+		},
+		{
+			name:    "empty query",
+			query:   " ; ",
+			errType: model.ErrorBadData,
+		},
+		{
+			name:    "multiple statements",
+			query:   "SELECT 1 FROM signoz_logs.distributed_logs; DROP TABLE signoz_logs.logs",
+			errType: model.ErrorBadData,
+		},
+		{
+			name:    "unterminated string",
+			query:   "SELECT 'abc FROM signoz_logs.distributed_logs",
+			errType: model.ErrorBadData,
+		},
+		{
+			name:    "alter",
+			query:   "ALTER TABLE signoz_logs.logs DELETE WHERE true",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "insert",
+			query:   "INSERT INTO signoz_logs.logs SELECT * FROM signoz_logs.logs",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "database not allowed",
+			query:   "SELECT * FROM system.users",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "database not allowed in a join",
+			query:   "SELECT * FROM signoz_logs.distributed_logs AS l LEFT JOIN system.tables AS t ON l.id = t.name",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "database not allowed in a comma join",
+			query:   "SELECT * FROM signoz_logs.distributed_logs AS l, system.tables",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "database not allowed in a subquery of an expression",
+			query:   "SELECT count() FROM signoz_logs.distributed_logs WHERE has((SELECT groupArray(name) FROM system.databases), 'x')",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "database not allowed in in",
+			query:   "SELECT * FROM signoz_logs.distributed_logs WHERE body GLOBAL IN system.tables",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "table without database",
+			query:   "SELECT * FROM users",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "table function",
+			query:   "SELECT * FROM url('http://169.254.169.254/latest', CSV, 'a String')",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "dictionary of a database not allowed",
+			query:   "SELECT dictGet('system.secrets', 'value', 1) FROM signoz_logs.distributed_logs",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "dictionary of the default database",
+			query:   "SELECT dictGet('secrets', 'value', 1) FROM signoz_logs.distributed_logs",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "dictionary name not a string",
+			query:   "SELECT dictGet(concat('sys', 'tem.secrets'), 'value', 1) FROM signoz_logs.distributed_logs",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "database not allowed after sample",
+			query:   "SELECT * FROM signoz_traces.distributed_signoz_index_v2 SAMPLE 1, system.users",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "database not allowed after sample offset",
+			query:   "SELECT * FROM signoz_traces.distributed_signoz_index_v2 SAMPLE 1/10 OFFSET 0, system.users",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "database not allowed after final sample",
+			query:   "SELECT * FROM signoz_traces.distributed_signoz_index_v2 AS t FINAL SAMPLE 1, system.users",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "database not allowed after alias columns",
+			query:   "SELECT * FROM (SELECT 1) AS s(a), system.users",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "database not allowed after using",
+			query:   "SELECT * FROM signoz_logs.distributed_logs INNER JOIN (SELECT 1) USING a, system.users",
+			errType: model.ErrorForbidden,
+		},
+		{
+			name:    "into outfile",
+			query:   "SELECT * FROM signoz_logs.distributed_logs INTO OUTFILE 'logs.csv'",
+			errType: model.ErrorForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			apiErr := Check(tc.query, allowedDatabases)
+			if tc.errType == "" {
+				if apiErr != nil {
+					t.Fatalf("expected no error, got %v", apiErr.Err)
+				}
+				return
+			}
+			if apiErr == nil {
+				t.Fatalf("expected a %s error, got nil", tc.errType)
+			}
+			if apiErr.Typ != tc.errType {
+				t.Errorf("expected a %s error, got %s: %v", tc.errType, apiErr.Typ, apiErr.Err)
+			}
+		})
+	}
+}
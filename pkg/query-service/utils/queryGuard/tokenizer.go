@@ -0,0 +1,141 @@
+package queryguard
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	// unquoted identifier or keyword
+	tokenWord tokenKind = iota
+	// identifier quoted with backticks or double quotes
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// is reports whether the token is the keyword
+func (t token) is(keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+func (t token) isPunct(p string) bool {
+	return t.kind == tokenPunct && t.text == p
+}
+
+func (t token) isName() bool {
+	return t.kind == tokenWord || t.kind == tokenIdent
+}
+
+func isWordByte(c byte, first bool) bool {
+	return c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && (c >= '0' && c <= '9' || c == '$'))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// tokenize splits the query into tokens, the comments are dropped and the
+// quoted strings and identifiers are unquoted
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case c == '#' || (c == '-' && strings.HasPrefix(query[i:], "--")):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 1
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end, err := skipComment(query, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case c == '\'' || c == '`' || c == '"':
+			text, end, err := readQuoted(query, i)
+			if err != nil {
+				return nil, err
+			}
+			kind := tokenIdent
+			if c == '\'' {
+				kind = tokenString
+			}
+			tokens = append(tokens, token{kind: kind, text: text})
+			i = end
+		case isWordByte(c, true):
+			end := i + 1
+			for end < len(query) && isWordByte(query[end], false) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: query[i:end]})
+			i = end
+		case isDigit(c):
+			end := i + 1
+			for end < len(query) && (isWordByte(query[end], false) || query[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: query[i:end]})
+			i = end
+		default:
+			tokens = append(tokens, token{kind: tokenPunct, text: string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// skipComment returns the index after the block comment starting at i,
+// block comments can be nested in clickhouse
+func skipComment(query string, i int) (int, error) {
+	depth := 0
+	for j := i; j+1 < len(query); j++ {
+		switch {
+		case query[j] == '/' && query[j+1] == '*':
+			depth++
+			j++
+		case query[j] == '*' && query[j+1] == '/':
+			depth--
+			j++
+			if depth == 0 {
+				return j + 1, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated comment")
+}
+
+// readQuoted returns the unescaped text quoted at i and the index after it
+func readQuoted(query string, i int) (string, int, error) {
+	quote := query[i]
+	var sb strings.Builder
+	for j := i + 1; j < len(query); j++ {
+		c := query[j]
+		switch {
+		case c == '\\' && j+1 < len(query):
+			j++
+			sb.WriteByte(query[j])
+		case c == quote && j+1 < len(query) && query[j+1] == quote:
+			j++
+			sb.WriteByte(quote)
+		case c == quote:
+			return sb.String(), j + 1, nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted text")
+}